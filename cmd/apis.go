@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/f-marschall/apim-kura/pkg/backup"
+	"github.com/spf13/cobra"
+)
+
+var apisCmd = &cobra.Command{
+	Use:   "apis",
+	Short: "Work with the APIs of an Azure API Management instance",
+	Long:  `APIs provides commands for the APIs of an Azure API Management instance.`,
+}
+
+var apisExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the definitions of the APIs of an instance",
+	Long: `Export downloads the definition of every API of an Azure API Management
+instance, together with its settings, such as its path, service URL and
+version, so the APIs can be recreated after the instance is lost.
+
+Each API is written to its own directory under
+backup/.config/<resource-group>/<apim-name>/apis/<api-id>/, or under the
+directory or azblob:// URL given with --output: api.json holds the settings and
+openapi.json (see --format) the definition. SOAP APIs are always exported as
+WSDL. GraphQL and WebSocket APIs have no exportable definition and are skipped.
+
+Example:
+  kura apis export -g mygroup -a myapim
+  kura apis export -g mygroup -a myapim --format openapi --api-id petstore
+  kura apis export -g mygroup -a myapim -o azblob://mystorage/dr/apis`,
+	Args: cobra.NoArgs,
+	RunE: runAPIsExport,
+}
+
+var (
+	apisResourceGroup string
+	apisAPIMName      string
+	apisSubscription  string
+	apisOutput        string
+	apisFormat        string
+	apisIDs           []string
+)
+
+func init() {
+	rootCmd.AddCommand(apisCmd)
+	apisCmd.AddCommand(apisExportCmd)
+
+	apisExportCmd.Flags().StringVarP(&apisResourceGroup, "resource-group", "g", "", "Azure resource group name (required)")
+	apisExportCmd.Flags().StringVarP(&apisAPIMName, "apim-name", "a", "", "Azure API Management instance name (required)")
+	apisExportCmd.Flags().StringVarP(&apisSubscription, "subscription", "s", "", "Azure subscription ID")
+	apisExportCmd.Flags().StringVarP(&apisOutput, "output", "o", "", "Output directory or azblob:// URL (if not specified, defaults to backup folder structure)")
+	apisExportCmd.Flags().StringVar(&apisFormat, "format", azure.APIFormatOpenAPIJSON, "Definition format: openapi+json, openapi (YAML) or swagger")
+	apisExportCmd.Flags().StringSliceVar(&apisIDs, "api-id", nil, "Only export the API with this ID (repeatable)")
+
+	apisExportCmd.MarkFlagRequired("resource-group")
+	apisExportCmd.MarkFlagRequired("apim-name")
+}
+
+func runAPIsExport(cmd *cobra.Command, args []string) error {
+	switch apisFormat {
+	case azure.APIFormatOpenAPIJSON, azure.APIFormatOpenAPI, azure.APIFormatSwagger:
+	default:
+		return fmt.Errorf("invalid --format %q: must be openapi+json, openapi or swagger", apisFormat)
+	}
+	dir := apisOutput
+	if dir == "" {
+		dir = backup.APIExportDir(apisResourceGroup, apisAPIMName)
+	}
+
+	fmt.Printf("Exporting APIs from APIM instance: %s\n", apisAPIMName)
+	fmt.Printf("Resource Group: %s\n", apisResourceGroup)
+	fmt.Printf("Output directory: %s\n", dir)
+
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	fmt.Println("\nAuthenticating with Azure CLI...")
+	client, err := azure.NewClient(ctx, apisSubscription, apisResourceGroup, apisAPIMName)
+	if err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+
+	fmt.Println("\nFetching APIs...")
+	apis, err := client.ListAPIDetails(ctx)
+	if err != nil {
+		return err
+	}
+	if len(apisIDs) > 0 {
+		apis, err = selectAPIs(apis, apisIDs)
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Found %d API(s)\n\n", len(apis))
+
+	var exported, skipped, failed int
+	for _, api := range apis {
+		format := apisFormat
+		switch api.Type {
+		case "soap":
+			format = azure.APIFormatWSDL
+		case "graphql", "websocket":
+			fmt.Printf("  [SKIP] %s (%s APIs cannot be exported)\n", api.ID, api.Type)
+			skipped++
+			continue
+		}
+
+		var path string
+		definition, err := client.ExportAPI(ctx, api.ID, format)
+		if err == nil {
+			path, err = backup.SaveAPIExport(dir, api, format, definition)
+		}
+		if err != nil {
+			fmt.Printf("  [FAIL] %s: %v\n", api.ID, err)
+			failed++
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		fmt.Printf("  [OK]   %s -> %s\n", api.ID, path)
+		exported++
+	}
+
+	fmt.Printf("\nExport complete: %d exported, %d skipped, %d failed\n", exported, skipped, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d API(s) failed to export", failed)
+	}
+	return nil
+}
+
+// selectAPIs returns the APIs with the given IDs, which are case-insensitive
+// in APIM. It fails for an ID without API.
+func selectAPIs(apis []azure.APIDetails, ids []string) ([]azure.APIDetails, error) {
+	byID := make(map[string]azure.APIDetails, len(apis))
+	for _, api := range apis {
+		byID[strings.ToLower(api.ID)] = api
+	}
+	selected := make([]azure.APIDetails, 0, len(ids))
+	for _, id := range ids {
+		api, ok := byID[strings.ToLower(id)]
+		if !ok {
+			return nil, fmt.Errorf("API %q not found in %s", id, apisAPIMName)
+		}
+		selected = append(selected, api)
+	}
+	return selected, nil
+}
@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/f-marschall/apim-kura/pkg/backup"
+	"github.com/spf13/cobra"
+)
+
+var applyCmd = &cobra.Command{
+	Use:   "apply [plan.json]",
+	Short: "Make an APIM instance match a desired-state file or a saved plan",
+	Long: `Apply treats a backup file as the desired state of the subscriptions of an
+Azure API Management instance, so they can be managed declaratively from a
+repository: subscriptions in the file that do not exist are created and
+subscriptions whose attributes or keys drifted are updated. With --prune,
+subscriptions that are not in the file are deleted.
+
+Subscriptions are paired by sid; entries without a sid get one derived from
+their content, as with restore. Scopes are rebuilt for the instance and owners
+point to the user with the same ID, unless --owner-map maps them to another
+user. The built-in master subscription is never changed.
+
+Apply first prints the plan of creates, updates and deletes. With --dry-run it
+stops there. Otherwise, if the plan updates or deletes subscriptions, kura asks
+for confirmation (skip with --yes) and, before pruning, saves the subscriptions
+it deletes to a backup under backup/.pre-delete (skip with --no-backup).
+
+Instead of a desired-state file, apply accepts a plan saved by the plan command.
+It then executes exactly the reviewed actions, after checking that the instance
+has not changed since the plan was created.
+
+Example:
+  kura apply -g mygroup -a myapim -f desired.json --dry-run
+  kura apply -g mygroup -a myapim -f desired.json --prune --yes
+  kura apply plan.json`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runApply,
+}
+
+var (
+	applyResourceGroup string
+	applyAPIMName      string
+	applySubscription  string
+	applyFile          string
+	applyProductID     string
+	applyOwnerMap      string
+	applyPrune         bool
+	applyDryRun        bool
+	applyNoBackup      bool
+	applyYes           bool
+)
+
+func init() {
+	rootCmd.AddCommand(applyCmd)
+
+	applyCmd.Flags().StringVarP(&applyResourceGroup, "resource-group", "g", "", "Azure resource group name (required without a plan file)")
+	applyCmd.Flags().StringVarP(&applyAPIMName, "apim-name", "a", "", "Azure API Management instance name (required without a plan file)")
+	applyCmd.Flags().StringVarP(&applySubscription, "subscription", "s", "", "Azure subscription ID")
+	applyCmd.Flags().StringVarP(&applyFile, "file", "f", "", "Backup file holding the desired subscriptions (required without a plan file)")
+	applyCmd.Flags().StringVarP(&applyProductID, "product-id", "p", "", "Only manage subscriptions scoped to this product")
+	applyCmd.Flags().StringVar(&applyOwnerMap, "owner-map", "", "YAML file mapping user IDs in the file to user IDs of the instance (or \"drop\")")
+	applyCmd.Flags().BoolVar(&applyPrune, "prune", false, "Delete subscriptions that are not in the file")
+	applyCmd.Flags().BoolVar(&applyDryRun, "dry-run", false, "Only print the plan without applying it")
+	applyCmd.Flags().BoolVar(&applyNoBackup, "no-backup", false, "Do not back up pruned subscriptions before deleting them")
+	registerYesFlags(applyCmd, &applyYes)
+}
+
+func runApply(cmd *cobra.Command, args []string) error {
+	if len(args) == 1 {
+		for _, name := range []string{"resource-group", "apim-name", "subscription", "file", "product-id", "owner-map", "prune"} {
+			if cmd.Flags().Changed(name) {
+				return fmt.Errorf("--%s cannot be combined with a plan file; the plan already holds it", name)
+			}
+		}
+		return runApplySavedPlan(args[0])
+	}
+	for _, name := range []string{"resource-group", "apim-name", "file"} {
+		if !cmd.Flags().Changed(name) {
+			return fmt.Errorf("--%s is required unless a plan file is given", name)
+		}
+	}
+
+	fmt.Printf("Applying desired state to APIM instance: %s\n", applyAPIMName)
+	fmt.Printf("Resource Group: %s\n", applyResourceGroup)
+	if applySubscription != "" {
+		fmt.Printf("Subscription ID: %s\n", applySubscription)
+	}
+
+	if applyDryRun {
+		fmt.Println("\nRunning in DRY-RUN mode. No changes will be applied.")
+	}
+
+	if !applyDryRun {
+		unlock, err := lockInstance(applyResourceGroup, applyAPIMName, "apply")
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	client, plan, live, err := planDesiredState(ctx, desiredStateOptions{
+		subscription:  applySubscription,
+		resourceGroup: applyResourceGroup,
+		apimName:      applyAPIMName,
+		file:          applyFile,
+		productID:     applyProductID,
+		ownerMap:      applyOwnerMap,
+		prune:         applyPrune,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	plan.print()
+	if len(plan.Actions) == 0 {
+		fmt.Println("Instance matches the desired state. Nothing to do.")
+		return nil
+	}
+	if applyDryRun {
+		return nil
+	}
+
+	return applyPlan(ctx, client, plan, live, applyResourceGroup, applyAPIMName, applyYes, applyNoBackup)
+}
+
+// desiredStateOptions selects the instance and the desired-state file a plan
+// is computed for.
+type desiredStateOptions struct {
+	subscription  string
+	resourceGroup string
+	apimName      string
+	file          string
+	productID     string
+	ownerMap      string
+	prune         bool
+}
+
+// planDesiredState loads the desired-state file, fetches the live
+// subscriptions of the instance and computes the plan that makes them match.
+// It returns the authenticated client and the live subscriptions with it.
+func planDesiredState(ctx context.Context, opts desiredStateOptions) (*azure.Client, *reconcilePlan, []azure.SubscriptionInfo, error) {
+	fmt.Printf("Desired state: %s\n", opts.file)
+	if opts.productID != "" {
+		fmt.Printf("Product ID: %s\n", opts.productID)
+	}
+	if opts.prune {
+		fmt.Println("Mode: Prune (delete subscriptions missing in the file)")
+	}
+
+	var ownerMap backup.OwnerMap
+	if opts.ownerMap != "" {
+		var err error
+		ownerMap, err = backup.LoadOwnerMap(opts.ownerMap)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		fmt.Printf("Owner map: %s (%d entries)\n", opts.ownerMap, len(ownerMap))
+	}
+
+	desired, err := loadRestoreFile(opts.file)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to load desired state: %w", err)
+	}
+	// Hand-written desired-state files may omit the sid.
+	if generated := backup.GenerateMissingSIDs(desired); len(generated) > 0 {
+		fmt.Printf("Generated sids for %d entry(ies) without a name\n", len(generated))
+	}
+	if opts.productID != "" {
+		desired = filterByProduct(desired, opts.productID)
+	}
+
+	fmt.Println("\nAuthenticating with Azure CLI...")
+	client, err := azure.NewClient(ctx, opts.subscription, opts.resourceGroup, opts.apimName)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("authentication failed: %w", err)
+	}
+	fmt.Println("Successfully authenticated with Azure CLI")
+
+	fmt.Println("\nFetching subscriptions...")
+	live, err := client.ListSubscriptions(ctx, opts.productID)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+
+	desired = retarget(desired, ownerMap, client.SubscriptionID(), opts.resourceGroup, opts.apimName)
+	return client, buildPlan(desired, live, opts.prune), live, nil
+}
+
+// filterByProduct returns the subscriptions of subs that are scoped to
+// productID. Product names are compared case-insensitively, as APIM does.
+func filterByProduct(subs []azure.SubscriptionInfo, productID string) []azure.SubscriptionInfo {
+	var filtered []azure.SubscriptionInfo
+	for _, sub := range subs {
+		if strings.EqualFold(extractScopeSuffix(sub.Properties.Scope), "products/"+productID) {
+			filtered = append(filtered, sub)
+		}
+	}
+	return filtered
+}
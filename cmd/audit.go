@@ -0,0 +1,269 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/f-marschall/apim-kura/internal/audit"
+	"github.com/spf13/cobra"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Query the journal of changes kura made to an instance",
+	Long: `Every change kura makes to a subscription or to other configuration of an
+instance, such as a named value or a gateway key, is appended to an audit
+journal: when, by whom (the identity of the Azure credential), from which
+command, on which instance and entity, and a hash of the entity before and
+after the change. Keys are never written to the journal. The journal is a JSON
+Lines file at backup/.audit/journal.jsonl unless --audit-file or
+KURA_AUDIT_FILE selects another one; --no-audit disables it for a run.
+
+Audit, like audit list, prints the entries of the journal, oldest first,
+filtered by the flags. Audit show prints a single entry in full.
+
+Example:
+  kura audit list
+  kura audit list -g mygroup -a myapim --since 7d
+  kura audit list --sid 5f1c2a7e --output json
+  kura audit list --kind named-values --since 2026-10-01
+  kura audit list --operation delete --actor alice@contoso.com
+  kura audit show 3f9a0c1b2d4e`,
+	Args: cobra.NoArgs,
+	RunE: runAudit,
+}
+
+var auditListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the changes in the audit journal",
+	Long: `List prints the entries of the audit journal, oldest first, filtered by the
+flags. The first column is the ID of the entry for audit show.
+
+Example:
+  kura audit list -g mygroup -a myapim --since 7d
+  kura audit list --kind gateways --operation regenerate-keys`,
+	Args: cobra.NoArgs,
+	RunE: runAudit,
+}
+
+var auditShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show a single change of the audit journal",
+	Long: `Show prints every field of the audit journal entry with the given ID, or
+with an ID starting with the given prefix, as printed by audit list.
+
+Example:
+  kura audit show 3f9a0c1b2d4e
+  kura audit show 3f9a --output json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runAuditShow,
+}
+
+var (
+	auditResourceGroup string
+	auditAPIMName      string
+	auditSID           string
+	auditKind          string
+	auditTarget        string
+	auditOperation     string
+	auditActor         string
+	auditCommand       string
+	auditSince         string
+	auditLimit         int
+	auditOutput        string
+)
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+	auditCmd.AddCommand(auditListCmd, auditShowCmd)
+
+	registerAuditFilterFlags(auditCmd)
+	registerAuditFilterFlags(auditListCmd)
+	auditShowCmd.Flags().StringVar(&auditOutput, "output", "text", "Output format: text or json")
+}
+
+// registerAuditFilterFlags adds the flags filtering the journal to cmd. audit
+// and audit list share them.
+func registerAuditFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&auditResourceGroup, "resource-group", "g", "", "Only show changes in this resource group")
+	cmd.Flags().StringVarP(&auditAPIMName, "apim-name", "a", "", "Only show changes to this APIM instance")
+	cmd.Flags().StringVar(&auditSID, "sid", "", "Only show changes to this subscription")
+	cmd.Flags().StringVar(&auditKind, "kind", "", "Only show changes to this kind of entity, such as named-values, or subscriptions")
+	cmd.Flags().StringVar(&auditTarget, "target", "", "Only show changes to the entity with this ID, for other kinds than subscriptions")
+	cmd.Flags().StringVar(&auditOperation, "operation", "", "Only show this operation (create, update, set-state, set-expiration, clear-expiration, delete, regenerate-keys)")
+	cmd.Flags().StringVar(&auditActor, "actor", "", "Only show changes made by this account")
+	cmd.Flags().StringVar(&auditCommand, "command", "", "Only show changes made by this kura command, such as restore")
+	cmd.Flags().StringVar(&auditSince, "since", "", "Only show changes since a duration ago (e.g. 7d, 12h) or a date or timestamp")
+	cmd.Flags().IntVar(&auditLimit, "limit", 0, "Only show the newest N matching changes")
+	cmd.Flags().StringVar(&auditOutput, "output", "text", "Output format: text or json")
+}
+
+// validateAuditOutput checks --output of the audit commands.
+func validateAuditOutput() error {
+	switch auditOutput {
+	case "text", "json":
+		return nil
+	}
+	return fmt.Errorf("invalid --output %q: must be text or json", auditOutput)
+}
+
+func runAudit(cmd *cobra.Command, args []string) error {
+	if err := validateAuditOutput(); err != nil {
+		return err
+	}
+	var since time.Time
+	if auditSince != "" {
+		var err error
+		since, err = parseSince(auditSince)
+		if err != nil {
+			return err
+		}
+	}
+
+	entries, err := audit.Read(auditFile)
+	if err != nil {
+		return err
+	}
+
+	matches := []audit.Entry{}
+	for _, e := range entries {
+		switch {
+		case auditResourceGroup != "" && !strings.EqualFold(e.ResourceGroup, auditResourceGroup):
+		case auditAPIMName != "" && !strings.EqualFold(e.APIMName, auditAPIMName):
+		case auditSID != "" && e.SID != auditSID:
+		case auditKind != "" && entryKind(e) != auditKind:
+		case auditTarget != "" && !strings.EqualFold(e.Target, auditTarget):
+		case auditOperation != "" && e.Operation != auditOperation:
+		case auditActor != "" && !strings.EqualFold(e.Actor, auditActor):
+		case auditCommand != "" && e.Command != auditCommand:
+		case !since.IsZero() && e.Time.Before(since):
+		default:
+			matches = append(matches, e)
+		}
+	}
+	if auditLimit > 0 && len(matches) > auditLimit {
+		matches = matches[len(matches)-auditLimit:]
+	}
+
+	if auditOutput == "json" {
+		return printJSON(matches)
+	}
+	if len(matches) == 0 {
+		fmt.Printf("No changes found in %s\n", auditFile)
+		return nil
+	}
+	for _, e := range matches {
+		fmt.Printf("%s  %s  %-16s %-20s %s/%s  %s  %s -> %s\n",
+			e.ID, e.Time.Format(time.RFC3339), e.Operation, e.Actor, e.ResourceGroup, e.APIMName,
+			entryTarget(e), shortHash(e.BeforeHash), shortHash(e.AfterHash))
+		if e.Error != "" {
+			fmt.Printf("    [FAIL] %s\n", e.Error)
+		}
+	}
+	fmt.Printf("\n%d change(s)\n", len(matches))
+	return nil
+}
+
+func runAuditShow(cmd *cobra.Command, args []string) error {
+	if err := validateAuditOutput(); err != nil {
+		return err
+	}
+	entries, err := audit.Read(auditFile)
+	if err != nil {
+		return err
+	}
+
+	var found []audit.Entry
+	for _, e := range entries {
+		if strings.HasPrefix(e.ID, strings.ToLower(args[0])) {
+			found = append(found, e)
+		}
+	}
+	switch {
+	case len(found) == 0:
+		return fmt.Errorf("no change with ID %s in %s", args[0], auditFile)
+	case len(found) > 1:
+		return fmt.Errorf("ID %s is ambiguous: %d changes in %s start with it", args[0], len(found), auditFile)
+	}
+	e := found[0]
+
+	if auditOutput == "json" {
+		return printJSON(e)
+	}
+	fmt.Printf("ID:              %s\n", e.ID)
+	fmt.Printf("Time:            %s\n", e.Time.Format(time.RFC3339))
+	fmt.Printf("Actor:           %s\n", e.Actor)
+	fmt.Printf("Host:            %s\n", e.Host)
+	fmt.Printf("Command:         %s\n", e.Command)
+	fmt.Printf("Operation:       %s\n", e.Operation)
+	fmt.Printf("Subscription ID: %s\n", e.SubscriptionID)
+	fmt.Printf("Resource Group:  %s\n", e.ResourceGroup)
+	fmt.Printf("APIM Instance:   %s\n", e.APIMName)
+	fmt.Printf("Kind:            %s\n", entryKind(e))
+	if e.Kind == "" {
+		fmt.Printf("SID:             %s\n", e.SID)
+		fmt.Printf("Display Name:    %s\n", e.DisplayName)
+	} else {
+		fmt.Printf("Target:          %s\n", e.Target)
+	}
+	fmt.Printf("Before:          %s\n", fullHash(e.BeforeHash))
+	fmt.Printf("After:           %s\n", fullHash(e.AfterHash))
+	if e.Error != "" {
+		fmt.Printf("Error:           %s\n", e.Error)
+	}
+	return nil
+}
+
+// entryKind returns the kind of entity e changed, "subscriptions" for the
+// entries of subscriptions.
+func entryKind(e audit.Entry) string {
+	if e.Kind == "" {
+		return "subscriptions"
+	}
+	return e.Kind
+}
+
+// entryTarget describes the entity e changed for text output.
+func entryTarget(e audit.Entry) string {
+	if e.Kind == "" {
+		return fmt.Sprintf("%s (sid=%s)", e.DisplayName, e.SID)
+	}
+	return e.Kind + "/" + e.Target
+}
+
+// parseSince parses --since as a duration before now, such as 7d, or as a
+// date or RFC 3339 timestamp.
+func parseSince(value string) (time.Time, error) {
+	if d, err := parseDays("since", value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q: expected a duration (7d, 12h), a date (2006-01-02) or a timestamp", value)
+}
+
+// shortHash shortens a hash of the journal for text output. A missing
+// subscription is shown as "-".
+func shortHash(hash string) string {
+	if hash == "" {
+		return "-"
+	}
+	hash = strings.TrimPrefix(hash, "sha256:")
+	if len(hash) > 12 {
+		hash = hash[:12]
+	}
+	return hash
+}
+
+// fullHash returns a hash of the journal for audit show, "-" for a missing
+// entity.
+func fullHash(hash string) string {
+	if hash == "" {
+		return "-"
+	}
+	return hash
+}
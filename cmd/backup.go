@@ -2,13 +2,12 @@ package cmd
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"os"
 	"path/filepath"
+	"time"
 
-	"github.com/f-marschall/apim-kura/internal/azure"
-	"github.com/f-marschall/apim-kura/internal/backup"
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/f-marschall/apim-kura/pkg/backup"
 	"github.com/spf13/cobra"
 )
 
@@ -18,13 +17,25 @@ var backupCmd = &cobra.Command{
 	Long: `Backup retrieves subscription keys from an Azure API Management instance
 and saves them to a local backup directory or file.
 
-By default, backups are stored under: backup/<resource-group>/<apim-name>[/<product-id>]
+By default, backups are stored under: backup/<resource-group>/<apim-name>[/<product-id>],
+or backup/<resource-group>/<apim-name>/apis/<api-id> with --api-id.
 Use --output to save to a custom file path instead.
 
+If the newest backup file in the target folder (or the --output file) already
+contains the same subscriptions, nothing is written. Use --force to always write.
+
+Use --name-template to control the file name inside the backup folder. The
+template is a Go text/template with the fields .ResourceGroup, .APIM, .Product,
+.API, .Date (2006-01-02), .Time (150405) and .Timestamp (20060102T150405Z).
+
 Example:
   kura backup --resource-group mygroup --apim-name myapim
   kura backup --resource-group mygroup --apim-name myapim --product-id myproduct
-  kura backup -g mygroup -a myapim --output ./my-backup.json`,
+  kura backup --resource-group mygroup --apim-name myapim --api-id petstore
+  kura backup -g mygroup -a myapim --output ./my-backup.json
+  kura backup -g mygroup -a myapim --name-template "{{.APIM}}-{{.Date}}.json"
+  kura backup -g mygroup -a myapim --resolve-owners
+  kura backup -g mygroup -a myapim --anonymize --output ./shareable.json`,
 	RunE: runBackup,
 }
 
@@ -33,7 +44,15 @@ var (
 	backupAPIMName      string
 	backupSubscription  string
 	backupProductID     string
+	backupAPIID         string
 	backupOutput        string
+	backupNameTemplate  string
+	backupAnonymize     bool
+	backupResolveOwners bool
+	backupForce         bool
+	backupConcurrency   int
+	backupNotify        notifyFlags
+	backupHooks         hookFlags
 )
 
 func init() {
@@ -44,14 +63,31 @@ func init() {
 	backupCmd.Flags().StringVarP(&backupAPIMName, "apim-name", "a", "", "Azure API Management instance name (required)")
 	backupCmd.Flags().StringVarP(&backupSubscription, "subscription", "s", "", "Azure subscription ID")
 	backupCmd.Flags().StringVarP(&backupProductID, "product-id", "p", "", "Azure APIM product ID (optional, scopes backup to a product)")
-	backupCmd.Flags().StringVarP(&backupOutput, "output", "o", "", "Output file path (if not specified, defaults to backup folder structure)")
+	backupCmd.Flags().StringVar(&backupAPIID, "api-id", "", "Azure APIM API ID (optional, scopes backup to subscriptions of a single API)")
+	backupCmd.Flags().StringVarP(&backupOutput, "output", "o", "", "Output file path or azblob:// URL (if not specified, defaults to backup folder structure)")
+	backupCmd.Flags().StringVar(&backupNameTemplate, "name-template", "", "File name template for the backup file, e.g. \"{{.APIM}}-{{.Date}}.json\"")
+	backupCmd.Flags().BoolVar(&backupResolveOwners, "resolve-owners", false, "Look up each owner and add their name and email to the backup")
+	backupCmd.Flags().BoolVar(&backupForce, "force", false, "Write the backup even if nothing changed since the last snapshot")
+	backupCmd.Flags().BoolVar(&backupAnonymize, "anonymize", false, "Replace keys and owner IDs with salted hashes and strip PII so the backup can be shared")
+	backupCmd.Flags().IntVar(&backupConcurrency, "concurrency", 1, "Number of subscriptions to fetch the keys of in parallel")
+	registerNotifyFlags(backupCmd, &backupNotify)
+	registerHookFlags(backupCmd, &backupHooks)
 
 	// Mark required flags
 	backupCmd.MarkFlagRequired("resource-group")
 	backupCmd.MarkFlagRequired("apim-name")
+	backupCmd.MarkFlagsMutuallyExclusive("output", "name-template")
+	backupCmd.MarkFlagsMutuallyExclusive("product-id", "api-id")
 }
 
-func runBackup(cmd *cobra.Command, args []string) error {
+func runBackup(cmd *cobra.Command, args []string) (err error) {
+	summary := newRunSummary("backup", backupResourceGroup, backupAPIMName, backupSubscription)
+	defer func() { err = summary.send(backupNotify, err) }()
+	if err := backupHooks.runPre(summary); err != nil {
+		return err
+	}
+	defer func() { err = backupHooks.runPost(summary, err) }()
+
 	fmt.Printf("Backing up subscription keys from APIM instance: %s\n", backupAPIMName)
 	fmt.Printf("Resource Group: %s\n", backupResourceGroup)
 
@@ -61,6 +97,9 @@ func runBackup(cmd *cobra.Command, args []string) error {
 	if backupProductID != "" {
 		fmt.Printf("Product ID: %s\n", backupProductID)
 	}
+	if backupAPIID != "" {
+		fmt.Printf("API ID: %s\n", backupAPIID)
+	}
 
 	// Determine output file path
 	var filePath string
@@ -68,47 +107,104 @@ func runBackup(cmd *cobra.Command, args []string) error {
 		filePath = backupOutput
 		fmt.Printf("Output file: %s\n", filePath)
 	} else {
+		fileName := backup.DefaultFileName
+		if backupNameTemplate != "" {
+			data := backup.NewNameData(backupResourceGroup, backupAPIMName, backupProductID, time.Now())
+			data.API = backupAPIID
+			name, err := backup.RenderFileName(backupNameTemplate, data)
+			if err != nil {
+				return err
+			}
+			fileName = name
+		}
+
 		// Create backup directory structure
-		backupDir, err := backup.EnsureBackupDir(backupResourceGroup, backupAPIMName, backupProductID)
+		var backupDir string
+		if backupAPIID != "" {
+			backupDir, err = backup.EnsureAPIBackupDir(backupResourceGroup, backupAPIMName, backupAPIID)
+		} else {
+			backupDir, err = backup.EnsureBackupDir(backupResourceGroup, backupAPIMName, backupProductID)
+		}
 		if err != nil {
 			return fmt.Errorf("failed to create backup directory: %w", err)
 		}
-		filePath = filepath.Join(backupDir, "subscriptions.json")
+		filePath = filepath.Join(backupDir, fileName)
 		fmt.Printf("Backup directory: %s\n", backupDir)
 	}
 
 	// Authenticate with Azure CLI
-	ctx := context.Background()
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
 	fmt.Println("\nAuthenticating with Azure CLI...")
 
 	client, err := azure.NewClient(ctx, backupSubscription, backupResourceGroup, backupAPIMName)
 	if err != nil {
 		return fmt.Errorf("authentication failed: %w", err)
 	}
+	client.SetConcurrency(backupConcurrency)
 	fmt.Println("\nFetching subscriptions...")
-	subs, err := client.ListSubscriptions(ctx, backupProductID)
+	stopProgress := showListProgress(client)
+	subs, err := client.ListSubscriptionsMatching(ctx, azure.SubscriptionFilter{ProductID: backupProductID, APIID: backupAPIID})
+	stopProgress()
+	if err != nil {
+		return fmt.Errorf("failed to list subscriptions: %w", err)
+	}
 
 	fmt.Printf("\nFound %d subscription(s)\n", len(subs))
+	summary.Counts["subscriptions"] = len(subs)
 
-	prettyJSON, err := json.MarshalIndent(subs, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal subscriptions to JSON: %w", err)
+	if backupResolveOwners {
+		fmt.Println("Resolving subscription owners...")
+		if err := client.ResolveOwners(ctx, subs); err != nil {
+			return fmt.Errorf("failed to resolve owners: %w", err)
+		}
 	}
 
-	// Ensure parent directories exist if using custom output path
-	if backupOutput != "" {
-		dir := filepath.Dir(filePath)
-		if dir != "." && dir != "" {
-			if err := os.MkdirAll(dir, 0755); err != nil {
-				return fmt.Errorf("failed to create output directory: %w", err)
+	var salt string
+	if backupAnonymize {
+		salt, err = backup.NewSalt()
+		if err != nil {
+			return err
+		}
+		subs = backup.Anonymize(subs, salt)
+		fmt.Println("Anonymized sids, keys, owner IDs, instance names and PII (backup cannot be restored)")
+	}
+
+	// Skip writing if the last snapshot already holds the same subscriptions.
+	// Anonymized subscriptions are hashed with a new salt and never match.
+	if !backupForce && !backupAnonymize {
+		previous := filePath
+		if backupOutput == "" {
+			previous, err = backup.LatestSnapshot(filepath.Dir(filePath))
+			if err != nil {
+				return err
 			}
 		}
+		if backup.Unchanged(previous, subs) {
+			fmt.Printf("No changes since last snapshot %s, skipping write\n", previous)
+			return nil
+		}
 	}
 
-	if err := os.WriteFile(filePath, prettyJSON, 0644); err != nil {
-		return fmt.Errorf("failed to write backup file: %w", err)
+	file := &backup.File{
+		Metadata: backup.Metadata{
+			SubscriptionID: client.SubscriptionID(),
+			ResourceGroup:  backupResourceGroup,
+			APIMName:       backupAPIMName,
+			ProductID:      backupProductID,
+			APIID:          backupAPIID,
+		},
+		Subscriptions: subs,
+	}
+	if backupAnonymize {
+		file.Metadata = backup.AnonymizeMetadata(file.Metadata, salt)
+	}
+	if err := backup.Save(filePath, file); err != nil {
+		return err
 	}
 	fmt.Printf("Backup saved to: %s\n", filePath)
+	summary.Counts["written"] = 1
+	summary.addFile("backup", filePath)
 
 	fmt.Println("Backup completed successfully")
 	return nil
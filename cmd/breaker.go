@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"github.com/f-marschall/apim-kura/internal/pool"
+	"github.com/spf13/cobra"
+)
+
+// breakerFlags holds the values of the circuit breaker flags of a bulk
+// command.
+type breakerFlags struct {
+	maxFailures    int
+	maxConsecutive int
+}
+
+// registerBreakerFlags adds --max-failures and --max-consecutive-failures to
+// cmd, which stop it early once too many of its subscriptions failed.
+func registerBreakerFlags(cmd *cobra.Command, b *breakerFlags) {
+	cmd.Flags().IntVar(&b.maxFailures, "max-failures", 0,
+		"Stop after this many subscriptions failed (0 for no limit)")
+	cmd.Flags().IntVar(&b.maxConsecutive, "max-consecutive-failures", 10,
+		"Stop after this many subscriptions failed in a row, e.g. for missing permissions (0 for no limit)")
+}
+
+// breaker returns the breaker configured by the flags, or nil if both limits
+// are disabled.
+func (b *breakerFlags) breaker() *pool.Breaker {
+	if b.maxFailures <= 0 && b.maxConsecutive <= 0 {
+		return nil
+	}
+	return &pool.Breaker{MaxFailures: b.maxFailures, MaxConsecutive: b.maxConsecutive}
+}
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/spf13/cobra"
+)
+
+var certificatesExpiringCmd = &cobra.Command{
+	Use:   "expiring",
+	Short: "Report certificates that expired or expire soon",
+	Long: `Expiring reports the certificates of an Azure API Management instance that
+have expired or expire within the given window: certificate entities, CA and
+root certificates and the certificates of custom host names, sorted by
+expiration date.
+
+The command exits with 0 if no certificate expires within the window, with 1
+if some do and with 2 if the report could not be created, like the expiring
+command for subscriptions.
+
+Example:
+  kura certificates expiring -g mygroup -a myapim
+  kura certificates expiring -g mygroup -a myapim --within 90d --output json`,
+	Args: cobra.NoArgs,
+	RunE: runCertificatesExpiring,
+}
+
+var (
+	certificatesResourceGroup string
+	certificatesAPIMName      string
+	certificatesSubscription  string
+	certificatesWithin        string
+	certificatesOutput        string
+)
+
+func init() {
+	certificatesCmd := newConfigCommand(configKind[azure.CertificateInfo]{
+		use:    "certificates",
+		noun:   "certificate",
+		plural: "certificates",
+		short:  "Back up the certificate inventory of an Azure API Management instance",
+		long: `Certificates backs up the certificate inventory of an Azure API Management
+instance and reports expiring certificates.
+
+The inventory lists the certificate entities, the CA and root certificates and
+the certificates of custom host names with their subject, thumbprint,
+expiration date and Key Vault reference. It holds no private keys, so it
+cannot be restored as is: when rebuilding an instance, it tells which
+certificates to reference from Key Vault again and which to upload.`,
+		list: func(ctx context.Context, client *azure.Client) ([]azure.CertificateInfo, error) {
+			return client.ListCertificates(ctx)
+		},
+		name: func(cert azure.CertificateInfo) string {
+			return cert.Kind + "/" + cert.ID
+		},
+	})
+	rootCmd.AddCommand(certificatesCmd)
+	certificatesCmd.AddCommand(certificatesExpiringCmd)
+
+	certificatesExpiringCmd.Flags().StringVarP(&certificatesResourceGroup, "resource-group", "g", "", "Azure resource group name (required)")
+	certificatesExpiringCmd.Flags().StringVarP(&certificatesAPIMName, "apim-name", "a", "", "Azure API Management instance name (required)")
+	certificatesExpiringCmd.Flags().StringVarP(&certificatesSubscription, "subscription", "s", "", "Azure subscription ID")
+	certificatesExpiringCmd.Flags().StringVar(&certificatesWithin, "within", "30d", "Report certificates expiring within this window (e.g. 30d or 72h)")
+	certificatesExpiringCmd.Flags().StringVar(&certificatesOutput, "output", "text", "Output format: text or json")
+
+	certificatesExpiringCmd.MarkFlagRequired("resource-group")
+	certificatesExpiringCmd.MarkFlagRequired("apim-name")
+}
+
+// errCertificatesExpiring is wrapped by the error certificates expiring
+// returns when certificates expire within the window. It is reported with
+// exit code 1 instead of 2.
+var errCertificatesExpiring = errors.New("certificates expiring")
+
+// expiringCertificate is a certificate that expired or expires within the
+// window.
+type expiringCertificate struct {
+	azure.CertificateInfo
+	// DaysLeft is negative for expired certificates.
+	DaysLeft int `json:"daysLeft"`
+}
+
+func runCertificatesExpiring(cmd *cobra.Command, args []string) error {
+	switch certificatesOutput {
+	case "text", "json":
+	default:
+		return fmt.Errorf("invalid --output %q: must be text or json", certificatesOutput)
+	}
+	within, err := parseDays("within", certificatesWithin)
+	if err != nil {
+		return err
+	}
+	text := certificatesOutput == "text"
+
+	if text {
+		fmt.Printf("Checking certificates in APIM instance: %s\n", certificatesAPIMName)
+		fmt.Printf("Resource Group: %s\n", certificatesResourceGroup)
+		fmt.Printf("Window: %s\n", certificatesWithin)
+	}
+
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	client, err := azure.NewClient(ctx, certificatesSubscription, certificatesResourceGroup, certificatesAPIMName)
+	if err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+
+	certs, err := client.ListCertificates(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	var expiring []expiringCertificate
+	for _, cert := range certs {
+		if cert.ExpirationDate == nil || cert.ExpirationDate.After(now.Add(within)) {
+			continue
+		}
+		expiring = append(expiring, expiringCertificate{
+			CertificateInfo: cert,
+			DaysLeft:        int(math.Floor(cert.ExpirationDate.Sub(now).Hours() / 24)),
+		})
+	}
+	sort.SliceStable(expiring, func(i, j int) bool {
+		return expiring[i].ExpirationDate.Before(*expiring[j].ExpirationDate)
+	})
+
+	if text {
+		if err := printExpiringCertificates(expiring, len(certs)); err != nil {
+			return err
+		}
+	} else if err := printJSON(expiring); err != nil {
+		return err
+	}
+
+	if len(expiring) == 0 {
+		return nil
+	}
+	cmd.SilenceUsage = true
+	return fmt.Errorf("%w: %d certificate(s) expired or expire within %s", errCertificatesExpiring, len(expiring), certificatesWithin)
+}
+
+// printExpiringCertificates prints the expiring certificates as a table.
+func printExpiringCertificates(expiring []expiringCertificate, total int) error {
+	if len(expiring) == 0 {
+		fmt.Printf("\nNone of the %d certificate(s) expires within the window.\n", total)
+		return nil
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tID\tSUBJECT\tTHUMBPRINT\tEXPIRES\tDAYS LEFT\tKEY VAULT")
+	for _, cert := range expiring {
+		keyVault := "no"
+		if cert.KeyVault != nil {
+			keyVault = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n", cert.Kind, cert.ID, cert.Subject, cert.Thumbprint,
+			cert.ExpirationDate.UTC().Format("2006-01-02"), cert.DaysLeft, keyVault)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\n%d of %d certificate(s) expired or expire within the window\n", len(expiring), total)
+	return nil
+}
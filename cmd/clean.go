@@ -3,37 +3,104 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/f-marschall/apim-kura/internal/audit"
+	"github.com/f-marschall/apim-kura/pkg/backup"
 	"github.com/spf13/cobra"
 )
 
 var cleanCmd = &cobra.Command{
 	Use:   "clean",
-	Short: "Delete the backup folder and all its contents",
-	Long: `Clean removes the local backup directory and all subfolders created by
-the backup command.
+	Short: "Delete the backups in the backup folder",
+	Long: `Clean removes the backups in the local backup directory: the folders of the
+resource groups written by the backup command and any other file or folder
+without a leading dot.
+
+The directories with a leading dot hold the state of other commands and are
+kept unless --all is given: the rollback files of restore in .rollback, the
+backups taken before deletes in .pre-delete, restore checkpoints in
+.checkpoints, configuration backups in .config and migration and promotion
+reports. They are what you need to undo a bad restore or delete. The audit
+journal in .audit and the instance locks in .locks, which may be held by a
+running restore, are kept even with --all.
 
 Example:
-  kura clean`,
+  kura clean
+  kura clean --all --yes`,
+	Args: cobra.NoArgs,
 	RunE: runClean,
 }
 
+var (
+	cleanAll bool
+	cleanYes bool
+)
+
 func init() {
 	rootCmd.AddCommand(cleanCmd)
+
+	cleanCmd.Flags().BoolVar(&cleanAll, "all", false, "Also remove the hidden folders with rollback files, pre-delete backups, checkpoints and configuration backups")
+	registerYesFlags(cleanCmd, &cleanYes)
+}
+
+// cleanKeeps reports whether clean --all keeps the entry at path: the
+// directory of the audit journal, which is only ever appended to, and the
+// directory of the instance locks, whose removal would let a second run
+// change an instance locked by a running one.
+func cleanKeeps(path string) bool {
+	path = filepath.Clean(path)
+	return path == filepath.Dir(filepath.Clean(auditFile)) || path == filepath.Dir(audit.DefaultPath) ||
+		path == filepath.Clean(backup.LockDir())
 }
 
 func runClean(cmd *cobra.Command, args []string) error {
-	dir := "backup"
+	dir := backup.RootDir
 
-	if _, err := os.Stat(dir); os.IsNotExist(err) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
 		fmt.Println("No backup folder found. Nothing to clean.")
 		return nil
 	}
+	if err != nil {
+		return fmt.Errorf("failed to read backup folder: %w", err)
+	}
+
+	if cleanAll && !cleanYes {
+		ok, err := confirm(fmt.Sprintf("Remove all backups in %s, including the rollback files and pre-delete backups needed to undo restores and deletes?", dir))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println("Aborted. Nothing was removed.")
+			return nil
+		}
+	}
 
-	if err := os.RemoveAll(dir); err != nil {
-		return fmt.Errorf("failed to remove backup folder: %w", err)
+	var removed, kept int
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		if strings.HasPrefix(e.Name(), ".") && (!cleanAll || cleanKeeps(path)) {
+			kept++
+			continue
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return fmt.Errorf("failed to remove backup folder: %w", err)
+		}
+		removed++
 	}
 
-	fmt.Println("Backup folder removed successfully.")
+	if removed == 0 {
+		fmt.Println("No backups found. Nothing to clean.")
+	} else {
+		fmt.Println("Backups removed successfully.")
+	}
+	switch {
+	case kept > 0 && cleanAll:
+		fmt.Printf("Kept the audit journal and the instance locks in %s.\n", dir)
+	case kept > 0:
+		fmt.Printf("Kept %d hidden folder(s) in %s with rollback files, pre-delete backups and the audit journal; use --all to remove them as well.\n", kept, dir)
+	}
 	return nil
 }
@@ -1,26 +1,41 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
-	"github.com/f-marschall/apim-kura/internal/azure"
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/f-marschall/apim-kura/pkg/backup"
 	"github.com/spf13/cobra"
 )
 
 var compareCmd = &cobra.Command{
 	Use:   "compare <file_a> <file_b>",
-	Short: "Compare subscription keys in two backup files",
+	Short: "Compare subscription keys in two backup files or APIM instances",
 	Long: `Compare loads two backup JSON files and checks if all subscription keys
 from the first file exist in the second file with the same attributes.
 
+Instead of files, either side can be a live APIM instance: use --source-apim
+and --source-resource-group for side A and --target-apim and
+--target-resource-group for side B. This validates a migration between two
+instances in one step.
+
 Master subscriptions are excluded from the comparison. All subscription
-attributes must match for a key to be considered equivalent.
+attributes must match for a key to be considered equivalent. Scopes and owners
+are compared relative to their APIM instance, so subscriptions from different
+instances can match.
 
 Example:
   kura compare before.json after.json
-  kura compare -a file1.json -b file2.json`,
+  kura compare -a file1.json -b file2.json
+  kura compare --source-resource-group rg1 --source-apim apim1 --target-resource-group rg2 --target-apim apim2
+  kura compare before.json --target-resource-group rg2 --target-apim apim2`,
 	Args: cobra.MaximumNArgs(2),
 	RunE: runCompare,
 }
@@ -28,6 +43,23 @@ Example:
 var (
 	compareFileA string
 	compareFileB string
+
+	compareSourceAPIM         string
+	compareSourceRG           string
+	compareSourceSubscription string
+	compareTargetAPIM         string
+	compareTargetRG           string
+	compareTargetSubscription string
+
+	compareBidirectional bool
+	compareMatchBy       string
+	compareIgnoreFields  []string
+	compareOutput        string
+	compareDiff          bool
+	compareDateTolerance time.Duration
+	compareBase          string
+	compareStream        bool
+	compareReport        string
 )
 
 func init() {
@@ -35,98 +67,559 @@ func init() {
 
 	compareCmd.Flags().StringVarP(&compareFileA, "a", "a", "", "First backup file path")
 	compareCmd.Flags().StringVarP(&compareFileB, "b", "b", "", "Second backup file path")
+
+	compareCmd.Flags().StringVar(&compareSourceAPIM, "source-apim", "", "Use this live APIM instance as side A")
+	compareCmd.Flags().StringVar(&compareSourceRG, "source-resource-group", "", "Resource group of --source-apim")
+	compareCmd.Flags().StringVar(&compareSourceSubscription, "source-subscription", "", "Azure subscription ID of --source-apim")
+	compareCmd.Flags().StringVar(&compareTargetAPIM, "target-apim", "", "Use this live APIM instance as side B")
+	compareCmd.Flags().StringVar(&compareTargetRG, "target-resource-group", "", "Resource group of --target-apim")
+	compareCmd.Flags().StringVar(&compareTargetSubscription, "target-subscription", "", "Azure subscription ID of --target-apim")
+
+	compareCmd.Flags().BoolVar(&compareBidirectional, "bidirectional", false, "Also report subscriptions in B that are not in A")
+
+	compareCmd.Flags().StringVar(&compareMatchBy, "match-by", "keys", "How to pair subscriptions of A and B: keys or name (sid)")
+
+	compareCmd.Flags().StringSliceVar(&compareIgnoreFields, "ignore-fields", nil, "Comma-separated attributes to leave out of the comparison (e.g. createdDate,stateComment)")
+
+	compareCmd.Flags().StringVar(&compareOutput, "output", "text", "Output format: text or json")
+
+	compareCmd.Flags().BoolVar(&compareDiff, "diff", false, "Render differences as a unified field-level diff")
+
+	compareCmd.Flags().DurationVar(&compareDateTolerance, "date-tolerance", 0, "Treat dates at most this far apart as equal (e.g. 24h)")
+
+	compareCmd.Flags().StringVar(&compareBase, "base", "", "Common base backup file for a three-way comparison of A and B")
+
+	compareCmd.Flags().BoolVar(&compareStream, "stream", false, "Decode file A one subscription at a time instead of loading it; file B is still loaded")
+
+	compareCmd.Flags().StringVar(&compareReport, "report", "", "Also write the comparison result as an HTML report to this file")
+
+	compareCmd.MarkFlagsMutuallyExclusive("diff", "output")
+	compareCmd.MarkFlagsMutuallyExclusive("stream", "output")
+	compareCmd.MarkFlagsMutuallyExclusive("stream", "base")
+	compareCmd.MarkFlagsMutuallyExclusive("report", "stream")
+	compareCmd.MarkFlagsMutuallyExclusive("report", "base")
+	compareCmd.MarkFlagsMutuallyExclusive("base", "bidirectional")
+	compareCmd.MarkFlagsMutuallyExclusive("base", "diff")
+	compareCmd.MarkFlagsRequiredTogether("source-apim", "source-resource-group")
+	compareCmd.MarkFlagsRequiredTogether("target-apim", "target-resource-group")
+}
+
+// compareSide is one side of a comparison: a backup file or a live instance.
+type compareSide struct {
+	file          string
+	subscription  string
+	resourceGroup string
+	apimName      string
+}
+
+func (s compareSide) String() string {
+	if s.file != "" {
+		return s.file
+	}
+	return fmt.Sprintf("APIM instance %s (resource group %s)", s.apimName, s.resourceGroup)
+}
+
+// load returns the subscriptions of the side, fetching them from Azure for live instances.
+func (s compareSide) load(ctx context.Context) ([]azure.SubscriptionInfo, error) {
+	if s.file != "" {
+		return loadBackupFile(s.file)
+	}
+	client, err := azure.NewClient(ctx, s.subscription, s.resourceGroup, s.apimName)
+	if err != nil {
+		return nil, fmt.Errorf("authentication failed: %w", err)
+	}
+	return client.ListSubscriptions(ctx, "")
+}
+
+// resolveCompareSides determines sides A and B from positional arguments,
+// the -a/-b flags and the live instance flags, in that order.
+func resolveCompareSides(args []string) (compareSide, compareSide, error) {
+	var sides []compareSide
+	if compareSourceAPIM != "" {
+		sides = append(sides, compareSide{subscription: compareSourceSubscription, resourceGroup: compareSourceRG, apimName: compareSourceAPIM})
+	}
+
+	files := args
+	if len(files) == 0 {
+		for _, f := range []string{compareFileA, compareFileB} {
+			if f != "" {
+				files = append(files, f)
+			}
+		}
+	}
+	for _, f := range files {
+		sides = append(sides, compareSide{file: f})
+	}
+
+	if compareTargetAPIM != "" {
+		sides = append(sides, compareSide{subscription: compareTargetSubscription, resourceGroup: compareTargetRG, apimName: compareTargetAPIM})
+	}
+
+	if len(sides) != 2 {
+		return compareSide{}, compareSide{}, fmt.Errorf("must provide exactly two sides to compare (two files, -a and -b, or --source-apim/--target-apim), got %d", len(sides))
+	}
+	return sides[0], sides[1], nil
 }
 
 func runCompare(cmd *cobra.Command, args []string) error {
-	var fileA, fileB string
-
-	// Use positional arguments if provided
-	if len(args) == 2 {
-		fileA = args[0]
-		fileB = args[1]
-	} else if len(args) == 1 {
-		return fmt.Errorf("expected 2 files, got 1")
+	switch compareMatchBy {
+	case "keys", "name":
+	default:
+		return fmt.Errorf("invalid --match-by %q: must be keys or name", compareMatchBy)
+	}
+	switch compareOutput {
+	case "text", "json":
+	default:
+		return fmt.Errorf("invalid --output %q: must be text or json", compareOutput)
+	}
+	if compareDateTolerance < 0 {
+		return fmt.Errorf("invalid --date-tolerance %s: must not be negative", compareDateTolerance)
+	}
+	ignoreFields, err := parseIgnoreFields(compareIgnoreFields)
+	if err != nil {
+		return err
+	}
+
+	sideA, sideB, err := resolveCompareSides(args)
+	if err != nil {
+		return err
+	}
+	if compareStream && (sideA.file == "" || sideB.file == "") {
+		return fmt.Errorf("--stream requires two backup files")
+	}
+
+	opts := compareOptions{
+		bidirectional: compareBidirectional,
+		matchByName:   compareMatchBy == "name",
+		ignoreFields:  ignoreFields,
+		dateTolerance: compareDateTolerance,
+	}
+
+	jsonOutput := compareOutput == "json"
+	if !jsonOutput {
+		fmt.Printf("Comparing subscriptions:\n")
+		if compareBase != "" {
+			fmt.Printf("  Base: %s\n", compareBase)
+		}
+		fmt.Printf("  A: %s\n", sideA)
+		fmt.Printf("  B: %s\n", sideB)
+	}
+
+	if compareStream {
+		return runCompareStream(cmd, sideA.file, sideB.file, opts)
+	}
+
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+
+	var subsBase []azure.SubscriptionInfo
+	if compareBase != "" {
+		subsBase, err = loadBackupFile(compareBase)
+		if err != nil {
+			return fmt.Errorf("failed to load base: %w", err)
+		}
+	}
+
+	// Load side A
+	subsA, err := sideA.load(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load A: %w", err)
+	}
+
+	// Load side B
+	subsB, err := sideB.load(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load B: %w", err)
+	}
+
+	if compareBase != "" {
+		result := compareThreeWay(subsBase, subsA, subsB, opts)
+		if jsonOutput {
+			result.Base = compareBase
+			result.A = sideA.String()
+			result.B = sideB.String()
+			if err := printJSON(result); err != nil {
+				return err
+			}
+		} else {
+			result.printText()
+		}
+		cmd.SilenceUsage = true
+		return result.Err()
+	}
+
+	result := compareSubscriptions(subsA, subsB, opts)
+	result.A = sideA.String()
+	result.B = sideB.String()
+	if compareReport != "" {
+		if err := writeCompareReport(compareReport, result); err != nil {
+			return err
+		}
+	}
+	if jsonOutput {
+		if err := printJSON(result); err != nil {
+			return err
+		}
 	} else {
-		// Fall back to flags
-		if compareFileA == "" || compareFileB == "" {
-			return fmt.Errorf("must provide either two positional arguments or both -a and -b flags")
+		result.printText(compareDiff)
+		if compareReport != "" {
+			fmt.Printf("Report written to: %s\n", compareReport)
 		}
-		fileA = compareFileA
-		fileB = compareFileB
 	}
 
-	fmt.Printf("Comparing backup files:\n")
-	fmt.Printf("  File A: %s\n", fileA)
-	fmt.Printf("  File B: %s\n", fileB)
+	// Differences are a result, not a usage error.
+	cmd.SilenceUsage = true
+	return result.Err()
+}
 
-	// Load file A
-	subsA, err := loadBackupFile(fileA)
+// runCompareStream compares two plain backup files without loading file A into
+// memory: file B is loaded and indexed, then file A is decoded one subscription
+// at a time and each result is printed as soon as it is known. Memory use
+// therefore grows with the size of B; matching needs all of B at hand, as the
+// counterpart of a subscription in A may be anywhere in B.
+func runCompareStream(cmd *cobra.Command, fileA, fileB string, opts compareOptions) error {
+	var subsB []azure.SubscriptionInfo
+	err := backup.Stream(fileB, func(sub *azure.SubscriptionInfo) error {
+		if sub.Name != "master" {
+			subsB = append(subsB, *sub)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to load file A: %w", err)
+		return fmt.Errorf("failed to load B: %w", err)
 	}
 
-	// Load file B
-	subsB, err := loadBackupFile(fileB)
+	c := newComparer(subsB, opts)
+	fmt.Printf("\nB: %d subscription(s) (master excluded)\n", c.summary.B)
+
+	err = backup.Stream(fileA, func(sub *azure.SubscriptionInfo) error {
+		if item, ok := c.compare(sub); ok {
+			printCompareItem(&item, opts.matchByName, compareDiff)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to load file B: %w", err)
+		return fmt.Errorf("failed to load A: %w", err)
+	}
+	extras := c.extras()
+	for i := range extras {
+		printCompareItem(&extras[i], opts.matchByName, compareDiff)
 	}
 
-	// Filter out master subscriptions
-	subsA = filterOutMaster(subsA)
-	subsB = filterOutMaster(subsB)
+	printCompareSummary(&c.summary, opts.bidirectional)
 
-	fmt.Printf("\nFile A: %d subscription(s) (master excluded)\n", len(subsA))
-	fmt.Printf("File B: %d subscription(s) (master excluded)\n", len(subsB))
-
-	// Compare: check if each key in A exists in B with same attributes
-	var matched, missing, mismatch int
-	for _, subA := range subsA {
-		found := false
-		for _, subB := range subsB {
-			if subA.Properties.PrimaryKey == subB.Properties.PrimaryKey &&
-				subA.Properties.SecondaryKey == subB.Properties.SecondaryKey {
-				// Found matching keys, check all attributes
-				if attributesEqual(&subA, &subB) {
-					fmt.Printf("  [OK]   %s\n", subA.Properties.DisplayName)
-					matched++
-				} else {
-					fmt.Printf("  [DIFF] %s (keys match, attributes differ)\n", subA.Properties.DisplayName)
-					printAttributeDifferences(&subA, &subB)
-					mismatch++
-				}
-				found = true
-				break
-			}
+	result := compareResult{Summary: c.summary}
+	cmd.SilenceUsage = true
+	return result.Err()
+}
+
+// printJSON prints v as indented JSON.
+func printJSON(v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON output: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// compareOptions controls how compareSubscriptions pairs and reports subscriptions.
+type compareOptions struct {
+	bidirectional bool
+	// matchByName pairs subscriptions by sid instead of by key values, so
+	// rotated keys show up as attribute differences rather than as missing.
+	matchByName bool
+	// ignoreFields holds the names of attributes that are not compared.
+	ignoreFields map[string]bool
+	// dateTolerance is the largest difference at which two dates are still
+	// considered equal.
+	dateTolerance time.Duration
+}
+
+// Statuses of a subscription in a compareResult.
+const (
+	compareStatusOK      = "ok"
+	compareStatusDiff    = "diff"
+	compareStatusMissing = "missing"
+	compareStatusExtra   = "extra"
+)
+
+// compareResult is the outcome of comparing two sets of subscriptions.
+type compareResult struct {
+	A             string        `json:"a,omitempty"`
+	B             string        `json:"b,omitempty"`
+	MatchBy       string        `json:"matchBy"`
+	Bidirectional bool          `json:"bidirectional"`
+	Summary       compareCounts `json:"summary"`
+	Subscriptions []compareItem `json:"subscriptions"`
+}
+
+// compareCounts summarizes a compareResult. A and B are the number of
+// subscriptions on each side, master excluded.
+type compareCounts struct {
+	A          int `json:"a"`
+	B          int `json:"b"`
+	Matched    int `json:"matched"`
+	Mismatched int `json:"mismatched"`
+	Missing    int `json:"missing"`
+	Extra      int `json:"extra"`
+}
+
+// compareItem is the comparison result of a single subscription. Subscriptions
+// only in B have status extra; all others come from A.
+type compareItem struct {
+	Status      string              `json:"status"`
+	SID         string              `json:"sid"`
+	DisplayName string              `json:"displayName"`
+	PrimaryKey  string              `json:"primaryKey,omitempty"`
+	Differences []compareDifference `json:"differences,omitempty"`
+}
+
+// compareDifference is an attribute with different values in A (before) and B (after).
+type compareDifference struct {
+	Field  string `json:"field"`
+	Before any    `json:"before"`
+	After  any    `json:"after"`
+}
+
+// compareSubscriptions checks that every non-master subscription in subsA exists
+// in subsB with the same keys and attributes. With opts.bidirectional,
+// subscriptions in subsB without a counterpart in subsA are reported as extra.
+func compareSubscriptions(subsA, subsB []azure.SubscriptionInfo, opts compareOptions) *compareResult {
+	c := newComparer(subsB, opts)
+	result := &compareResult{
+		MatchBy:       c.matchBy(),
+		Bidirectional: opts.bidirectional,
+		Subscriptions: []compareItem{},
+	}
+	for i := range subsA {
+		if item, ok := c.compare(&subsA[i]); ok {
+			result.Subscriptions = append(result.Subscriptions, item)
 		}
+	}
+	result.Subscriptions = append(result.Subscriptions, c.extras()...)
+	result.Summary = c.summary
+	return result
+}
+
+// comparer compares subscriptions of A one at a time against an indexed set
+// of subscriptions of B, so A never has to be held in memory as a whole.
+type comparer struct {
+	opts    compareOptions
+	subsB   []azure.SubscriptionInfo
+	indexB  *counterpartIndex
+	pairedB []bool
+	summary compareCounts
+}
+
+// newComparer indexes the non-master subscriptions of subsB.
+func newComparer(subsB []azure.SubscriptionInfo, opts compareOptions) *comparer {
+	subsB = filterOutMaster(subsB)
+	return &comparer{
+		opts:    opts,
+		subsB:   subsB,
+		indexB:  newCounterpartIndex(subsB, opts.matchByName),
+		pairedB: make([]bool, len(subsB)),
+		summary: compareCounts{B: len(subsB)},
+	}
+}
+
+func (c *comparer) matchBy() string {
+	if c.opts.matchByName {
+		return "name"
+	}
+	return "keys"
+}
+
+// compare checks that subA exists in B with the same attributes. It returns
+// false for master subscriptions, which are not compared.
+func (c *comparer) compare(subA *azure.SubscriptionInfo) (compareItem, bool) {
+	if subA.Name == "master" {
+		return compareItem{}, false
+	}
+	c.summary.A++
 
-		if !found {
-			fmt.Printf("  [MISS] %s (primaryKey=%s)\n", subA.Properties.DisplayName, subA.Properties.PrimaryKey)
-			missing++
+	item := compareItem{SID: subA.Name, DisplayName: subA.Properties.DisplayName}
+	i := c.indexB.find(subA)
+	if i < 0 {
+		item.Status = compareStatusMissing
+		item.PrimaryKey = subA.Properties.PrimaryKey
+		c.summary.Missing++
+		return item, true
+	}
+	c.pairedB[i] = true
+
+	subB := &c.subsB[i]
+	if attributesEqual(subA, subB, &c.opts) {
+		item.Status = compareStatusOK
+		c.summary.Matched++
+	} else {
+		item.Status = compareStatusDiff
+		item.Differences = attributeDifferences(subA, subB, &c.opts)
+		c.summary.Mismatched++
+	}
+	return item, true
+}
+
+// extras returns the subscriptions of B that no subscription of A paired
+// with. It returns nothing unless the comparison is bidirectional.
+func (c *comparer) extras() []compareItem {
+	if !c.opts.bidirectional {
+		return nil
+	}
+	var items []compareItem
+	for i := range c.subsB {
+		if c.pairedB[i] {
+			continue
 		}
+		subB := &c.subsB[i]
+		items = append(items, compareItem{
+			Status:      compareStatusExtra,
+			SID:         subB.Name,
+			DisplayName: subB.Properties.DisplayName,
+			PrimaryKey:  subB.Properties.PrimaryKey,
+		})
+		c.summary.Extra++
 	}
+	return items
+}
+
+// errCompareDifferences is wrapped by the error compare returns when the
+// comparison ran but found differences.
+var errCompareDifferences = errors.New("differences found")
 
-	fmt.Printf("\nComparison complete: %d matched, %d mismatched, %d missing (out of %d total)\n", matched, mismatch, missing, len(subsA))
-	if missing > 0 || mismatch > 0 {
-		return fmt.Errorf("%d key(s) missing or attributes differ", missing+mismatch)
+// Err returns an error wrapping errCompareDifferences if any subscription is
+// missing, extra or differs.
+func (r *compareResult) Err() error {
+	if n := r.Summary.Missing + r.Summary.Mismatched + r.Summary.Extra; n > 0 {
+		return fmt.Errorf("%w: %d key(s) missing, extra or attributes differ", errCompareDifferences, n)
 	}
 	return nil
 }
 
+// printText prints one line per subscription followed by a summary. With
+// unified, differences are rendered as a unified field-level diff.
+func (r *compareResult) printText(unified bool) {
+	fmt.Printf("\nA: %d subscription(s) (master excluded)\n", r.Summary.A)
+	fmt.Printf("B: %d subscription(s) (master excluded)\n", r.Summary.B)
+	for i := range r.Subscriptions {
+		printCompareItem(&r.Subscriptions[i], r.MatchBy == "name", unified)
+	}
+	printCompareSummary(&r.Summary, r.Bidirectional)
+}
+
+// printCompareItem prints the comparison result of a single subscription.
+func printCompareItem(item *compareItem, byName, unified bool) {
+	matchedBy := "keys match"
+	ref := fmt.Sprintf("primaryKey=%s", item.PrimaryKey)
+	if byName {
+		matchedBy = "sid matches"
+		ref = fmt.Sprintf("sid=%s", item.SID)
+	}
+
+	switch item.Status {
+	case compareStatusOK:
+		fmt.Printf("  [OK]   %s\n", item.DisplayName)
+	case compareStatusDiff:
+		fmt.Printf("  [DIFF] %s (%s, attributes differ)\n", item.DisplayName, matchedBy)
+		if unified {
+			printUnifiedDiff(item)
+			return
+		}
+		for _, d := range item.Differences {
+			fmt.Printf("      %s: %#v != %#v\n", d.Field, d.Before, d.After)
+		}
+	case compareStatusMissing:
+		fmt.Printf("  [MISS] %s (%s)\n", item.DisplayName, ref)
+	case compareStatusExtra:
+		fmt.Printf("  [EXTRA] %s (%s, only in B)\n", item.DisplayName, ref)
+	}
+}
+
+func printCompareSummary(summary *compareCounts, bidirectional bool) {
+	fmt.Printf("\nComparison complete: %d matched, %d mismatched, %d missing (out of %d total)\n", summary.Matched, summary.Mismatched, summary.Missing, summary.A)
+	if bidirectional {
+		fmt.Printf("Extra in B: %d\n", summary.Extra)
+	}
+}
+
+// printUnifiedDiff renders the differences of item as a unified diff with one
+// -/+ line pair per field. The lines are not indented, so the block can be
+// pasted into a diff code block as is.
+func printUnifiedDiff(item *compareItem) {
+	fmt.Printf("--- a/%s\n", item.SID)
+	fmt.Printf("+++ b/%s\n", item.SID)
+	fmt.Printf("@@ %s @@\n", item.DisplayName)
+	for _, d := range item.Differences {
+		fmt.Printf("-%s: %#v\n", d.Field, d.Before)
+		fmt.Printf("+%s: %#v\n", d.Field, d.After)
+	}
+}
+
+// matchKey returns the key under which sub is paired with its counterpart:
+// its sid, or its primary and secondary key.
+func matchKey(sub *azure.SubscriptionInfo, byName bool) string {
+	if byName {
+		return sub.Name
+	}
+	return sub.Properties.PrimaryKey + "\x00" + sub.Properties.SecondaryKey
+}
+
+// counterpartIndex finds subscriptions by match key in constant time, so
+// pairing two large sets of subscriptions is linear rather than quadratic.
+type counterpartIndex struct {
+	byName bool
+	index  map[string]int
+}
+
+// newCounterpartIndex indexes subs by match key. If several subscriptions
+// share a key, the first one wins.
+func newCounterpartIndex(subs []azure.SubscriptionInfo, byName bool) *counterpartIndex {
+	idx := &counterpartIndex{byName: byName, index: make(map[string]int, len(subs))}
+	for i := range subs {
+		key := matchKey(&subs[i], byName)
+		if _, ok := idx.index[key]; !ok {
+			idx.index[key] = i
+		}
+	}
+	return idx
+}
+
+// find returns the index of the subscription that pairs with sub, or -1 if
+// there is none.
+func (idx *counterpartIndex) find(sub *azure.SubscriptionInfo) int {
+	if i, ok := idx.index[matchKey(sub, idx.byName)]; ok {
+		return i
+	}
+	return -1
+}
+
+// loadBackupFile reads a backup file, decrypting it if it is age or SOPS
+// encrypted. It warns if the file can be read by other users.
 func loadBackupFile(filePath string) ([]azure.SubscriptionInfo, error) {
-	data, err := os.ReadFile(filePath)
+	warnPermissions(filePath)
+	return backup.Open(filePath, &backup.DecryptOptions{AgeIdentity: ageIdentity})
+}
+
+// loadRestoreFile reads a backup file like loadBackupFile for commands that
+// write its subscriptions to an instance. Anonymized backups are rejected, as
+// their sids and keys are hashes.
+func loadRestoreFile(filePath string) ([]azure.SubscriptionInfo, error) {
+	warnPermissions(filePath)
+	f, err := backup.OpenFile(filePath, &backup.DecryptOptions{AgeIdentity: ageIdentity})
 	if err != nil {
 		return nil, err
 	}
-
-	var subs []azure.SubscriptionInfo
-	if err := json.Unmarshal(data, &subs); err != nil {
-		return nil, err
+	if f.Metadata.Anonymized {
+		return nil, errors.New("the file is an anonymized backup, which cannot be restored")
 	}
-
-	return subs, nil
+	return f.Subscriptions, nil
 }
 
 func filterOutMaster(subs []azure.SubscriptionInfo) []azure.SubscriptionInfo {
+	if !slices.ContainsFunc(subs, func(sub azure.SubscriptionInfo) bool { return sub.Name == "master" }) {
+		return subs // nothing to remove, so large lists are not copied
+	}
 	var filtered []azure.SubscriptionInfo
 	for _, sub := range subs {
 		if sub.Name != "master" {
@@ -136,59 +629,132 @@ func filterOutMaster(subs []azure.SubscriptionInfo) []azure.SubscriptionInfo {
 	return filtered
 }
 
-func attributesEqual(subA, subB *azure.SubscriptionInfo) bool {
-	propsA := &subA.Properties
-	propsB := &subB.Properties
+// normalizeOwnerID strips the instance-specific prefix from an ownerId so that
+// owners of subscriptions in different APIM instances can be compared.
+func normalizeOwnerID(ownerID string) string {
+	if userID := azure.OwnerUserID(ownerID); userID != "" {
+		return "users/" + userID
+	}
+	return ownerID
+}
 
-	return propsA.DisplayName == propsB.DisplayName &&
-		propsA.Scope == propsB.Scope &&
-		propsA.State == propsB.State &&
-		propsA.OwnerID == propsB.OwnerID &&
-		propsA.PrimaryKey == propsB.PrimaryKey &&
-		propsA.SecondaryKey == propsB.SecondaryKey &&
-		propsA.AllowTracing == propsB.AllowTracing &&
-		propsA.StartDate == propsB.StartDate &&
-		propsA.EndDate == propsB.EndDate &&
-		propsA.ExpirationDate == propsB.ExpirationDate &&
-		propsA.NotificationDate == propsB.NotificationDate &&
-		propsA.StateComment == propsB.StateComment
+// compareField is a subscription attribute checked by compare.
+type compareField struct {
+	name  string
+	value func(p *azure.SubscriptionInfoProperties) any
+	// normalize maps a value to the form it is compared in. If nil, values
+	// are compared as they are.
+	normalize func(v any) any
+	// reportOnly fields are listed for a mismatched pair but never cause a
+	// mismatch on their own.
+	reportOnly bool
+	// date fields hold timestamps and are compared with the date tolerance.
+	date bool
 }
 
-func printAttributeDifferences(subA, subB *azure.SubscriptionInfo) {
-	propsA := &subA.Properties
-	propsB := &subB.Properties
+// compareFields lists the attributes compare checks, in the order they are reported.
+var compareFields = []compareField{
+	{name: "displayName", value: func(p *azure.SubscriptionInfoProperties) any { return p.DisplayName }},
+	{name: "scope", value: func(p *azure.SubscriptionInfoProperties) any { return p.Scope },
+		normalize: func(v any) any { return extractScopeSuffix(v.(string)) }},
+	{name: "state", value: func(p *azure.SubscriptionInfoProperties) any { return p.State }},
+	{name: "ownerId", value: func(p *azure.SubscriptionInfoProperties) any { return p.OwnerID },
+		normalize: func(v any) any { return normalizeOwnerID(v.(string)) }},
+	{name: "primaryKey", value: func(p *azure.SubscriptionInfoProperties) any { return p.PrimaryKey }},
+	{name: "secondaryKey", value: func(p *azure.SubscriptionInfoProperties) any { return p.SecondaryKey }},
+	{name: "allowTracing", value: func(p *azure.SubscriptionInfoProperties) any { return p.AllowTracing }},
+	{name: "createdDate", value: func(p *azure.SubscriptionInfoProperties) any { return p.CreatedDate }, date: true, reportOnly: true},
+	{name: "startDate", value: func(p *azure.SubscriptionInfoProperties) any { return p.StartDate }, date: true},
+	{name: "endDate", value: func(p *azure.SubscriptionInfoProperties) any { return p.EndDate }, date: true},
+	{name: "expirationDate", value: func(p *azure.SubscriptionInfoProperties) any { return p.ExpirationDate }, date: true},
+	{name: "notificationDate", value: func(p *azure.SubscriptionInfoProperties) any { return p.NotificationDate }, date: true},
+	{name: "stateComment", value: func(p *azure.SubscriptionInfoProperties) any { return p.StateComment }},
+}
 
-	if propsA.DisplayName != propsB.DisplayName {
-		fmt.Printf("      displayName: %q != %q\n", propsA.DisplayName, propsB.DisplayName)
-	}
-	if propsA.Scope != propsB.Scope {
-		fmt.Printf("      scope: %q != %q\n", propsA.Scope, propsB.Scope)
-	}
-	if propsA.State != propsB.State {
-		fmt.Printf("      state: %q != %q\n", propsA.State, propsB.State)
+// parseIgnoreFields validates the field names given to --ignore-fields.
+func parseIgnoreFields(names []string) (map[string]bool, error) {
+	ignore := make(map[string]bool, len(names))
+	for _, name := range names {
+		known := false
+		for _, f := range compareFields {
+			if f.name == name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			valid := make([]string, len(compareFields))
+			for i, f := range compareFields {
+				valid[i] = f.name
+			}
+			return nil, fmt.Errorf("unknown field %q in --ignore-fields: must be one of %s", name, strings.Join(valid, ", "))
+		}
+		ignore[name] = true
 	}
-	if propsA.OwnerID != propsB.OwnerID {
-		fmt.Printf("      ownerId: %q != %q\n", propsA.OwnerID, propsB.OwnerID)
+	return ignore, nil
+}
+
+// differs reports whether the field has a different value in propsA and propsB.
+// Dates less than tolerance apart are considered equal.
+func (f *compareField) differs(propsA, propsB *azure.SubscriptionInfoProperties, tolerance time.Duration) bool {
+	a, b := f.value(propsA), f.value(propsB)
+	if f.normalize != nil {
+		a, b = f.normalize(a), f.normalize(b)
 	}
-	if propsA.AllowTracing != propsB.AllowTracing {
-		fmt.Printf("      allowTracing: %v != %v\n", propsA.AllowTracing, propsB.AllowTracing)
+	if a == b {
+		return false
 	}
-	if propsA.CreatedDate != propsB.CreatedDate {
-		fmt.Printf("      createdDate: %q != %q\n", propsA.CreatedDate, propsB.CreatedDate)
+	if f.date && tolerance > 0 {
+		return !datesWithin(a.(string), b.(string), tolerance)
 	}
-	if propsA.StartDate != propsB.StartDate {
-		fmt.Printf("      startDate: %q != %q\n", propsA.StartDate, propsB.StartDate)
+	return true
+}
+
+// datesWithin reports whether the RFC 3339 timestamps a and b are at most
+// tolerance apart. Values that are not timestamps never match.
+func datesWithin(a, b string, tolerance time.Duration) bool {
+	ta, errA := time.Parse(time.RFC3339, a)
+	tb, errB := time.Parse(time.RFC3339, b)
+	if errA != nil || errB != nil {
+		return false
 	}
-	if propsA.EndDate != propsB.EndDate {
-		fmt.Printf("      endDate: %q != %q\n", propsA.EndDate, propsB.EndDate)
+	d := ta.Sub(tb)
+	if d < 0 {
+		d = -d
 	}
-	if propsA.ExpirationDate != propsB.ExpirationDate {
-		fmt.Printf("      expirationDate: %q != %q\n", propsA.ExpirationDate, propsB.ExpirationDate)
+	return d <= tolerance
+}
+
+func attributesEqual(subA, subB *azure.SubscriptionInfo, opts *compareOptions) bool {
+	for i := range compareFields {
+		f := &compareFields[i]
+		if f.reportOnly || opts.ignoreFields[f.name] {
+			continue
+		}
+		if f.differs(&subA.Properties, &subB.Properties, opts.dateTolerance) {
+			return false
+		}
 	}
-	if propsA.NotificationDate != propsB.NotificationDate {
-		fmt.Printf("      notificationDate: %q != %q\n", propsA.NotificationDate, propsB.NotificationDate)
+	return true
+}
+
+// attributeDifferences lists the attributes in which subA and subB differ.
+func attributeDifferences(subA, subB *azure.SubscriptionInfo, opts *compareOptions) []compareDifference {
+	var diffs []compareDifference
+	for i := range compareFields {
+		f := &compareFields[i]
+		if opts.ignoreFields[f.name] || !f.differs(&subA.Properties, &subB.Properties, opts.dateTolerance) {
+			continue
+		}
+		diffs = append(diffs, compareDifference{Field: f.name, Before: f.value(&subA.Properties), After: f.value(&subB.Properties)})
 	}
-	if propsA.StateComment != propsB.StateComment {
-		fmt.Printf("      stateComment: %q != %q\n", propsA.StateComment, propsB.StateComment)
+	return diffs
+}
+
+// warnPermissions warns on stderr, so JSON output stays intact, if the local
+// backup file at filePath can be read by its group or by other users.
+func warnPermissions(filePath string) {
+	if err := backup.CheckPermissions(filePath); err != nil {
+		fmt.Fprintf(os.Stderr, "\n⚠️  WARNING: %v\n\n", err)
 	}
 }
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"time"
+
+	"github.com/f-marschall/apim-kura/pkg/backup"
+)
+
+// compareReportTemplate renders a compareResult as a self-contained HTML page
+// with a collapsible section per subscription, a search box and a status filter.
+var compareReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"value": reportValue,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>kura compare report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #1f2328; }
+h1 { font-size: 1.4em; }
+table.meta td { padding: 0.1em 1em 0.1em 0; }
+.summary span { display: inline-block; margin-right: 1em; padding: 0.2em 0.6em; border-radius: 4px; }
+.controls { margin: 1em 0; }
+.controls input { width: 24em; padding: 0.3em; }
+details { border: 1px solid #d0d7de; border-radius: 4px; margin: 0.3em 0; padding: 0.3em 0.6em; }
+summary { cursor: pointer; }
+.status { display: inline-block; width: 5em; font-weight: bold; }
+.ok { background: #dafbe1; color: #116329; }
+.diff { background: #fff8c5; color: #7d4e00; }
+.missing { background: #ffebe9; color: #a40e26; }
+.extra { background: #ddf4ff; color: #0550ae; }
+table.fields { border-collapse: collapse; margin: 0.5em 0; }
+table.fields th, table.fields td { border: 1px solid #d0d7de; padding: 0.2em 0.6em; text-align: left; font-family: monospace; }
+</style>
+</head>
+<body>
+<h1>kura compare report</h1>
+<table class="meta">
+<tr><td>A</td><td>{{.Result.A}}</td></tr>
+<tr><td>B</td><td>{{.Result.B}}</td></tr>
+<tr><td>Matched by</td><td>{{.Result.MatchBy}}</td></tr>
+<tr><td>Generated</td><td>{{.Generated}}</td></tr>
+</table>
+<p class="summary">
+<span class="ok">{{.Result.Summary.Matched}} matched</span>
+<span class="diff">{{.Result.Summary.Mismatched}} mismatched</span>
+<span class="missing">{{.Result.Summary.Missing}} missing</span>
+{{if .Result.Bidirectional}}<span class="extra">{{.Result.Summary.Extra}} extra</span>{{end}}
+</p>
+<div class="controls">
+<input id="search" type="search" placeholder="Search by name or sid" oninput="filter()">
+<select id="status" onchange="filter()">
+<option value="">All statuses</option>
+<option value="ok">OK</option>
+<option value="diff">Mismatched</option>
+<option value="missing">Missing</option>
+<option value="extra">Extra</option>
+</select>
+</div>
+{{range .Result.Subscriptions}}
+<details class="item" data-status="{{.Status}}" data-search="{{.DisplayName}} {{.SID}}">
+<summary><span class="status {{.Status}}">{{.Status}}</span> {{.DisplayName}} <small>(sid={{.SID}})</small></summary>
+{{if .Differences}}<table class="fields">
+<tr><th>Field</th><th>A</th><th>B</th></tr>
+{{range .Differences}}<tr><td>{{.Field}}</td><td>{{value .Field .Before}}</td><td>{{value .Field .After}}</td></tr>
+{{end}}</table>
+{{else if eq .Status "missing"}}<p>Only in A.</p>
+{{else if eq .Status "extra"}}<p>Only in B.</p>
+{{else}}<p>All compared attributes match.</p>
+{{end}}</details>
+{{end}}
+<script>
+function filter() {
+  var q = document.getElementById("search").value.toLowerCase();
+  var s = document.getElementById("status").value;
+  document.querySelectorAll("details.item").forEach(function (el) {
+    var show = el.dataset.search.toLowerCase().indexOf(q) !== -1 && (s === "" || el.dataset.status === s);
+    el.style.display = show ? "" : "none";
+  });
+}
+</script>
+</body>
+</html>
+`))
+
+// reportValue formats the value of field in the report. The report is meant
+// to be shared, so keys are masked.
+func reportValue(field string, v any) string {
+	if key, ok := v.(string); ok && (field == "primaryKey" || field == "secondaryKey") {
+		v = maskKey(key)
+	}
+	return fmt.Sprintf("%#v", v)
+}
+
+// writeCompareReport writes result as an HTML report to path, readable only
+// by the current user like backup files.
+func writeCompareReport(path string, result *compareResult) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, backup.FileMode)
+	if err != nil {
+		return fmt.Errorf("failed to create report: %w", err)
+	}
+	defer f.Close()
+
+	data := struct {
+		Result    *compareResult
+		Generated string
+	}{result, time.Now().UTC().Format("2006-01-02T15:04:05Z")}
+	if err := compareReportTemplate.Execute(f, data); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+)
+
+// testSub returns a subscription with the given sid, display name and keys.
+func testSub(sid, displayName, primaryKey, secondaryKey string) azure.SubscriptionInfo {
+	return azure.SubscriptionInfo{
+		Name: sid,
+		Properties: azure.SubscriptionInfoProperties{
+			Scope:        "/subscriptions/x/resourceGroups/rg/providers/Microsoft.ApiManagement/service/apim/products/starter",
+			DisplayName:  displayName,
+			State:        "active",
+			PrimaryKey:   primaryKey,
+			SecondaryKey: secondaryKey,
+		},
+	}
+}
+
+// statuses returns "sid=status" for each item of r, in order.
+func statuses(r *compareResult) string {
+	var s []string
+	for _, item := range r.Subscriptions {
+		s = append(s, item.SID+"="+item.Status)
+	}
+	return strings.Join(s, ",")
+}
+
+func TestCompareSubscriptions(t *testing.T) {
+	renamed := testSub("a", "A", "ka1", "ka2")
+	renamed.Properties.DisplayName = "A renamed"
+	rotated := testSub("a", "A", "new1", "new2")
+	master := testSub("master", "Built-in", "km1", "km2")
+
+	for _, tc := range []struct {
+		name  string
+		a, b  []azure.SubscriptionInfo
+		opts  compareOptions
+		want  string
+		count compareCounts
+	}{
+		{
+			name:  "identical",
+			a:     []azure.SubscriptionInfo{testSub("a", "A", "ka1", "ka2"), testSub("b", "B", "kb1", "kb2")},
+			b:     []azure.SubscriptionInfo{testSub("b", "B", "kb1", "kb2"), testSub("a", "A", "ka1", "ka2")},
+			want:  "a=ok,b=ok",
+			count: compareCounts{A: 2, B: 2, Matched: 2},
+		},
+		{
+			name:  "paired by keys despite a different sid",
+			a:     []azure.SubscriptionInfo{testSub("a", "A", "ka1", "ka2")},
+			b:     []azure.SubscriptionInfo{testSub("other", "A", "ka1", "ka2")},
+			want:  "a=ok",
+			count: compareCounts{A: 1, B: 1, Matched: 1},
+		},
+		{
+			name:  "rotated keys are missing when matching by keys",
+			a:     []azure.SubscriptionInfo{testSub("a", "A", "ka1", "ka2")},
+			b:     []azure.SubscriptionInfo{rotated},
+			want:  "a=missing",
+			count: compareCounts{A: 1, B: 1, Missing: 1},
+		},
+		{
+			name:  "rotated keys differ when matching by name",
+			a:     []azure.SubscriptionInfo{testSub("a", "A", "ka1", "ka2")},
+			b:     []azure.SubscriptionInfo{rotated},
+			opts:  compareOptions{matchByName: true},
+			want:  "a=diff",
+			count: compareCounts{A: 1, B: 1, Mismatched: 1},
+		},
+		{
+			name:  "ignored fields do not differ",
+			a:     []azure.SubscriptionInfo{testSub("a", "A", "ka1", "ka2")},
+			b:     []azure.SubscriptionInfo{renamed},
+			opts:  compareOptions{ignoreFields: map[string]bool{"displayName": true}},
+			want:  "a=ok",
+			count: compareCounts{A: 1, B: 1, Matched: 1},
+		},
+		{
+			name:  "extra only when bidirectional",
+			a:     []azure.SubscriptionInfo{testSub("a", "A", "ka1", "ka2")},
+			b:     []azure.SubscriptionInfo{testSub("a", "A", "ka1", "ka2"), testSub("b", "B", "kb1", "kb2")},
+			want:  "a=ok",
+			count: compareCounts{A: 1, B: 2, Matched: 1},
+		},
+		{
+			name:  "extra",
+			a:     []azure.SubscriptionInfo{testSub("a", "A", "ka1", "ka2")},
+			b:     []azure.SubscriptionInfo{testSub("a", "A", "ka1", "ka2"), testSub("b", "B", "kb1", "kb2")},
+			opts:  compareOptions{bidirectional: true},
+			want:  "a=ok,b=extra",
+			count: compareCounts{A: 1, B: 2, Matched: 1, Extra: 1},
+		},
+		{
+			name:  "master is not compared",
+			a:     []azure.SubscriptionInfo{master, testSub("a", "A", "ka1", "ka2")},
+			b:     []azure.SubscriptionInfo{testSub("a", "A", "ka1", "ka2")},
+			opts:  compareOptions{bidirectional: true},
+			want:  "a=ok",
+			count: compareCounts{A: 1, B: 1, Matched: 1},
+		},
+		{
+			name:  "duplicate keys pair with the first subscription",
+			a:     []azure.SubscriptionInfo{testSub("a", "A", "k1", "k2"), testSub("b", "A", "k1", "k2")},
+			b:     []azure.SubscriptionInfo{testSub("x", "A", "k1", "k2"), testSub("y", "A", "k1", "k2")},
+			opts:  compareOptions{bidirectional: true},
+			want:  "a=ok,b=ok,y=extra",
+			count: compareCounts{A: 2, B: 2, Matched: 2, Extra: 1},
+		},
+	} {
+		result := compareSubscriptions(tc.a, tc.b, tc.opts)
+		if got := statuses(result); got != tc.want {
+			t.Errorf("%s: statuses = %s, want %s", tc.name, got, tc.want)
+		}
+		if result.Summary != tc.count {
+			t.Errorf("%s: summary = %+v, want %+v", tc.name, result.Summary, tc.count)
+		}
+		wantErr := tc.count.Missing+tc.count.Mismatched+tc.count.Extra > 0
+		if err := result.Err(); (err != nil) != wantErr {
+			t.Errorf("%s: Err() = %v, want error %v", tc.name, err, wantErr)
+		}
+	}
+}
+
+func TestCompareDifferences(t *testing.T) {
+	a := testSub("a", "A", "ka1", "ka2")
+	b := testSub("a", "A renamed", "ka1", "ka2")
+	b.Properties.Scope = strings.Replace(b.Properties.Scope, "service/apim", "service/other", 1)
+	b.Properties.CreatedDate = "2026-01-01T00:00:00Z"
+
+	result := compareSubscriptions([]azure.SubscriptionInfo{a}, []azure.SubscriptionInfo{b}, compareOptions{matchByName: true})
+	if len(result.Subscriptions) != 1 {
+		t.Fatalf("got %d items, want 1", len(result.Subscriptions))
+	}
+	var fields []string
+	for _, d := range result.Subscriptions[0].Differences {
+		fields = append(fields, d.Field)
+	}
+	// The scope of another instance with the same product is the same scope,
+	// and the report-only createdDate is listed for a mismatched pair.
+	if got, want := strings.Join(fields, ","), "displayName,createdDate"; got != want {
+		t.Errorf("differences = %s, want %s", got, want)
+	}
+}
+
+func TestDatesWithin(t *testing.T) {
+	for _, tc := range []struct {
+		a, b      string
+		tolerance time.Duration
+		want      bool
+	}{
+		{"2026-01-01T00:00:00Z", "2026-01-01T00:00:00Z", 0, true},
+		{"2026-01-01T00:00:00Z", "2026-01-01T00:00:59Z", time.Minute, true},
+		{"2026-01-01T00:01:00Z", "2026-01-01T00:00:00Z", time.Minute, true},
+		{"2026-01-01T00:00:00Z", "2026-01-01T00:01:01Z", time.Minute, false},
+		{"2026-01-01T01:00:00+01:00", "2026-01-01T00:00:00Z", 0, true},
+		{"2026-01-01", "2026-01-01", time.Hour, false},
+		{"", "2026-01-01T00:00:00Z", time.Hour, false},
+	} {
+		if got := datesWithin(tc.a, tc.b, tc.tolerance); got != tc.want {
+			t.Errorf("datesWithin(%q, %q, %s) = %v, want %v", tc.a, tc.b, tc.tolerance, got, tc.want)
+		}
+	}
+}
+
+func TestCompareDateTolerance(t *testing.T) {
+	a := testSub("a", "A", "ka1", "ka2")
+	a.Properties.ExpirationDate = "2026-06-01T00:00:00Z"
+	b := a
+	b.Properties.ExpirationDate = "2026-06-01T00:00:30Z"
+
+	for _, tc := range []struct {
+		tolerance time.Duration
+		want      string
+	}{
+		{0, "a=diff"},
+		{10 * time.Second, "a=diff"},
+		{time.Minute, "a=ok"},
+	} {
+		result := compareSubscriptions([]azure.SubscriptionInfo{a}, []azure.SubscriptionInfo{b}, compareOptions{dateTolerance: tc.tolerance})
+		if got := statuses(result); got != tc.want {
+			t.Errorf("tolerance %s: statuses = %s, want %s", tc.tolerance, got, tc.want)
+		}
+	}
+}
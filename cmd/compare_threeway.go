@@ -0,0 +1,260 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+)
+
+// Kinds of a change in a three-way comparison.
+const (
+	threeWayChangedA    = "changed in A"
+	threeWayChangedB    = "changed in B"
+	threeWayChangedBoth = "changed in both"
+	threeWayConflict    = "conflict"
+)
+
+// Statuses of a subscription in a threeWayResult.
+const (
+	threeWayStatusUnchanged = "unchanged"
+	threeWayStatusChanged   = "changed"
+	threeWayStatusConflict  = "conflict"
+)
+
+// existsField is the pseudo-field under which a subscription being added or
+// removed on one side is reported.
+const existsField = "exists"
+
+// threeWayResult is the outcome of comparing A and B against a common base.
+type threeWayResult struct {
+	Base          string         `json:"base,omitempty"`
+	A             string         `json:"a,omitempty"`
+	B             string         `json:"b,omitempty"`
+	MatchBy       string         `json:"matchBy"`
+	Summary       threeWayCounts `json:"summary"`
+	Subscriptions []threeWayItem `json:"subscriptions"`
+}
+
+// threeWayCounts summarizes a threeWayResult by subscription status.
+type threeWayCounts struct {
+	Unchanged   int `json:"unchanged"`
+	Changed     int `json:"changed"`
+	Conflicting int `json:"conflicting"`
+}
+
+// threeWayItem is the three-way comparison result of a single subscription.
+type threeWayItem struct {
+	Status      string           `json:"status"`
+	SID         string           `json:"sid"`
+	DisplayName string           `json:"displayName"`
+	Changes     []threeWayChange `json:"changes,omitempty"`
+}
+
+// threeWayChange is a field that differs from the base on at least one side.
+// Values of a side the subscription does not exist on are nil.
+type threeWayChange struct {
+	Field string `json:"field"`
+	Kind  string `json:"kind"`
+	Base  any    `json:"base"`
+	A     any    `json:"a"`
+	B     any    `json:"b"`
+}
+
+// compareThreeWay pairs the non-master subscriptions of base, A and B and
+// classifies every difference as changed in A, changed in B, changed in both
+// (to the same value) or a conflict.
+func compareThreeWay(subsBase, subsA, subsB []azure.SubscriptionInfo, opts compareOptions) *threeWayResult {
+	subsBase = filterOutMaster(subsBase)
+	subsA = filterOutMaster(subsA)
+	subsB = filterOutMaster(subsB)
+
+	result := &threeWayResult{MatchBy: "keys", Subscriptions: []threeWayItem{}}
+	if opts.matchByName {
+		result.MatchBy = "name"
+	}
+
+	pairedA := make([]bool, len(subsA))
+	pairedB := make([]bool, len(subsB))
+	indexA := newCounterpartIndex(subsA, opts.matchByName)
+	indexB := newCounterpartIndex(subsB, opts.matchByName)
+	counterpart := func(sub *azure.SubscriptionInfo, idx *counterpartIndex, subs []azure.SubscriptionInfo, paired []bool) *azure.SubscriptionInfo {
+		i := idx.find(sub)
+		if i < 0 {
+			return nil
+		}
+		paired[i] = true
+		return &subs[i]
+	}
+
+	for i := range subsBase {
+		base := &subsBase[i]
+		a := counterpart(base, indexA, subsA, pairedA)
+		b := counterpart(base, indexB, subsB, pairedB)
+		result.add(classifyThreeWay(base, a, b, &opts))
+	}
+	// Subscriptions added since the base, on one or both sides.
+	for i := range subsA {
+		if pairedA[i] {
+			continue
+		}
+		a := &subsA[i]
+		b := counterpart(a, indexB, subsB, pairedB)
+		result.add(classifyThreeWay(nil, a, b, &opts))
+	}
+	for i := range subsB {
+		if !pairedB[i] {
+			result.add(classifyThreeWay(nil, nil, &subsB[i], &opts))
+		}
+	}
+
+	return result
+}
+
+func (r *threeWayResult) add(item threeWayItem) {
+	switch item.Status {
+	case threeWayStatusUnchanged:
+		r.Summary.Unchanged++
+	case threeWayStatusChanged:
+		r.Summary.Changed++
+	case threeWayStatusConflict:
+		r.Summary.Conflicting++
+	}
+	r.Subscriptions = append(r.Subscriptions, item)
+}
+
+// classifyThreeWay compares one subscription across the three sides. Any of
+// base, a and b may be nil if the subscription does not exist there. When a
+// side is missing, only conflicting fields are listed besides the exists
+// pseudo-field, as every other field trivially follows from the addition or
+// removal.
+func classifyThreeWay(base, a, b *azure.SubscriptionInfo, opts *compareOptions) threeWayItem {
+	var item threeWayItem
+	for _, sub := range []*azure.SubscriptionInfo{base, a, b} {
+		if sub != nil {
+			item.SID = sub.Name
+			item.DisplayName = sub.Properties.DisplayName
+			break
+		}
+	}
+
+	if kind := classifyChange(base != nil, a != nil, b != nil, func(x, y bool) bool { return x == y }); kind != "" {
+		item.Changes = append(item.Changes, threeWayChange{Field: existsField, Kind: kind, Base: base != nil, A: a != nil, B: b != nil})
+	}
+
+	allPresent := base != nil && a != nil && b != nil
+	for i := range compareFields {
+		f := &compareFields[i]
+		if f.reportOnly || opts.ignoreFields[f.name] {
+			continue
+		}
+		equal := func(x, y *azure.SubscriptionInfo) bool {
+			if x == nil || y == nil {
+				return x == nil && y == nil
+			}
+			return !f.differs(&x.Properties, &y.Properties, opts.dateTolerance)
+		}
+		kind := classifyChange(base, a, b, equal)
+		if kind == "" || !allPresent && kind != threeWayConflict {
+			continue
+		}
+		value := func(sub *azure.SubscriptionInfo) any {
+			if sub == nil {
+				return nil
+			}
+			return f.value(&sub.Properties)
+		}
+		item.Changes = append(item.Changes, threeWayChange{Field: f.name, Kind: kind, Base: value(base), A: value(a), B: value(b)})
+	}
+
+	item.Status = threeWayStatusUnchanged
+	for _, c := range item.Changes {
+		if c.Kind == threeWayConflict {
+			item.Status = threeWayStatusConflict
+			break
+		}
+		item.Status = threeWayStatusChanged
+	}
+	return item
+}
+
+// classifyChange returns how a and b changed relative to base, or an empty
+// string if neither changed.
+func classifyChange[T any](base, a, b T, equal func(x, y T) bool) string {
+	changedA := !equal(base, a)
+	changedB := !equal(base, b)
+	switch {
+	case !changedA && !changedB:
+		return ""
+	case !changedB:
+		return threeWayChangedA
+	case !changedA:
+		return threeWayChangedB
+	case equal(a, b):
+		return threeWayChangedBoth
+	default:
+		return threeWayConflict
+	}
+}
+
+// Err returns an error wrapping errCompareDifferences if any subscription has
+// conflicting changes. Changes on only one side, or the same change on both,
+// can be reconciled automatically and are not an error.
+func (r *threeWayResult) Err() error {
+	if r.Summary.Conflicting > 0 {
+		return fmt.Errorf("%w: %d subscription(s) with conflicting changes", errCompareDifferences, r.Summary.Conflicting)
+	}
+	return nil
+}
+
+// printText prints one line per subscription followed by a summary.
+func (r *threeWayResult) printText() {
+	fmt.Println()
+	for _, item := range r.Subscriptions {
+		switch item.Status {
+		case threeWayStatusUnchanged:
+			fmt.Printf("  [OK]       %s\n", item.DisplayName)
+			continue
+		case threeWayStatusChanged:
+			fmt.Printf("  [CHANGED]  %s (sid=%s)\n", item.DisplayName, item.SID)
+		case threeWayStatusConflict:
+			fmt.Printf("  [CONFLICT] %s (sid=%s)\n", item.DisplayName, item.SID)
+		}
+		for _, c := range item.Changes {
+			switch {
+			case c.Field == existsField:
+				fmt.Printf("      %s\n", describeExistsChange(&c))
+			case c.Kind == threeWayConflict:
+				fmt.Printf("      %s: conflict: base %s, A %s, B %s\n", c.Field, formatThreeWayValue(c.Base), formatThreeWayValue(c.A), formatThreeWayValue(c.B))
+			case c.Kind == threeWayChangedB:
+				fmt.Printf("      %s: %s: %#v -> %#v\n", c.Field, c.Kind, c.Base, c.B)
+			default:
+				fmt.Printf("      %s: %s: %#v -> %#v\n", c.Field, c.Kind, c.Base, c.A)
+			}
+		}
+	}
+
+	fmt.Printf("\nThree-way comparison complete: %d unchanged, %d changed without conflict, %d conflicting\n", r.Summary.Unchanged, r.Summary.Changed, r.Summary.Conflicting)
+}
+
+// describeExistsChange renders an addition or removal of a subscription.
+func describeExistsChange(c *threeWayChange) string {
+	verb := "added"
+	if c.Base.(bool) {
+		verb = "removed"
+	}
+	switch c.Kind {
+	case threeWayChangedA:
+		return verb + " in A"
+	case threeWayChangedB:
+		return verb + " in B"
+	default:
+		return verb + " in both"
+	}
+}
+
+func formatThreeWayValue(v any) string {
+	if v == nil {
+		return "(none)"
+	}
+	return fmt.Sprintf("%#v", v)
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+)
+
+// changes returns "field:kind" for each change of item, in order.
+func changes(item threeWayItem) string {
+	var s []string
+	for _, c := range item.Changes {
+		s = append(s, c.Field+":"+c.Kind)
+	}
+	return strings.Join(s, ",")
+}
+
+func TestClassifyThreeWay(t *testing.T) {
+	base := testSub("a", "A", "k1", "k2")
+	with := func(f func(p *azure.SubscriptionInfoProperties)) *azure.SubscriptionInfo {
+		sub := base
+		f(&sub.Properties)
+		return &sub
+	}
+	renamedX := with(func(p *azure.SubscriptionInfoProperties) { p.DisplayName = "X" })
+	renamedY := with(func(p *azure.SubscriptionInfoProperties) { p.DisplayName = "Y" })
+	suspended := with(func(p *azure.SubscriptionInfoProperties) { p.State = "suspended" })
+
+	for _, tc := range []struct {
+		name          string
+		base, a, b    *azure.SubscriptionInfo
+		status, kinds string
+	}{
+		{"unchanged", &base, &base, &base, threeWayStatusUnchanged, ""},
+		{"changed in A", &base, renamedX, &base, threeWayStatusChanged, "displayName:" + threeWayChangedA},
+		{"changed in B", &base, &base, suspended, threeWayStatusChanged, "state:" + threeWayChangedB},
+		{"changed in both to the same value", &base, renamedX, renamedX, threeWayStatusChanged, "displayName:" + threeWayChangedBoth},
+		{"different fields on each side", &base, renamedX, suspended, threeWayStatusChanged,
+			"displayName:" + threeWayChangedA + ",state:" + threeWayChangedB},
+		{"conflict", &base, renamedX, renamedY, threeWayStatusConflict, "displayName:" + threeWayConflict},
+		{"removed in A", &base, nil, &base, threeWayStatusChanged, "exists:" + threeWayChangedA},
+		{"added in B", nil, nil, &base, threeWayStatusChanged, "exists:" + threeWayChangedB},
+		{"added in both alike", nil, &base, &base, threeWayStatusChanged, "exists:" + threeWayChangedBoth},
+		{"added in both differently", nil, renamedX, renamedY, threeWayStatusConflict,
+			"exists:" + threeWayChangedBoth + ",displayName:" + threeWayConflict},
+		{"removed in A and changed in B", &base, nil, suspended, threeWayStatusConflict,
+			"exists:" + threeWayChangedA + ",state:" + threeWayConflict},
+	} {
+		item := classifyThreeWay(tc.base, tc.a, tc.b, &compareOptions{})
+		if item.Status != tc.status {
+			t.Errorf("%s: status = %s, want %s", tc.name, item.Status, tc.status)
+		}
+		if got := changes(item); got != tc.kinds {
+			t.Errorf("%s: changes = %s, want %s", tc.name, got, tc.kinds)
+		}
+		if item.SID != "a" {
+			t.Errorf("%s: sid = %q, want a", tc.name, item.SID)
+		}
+	}
+}
+
+func TestCompareThreeWay(t *testing.T) {
+	subs := func(s ...azure.SubscriptionInfo) []azure.SubscriptionInfo { return s }
+	a, b, c := testSub("a", "A", "ka1", "ka2"), testSub("b", "B", "kb1", "kb2"), testSub("c", "C", "kc1", "kc2")
+	bRenamed := b
+	bRenamed.Properties.DisplayName = "B renamed"
+
+	result := compareThreeWay(
+		subs(testSub("master", "M", "km1", "km2"), a, b),
+		subs(a, bRenamed, c),
+		subs(a, b),
+		compareOptions{matchByName: true},
+	)
+	var got []string
+	for _, item := range result.Subscriptions {
+		got = append(got, item.SID+"="+item.Status)
+	}
+	if want := "a=unchanged,b=changed,c=changed"; strings.Join(got, ",") != want {
+		t.Errorf("statuses = %s, want %s", strings.Join(got, ","), want)
+	}
+	if want := (threeWayCounts{Unchanged: 1, Changed: 2}); result.Summary != want {
+		t.Errorf("summary = %+v, want %+v", result.Summary, want)
+	}
+	if err := result.Err(); err != nil {
+		t.Errorf("Err() = %v, want nil without conflicts", err)
+	}
+}
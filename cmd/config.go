@@ -0,0 +1,237 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/f-marschall/apim-kura/pkg/backup"
+	"github.com/spf13/cobra"
+)
+
+// configKind describes a kind of instance configuration other than
+// subscriptions, such as named values, that kura backs up and restores. Each
+// kind gets a command with backup and restore subcommands, see
+// newConfigCommand.
+type configKind[T any] struct {
+	// use is the name of the command and the kind of its backup files,
+	// such as "named-values".
+	use    string
+	noun   string // such as "named value"
+	plural string // such as "named values"
+	short  string
+	long   string
+
+	list func(ctx context.Context, client *azure.Client) ([]T, error)
+	// put creates or replaces item. Kinds without put can only be backed
+	// up and get no restore subcommand.
+	put func(ctx context.Context, client *azure.Client, item T) error
+	// name returns the ID of item in output.
+	name func(item T) string
+}
+
+// configFlags holds the flags of the backup and restore subcommands of a
+// configuration kind.
+type configFlags struct {
+	resourceGroup string
+	apimName      string
+	subscription  string
+	file          string
+	dryRun        bool
+	yes           bool
+}
+
+// register adds the flags shared by backup and restore to cmd.
+func (f *configFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&f.resourceGroup, "resource-group", "g", "", "Azure resource group name (required)")
+	cmd.Flags().StringVarP(&f.apimName, "apim-name", "a", "", "Azure API Management instance name (required)")
+	cmd.Flags().StringVarP(&f.subscription, "subscription", "s", "", "Azure subscription ID")
+	cmd.MarkFlagRequired("resource-group")
+	cmd.MarkFlagRequired("apim-name")
+}
+
+// path returns the backup file of the flags, by default the one of kind in
+// the backup folder structure.
+func (f *configFlags) path(kind string) string {
+	if f.file != "" {
+		return f.file
+	}
+	return backup.ConfigPath(f.resourceGroup, f.apimName, kind)
+}
+
+// newConfigCommand returns the command of k with its backup and restore
+// subcommands. Further subcommands can be added to it.
+func newConfigCommand[T any](k configKind[T]) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   k.use,
+		Short: k.short,
+		Long:  k.long,
+	}
+
+	var backupFlags configFlags
+	backupCmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Back up the " + k.plural + " of an instance",
+		Long: fmt.Sprintf(`Backup writes the %s of an Azure API Management instance to a
+file, by default %s.
+
+Example:
+  kura %s backup -g mygroup -a myapim`, k.plural, backup.ConfigPath("<resource-group>", "<apim-name>", k.use), k.use),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConfigBackup(k, &backupFlags)
+		},
+	}
+	backupFlags.register(backupCmd)
+	backupCmd.Flags().StringVarP(&backupFlags.file, "output", "o", "", "Output file path or azblob:// URL (if not specified, defaults to backup folder structure)")
+
+	cmd.AddCommand(backupCmd)
+	if k.put == nil {
+		return cmd
+	}
+
+	var restoreFlags configFlags
+	restoreCmd := &cobra.Command{
+		Use:   "restore",
+		Short: "Restore " + k.plural + " from a backup file",
+		Long: fmt.Sprintf(`Restore creates or replaces the %s of a backup file in an Azure API
+Management instance, by default the ones backed up from that instance. Those
+of the instance that are not in the file are left untouched.
+
+Restore asks for confirmation first; use --yes (or --force) to skip the prompt.
+
+Example:
+  kura %s restore -g mygroup -a myapim --dry-run
+  kura %s restore -g dr-group -a dr-apim -i %s --yes`,
+			k.plural, k.use, k.use, backup.ConfigPath("mygroup", "myapim", k.use)),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConfigRestore(k, &restoreFlags)
+		},
+	}
+	restoreFlags.register(restoreCmd)
+	restoreCmd.Flags().StringVarP(&restoreFlags.file, "input", "i", "", "Backup file path or azblob:// URL to restore from (defaults to the backup folder structure)")
+	restoreCmd.Flags().BoolVar(&restoreFlags.dryRun, "dry-run", false, "Preview changes without applying them")
+	registerYesFlags(restoreCmd, &restoreFlags.yes)
+
+	cmd.AddCommand(restoreCmd)
+	return cmd
+}
+
+func runConfigBackup[T any](k configKind[T], f *configFlags) error {
+	fmt.Printf("Backing up %s from APIM instance: %s\n", k.plural, f.apimName)
+	fmt.Printf("Resource Group: %s\n", f.resourceGroup)
+
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	fmt.Println("\nAuthenticating with Azure CLI...")
+	client, err := azure.NewClient(ctx, f.subscription, f.resourceGroup, f.apimName)
+	if err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+
+	fmt.Printf("\nFetching %s...\n", k.plural)
+	items, err := k.list(ctx, client)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Found %d %s(s)\n", len(items), k.noun)
+
+	path := f.path(k.use)
+	meta := backup.Metadata{
+		SubscriptionID: client.SubscriptionID(),
+		ResourceGroup:  f.resourceGroup,
+		APIMName:       f.apimName,
+	}
+	if err := backup.SaveConfig(path, k.use, meta, items); err != nil {
+		return err
+	}
+	fmt.Printf("Backup saved to: %s\n", path)
+	return nil
+}
+
+func runConfigRestore[T any](k configKind[T], f *configFlags) error {
+	path := f.path(k.use)
+	warnPermissions(path)
+	file, err := backup.LoadConfig[T](path, k.use)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Restoring %d %s(s) from %s to APIM instance: %s\n", len(file.Items), k.noun, path, f.apimName)
+	fmt.Printf("Resource Group: %s\n", f.resourceGroup)
+	if len(file.Items) == 0 {
+		fmt.Printf("No %s in the backup. Nothing to restore.\n", k.plural)
+		return nil
+	}
+
+	if !f.dryRun && !f.yes {
+		ok, err := confirm(fmt.Sprintf("Create or replace %d %s(s) in %s?", len(file.Items), k.noun, f.apimName))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Printf("Aborted. No %s were restored.\n", k.plural)
+			return nil
+		}
+	}
+
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	fmt.Println("\nAuthenticating with Azure CLI...")
+	client, err := azure.NewClient(ctx, f.subscription, f.resourceGroup, f.apimName)
+	if err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	fmt.Println()
+
+	// The audit journal records each entity as it was before the restore.
+	var live map[string]T
+	if azure.Recording() && !f.dryRun {
+		items, err := k.list(ctx, client)
+		if err != nil {
+			fmt.Printf("  [WARNING] failed to read the %s before restoring them for the audit journal: %v\n", k.plural, err)
+		}
+		live = make(map[string]T, len(items))
+		for _, item := range items {
+			live[k.name(item)] = item
+		}
+	}
+
+	var succeeded, failed int
+	for _, item := range file.Items {
+		name := k.name(item)
+		if f.dryRun {
+			fmt.Printf("  [DRY-RUN] Would restore %s\n", name)
+			continue
+		}
+		m := azure.Mutation{Kind: k.use, Operation: azure.OperationCreate, Target: name}
+		if before, ok := live[name]; ok {
+			m.Operation = azure.OperationUpdate
+			m.BeforeEntity = before
+		}
+		err := client.RecordChange(ctx, m, func() (any, error) {
+			return item, k.put(ctx, client, item)
+		})
+		if err != nil {
+			fmt.Printf("  [FAIL] %s: %v\n", name, err)
+			failed++
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		fmt.Printf("  [OK]   %s\n", name)
+		succeeded++
+	}
+
+	if f.dryRun {
+		fmt.Printf("\nDry run complete: %d %s(s) would be restored\n", len(file.Items), k.noun)
+		return nil
+	}
+	fmt.Printf("\nRestore complete: %d succeeded, %d failed (out of %d total)\n", succeeded, failed, len(file.Items))
+	if failed > 0 {
+		return fmt.Errorf("%d %s(s) failed to restore", failed, k.noun)
+	}
+	return nil
+}
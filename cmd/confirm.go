@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// registerYesFlags adds --yes (-y) and its alias --force to cmd. Both skip the
+// confirmation prompt of a destructive operation.
+func registerYesFlags(cmd *cobra.Command, yes *bool) {
+	cmd.Flags().BoolVarP(yes, "yes", "y", false, "Do not ask for confirmation")
+	cmd.Flags().BoolVar(yes, "force", false, "Alias for --yes")
+}
+
+// confirm asks question on stdout and reads a y/N answer from stdin. It
+// returns an error if stdin is closed before an answer was given, so scripts
+// that forgot --yes fail instead of silently doing nothing.
+func confirm(question string) (bool, error) {
+	fmt.Printf("%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Println()
+		return false, fmt.Errorf("no confirmation received; use --yes to skip the prompt")
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/f-marschall/apim-kura/pkg/backup"
+	"github.com/spf13/cobra"
+)
+
+var copyCmd = &cobra.Command{
+	Use:   "copy",
+	Short: "Copy subscription keys from one APIM instance to another",
+	Long: `Copy reads the subscriptions of a source Azure API Management instance and
+recreates them in a target instance in a single run, without writing the keys
+to a backup file in between.
+
+Scopes are rebuilt for the target instance the same way restore does it, so
+the products and APIs the subscriptions are scoped to must exist there. Use
+--owner-map to map source users to users of the target instance. The built-in
+master subscription is never copied.
+
+If the copy would overwrite existing subscriptions of the target instance,
+kura asks for confirmation; use --yes (or --force) to skip the prompt. Unlike
+restore, copy does not take a safety backup of the target instance.
+
+Example:
+  kura copy --source-resource-group prod-rg --source-apim prod-apim --target-apim test-apim --target-resource-group test-rg
+  kura copy --source-resource-group rg --source-apim a --target-apim b --product-id starter --dry-run`,
+	Args: cobra.NoArgs,
+	RunE: runCopy,
+}
+
+var (
+	copySourceRG           string
+	copySourceAPIM         string
+	copySourceSubscription string
+	copyTargetRG           string
+	copyTargetAPIM         string
+	copyTargetSubscription string
+	copyProductID          string
+	copyOwnerMap           string
+	copySkipExisting       bool
+	copyDryRun             bool
+	copyYes                bool
+)
+
+func init() {
+	rootCmd.AddCommand(copyCmd)
+
+	copyCmd.Flags().StringVar(&copySourceRG, "source-resource-group", "", "Resource group of --source-apim (required)")
+	copyCmd.Flags().StringVar(&copySourceAPIM, "source-apim", "", "APIM instance to copy the subscriptions from (required)")
+	copyCmd.Flags().StringVar(&copySourceSubscription, "source-subscription", "", "Azure subscription ID of --source-apim")
+	copyCmd.Flags().StringVar(&copyTargetRG, "target-resource-group", "", "Resource group of --target-apim (defaults to --source-resource-group)")
+	copyCmd.Flags().StringVar(&copyTargetAPIM, "target-apim", "", "APIM instance to copy the subscriptions to (required)")
+	copyCmd.Flags().StringVar(&copyTargetSubscription, "target-subscription", "", "Azure subscription ID of --target-apim")
+	copyCmd.Flags().StringVarP(&copyProductID, "product-id", "p", "", "Only copy subscriptions scoped to this product")
+	copyCmd.Flags().StringVar(&copyOwnerMap, "owner-map", "", "YAML file mapping source user IDs to target user IDs (or \"drop\")")
+	copyCmd.Flags().BoolVar(&copySkipExisting, "skip-existing", false, "Skip subscriptions whose sid already exists in the target instance")
+	copyCmd.Flags().BoolVar(&copyDryRun, "dry-run", false, "Preview the copy without applying it")
+	registerYesFlags(copyCmd, &copyYes)
+
+	copyCmd.MarkFlagRequired("source-resource-group")
+	copyCmd.MarkFlagRequired("source-apim")
+	copyCmd.MarkFlagRequired("target-apim")
+}
+
+func runCopy(cmd *cobra.Command, args []string) error {
+	if copyTargetRG == "" {
+		copyTargetRG = copySourceRG
+	}
+	if copyTargetAPIM == copySourceAPIM && copyTargetRG == copySourceRG && copyTargetSubscription == copySourceSubscription {
+		return fmt.Errorf("source and target are the same APIM instance")
+	}
+
+	fmt.Printf("Copying subscription keys to APIM instance: %s\n", copyTargetAPIM)
+	fmt.Printf("Source: %s (resource group %s)\n", copySourceAPIM, copySourceRG)
+	fmt.Printf("Target: %s (resource group %s)\n", copyTargetAPIM, copyTargetRG)
+	if copyProductID != "" {
+		fmt.Printf("Product ID: %s\n", copyProductID)
+	}
+
+	var ownerMap backup.OwnerMap
+	if copyOwnerMap != "" {
+		var err error
+		ownerMap, err = backup.LoadOwnerMap(copyOwnerMap)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Owner map: %s (%d entries)\n", copyOwnerMap, len(ownerMap))
+	}
+
+	if copyDryRun {
+		fmt.Println("\nRunning in DRY-RUN mode. No changes will be applied.")
+	}
+
+	if !copyDryRun {
+		unlock, err := lockInstance(copyTargetRG, copyTargetAPIM, "copy")
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	fmt.Println("\nAuthenticating with Azure CLI...")
+
+	source, err := azure.NewClient(ctx, copySourceSubscription, copySourceRG, copySourceAPIM)
+	if err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	target, err := azure.NewClient(ctx, copyTargetSubscription, copyTargetRG, copyTargetAPIM)
+	if err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	fmt.Println("Successfully authenticated with Azure CLI")
+
+	fmt.Println("\nFetching subscriptions of the source instance...")
+	all, err := source.ListSubscriptions(ctx, copyProductID)
+	if err != nil {
+		return fmt.Errorf("failed to list source subscriptions: %w", err)
+	}
+	subs := filterOutMaster(all)
+	if len(subs) == 0 {
+		fmt.Println("No subscriptions found. Nothing to copy.")
+		return nil
+	}
+	fmt.Printf("Found %d subscription(s)\n", len(subs))
+
+	fmt.Println("\nFetching subscriptions of the target instance...")
+	current, err := target.ListSubscriptionsWithoutKeys(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed to list target subscriptions: %w", err)
+	}
+	existing := make(map[string]bool, len(current))
+	for _, sub := range current {
+		existing[sub.Name] = true
+	}
+
+	if !copyDryRun && !copyYes && !copySkipExisting {
+		var overwrites int
+		for _, sub := range subs {
+			if existing[sub.Name] {
+				overwrites++
+			}
+		}
+		if overwrites > 0 {
+			fmt.Println()
+			ok, err := confirm(fmt.Sprintf("Copy %d subscription(s) to APIM instance %s, overwriting %d existing subscription(s)?", len(subs), copyTargetAPIM, overwrites))
+			if err != nil {
+				return err
+			}
+			if !ok {
+				fmt.Println("Aborted. No subscriptions were copied.")
+				return nil
+			}
+		}
+	}
+
+	fmt.Println()
+	azureSubID := target.SubscriptionID() // Needed to rebuild scopes.
+	var copied, skipped, failed int
+	for _, sub := range subs {
+		sid := sub.Name
+		displayName := sub.Properties.DisplayName
+
+		if copySkipExisting && existing[sid] {
+			fmt.Printf("  [SKIP] %s (sid=%s already exists)\n", displayName, sid)
+			skipped++
+			continue
+		}
+
+		scopeSuffix, scope := targetScope(&sub, azureSubID, copyTargetRG, copyTargetAPIM)
+		remapOwner(&sub, ownerMap, azureSubID, copyTargetRG, copyTargetAPIM)
+		opts, err := newCreateOptions(&sub)
+		if err != nil {
+			fmt.Printf("  [FAIL] %s: %v\n", displayName, err)
+			failed++
+			continue
+		}
+
+		if copyDryRun {
+			action := "create"
+			if existing[sid] {
+				action = "overwrite"
+			}
+			fmt.Printf("  [DRY-RUN] Would %s: %s (sid=%s, scope=%s)\n", action, displayName, sid, scopeSuffix)
+			copied++
+			continue
+		}
+
+		fmt.Printf("  Copying: %s (sid=%s, scope=%s)...\n", displayName, sid, scopeSuffix)
+		if _, err := target.CreateSubscription(ctx, sid, scope, displayName, opts); warnExpiration(err) != nil {
+			fmt.Printf("  [FAIL] %s: %v\n", displayName, err)
+			failed++
+			continue
+		}
+		fmt.Printf("  [OK]   %s\n", displayName)
+		copied++
+	}
+
+	fmt.Printf("\nCopy complete: %d copied, %d skipped, %d failed\n", copied, skipped, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d subscription(s) failed to copy", failed)
+	}
+	return nil
+}
@@ -0,0 +1,444 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/f-marschall/apim-kura/internal/eventgrid"
+	"github.com/f-marschall/apim-kura/internal/notify"
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/f-marschall/apim-kura/pkg/backup"
+	"github.com/spf13/cobra"
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Back up subscription keys on a schedule",
+	Long: `Daemon runs as a long-lived process that backs up the subscriptions of an
+Azure API Management instance every --interval, starting right away. It is
+meant to run as a systemd service or a sidecar container instead of a cron job.
+
+Each backup is written to the regular backup folder with a timestamped file
+name (see --name-template), unless nothing changed since the newest backup.
+With --drift, every cycle also logs how the live subscriptions differ from the
+previous backup. With --sync-target-apim, every cycle also syncs the
+subscriptions to that instance, like the sync command with --yes.
+
+With --listen, the daemon also serves an Azure Event Grid webhook endpoint and
+runs a cycle within seconds of any subscription change, in addition to the
+periodic ones. Register http(s)://<host>/events?token=<--event-token> as the
+endpoint of an Event Grid subscription on the API Management system topic
+(Microsoft.ApiManagement.Subscription* events) or on the resource group
+(resource write and delete events). Events arriving within --event-debounce of
+each other trigger a single cycle, and as with the periodic cycles a backup is
+only written if the subscriptions changed.
+
+Results are logged as one structured record per event to stdout, as JSON by
+default (see --log-format). A failed cycle is logged and retried at the next
+interval. On SIGINT or SIGTERM the daemon finishes the running cycle and exits.
+
+Example:
+  kura daemon --resource-group mygroup --apim-name myapim --interval 1h
+  kura daemon -g mygroup -a myapim --interval 6h --drift --sync-target-apim dr-apim --sync-target-resource-group dr-rg
+  kura daemon -g mygroup -a myapim --interval 1d --listen :8080 --event-token "$KURA_EVENT_TOKEN"`,
+	Args: cobra.NoArgs,
+	RunE: runDaemon,
+}
+
+var (
+	daemonResourceGroup  string
+	daemonAPIMName       string
+	daemonSubscription   string
+	daemonProductID      string
+	daemonInterval       string
+	daemonNameTemplate   string
+	daemonDrift          bool
+	daemonSyncTargetRG   string
+	daemonSyncTargetAPIM string
+	daemonSyncTargetSub  string
+	daemonSyncPrune      bool
+	daemonSyncNoBackup   bool
+	daemonLogFormat      string
+	daemonListen         string
+	daemonEventToken     string
+	daemonEventDebounce  string
+)
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+
+	daemonCmd.Flags().StringVarP(&daemonResourceGroup, "resource-group", "g", "", "Azure resource group name (required)")
+	daemonCmd.Flags().StringVarP(&daemonAPIMName, "apim-name", "a", "", "Azure API Management instance name (required)")
+	daemonCmd.Flags().StringVarP(&daemonSubscription, "subscription", "s", "", "Azure subscription ID")
+	daemonCmd.Flags().StringVarP(&daemonProductID, "product-id", "p", "", "Azure APIM product ID (optional, scopes backups to a product)")
+	daemonCmd.Flags().StringVar(&daemonInterval, "interval", "1h", "Time between two cycles, such as 30m, 6h or 1d")
+	daemonCmd.Flags().StringVar(&daemonNameTemplate, "name-template", "subscriptions-{{.Timestamp}}.json", "File name template for the backup files")
+	daemonCmd.Flags().BoolVar(&daemonDrift, "drift", false, "Log how the live subscriptions differ from the previous backup")
+	daemonCmd.Flags().StringVar(&daemonSyncTargetRG, "sync-target-resource-group", "", "Resource group of --sync-target-apim (defaults to --resource-group)")
+	daemonCmd.Flags().StringVar(&daemonSyncTargetAPIM, "sync-target-apim", "", "APIM instance to sync the subscriptions to every cycle")
+	daemonCmd.Flags().StringVar(&daemonSyncTargetSub, "sync-target-subscription", "", "Azure subscription ID of --sync-target-apim")
+	daemonCmd.Flags().BoolVar(&daemonSyncPrune, "sync-prune", false, "With --sync-target-apim, delete target subscriptions that do not exist in the source")
+	daemonCmd.Flags().BoolVar(&daemonSyncNoBackup, "sync-no-backup", false, "With --sync-prune, do not back up pruned subscriptions before deleting them")
+	daemonCmd.Flags().StringVar(&daemonLogFormat, "log-format", "json", "Log format: json or text")
+	daemonCmd.Flags().StringVar(&daemonListen, "listen", "", "Address to receive Event Grid events on, such as :8080 (disabled by default)")
+	daemonCmd.Flags().StringVar(&daemonEventToken, "event-token", os.Getenv("KURA_EVENT_TOKEN"), "Secret expected as the token query parameter of event deliveries (default $KURA_EVENT_TOKEN)")
+	daemonCmd.Flags().StringVar(&daemonEventDebounce, "event-debounce", "10s", "Wait this long after an event for further events before running a cycle")
+
+	daemonCmd.MarkFlagRequired("resource-group")
+	daemonCmd.MarkFlagRequired("apim-name")
+}
+
+// daemon holds the clients and settings of a running daemon.
+type daemon struct {
+	command  string // daemon or run, recorded in the locks it takes
+	log      *slog.Logger
+	source   azure.SubscriptionStore
+	target   azure.SubscriptionStore // nil without --sync-target-apim
+	insights *notify.AppInsights     // nil without an Application Insights connection string
+}
+
+func runDaemon(cmd *cobra.Command, args []string) error {
+	interval, err := parseDays("interval", daemonInterval)
+	if err != nil {
+		return err
+	}
+	debounce, err := parseDays("event-debounce", daemonEventDebounce)
+	if err != nil {
+		return err
+	}
+	if daemonListen == "" && cmd.Flags().Changed("event-token") {
+		return fmt.Errorf("--event-token requires --listen")
+	}
+	if err := checkDaemonSettings(); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	d, err := newDaemon(ctx, "daemon")
+	if err != nil {
+		return err
+	}
+
+	// changed receives a value when events report a subscription change. It
+	// is buffered, so changes arriving during a cycle trigger one more cycle.
+	changed := make(chan struct{}, 1)
+	if daemonListen != "" {
+		if daemonEventToken == "" {
+			d.log.Warn("event endpoint has no --event-token; anyone who can reach it can trigger cycles")
+		}
+		srv, err := d.listen(daemonListen, daemonEventToken, changed)
+		if err != nil {
+			return err
+		}
+		defer srv.Shutdown(context.Background())
+	}
+
+	d.log.Info("daemon started", "interval", interval.String(), "drift", daemonDrift, "syncTarget", daemonSyncTargetAPIM, "listen", daemonListen)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	// debounced fires once no event arrived for the debounce period. It is
+	// nil while no event is pending.
+	var debounced <-chan time.Time
+	for {
+		// A cycle is not cancelled by a signal, so it never stops halfway
+		// through a backup or a sync. --timeout limits each cycle.
+		cycleCtx, cancel := withTimeout(context.WithoutCancel(ctx))
+		d.runCycle(cycleCtx) // errors are logged
+		cancel()
+
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				d.log.Info("daemon stopped")
+				return nil
+			case <-ticker.C:
+				break wait
+			case <-changed:
+				debounced = time.After(debounce)
+			case <-debounced:
+				debounced = nil
+				d.log.Info("running cycle after subscription change")
+				break wait
+			}
+		}
+	}
+}
+
+// checkDaemonSettings validates the settings shared by daemon and run and
+// fills in their defaults.
+func checkDaemonSettings() error {
+	if daemonSyncTargetAPIM == "" && (daemonSyncPrune || daemonSyncNoBackup) {
+		return fmt.Errorf("--sync-prune and --sync-no-backup require --sync-target-apim")
+	}
+	if daemonSyncTargetRG == "" {
+		daemonSyncTargetRG = daemonResourceGroup
+	}
+	// Render the template once up front, so a broken template fails at startup.
+	_, err := backup.RenderFileName(daemonNameTemplate, backup.NewNameData(daemonResourceGroup, daemonAPIMName, daemonProductID, time.Now()))
+	return err
+}
+
+// newDaemon sets up the logger and authenticates the clients of a daemon
+// running as command.
+func newDaemon(ctx context.Context, command string) (*daemon, error) {
+	logger, err := newLogger(daemonLogFormat)
+	if err != nil {
+		return nil, err
+	}
+	d := &daemon{command: command, log: logger.With("resourceGroup", daemonResourceGroup, "apimName", daemonAPIMName)}
+
+	d.insights, err = notify.AppInsightsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	d.source, err = azure.NewClient(ctx, daemonSubscription, daemonResourceGroup, daemonAPIMName)
+	if err != nil {
+		return nil, fmt.Errorf("authentication failed: %w", err)
+	}
+	if daemonSyncTargetAPIM != "" {
+		d.target, err = azure.NewClient(ctx, daemonSyncTargetSub, daemonSyncTargetRG, daemonSyncTargetAPIM)
+		if err != nil {
+			return nil, fmt.Errorf("authentication failed: %w", err)
+		}
+	}
+	return d, nil
+}
+
+// listen serves the Event Grid webhook endpoint on addr in the background and
+// signals changed when a delivery reports a subscription change of the
+// instance.
+func (d *daemon) listen(addr, token string, changed chan<- struct{}) (*http.Server, error) {
+	mux := http.NewServeMux()
+	mux.Handle("/events", &eventgrid.Handler{
+		Token: token,
+		OnEvents: func(events []eventgrid.Event) {
+			for _, e := range events {
+				if !eventgrid.ConcernsSubscriptions(e, daemonResourceGroup, daemonAPIMName) {
+					continue
+				}
+				d.log.Info("event received", "type", e.Type, "subject", e.Subject, "id", e.ID)
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			}
+		},
+	})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			d.log.Error("event endpoint failed", "error", err.Error())
+		}
+	}()
+	d.log.Info("event endpoint listening", "address", ln.Addr().String(), "path", "/events")
+	return srv, nil
+}
+
+// newLogger returns a logger writing to stdout in the given --log-format.
+func newLogger(format string) (*slog.Logger, error) {
+	switch format {
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stdout, nil)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(os.Stdout, nil)), nil
+	}
+	return nil, fmt.Errorf("invalid --log-format %q: must be json or text", format)
+}
+
+// runCycle takes a backup and runs the optional drift check and sync. Errors
+// are logged and also returned, so run can exit with a failure; the daemon
+// ignores them and keeps running.
+func (d *daemon) runCycle(ctx context.Context) (err error) {
+	start := time.Now()
+	log := d.log.With("cycle", start.UTC().Format(time.RFC3339))
+
+	var subs []azure.SubscriptionInfo
+	defer func() { d.track(log, start, len(subs), err) }()
+
+	subs, err = d.source.ListSubscriptions(ctx, daemonProductID)
+	if err != nil {
+		log.Error("cycle failed", "error", err.Error())
+		return err
+	}
+
+	var errs []error
+	if err := d.backup(log, subs, start); err != nil {
+		log.Error("backup failed", "error", err.Error())
+		errs = append(errs, fmt.Errorf("backup failed: %w", err))
+	}
+	if d.target != nil {
+		if err := d.sync(ctx, log, subs); err != nil {
+			log.Error("sync failed", "error", err.Error())
+			errs = append(errs, fmt.Errorf("sync failed: %w", err))
+		}
+	}
+
+	log.Info("cycle finished", "subscriptions", len(subs), "duration", time.Since(start).Round(time.Millisecond).String())
+	return errors.Join(errs...)
+}
+
+// track sends the result of a cycle to Application Insights as a
+// "kura.<command>" event, if a connection string is configured.
+func (d *daemon) track(log *slog.Logger, start time.Time, subscriptions int, cycleErr error) {
+	if d.insights == nil {
+		return
+	}
+	t := notify.Telemetry{
+		Name: "kura." + d.command,
+		Properties: map[string]string{
+			"command":       d.command,
+			"status":        "succeeded",
+			"resourceGroup": daemonResourceGroup,
+			"apimName":      daemonAPIMName,
+			"syncTarget":    daemonSyncTargetAPIM,
+		},
+		Measurements: map[string]float64{
+			"durationSeconds": time.Since(start).Seconds(),
+			"subscriptions":   float64(subscriptions),
+			"failures":        0,
+		},
+	}
+	if cycleErr != nil {
+		t.Properties["status"] = "failed"
+		t.Properties["error"] = cycleErr.Error()
+		t.Measurements["failures"] = 1
+	}
+	if err := d.insights.Track(context.Background(), t); err != nil {
+		log.Warn("failed to send telemetry", "error", err.Error())
+	}
+}
+
+// backup writes subs to a new backup file, unless the newest backup already
+// holds the same subscriptions. With --drift it first logs the differences to
+// that backup.
+func (d *daemon) backup(log *slog.Logger, subs []azure.SubscriptionInfo, now time.Time) error {
+	dir, err := backup.EnsureBackupDir(daemonResourceGroup, daemonAPIMName, daemonProductID)
+	if err != nil {
+		return err
+	}
+	previous, err := backup.LatestSnapshot(dir)
+	if err != nil {
+		return err
+	}
+
+	if daemonDrift && previous != "" {
+		prevSubs, err := backup.ReadFile(previous)
+		if err != nil {
+			return err
+		}
+		result := compareSubscriptions(prevSubs, subs, compareOptions{bidirectional: true, matchByName: true})
+		level := slog.LevelInfo
+		if result.Err() != nil {
+			level = slog.LevelWarn
+		}
+		log.Log(context.Background(), level, "drift checked",
+			"baseline", previous,
+			"changed", result.Summary.Mismatched,
+			"removed", result.Summary.Missing,
+			"added", result.Summary.Extra)
+	}
+
+	if backup.Unchanged(previous, subs) {
+		log.Info("backup skipped", "reason", "no changes since last snapshot", "previous", previous)
+		return nil
+	}
+
+	name, err := backup.RenderFileName(daemonNameTemplate, backup.NewNameData(daemonResourceGroup, daemonAPIMName, daemonProductID, now))
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, name)
+	file := &backup.File{
+		Metadata: backup.Metadata{
+			CreatedAt:      now.UTC(),
+			SubscriptionID: d.source.SubscriptionID(),
+			ResourceGroup:  daemonResourceGroup,
+			APIMName:       daemonAPIMName,
+			ProductID:      daemonProductID,
+		},
+		Subscriptions: subs,
+	}
+	if err := backup.Save(path, file); err != nil {
+		return err
+	}
+	log.Info("backup written", "path", path, "subscriptions", len(subs))
+	return nil
+}
+
+// sync makes the subscriptions of the sync target match subs.
+func (d *daemon) sync(ctx context.Context, log *slog.Logger, subs []azure.SubscriptionInfo) error {
+	log = log.With("syncTarget", daemonSyncTargetAPIM)
+
+	// Skip the sync while another run, such as a restore, changes the target.
+	lock, err := backup.AcquireLock(daemonSyncTargetRG, daemonSyncTargetAPIM, d.command)
+	if err != nil {
+		var locked *backup.LockedError
+		if errors.As(err, &locked) {
+			log.Warn("sync skipped, target is locked", "holder", locked.Holder.String())
+			return nil
+		}
+		return err
+	}
+	defer func() {
+		if err := lock.Release(); err != nil {
+			log.Warn("failed to release lock", "error", err.Error())
+		}
+	}()
+
+	live, err := d.target.ListSubscriptions(ctx, daemonProductID)
+	if err != nil {
+		return fmt.Errorf("failed to list target subscriptions: %w", err)
+	}
+	desired := retarget(subs, nil, d.target.SubscriptionID(), daemonSyncTargetRG, daemonSyncTargetAPIM)
+	plan := buildPlan(desired, live, daemonSyncPrune)
+
+	if deleted := plan.deleted(live); len(deleted) > 0 && !daemonSyncNoBackup {
+		path := backup.NewPreDeletePath(daemonSyncTargetRG, daemonSyncTargetAPIM, time.Now())
+		if err := backup.WriteSubscriptions(path, deleted); err != nil {
+			return err
+		}
+		log.Info("pre-delete backup written", "path", path, "subscriptions", len(deleted))
+	}
+
+	var failed int
+	for _, a := range plan.Actions {
+		if err := a.apply(ctx, d.target); err != nil {
+			log.Error("sync action failed", "action", a.Action, "sid", a.SID, "displayName", a.DisplayName, "error", err.Error())
+			failed++
+			continue
+		}
+		log.Info("sync action applied", "action", a.Action, "sid", a.SID, "displayName", a.DisplayName)
+	}
+	log.Info("sync finished",
+		"created", plan.count(actionCreate),
+		"updated", plan.count(actionUpdate),
+		"deleted", plan.count(actionDelete),
+		"unchanged", plan.Unchanged,
+		"failed", failed)
+	if failed > 0 {
+		return fmt.Errorf("%d sync action(s) failed", failed)
+	}
+	return nil
+}
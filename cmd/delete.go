@@ -2,25 +2,49 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
-	"github.com/f-marschall/apim-kura/internal/azure"
+	"github.com/f-marschall/apim-kura/internal/pool"
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/f-marschall/apim-kura/pkg/backup"
 	"github.com/spf13/cobra"
 )
 
 var deleteCmd = &cobra.Command{
-	Use:   "delete",
+	Use:   "delete [sid]",
+	Args:  cobra.MaximumNArgs(1),
 	Short: "Delete subscription keys from Azure API Management",
 	Long: `Delete removes subscription keys from an Azure API Management instance.
 
 By default, built-in subscriptions (e.g. the master key) are preserved.
 Use --all to include built-in subscriptions in the deletion.
 
+Before deleting, kura shows how many subscriptions will be removed and asks for
+confirmation. Use --yes (or --force) to skip the prompt in automation.
+
+The subscriptions are then saved, keys included, to a timestamped backup under
+backup/.pre-delete, so an accidental delete can be undone with
+'kura restore --input <file>'. Use --no-backup to skip this step.
+
+Pass a sid as argument or with --sid to delete exactly one subscription.
+Use --match to only delete subscriptions whose display name matches a regular
+expression, e.g. to clean up temporary subscriptions. Use --state to only
+delete subscriptions in the given states, e.g. to purge cancelled and expired
+subscriptions while leaving active keys untouched.
+
 Example:
   kura delete --resource-group mygroup --apim-name myapim
+  kura delete 0f1e2d3c -g mygroup -a myapim
+  kura delete -g mygroup -a myapim --sid 0f1e2d3c --dry-run
   kura delete -g mygroup -a myapim --product-id myproduct
+  kura delete -g mygroup -a myapim --api-id petstore
+  kura delete -g mygroup -a myapim --match '^loadtest-'
+  kura delete -g mygroup -a myapim --state cancelled,expired
   kura delete -g mygroup -a myapim --dry-run
-  kura delete -g mygroup -a myapim --all`,
+  kura delete -g mygroup -a myapim --all --yes`,
 	RunE: runDelete,
 }
 
@@ -29,8 +53,18 @@ var (
 	deleteAPIMName      string
 	deleteSubscription  string
 	deleteProductID     string
+	deleteAPIID         string
 	deleteDryRun        bool
 	deleteAll           bool
+	deleteSID           string
+	deleteMatch         string
+	deleteStates        []string
+	deleteYes           bool
+	deleteNoBackup      bool
+	deleteNotify        notifyFlags
+	deleteHooks         hookFlags
+	deleteConcurrency   int
+	deleteBreaker       breakerFlags
 )
 
 func init() {
@@ -40,14 +74,73 @@ func init() {
 	deleteCmd.Flags().StringVarP(&deleteAPIMName, "apim-name", "a", "", "Azure API Management instance name (required)")
 	deleteCmd.Flags().StringVarP(&deleteSubscription, "subscription", "s", "", "Azure subscription ID")
 	deleteCmd.Flags().StringVarP(&deleteProductID, "product-id", "p", "", "Only delete subscriptions scoped to this product")
+	deleteCmd.Flags().StringVar(&deleteAPIID, "api-id", "", "Only delete subscriptions scoped to this API")
 	deleteCmd.Flags().BoolVar(&deleteDryRun, "dry-run", false, "Preview deletions without applying them")
 	deleteCmd.Flags().BoolVar(&deleteAll, "all", false, "Delete all subscriptions including built-in ones")
+	deleteCmd.Flags().StringVar(&deleteSID, "sid", "", "Only delete the subscription with this sid")
+	deleteCmd.Flags().StringSliceVar(&deleteStates, "state", nil, "Only delete subscriptions in this state, e.g. cancelled, expired or suspended (repeatable)")
+	deleteCmd.Flags().StringVar(&deleteMatch, "match", "", "Only delete subscriptions whose display name matches this regular expression")
+
+	deleteCmd.Flags().IntVar(&deleteConcurrency, "concurrency", 1, "Number of subscriptions to delete in parallel")
+	deleteCmd.Flags().BoolVar(&deleteNoBackup, "no-backup", false, "Do not back up the subscriptions before deleting them")
+	registerYesFlags(deleteCmd, &deleteYes)
+	registerNotifyFlags(deleteCmd, &deleteNotify)
+	registerHookFlags(deleteCmd, &deleteHooks)
+	registerBreakerFlags(deleteCmd, &deleteBreaker)
+
+	deleteCmd.MarkFlagsMutuallyExclusive("product-id", "api-id")
 
 	deleteCmd.MarkFlagRequired("resource-group")
 	deleteCmd.MarkFlagRequired("apim-name")
 }
 
-func runDelete(cmd *cobra.Command, args []string) error {
+// parseDeleteStates validates the --state values and returns them as a set.
+func parseDeleteStates(values []string) (map[string]bool, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	valid := map[string]bool{
+		"active": true, "cancelled": true, "expired": true,
+		"rejected": true, "submitted": true, "suspended": true,
+	}
+	states := make(map[string]bool, len(values))
+	for _, value := range values {
+		if !valid[value] {
+			return nil, fmt.Errorf("invalid --state %q: must be one of active, cancelled, expired, rejected, submitted or suspended", value)
+		}
+		states[value] = true
+	}
+	return states, nil
+}
+
+func runDelete(cmd *cobra.Command, args []string) (err error) {
+	summary := newRunSummary("delete", deleteResourceGroup, deleteAPIMName, deleteSubscription)
+	summary.DryRun = deleteDryRun
+	defer func() { err = summary.send(deleteNotify, err) }()
+	if err := deleteHooks.runPre(summary); err != nil {
+		return err
+	}
+	defer func() { err = deleteHooks.runPost(summary, err) }()
+
+	sid := deleteSID
+	if len(args) == 1 {
+		if sid != "" && sid != args[0] {
+			return fmt.Errorf("sid given both as argument (%s) and with --sid (%s)", args[0], sid)
+		}
+		sid = args[0]
+	}
+	if sid != "" && (deleteProductID != "" || deleteAPIID != "" || deleteMatch != "" || len(deleteStates) > 0) {
+		return fmt.Errorf("a sid cannot be combined with --product-id, --api-id, --match or --state")
+	}
+	states, err := parseDeleteStates(deleteStates)
+	if err != nil {
+		return err
+	}
+	match, err := compileFlagRegex("match", deleteMatch)
+	if err != nil {
+		return err
+	}
+
 	fmt.Printf("Deleting subscription keys from APIM instance: %s\n", deleteAPIMName)
 	fmt.Printf("Resource Group: %s\n", deleteResourceGroup)
 
@@ -59,9 +152,24 @@ func runDelete(cmd *cobra.Command, args []string) error {
 		fmt.Printf("Product ID: %s\n", deleteProductID)
 	}
 
-	if deleteAll {
+	if deleteAPIID != "" {
+		fmt.Printf("API ID: %s\n", deleteAPIID)
+	}
+
+	if match != nil {
+		fmt.Printf("Display name matches: %s\n", deleteMatch)
+	}
+
+	if len(states) > 0 {
+		fmt.Printf("States: %s\n", strings.Join(deleteStates, ", "))
+	}
+
+	switch {
+	case sid != "":
+		fmt.Printf("Mode: Delete single subscription %s\n", sid)
+	case deleteAll:
 		fmt.Println("Mode: Delete ALL subscriptions (including built-in)")
-	} else {
+	default:
 		fmt.Println("Mode: Delete all subscriptions except built-in (master)")
 	}
 
@@ -69,7 +177,8 @@ func runDelete(cmd *cobra.Command, args []string) error {
 		fmt.Println("\nRunning in DRY-RUN mode. No changes will be applied.")
 	}
 
-	ctx := context.Background()
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
 	fmt.Println("\nAuthenticating with Azure CLI...")
 
 	client, err := azure.NewClient(ctx, deleteSubscription, deleteResourceGroup, deleteAPIMName)
@@ -78,10 +187,35 @@ func runDelete(cmd *cobra.Command, args []string) error {
 	}
 	fmt.Println("Successfully authenticated with Azure CLI")
 
-	fmt.Println("\nFetching subscriptions...")
-	subs, err := client.ListSubscriptions(ctx, deleteProductID)
-	if err != nil {
-		return fmt.Errorf("failed to list subscriptions: %w", err)
+	var subs []azure.SubscriptionInfo
+	if sid != "" {
+		sub, err := client.GetSubscription(ctx, sid)
+		if err != nil {
+			if azure.IsNotFound(err) {
+				return fmt.Errorf("subscription %s not found in APIM instance %s", sid, deleteAPIMName)
+			}
+			return err
+		}
+		subs = append(subs, *sub)
+	} else {
+		fmt.Println("\nFetching subscriptions...")
+		subs, err = client.ListSubscriptionsMatching(ctx, azure.SubscriptionFilter{ProductID: deleteProductID, APIID: deleteAPIID})
+		if err != nil {
+			return fmt.Errorf("failed to list subscriptions: %w", err)
+		}
+		if match != nil || len(states) > 0 {
+			var matched []azure.SubscriptionInfo
+			for _, sub := range subs {
+				if match != nil && !match.MatchString(sub.Properties.DisplayName) {
+					continue
+				}
+				if len(states) > 0 && !states[sub.Properties.State] {
+					continue
+				}
+				matched = append(matched, sub)
+			}
+			subs = matched
+		}
 	}
 
 	if len(subs) == 0 {
@@ -90,36 +224,121 @@ func runDelete(cmd *cobra.Command, args []string) error {
 	}
 	fmt.Printf("\nFound %d subscription(s)\n", len(subs))
 
-	var deleted, skipped, failed int
+	var targets []azure.SubscriptionInfo
 	for _, sub := range subs {
-		sid := sub.Name
-		displayName := sub.Properties.DisplayName
+		if deleteAll || sub.Name != "master" {
+			targets = append(targets, sub)
+		}
+	}
+
+	if !deleteDryRun && !deleteYes && len(targets) > 0 {
+		fmt.Println()
+		ok, err := confirm(fmt.Sprintf("Delete %d subscription(s) from APIM instance %s?", len(targets), deleteAPIMName))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println("Aborted. No subscriptions were deleted.")
+			return nil
+		}
+	}
+
+	if !deleteDryRun && !deleteNoBackup && len(targets) > 0 {
+		path, err := backupBeforeDelete(deleteResourceGroup, deleteAPIMName, targets)
+		if err != nil {
+			return err
+		}
+		summary.addFile("preDeleteBackup", path)
+	}
 
-		if !deleteAll && sid == "master" {
-			fmt.Printf("  [SKIP] %s (built-in)\n", displayName)
+	var deleted, skipped, failed int
+	var pending []azure.SubscriptionInfo
+	for _, sub := range subs {
+		if !deleteAll && sub.Name == "master" {
+			fmt.Printf("  [SKIP] %s (built-in)\n", sub.Properties.DisplayName)
 			skipped++
 			continue
 		}
-
 		if deleteDryRun {
-			fmt.Printf("  [DRY-RUN] Would delete: %s (id=%s)\n", displayName, sid)
+			fmt.Printf("  [DRY-RUN] Would delete: %s (id=%s)\n", sub.Properties.DisplayName, sub.Name)
 			deleted++
 			continue
 		}
+		pending = append(pending, sub)
+	}
 
-		fmt.Printf("  Deleting: %s (id=%s)...\n", displayName, sid)
-		if err := client.DeleteSubscription(ctx, sid); err != nil {
-			fmt.Printf("  [FAIL] %s: %v\n", displayName, err)
+	breaker := deleteBreaker.breaker()
+	deleteOne := func(ctx context.Context, sub azure.SubscriptionInfo) (struct{}, error) {
+		err := deleteUnchanged(ctx, client, sub)
+		if bErr := breaker.Record(err != nil && !azure.IsNotFound(err)); bErr != nil {
+			return struct{}{}, pool.Stop(fmt.Errorf("%w; %w", err, bErr))
+		}
+		return struct{}{}, err
+	}
+	runErr := pool.Run(ctx, deleteConcurrency, pending, deleteOne, func(res pool.Result[azure.SubscriptionInfo, struct{}]) {
+		sid, displayName := res.Item.Name, res.Item.Properties.DisplayName
+		switch {
+		case azure.IsNotFound(res.Err):
+			fmt.Printf("  [SKIP] %s (already deleted)\n", displayName)
+			skipped++
+		case res.Err != nil:
+			fmt.Printf("  [FAIL] %s: %v\n", displayName, res.Err)
+			summary.addFailure(sid, displayName, res.Err)
 			failed++
-			continue
+		default:
+			fmt.Printf("  [OK]   %s (id=%s)\n", displayName, sid)
+			deleted++
 		}
-		fmt.Printf("  [OK]   %s\n", displayName)
-		deleted++
+	})
+	if runErr != nil && ctx.Err() != nil {
+		return fmt.Errorf("delete interrupted: %w", runErr)
 	}
 
-	fmt.Printf("\nDelete complete: %d deleted, %d skipped, %d failed\n", deleted, skipped, failed)
+	status := "complete"
+	if errors.Is(runErr, pool.ErrTooManyFailures) {
+		status = "stopped"
+	}
+	fmt.Printf("\nDelete %s: %d deleted, %d skipped, %d failed\n", status, deleted, skipped, failed)
+	summary.Counts["deleted"] = deleted
+	summary.Counts["skipped"] = skipped
+	summary.Counts["failed"] = failed
+	if status == "stopped" {
+		return runErr
+	}
 	if failed > 0 {
 		return fmt.Errorf("%d subscription(s) failed to delete", failed)
 	}
 	return nil
 }
+
+// deleteUnchanged deletes sub unless it was changed since it was read, and so
+// since it was confirmed and backed up. Listed subscriptions carry no entity
+// tag, so they are read again and compared first; the delete is then
+// conditional on the entity tag of that read.
+func deleteUnchanged(ctx context.Context, client *azure.Client, sub azure.SubscriptionInfo) error {
+	if sub.ETag == "" {
+		live, err := client.GetSubscription(ctx, sub.Name)
+		if err != nil {
+			return err
+		}
+		etag := live.ETag
+		live.ETag = ""
+		if *live != sub {
+			return fmt.Errorf("%w: subscription %s was changed since it was read and was not deleted", azure.ErrConflict, sub.Name)
+		}
+		sub.ETag = etag
+	}
+	return client.DeleteSubscription(azure.WithIfMatch(ctx, sub.ETag), sub.Name)
+}
+
+// backupBeforeDelete saves subs, which must include their keys, to a new
+// pre-delete backup, so an accidental delete can be undone with restore. It
+// returns the path of the backup.
+func backupBeforeDelete(resourceGroup, apimName string, subs []azure.SubscriptionInfo) (string, error) {
+	path := backup.NewPreDeletePath(resourceGroup, apimName, time.Now())
+	if err := backup.WriteSubscriptions(path, subs); err != nil {
+		return "", fmt.Errorf("failed to write pre-delete backup: %w", err)
+	}
+	fmt.Printf("\nBackup of %d subscription(s) saved to %s\n", len(subs), path)
+	return path, nil
+}
@@ -0,0 +1,352 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/f-marschall/apim-kura/pkg/backup"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the environment kura runs in and suggest fixes",
+	Long: `Doctor checks that kura can work in the current environment: that the Azure
+CLI is installed and logged in, that it hands out valid credentials, that the
+Azure subscription is accessible and the local clock is in sync with Azure,
+and that the backup, lock and audit directories are writable.
+
+With --resource-group and --apim-name, doctor also checks that the APIM
+instance and its gateway are reachable and that the account has the role
+assignments kura needs on the instance.
+
+Every failed check prints how to fix it. Doctor exits with an error if any
+check failed; warnings do not fail the run.
+
+Example:
+  kura doctor
+  kura doctor --resource-group mygroup --apim-name myapim`,
+	Args: cobra.NoArgs,
+	RunE: runDoctor,
+}
+
+var (
+	doctorResourceGroup string
+	doctorAPIMName      string
+	doctorSubscription  string
+)
+
+// Clock skew above doctorSkewWarn is reported as a warning and above
+// doctorSkewFail as a failure, as Microsoft Entra ID rejects tokens that are
+// used more than a few minutes off.
+const (
+	doctorSkewWarn = time.Minute
+	doctorSkewFail = 5 * time.Minute
+)
+
+// doctorReadActions are the actions kura needs on an instance to back it up.
+var doctorReadActions = []string{
+	"Microsoft.ApiManagement/service/read",
+	"Microsoft.ApiManagement/service/subscriptions/read",
+	"Microsoft.ApiManagement/service/subscriptions/listSecrets/action",
+	"Microsoft.ApiManagement/service/products/read",
+	"Microsoft.ApiManagement/service/apis/read",
+	"Microsoft.ApiManagement/service/users/read",
+}
+
+// doctorWriteActions are the actions kura needs on an instance to restore,
+// sync, delete or rotate subscriptions.
+var doctorWriteActions = []string{
+	"Microsoft.ApiManagement/service/subscriptions/write",
+	"Microsoft.ApiManagement/service/subscriptions/delete",
+	"Microsoft.ApiManagement/service/subscriptions/regeneratePrimaryKey/action",
+	"Microsoft.ApiManagement/service/subscriptions/regenerateSecondaryKey/action",
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+
+	doctorCmd.Flags().StringVarP(&doctorResourceGroup, "resource-group", "g", "", "Azure resource group of the APIM instance to check")
+	doctorCmd.Flags().StringVarP(&doctorAPIMName, "apim-name", "a", "", "Azure API Management instance to check")
+	doctorCmd.Flags().StringVarP(&doctorSubscription, "subscription", "s", "", "Azure subscription ID (defaults to current CLI context)")
+
+	doctorCmd.MarkFlagsRequiredTogether("resource-group", "apim-name")
+}
+
+// doctorReport prints the results of the checks and counts failures and
+// warnings.
+type doctorReport struct {
+	failed int
+	warned int
+}
+
+func (r *doctorReport) ok(check, detail string) {
+	fmt.Printf("  [OK]   %s: %s\n", check, detail)
+}
+
+func (r *doctorReport) fail(check, detail string, fixes ...string) {
+	r.failed++
+	fmt.Printf("  [FAIL] %s: %s\n", check, detail)
+	printFixes(fixes)
+}
+
+func (r *doctorReport) warn(check, detail string, fixes ...string) {
+	r.warned++
+	fmt.Printf("  [WARNING] %s: %s\n", check, detail)
+	printFixes(fixes)
+}
+
+func (r *doctorReport) skip(check, reason string) {
+	fmt.Printf("  [SKIP] %s: %s\n", check, reason)
+}
+
+func printFixes(fixes []string) {
+	for _, fix := range fixes {
+		fmt.Printf("         Fix: %s\n", fix)
+	}
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	r := &doctorReport{}
+	instance := doctorAPIMName != ""
+
+	fmt.Println("Checking the Azure CLI...")
+	client := doctorAzure(ctx, r)
+	if instance {
+		fmt.Printf("\nChecking APIM instance %s (resource group %s)...\n", doctorAPIMName, doctorResourceGroup)
+		doctorInstance(ctx, r, client)
+	}
+
+	fmt.Println("\nChecking local directories...")
+	doctorDirectory(r, "Backup directory", backup.RootDir)
+	if dir := os.Getenv(backup.LockDirEnv); dir != "" {
+		doctorDirectory(r, "Lock directory", dir)
+	}
+	if !noAudit {
+		doctorDirectory(r, "Audit journal", filepath.Dir(auditFile))
+	}
+
+	if !instance {
+		fmt.Println("\nPass --resource-group and --apim-name to also check an APIM instance.")
+	}
+	fmt.Printf("\nDoctor summary: %d failed, %d warning(s)\n", r.failed, r.warned)
+	if r.failed > 0 {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("%d check(s) failed", r.failed)
+	}
+	return nil
+}
+
+// doctorAzure checks the Azure CLI, its credentials, the Azure subscription
+// and the clock. It returns a client for the instance checks, or nil if they
+// cannot run.
+func doctorAzure(ctx context.Context, r *doctorReport) *azure.Client {
+	const (
+		cliCheck   = "Azure CLI"
+		loginCheck = "Azure CLI login"
+		credCheck  = "Credentials"
+		subCheck   = "Azure subscription"
+		clockCheck = "Clock skew"
+	)
+
+	path, err := exec.LookPath("az")
+	if err != nil {
+		r.fail(cliCheck, "az not found on PATH", "install the Azure CLI: https://learn.microsoft.com/cli/azure/install-azure-cli")
+		for _, check := range []string{loginCheck, credCheck, subCheck, clockCheck} {
+			r.skip(check, "requires the Azure CLI")
+		}
+		return nil
+	}
+	r.ok(cliCheck, path)
+
+	account, err := azure.CurrentAccount()
+	if err != nil {
+		r.fail(loginCheck, err.Error(), "run 'az login' (or 'az login --use-device-code' on a machine without a browser)")
+		for _, check := range []string{credCheck, subCheck, clockCheck} {
+			r.skip(check, "requires an Azure CLI login")
+		}
+		return nil
+	}
+	r.ok(loginCheck, fmt.Sprintf("%s (%s) in tenant %s", account.User.Name, account.User.Type, account.TenantID))
+
+	client, err := azure.NewClient(ctx, doctorSubscription, doctorResourceGroup, doctorAPIMName)
+	if err != nil {
+		r.fail(credCheck, err.Error(), "run 'az login' again")
+		r.skip(subCheck, "requires valid credentials")
+		r.skip(clockCheck, "requires valid credentials")
+		return nil
+	}
+	expiry, err := client.TokenExpiry(ctx)
+	if err != nil {
+		r.fail(credCheck, err.Error(), "run 'az login' again to refresh the expired or revoked login")
+		r.skip(subCheck, "requires valid credentials")
+		r.skip(clockCheck, "requires valid credentials")
+		return nil
+	}
+	r.ok(credCheck, "token valid until "+expiry.Local().Format(time.RFC3339))
+
+	sub, err := client.GetAzureSubscription(ctx)
+	switch {
+	case err != nil && (azure.IsNotFound(err) || azure.IsForbidden(err)):
+		r.fail(subCheck, fmt.Sprintf("subscription %s is not accessible to %s", client.SubscriptionID(), account.User.Name),
+			"check --subscription, or select a subscription the account can access with 'az account set --subscription <id>'",
+			"list the accessible subscriptions with 'az account list -o table'")
+		r.skip(clockCheck, "requires an accessible subscription")
+		return nil
+	case err != nil:
+		r.fail(subCheck, err.Error(), "check the network connection to management.azure.com and any proxy settings (HTTPS_PROXY)")
+		r.skip(clockCheck, "requires an accessible subscription")
+		return nil
+	case !strings.EqualFold(sub.State, "Enabled"):
+		r.warn(subCheck, fmt.Sprintf("%s (%s) is %s", sub.DisplayName, client.SubscriptionID(), sub.State),
+			"the subscription must be enabled to change subscriptions of its instances")
+	default:
+		r.ok(subCheck, fmt.Sprintf("%s (%s)", sub.DisplayName, client.SubscriptionID()))
+	}
+
+	if sub.ServerTime.IsZero() {
+		r.skip(clockCheck, "Azure reported no time")
+		return client
+	}
+	skew := time.Since(sub.ServerTime).Round(time.Second)
+	abs := skew
+	if abs < 0 {
+		abs = -abs
+	}
+	clockFix := "synchronize the system clock, such as with 'timedatectl set-ntp true' on Linux or 'w32tm /resync' on Windows"
+	switch {
+	case abs > doctorSkewFail:
+		r.fail(clockCheck, fmt.Sprintf("local clock is %s off Azure", skew), clockFix)
+	case abs > doctorSkewWarn:
+		r.warn(clockCheck, fmt.Sprintf("local clock is %s off Azure", skew), clockFix)
+	default:
+		r.ok(clockCheck, fmt.Sprintf("%s off Azure", skew))
+	}
+	return client
+}
+
+// doctorInstance checks that the APIM instance and its gateway are reachable
+// and that the account has the role assignments kura needs on it.
+func doctorInstance(ctx context.Context, r *doctorReport, client *azure.Client) {
+	const (
+		instanceCheck = "APIM instance"
+		gatewayCheck  = "APIM gateway"
+		rbacCheck     = "RBAC roles"
+	)
+	if client == nil {
+		for _, check := range []string{instanceCheck, gatewayCheck, rbacCheck} {
+			r.skip(check, "requires working Azure credentials")
+		}
+		return
+	}
+	roleFix := fmt.Sprintf("ask an owner of the instance to assign the \"API Management Service Contributor\" role: az role assignment create --assignee <account> --role \"API Management Service Contributor\" --scope /subscriptions/%s/resourceGroups/%s/providers/Microsoft.ApiManagement/service/%s",
+		client.SubscriptionID(), doctorResourceGroup, doctorAPIMName)
+
+	svc, err := client.GetService(ctx)
+	switch {
+	case err != nil && azure.IsNotFound(err):
+		r.fail(instanceCheck, fmt.Sprintf("%s not found in resource group %s of subscription %s", doctorAPIMName, doctorResourceGroup, client.SubscriptionID()),
+			"check --resource-group, --apim-name and --subscription",
+			"list the instances with 'az apim list -o table'")
+		r.skip(gatewayCheck, "requires a reachable instance")
+	case err != nil && azure.IsForbidden(err):
+		r.fail(instanceCheck, "the account may not read the instance", roleFix)
+		r.skip(gatewayCheck, "requires a reachable instance")
+	case err != nil:
+		r.fail(instanceCheck, err.Error(), "check the network connection to management.azure.com and any proxy settings (HTTPS_PROXY)")
+		r.skip(gatewayCheck, "requires a reachable instance")
+	default:
+		detail := fmt.Sprintf("%s SKU in %s, provisioning state %s", svc.SKU, svc.Location, svc.ProvisioningState)
+		if svc.ProvisioningState != "Succeeded" {
+			r.warn(instanceCheck, detail, "wait until the instance finished updating before restoring or syncing it")
+		} else {
+			r.ok(instanceCheck, detail)
+		}
+		doctorGateway(ctx, r, gatewayCheck, svc.GatewayURL)
+	}
+
+	perms, err := client.Permissions(ctx)
+	if err != nil {
+		r.fail(rbacCheck, fmt.Sprintf("failed to read the permissions of the account: %v", err), roleFix)
+		return
+	}
+	var missingRead, missingWrite []string
+	for _, action := range doctorReadActions {
+		if !azure.HasPermission(perms, action) {
+			missingRead = append(missingRead, action)
+		}
+	}
+	for _, action := range doctorWriteActions {
+		if !azure.HasPermission(perms, action) {
+			missingWrite = append(missingWrite, action)
+		}
+	}
+	switch {
+	case len(missingRead) > 0:
+		r.fail(rbacCheck, "missing "+strings.Join(append(missingRead, missingWrite...), ", "), roleFix)
+	case len(missingWrite) > 0:
+		r.warn(rbacCheck, "backups work, but restore, sync, delete and rotate will fail: missing "+strings.Join(missingWrite, ", "), roleFix)
+	default:
+		r.ok(rbacCheck, "the account may read and change subscriptions")
+	}
+}
+
+// doctorGateway probes the health endpoint of the gateway. An unreachable
+// gateway is only a warning: kura talks to Azure Resource Manager, and
+// gateways in a virtual network are not reachable from everywhere.
+func doctorGateway(ctx context.Context, r *doctorReport, check, gatewayURL string) {
+	if gatewayURL == "" {
+		r.skip(check, "the instance has no gateway URL")
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(gatewayURL, "/")+"/status-0123456789abcdef", nil)
+	if err != nil {
+		r.warn(check, err.Error())
+		return
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		r.warn(check, fmt.Sprintf("%s is not reachable: %v", gatewayURL, err),
+			"clients calling the APIs need the gateway; if it is in a virtual network, run doctor from inside that network")
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		r.warn(check, fmt.Sprintf("%s reports status %s", gatewayURL, resp.Status), "check the health of the instance in the Azure portal")
+		return
+	}
+	r.ok(check, gatewayURL+" is healthy")
+}
+
+// doctorDirectory checks that files can be created in dir, creating dir if
+// it does not exist yet.
+func doctorDirectory(r *doctorReport, check, dir string) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		abs = dir
+	}
+	fix := fmt.Sprintf("run kura from a directory you can write to, or fix the permissions of %s", abs)
+	if err := os.MkdirAll(dir, backup.DirMode); err != nil {
+		r.fail(check, err.Error(), fix)
+		return
+	}
+	f, err := os.CreateTemp(dir, ".kura-doctor-*")
+	if err != nil {
+		r.fail(check, fmt.Sprintf("%s is not writable: %v", abs, err), fix)
+		return
+	}
+	f.Close()
+	os.Remove(f.Name())
+	r.ok(check, abs+" is writable")
+}
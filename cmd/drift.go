@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/f-marschall/apim-kura/internal/notify"
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/spf13/cobra"
+)
+
+var driftCmd = &cobra.Command{
+	Use:   "drift",
+	Short: "Report how an APIM instance diverged from a baseline backup",
+	Long: `Drift compares the live subscriptions of an Azure API Management instance
+with a baseline backup file and reports every divergence: subscriptions that
+are new since the baseline, subscriptions that were removed and subscriptions
+whose attributes or keys changed. Subscriptions are paired by sid. Keys are
+masked in the output.
+
+With --notify-url, kura POSTs the JSON report to that URL when drift is found,
+or after every check with --notify-always.
+
+The command exits with 0 if the instance matches the baseline, with 1 if it
+drifted and with 2 if the check could not run, which makes it easy to run as a
+scheduled compliance check.
+
+Example:
+  kura drift --resource-group mygroup --apim-name myapim --baseline backup.json
+  kura drift -g mygroup -a myapim --baseline backup.json --ignore-fields stateComment --output json
+  kura drift -g mygroup -a myapim --baseline backup.json --notify-url https://hooks.example.com/kura`,
+	Args: cobra.NoArgs,
+	RunE: runDrift,
+}
+
+var (
+	driftResourceGroup string
+	driftAPIMName      string
+	driftSubscription  string
+	driftProductID     string
+	driftBaseline      string
+	driftIgnoreFields  []string
+	driftOutput        string
+	driftNotifyURL     string
+	driftNotifyAlways  bool
+)
+
+func init() {
+	rootCmd.AddCommand(driftCmd)
+
+	driftCmd.Flags().StringVarP(&driftResourceGroup, "resource-group", "g", "", "Azure resource group name (required)")
+	driftCmd.Flags().StringVarP(&driftAPIMName, "apim-name", "a", "", "Azure API Management instance name (required)")
+	driftCmd.Flags().StringVarP(&driftSubscription, "subscription", "s", "", "Azure subscription ID")
+	driftCmd.Flags().StringVarP(&driftProductID, "product-id", "p", "", "Only check subscriptions scoped to this product")
+	driftCmd.Flags().StringVar(&driftBaseline, "baseline", "", "Backup file holding the expected subscriptions (required)")
+	driftCmd.Flags().StringSliceVar(&driftIgnoreFields, "ignore-fields", nil, "Comma-separated attributes to leave out of the check (e.g. createdDate,stateComment)")
+	driftCmd.Flags().StringVar(&driftOutput, "output", "text", "Output format: text or json")
+	driftCmd.Flags().StringVar(&driftNotifyURL, "notify-url", "", "POST the JSON report to this URL when drift is found")
+	driftCmd.Flags().BoolVar(&driftNotifyAlways, "notify-always", false, "With --notify-url, also notify when no drift is found")
+
+	driftCmd.MarkFlagRequired("resource-group")
+	driftCmd.MarkFlagRequired("apim-name")
+	driftCmd.MarkFlagRequired("baseline")
+}
+
+// errDriftFound is wrapped by the error drift returns when the instance
+// diverged from the baseline. It is reported with exit code 1 instead of 2.
+var errDriftFound = errors.New("drift found")
+
+// Kinds of driftItem.
+const (
+	driftAdded   = "added"
+	driftRemoved = "removed"
+	driftChanged = "changed"
+)
+
+// driftReport is the result of a drift check.
+type driftReport struct {
+	ResourceGroup string       `json:"resourceGroup"`
+	APIMName      string       `json:"apimName"`
+	Baseline      string       `json:"baseline"`
+	CheckedAt     time.Time    `json:"checkedAt"`
+	Summary       driftSummary `json:"summary"`
+	Drift         []driftItem  `json:"drift"`
+}
+
+// driftSummary counts the subscriptions of a driftReport, master excluded.
+type driftSummary struct {
+	Baseline  int `json:"baseline"`
+	Live      int `json:"live"`
+	Unchanged int `json:"unchanged"`
+	Added     int `json:"added"`
+	Removed   int `json:"removed"`
+	Changed   int `json:"changed"`
+}
+
+// driftItem is a subscription that was added, removed or changed since the baseline.
+type driftItem struct {
+	Change      string              `json:"change"`
+	SID         string              `json:"sid"`
+	DisplayName string              `json:"displayName"`
+	Differences []compareDifference `json:"differences,omitempty"`
+}
+
+// newDriftReport builds a drift report from the comparison of the baseline (A)
+// with the live subscriptions (B). Key values are masked.
+func newDriftReport(result *compareResult) *driftReport {
+	r := &driftReport{
+		Summary: driftSummary{
+			Baseline:  result.Summary.A,
+			Live:      result.Summary.B,
+			Unchanged: result.Summary.Matched,
+			Added:     result.Summary.Extra,
+			Removed:   result.Summary.Missing,
+			Changed:   result.Summary.Mismatched,
+		},
+		Drift: []driftItem{},
+	}
+	for _, item := range result.Subscriptions {
+		d := driftItem{SID: item.SID, DisplayName: item.DisplayName}
+		switch item.Status {
+		case compareStatusExtra:
+			d.Change = driftAdded
+		case compareStatusMissing:
+			d.Change = driftRemoved
+		case compareStatusDiff:
+			d.Change = driftChanged
+			for _, diff := range item.Differences {
+				if diff.Field == "primaryKey" || diff.Field == "secondaryKey" {
+					diff.Before, diff.After = maskKey(diff.Before.(string)), maskKey(diff.After.(string))
+				}
+				d.Differences = append(d.Differences, diff)
+			}
+		default:
+			continue
+		}
+		r.Drift = append(r.Drift, d)
+	}
+	return r
+}
+
+func runDrift(cmd *cobra.Command, args []string) error {
+	switch driftOutput {
+	case "text", "json":
+	default:
+		return fmt.Errorf("invalid --output %q: must be text or json", driftOutput)
+	}
+	if driftNotifyAlways && driftNotifyURL == "" {
+		return fmt.Errorf("--notify-always requires --notify-url")
+	}
+	ignoreFields, err := parseIgnoreFields(driftIgnoreFields)
+	if err != nil {
+		return err
+	}
+	text := driftOutput == "text"
+
+	if text {
+		fmt.Printf("Checking drift of APIM instance: %s\n", driftAPIMName)
+		fmt.Printf("Resource Group: %s\n", driftResourceGroup)
+		if driftSubscription != "" {
+			fmt.Printf("Subscription ID: %s\n", driftSubscription)
+		}
+		if driftProductID != "" {
+			fmt.Printf("Product ID: %s\n", driftProductID)
+		}
+		fmt.Printf("Baseline: %s\n", driftBaseline)
+	}
+
+	baseline, err := loadBackupFile(driftBaseline)
+	if err != nil {
+		return fmt.Errorf("failed to load baseline: %w", err)
+	}
+
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	client, err := azure.NewClient(ctx, driftSubscription, driftResourceGroup, driftAPIMName)
+	if err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	live, err := client.ListSubscriptions(ctx, driftProductID)
+	if err != nil {
+		return fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+
+	result := compareSubscriptions(baseline, live, compareOptions{
+		bidirectional: true,
+		matchByName:   true,
+		ignoreFields:  ignoreFields,
+	})
+	report := newDriftReport(result)
+	report.ResourceGroup = driftResourceGroup
+	report.APIMName = driftAPIMName
+	report.Baseline = driftBaseline
+	report.CheckedAt = time.Now().UTC()
+
+	if text {
+		report.printText()
+	} else if err := printJSON(report); err != nil {
+		return err
+	}
+
+	drifted := len(report.Drift) > 0
+	if driftNotifyURL != "" && (drifted || driftNotifyAlways) {
+		if err := notify.Post(ctx, driftNotifyURL, report); err != nil {
+			return err
+		}
+		if text {
+			fmt.Printf("Notification sent to %s\n", driftNotifyURL)
+		}
+	}
+
+	if !drifted {
+		return nil
+	}
+	cmd.SilenceUsage = true
+	return fmt.Errorf("%w: %d subscription(s) added, removed or changed", errDriftFound, len(report.Drift))
+}
+
+// printText prints one line per drifted subscription followed by a summary.
+func (r *driftReport) printText() {
+	fmt.Println()
+	for _, d := range r.Drift {
+		switch d.Change {
+		case driftAdded:
+			fmt.Printf("  [NEW]     %s (sid=%s)\n", d.DisplayName, d.SID)
+		case driftRemoved:
+			fmt.Printf("  [REMOVED] %s (sid=%s)\n", d.DisplayName, d.SID)
+		case driftChanged:
+			fmt.Printf("  [CHANGED] %s (sid=%s)\n", d.DisplayName, d.SID)
+			for _, diff := range d.Differences {
+				fmt.Printf("      %s: %#v -> %#v\n", diff.Field, diff.Before, diff.After)
+			}
+		}
+	}
+	if len(r.Drift) == 0 {
+		fmt.Println("No drift found. The instance matches the baseline.")
+	}
+	fmt.Printf("\nDrift check complete: %d added, %d removed, %d changed, %d unchanged\n",
+		r.Summary.Added, r.Summary.Removed, r.Summary.Changed, r.Summary.Unchanged)
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+)
+
+func init() {
+	rootCmd.AddCommand(newConfigCommand(configKind[azure.EmailTemplateInfo]{
+		use:    "email-templates",
+		noun:   "email template",
+		plural: "email templates",
+		short:  "Back up and restore the email templates of an Azure API Management instance",
+		long: `Email-templates backs up and restores the notification email templates of an
+Azure API Management instance, such as the ones sent when developers sign up
+or subscriptions are approved.
+
+Only customized templates are backed up: templates with their default content
+are the same in every instance. Restore replaces the subject and body of each
+template in the file.`,
+		list: func(ctx context.Context, client *azure.Client) ([]azure.EmailTemplateInfo, error) {
+			return client.ListEmailTemplates(ctx)
+		},
+		put: func(ctx context.Context, client *azure.Client, tmpl azure.EmailTemplateInfo) error {
+			return client.PutEmailTemplate(ctx, tmpl)
+		},
+		name: func(tmpl azure.EmailTemplateInfo) string {
+			return tmpl.ID
+		},
+	}))
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/f-marschall/apim-kura/internal/emulator"
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/spf13/cobra"
+)
+
+var emulatorCmd = &cobra.Command{
+	Use:   "emulator",
+	Short: "Serve an in-memory fake of Azure API Management",
+	Long: `Emulator serves a fake of the Azure API Management subscription endpoints
+that kura uses, keeping all data in memory. Point other kura commands at it
+with --endpoint (or KURA_ENDPOINT) to try them, or to run end-to-end tests,
+without an Azure subscription. No Azure login is needed with --endpoint.
+
+Any resource group and instance name can be used; an instance starts empty on
+first use. With --seed, the instance given by --resource-group and --apim-name
+starts with the subscriptions of a backup file instead. Products and APIs are
+derived from the scopes of the subscriptions. All data is lost when the
+emulator stops.
+
+Example:
+  kura emulator --seed backup.json -g mygroup -a myapim
+  kura list --endpoint http://127.0.0.1:8780 -g mygroup -a myapim`,
+	Args: cobra.NoArgs,
+	RunE: runEmulator,
+}
+
+var (
+	emulatorListen        string
+	emulatorSeed          string
+	emulatorResourceGroup string
+	emulatorAPIMName      string
+	emulatorSubscription  string
+)
+
+func init() {
+	rootCmd.AddCommand(emulatorCmd)
+
+	emulatorCmd.Flags().StringVar(&emulatorListen, "listen", "127.0.0.1:8780", "Address to serve the emulator on")
+	emulatorCmd.Flags().StringVar(&emulatorSeed, "seed", "", "Backup file with the initial subscriptions of the instance given by --resource-group and --apim-name")
+	emulatorCmd.Flags().StringVarP(&emulatorResourceGroup, "resource-group", "g", "", "Resource group of the seeded instance")
+	emulatorCmd.Flags().StringVarP(&emulatorAPIMName, "apim-name", "a", "", "Name of the seeded instance")
+	emulatorCmd.Flags().StringVarP(&emulatorSubscription, "subscription", "s", azure.EmulatorSubscriptionID, "Azure subscription ID of the seeded instance")
+}
+
+func runEmulator(cmd *cobra.Command, args []string) error {
+	server := emulator.New()
+	if emulatorSeed != "" {
+		if emulatorResourceGroup == "" || emulatorAPIMName == "" {
+			return fmt.Errorf("--seed requires --resource-group and --apim-name")
+		}
+		subs, err := loadBackupFile(emulatorSeed)
+		if err != nil {
+			return fmt.Errorf("failed to load seed file: %w", err)
+		}
+		server.Seed(emulatorSubscription, emulatorResourceGroup, emulatorAPIMName, subs)
+		fmt.Printf("Seeded %s/%s with %d subscription(s)\n", emulatorResourceGroup, emulatorAPIMName, len(subs))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ln, err := net.Listen("tcp", emulatorListen)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", emulatorListen, err)
+	}
+	srv := &http.Server{Handler: server, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+
+	fmt.Printf("Emulator listening on http://%s\n", ln.Addr())
+	fmt.Printf("Use it with: kura <command> --endpoint http://%s\n", ln.Addr())
+	if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	fmt.Println("Emulator stopped")
+	return nil
+}
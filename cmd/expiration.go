@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/spf13/cobra"
+)
+
+var setExpirationCmd = &cobra.Command{
+	Use:   "set-expiration [sid]",
+	Short: "Set, extend or clear the expiration date of subscriptions",
+	Long: `Set-expiration manages the expirationDate of subscriptions in an Azure API
+Management instance.
+
+Use --date to set a fixed date, --extend to move the current expiration date
+(or now, if there is none) forward by a duration, or --clear to remove the
+expiration date.
+
+Pass a sid to change a single subscription. To change several subscriptions at
+once, select them with --all, --product-id or the selection flags (--sid,
+--name, --sid-regex, --name-regex and their --exclude variants). The built-in
+master subscription is never changed in bulk.
+
+Example:
+  kura set-expiration 0f1e2d3c -g mygroup -a myapim --date 2025-12-31
+  kura set-expiration -g mygroup -a myapim --product-id starter --extend 90d
+  kura set-expiration 0f1e2d3c -g mygroup -a myapim --clear`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runSetExpiration,
+}
+
+var (
+	expirationResourceGroup string
+	expirationAPIMName      string
+	expirationSubscription  string
+	expirationDate          string
+	expirationExtend        string
+	expirationClear         bool
+	expirationDryRun        bool
+	expirationTargets       targetFlags
+)
+
+func init() {
+	rootCmd.AddCommand(setExpirationCmd)
+
+	setExpirationCmd.Flags().StringVarP(&expirationResourceGroup, "resource-group", "g", "", "Azure resource group name (required)")
+	setExpirationCmd.Flags().StringVarP(&expirationAPIMName, "apim-name", "a", "", "Azure API Management instance name (required)")
+	setExpirationCmd.Flags().StringVarP(&expirationSubscription, "subscription", "s", "", "Azure subscription ID")
+	setExpirationCmd.Flags().StringVar(&expirationDate, "date", "", "Set the expiration date (2006-01-02 or an RFC 3339 timestamp)")
+	setExpirationCmd.Flags().StringVar(&expirationExtend, "extend", "", "Extend the expiration date by a duration (e.g. 90d or 720h)")
+	setExpirationCmd.Flags().BoolVar(&expirationClear, "clear", false, "Remove the expiration date")
+	setExpirationCmd.Flags().BoolVar(&expirationDryRun, "dry-run", false, "Preview the changes without applying them")
+	expirationTargets.register(setExpirationCmd, "change")
+
+	setExpirationCmd.MarkFlagsOneRequired("date", "extend", "clear")
+	setExpirationCmd.MarkFlagsMutuallyExclusive("date", "extend", "clear")
+	setExpirationCmd.MarkFlagRequired("resource-group")
+	setExpirationCmd.MarkFlagRequired("apim-name")
+}
+
+// parseExpirationDate parses a plain date (midnight UTC) or an RFC 3339 timestamp.
+func parseExpirationDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --date %q: expected a date (2006-01-02) or RFC 3339 timestamp", value)
+}
+
+// parseDays parses the value of flag as a Go duration, or a whole number of
+// days such as "90d". The duration must be positive.
+func parseDays(flag, value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	} else if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return d, nil
+	}
+	return 0, fmt.Errorf("invalid --%s %q: expected a positive duration such as 90d or 720h", flag, value)
+}
+
+func runSetExpiration(cmd *cobra.Command, args []string) error {
+	if err := expirationTargets.validate(args); err != nil {
+		return err
+	}
+
+	var date time.Time
+	var extension time.Duration
+	var err error
+	switch {
+	case expirationDate != "":
+		date, err = parseExpirationDate(expirationDate)
+	case expirationExtend != "":
+		extension, err = parseDays("extend", expirationExtend)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Updating expiration dates in APIM instance: %s\n", expirationAPIMName)
+	fmt.Printf("Resource Group: %s\n", expirationResourceGroup)
+	if expirationSubscription != "" {
+		fmt.Printf("Subscription ID: %s\n", expirationSubscription)
+	}
+	if expirationTargets.productID != "" {
+		fmt.Printf("Product ID: %s\n", expirationTargets.productID)
+	}
+
+	if expirationDryRun {
+		fmt.Println("\nRunning in DRY-RUN mode. No changes will be applied.")
+	}
+
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	fmt.Println("\nAuthenticating with Azure CLI...")
+
+	client, err := azure.NewClient(ctx, expirationSubscription, expirationResourceGroup, expirationAPIMName)
+	if err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	fmt.Println("Successfully authenticated with Azure CLI")
+
+	subs, err := expirationTargets.resolve(ctx, client, args)
+	if err != nil {
+		return err
+	}
+
+	if len(subs) == 0 {
+		fmt.Println("No subscriptions found. Nothing to change.")
+		return nil
+	}
+	fmt.Printf("\nFound %d subscription(s)\n", len(subs))
+
+	now := time.Now().UTC()
+	var changed, skipped, failed int
+	for _, sub := range subs {
+		sid := sub.Name
+		displayName := sub.Properties.DisplayName
+		current := sub.Properties.ExpirationDate
+
+		before := current
+		if before == "" {
+			before = "none"
+		}
+
+		if expirationClear {
+			if current == "" {
+				fmt.Printf("  [SKIP] %s (no expiration date)\n", displayName)
+				skipped++
+				continue
+			}
+			if expirationDryRun {
+				fmt.Printf("  [DRY-RUN] Would clear: %s (sid=%s) %s -> none\n", displayName, sid, before)
+				changed++
+				continue
+			}
+			if _, err := client.ClearExpirationDate(ctx, sid); err != nil {
+				fmt.Printf("  [FAIL] %s: %v\n", displayName, err)
+				failed++
+				continue
+			}
+			fmt.Printf("  [OK]   %s (sid=%s) %s -> none\n", displayName, sid, before)
+			changed++
+			continue
+		}
+
+		target := date
+		if extension > 0 {
+			// Extend from the current expiration date, or from now if there is none.
+			base := now
+			if current != "" {
+				parsed, err := time.Parse(time.RFC3339, current)
+				if err != nil {
+					fmt.Printf("  [FAIL] %s: cannot parse current expiration date %q: %v\n", displayName, current, err)
+					failed++
+					continue
+				}
+				base = parsed
+			}
+			target = base.Add(extension)
+		}
+		after := target.Format("2006-01-02T15:04:05Z")
+
+		if expirationDryRun {
+			fmt.Printf("  [DRY-RUN] Would change: %s (sid=%s) %s -> %s\n", displayName, sid, before, after)
+			changed++
+			continue
+		}
+		if _, err := client.SetExpirationDate(ctx, sid, target); err != nil {
+			fmt.Printf("  [FAIL] %s: %v\n", displayName, err)
+			failed++
+			continue
+		}
+		fmt.Printf("  [OK]   %s (sid=%s) %s -> %s\n", displayName, sid, before, after)
+		changed++
+	}
+
+	fmt.Printf("\nComplete: %d changed, %d skipped, %d failed\n", changed, skipped, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d subscription(s) failed to update", failed)
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/spf13/cobra"
+)
+
+var expiringCmd = &cobra.Command{
+	Use:   "expiring",
+	Short: "Report subscriptions that expire soon",
+	Long: `Expiring reports the subscriptions of an Azure API Management instance whose
+expiration date falls within the given window, sorted by expiration date and
+with the name and email of their owners, so they can be contacted in time.
+
+The command exits with 0 if no subscription expires within the window, with 1
+if some do and with 2 if the report could not be created, which makes it easy
+to run from a scheduled job.
+
+Example:
+  kura expiring --resource-group mygroup --apim-name myapim
+  kura expiring -g mygroup -a myapim --within 7d --product-id starter
+  kura expiring -g mygroup -a myapim --within 90d --output json`,
+	Args: cobra.NoArgs,
+	RunE: runExpiring,
+}
+
+var (
+	expiringResourceGroup string
+	expiringAPIMName      string
+	expiringSubscription  string
+	expiringProductID     string
+	expiringWithin        string
+	expiringOutput        string
+)
+
+func init() {
+	rootCmd.AddCommand(expiringCmd)
+
+	expiringCmd.Flags().StringVarP(&expiringResourceGroup, "resource-group", "g", "", "Azure resource group name (required)")
+	expiringCmd.Flags().StringVarP(&expiringAPIMName, "apim-name", "a", "", "Azure API Management instance name (required)")
+	expiringCmd.Flags().StringVarP(&expiringSubscription, "subscription", "s", "", "Azure subscription ID")
+	expiringCmd.Flags().StringVarP(&expiringProductID, "product-id", "p", "", "Only report subscriptions scoped to this product")
+	expiringCmd.Flags().StringVar(&expiringWithin, "within", "30d", "Report subscriptions expiring within this window (e.g. 30d or 72h)")
+	expiringCmd.Flags().StringVar(&expiringOutput, "output", "text", "Output format: text or json")
+
+	expiringCmd.MarkFlagRequired("resource-group")
+	expiringCmd.MarkFlagRequired("apim-name")
+}
+
+// errExpiringFound is wrapped by the error expiring returns when subscriptions
+// expire within the window. It is reported with exit code 1 instead of 2.
+var errExpiringFound = errors.New("subscriptions expiring")
+
+// expiringItem is a subscription that expires within the window.
+type expiringItem struct {
+	SID            string    `json:"sid"`
+	DisplayName    string    `json:"displayName"`
+	Scope          string    `json:"scope"`
+	State          string    `json:"state"`
+	ExpirationDate time.Time `json:"expirationDate"`
+	DaysLeft       int       `json:"daysLeft"`
+	OwnerID        string    `json:"ownerId,omitempty"`
+	OwnerName      string    `json:"ownerName,omitempty"`
+	OwnerEmail     string    `json:"ownerEmail,omitempty"`
+}
+
+func runExpiring(cmd *cobra.Command, args []string) error {
+	switch expiringOutput {
+	case "text", "json":
+	default:
+		return fmt.Errorf("invalid --output %q: must be text or json", expiringOutput)
+	}
+	within, err := parseDays("within", expiringWithin)
+	if err != nil {
+		return err
+	}
+	text := expiringOutput == "text"
+
+	if text {
+		fmt.Printf("Checking expiration dates in APIM instance: %s\n", expiringAPIMName)
+		fmt.Printf("Resource Group: %s\n", expiringResourceGroup)
+		if expiringSubscription != "" {
+			fmt.Printf("Subscription ID: %s\n", expiringSubscription)
+		}
+		if expiringProductID != "" {
+			fmt.Printf("Product ID: %s\n", expiringProductID)
+		}
+		fmt.Printf("Window: %s\n", expiringWithin)
+	}
+
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	client, err := azure.NewClient(ctx, expiringSubscription, expiringResourceGroup, expiringAPIMName)
+	if err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+
+	subs, err := client.ListSubscriptionsWithoutKeys(ctx, expiringProductID)
+	if err != nil {
+		return fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+
+	now := time.Now().UTC()
+	var matches []azure.SubscriptionInfo
+	var dates []time.Time
+	for _, sub := range subs {
+		if sub.Name == "master" || sub.Properties.ExpirationDate == "" {
+			continue
+		}
+		date, err := time.Parse(time.RFC3339, sub.Properties.ExpirationDate)
+		if err != nil {
+			return fmt.Errorf("subscription %s has an invalid expiration date %q: %w", sub.Name, sub.Properties.ExpirationDate, err)
+		}
+		if date.Before(now) || date.After(now.Add(within)) {
+			continue
+		}
+		matches = append(matches, sub)
+		dates = append(dates, date)
+	}
+
+	if err := client.ResolveOwners(ctx, matches); err != nil {
+		return fmt.Errorf("failed to resolve owners: %w", err)
+	}
+
+	expiring := make([]expiringItem, len(matches))
+	for i, sub := range matches {
+		expiring[i] = expiringItem{
+			SID:            sub.Name,
+			DisplayName:    sub.Properties.DisplayName,
+			Scope:          extractScopeSuffix(sub.Properties.Scope),
+			State:          sub.Properties.State,
+			ExpirationDate: dates[i].UTC(),
+			DaysLeft:       int(math.Floor(dates[i].Sub(now).Hours() / 24)),
+			OwnerID:        sub.Properties.OwnerID,
+			OwnerName:      sub.Properties.OwnerName,
+			OwnerEmail:     sub.Properties.OwnerEmail,
+		}
+	}
+	sort.SliceStable(expiring, func(i, j int) bool {
+		return expiring[i].ExpirationDate.Before(expiring[j].ExpirationDate)
+	})
+
+	if text {
+		if err := printExpiring(expiring); err != nil {
+			return err
+		}
+	} else if err := printJSON(expiring); err != nil {
+		return err
+	}
+
+	if len(expiring) == 0 {
+		return nil
+	}
+	cmd.SilenceUsage = true
+	return fmt.Errorf("%w: %d subscription(s) expire within %s", errExpiringFound, len(expiring), expiringWithin)
+}
+
+// printExpiring prints the expiring subscriptions as a table.
+func printExpiring(expiring []expiringItem) error {
+	if len(expiring) == 0 {
+		fmt.Println("\nNo subscriptions expire within the window.")
+		return nil
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "EXPIRES\tDAYS LEFT\tSID\tDISPLAY NAME\tSTATE\tSCOPE\tOWNER")
+	for _, e := range expiring {
+		scope := e.Scope
+		if scope == "" {
+			scope = "(instance)"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\n",
+			e.ExpirationDate.Format("2006-01-02T15:04:05Z"), e.DaysLeft,
+			e.SID, e.DisplayName, e.State, scope, formatOwner(e.OwnerID, e.OwnerName, e.OwnerEmail))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n%d subscription(s) expire within %s\n", len(expiring), expiringWithin)
+	return nil
+}
+
+// formatOwner returns "Name <email>" for a resolved owner, the user ID for an
+// unresolved one and "-" if there is no owner.
+func formatOwner(ownerID, name, email string) string {
+	switch {
+	case name != "" && email != "":
+		return fmt.Sprintf("%s <%s>", name, email)
+	case email != "":
+		return email
+	case name != "":
+		return name
+	case ownerID != "":
+		return azure.OwnerUserID(ownerID)
+	}
+	return "-"
+}
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/f-marschall/apim-kura/pkg/backup"
+	"github.com/spf13/cobra"
+)
+
+// filterFlags holds the raw values of the subscription selection flags shared
+// by commands that operate on a subset of subscriptions.
+type filterFlags struct {
+	sids         []string
+	names        []string
+	sidRegex     string
+	nameRegex    string
+	excludeSIDs  []string
+	excludeNames []string
+	excludeRegex string
+}
+
+// register adds the selection flags to cmd.
+func (f *filterFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&f.sids, "sid", nil, "Only include subscriptions with this sid (repeatable)")
+	cmd.Flags().StringSliceVar(&f.names, "name", nil, "Only include subscriptions with this display name (repeatable)")
+	cmd.Flags().StringVar(&f.sidRegex, "sid-regex", "", "Only include subscriptions whose sid matches this regular expression")
+	cmd.Flags().StringVar(&f.nameRegex, "name-regex", "", "Only include subscriptions whose display name matches this regular expression")
+	cmd.Flags().StringSliceVar(&f.excludeSIDs, "exclude-sid", nil, "Exclude subscriptions with this sid (repeatable)")
+	cmd.Flags().StringSliceVar(&f.excludeNames, "exclude-name", nil, "Exclude subscriptions with this display name (repeatable)")
+	cmd.Flags().StringVar(&f.excludeRegex, "exclude-regex", "", "Exclude subscriptions whose sid or display name matches this regular expression")
+}
+
+// build compiles the flag values into a backup.Filter.
+func (f *filterFlags) build() (*backup.Filter, error) {
+	filter := &backup.Filter{
+		SIDs:         f.sids,
+		Names:        f.names,
+		ExcludeSIDs:  f.excludeSIDs,
+		ExcludeNames: f.excludeNames,
+	}
+
+	var err error
+	if filter.SIDPattern, err = compileFlagRegex("sid-regex", f.sidRegex); err != nil {
+		return nil, err
+	}
+	if filter.NamePattern, err = compileFlagRegex("name-regex", f.nameRegex); err != nil {
+		return nil, err
+	}
+	if filter.ExcludePattern, err = compileFlagRegex("exclude-regex", f.excludeRegex); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+func compileFlagRegex(flag, expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --%s: %w", flag, err)
+	}
+	return re, nil
+}
+
+// targetFlags selects the live subscriptions a command changes: a single sid
+// given as argument, or several at once with --all, --product-id and the
+// selection flags. The built-in master subscription is never selected in bulk.
+type targetFlags struct {
+	productID string
+	all       bool
+	filters   filterFlags
+
+	filter *backup.Filter
+	bulk   bool
+}
+
+// register adds the bulk selection flags to cmd. verb describes what the
+// command does to the selected subscriptions, e.g. "change".
+func (t *targetFlags) register(cmd *cobra.Command, verb string) {
+	cmd.Flags().StringVarP(&t.productID, "product-id", "p", "", "Only "+verb+" subscriptions scoped to this product")
+	cmd.Flags().BoolVar(&t.all, "all", false, "Select all subscriptions except the built-in master subscription")
+	t.filters.register(cmd)
+}
+
+// validate checks that args and the flags select subscriptions in exactly one way.
+func (t *targetFlags) validate(args []string) error {
+	filter, err := t.filters.build()
+	if err != nil {
+		return err
+	}
+	t.filter = filter
+	t.bulk = t.all || t.productID != "" || !filter.IsEmpty()
+
+	if len(args) == 1 && t.bulk {
+		return fmt.Errorf("a sid cannot be combined with --all, --product-id or selection flags")
+	}
+	if len(args) == 0 && !t.bulk {
+		return fmt.Errorf("provide a sid, or select subscriptions with --all, --product-id or selection flags")
+	}
+	return nil
+}
+
+// resolve fetches the selected subscriptions. It must be called after validate.
+// Subscriptions selected in bulk include their keys; a single sid does not.
+func (t *targetFlags) resolve(ctx context.Context, client azure.SubscriptionStore, args []string) ([]azure.SubscriptionInfo, error) {
+	if !t.bulk {
+		sub, err := client.GetSubscriptionWithoutKeys(ctx, args[0])
+		if err != nil {
+			return nil, err
+		}
+		return []azure.SubscriptionInfo{*sub}, nil
+	}
+
+	fmt.Println("\nFetching subscriptions...")
+	all, err := client.ListSubscriptions(ctx, t.productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+	var subs []azure.SubscriptionInfo
+	for _, sub := range t.filter.Apply(all) {
+		if sub.Name != "master" {
+			subs = append(subs, sub)
+		}
+	}
+	return subs, nil
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/spf13/cobra"
+)
+
+var findKeyCmd = &cobra.Command{
+	Use:   "find-key <key>",
+	Short: "Find the subscription a key belongs to",
+	Long: `Find-key looks up which subscription a key belongs to, for example when a
+key has leaked. It fetches the keys of all subscriptions of the instance and
+reports the sid, display name, scope, state and owner of every subscription
+whose primary or secondary key matches.
+
+Pass - as key to read it from stdin, so it does not end up in the shell
+history. Use --suspend to suspend the matching subscriptions right away.
+
+Example:
+  kura find-key -g mygroup -a myapim 3f6c1a9e2b7d4c08a1e5f9b2c7d3e6a4
+  echo "$LEAKED_KEY" | kura find-key -g mygroup -a myapim - --suspend`,
+	Args: cobra.ExactArgs(1),
+	RunE: runFindKey,
+}
+
+var (
+	findKeyResourceGroup string
+	findKeyAPIMName      string
+	findKeySubscription  string
+	findKeySuspend       bool
+)
+
+func init() {
+	rootCmd.AddCommand(findKeyCmd)
+
+	findKeyCmd.Flags().StringVarP(&findKeyResourceGroup, "resource-group", "g", "", "Azure resource group name (required)")
+	findKeyCmd.Flags().StringVarP(&findKeyAPIMName, "apim-name", "a", "", "Azure API Management instance name (required)")
+	findKeyCmd.Flags().StringVarP(&findKeySubscription, "subscription", "s", "", "Azure subscription ID")
+	findKeyCmd.Flags().BoolVar(&findKeySuspend, "suspend", false, "Suspend the subscriptions the key belongs to")
+
+	findKeyCmd.MarkFlagRequired("resource-group")
+	findKeyCmd.MarkFlagRequired("apim-name")
+}
+
+func runFindKey(cmd *cobra.Command, args []string) error {
+	key := args[0]
+	if key == "-" {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("failed to read key from stdin: %w", err)
+		}
+		key = line
+	}
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return fmt.Errorf("key must not be empty")
+	}
+
+	fmt.Printf("Searching subscription keys in APIM instance: %s\n", findKeyAPIMName)
+	fmt.Printf("Resource Group: %s\n", findKeyResourceGroup)
+	if findKeySubscription != "" {
+		fmt.Printf("Subscription ID: %s\n", findKeySubscription)
+	}
+
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	fmt.Println("\nAuthenticating with Azure CLI...")
+
+	client, err := azure.NewClient(ctx, findKeySubscription, findKeyResourceGroup, findKeyAPIMName)
+	if err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	fmt.Println("Successfully authenticated with Azure CLI")
+
+	fmt.Println("\nFetching subscriptions...")
+	subs, err := client.ListSubscriptions(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+	fmt.Printf("Searching %d subscription(s)\n", len(subs))
+
+	var matches []azure.SubscriptionInfo
+	var matchedKeys []string
+	for _, sub := range subs {
+		switch key {
+		case sub.Properties.PrimaryKey:
+			matches = append(matches, sub)
+			matchedKeys = append(matchedKeys, "primary")
+		case sub.Properties.SecondaryKey:
+			matches = append(matches, sub)
+			matchedKeys = append(matchedKeys, "secondary")
+		}
+	}
+	if len(matches) == 0 {
+		return fmt.Errorf("key does not belong to any subscription in APIM instance %s", findKeyAPIMName)
+	}
+
+	if err := client.ResolveOwners(ctx, matches); err != nil {
+		fmt.Printf("  [WARNING] failed to resolve owners: %v\n", err)
+	}
+
+	fmt.Printf("\nKey found in %d subscription(s):\n", len(matches))
+	for i, sub := range matches {
+		scope := extractScopeSuffix(sub.Properties.Scope)
+		if scope == "" {
+			scope = "(instance)"
+		}
+		fmt.Printf("\n[%d] %s\n", i+1, sub.Properties.DisplayName)
+		fmt.Printf("    Sid:          %s\n", sub.Name)
+		fmt.Printf("    Matched Key:  %s\n", matchedKeys[i])
+		fmt.Printf("    Scope:        %s\n", scope)
+		fmt.Printf("    State:        %s\n", sub.Properties.State)
+		fmt.Printf("    Owner ID:     %s\n", sub.Properties.OwnerID)
+		if sub.Properties.OwnerName != "" || sub.Properties.OwnerEmail != "" {
+			fmt.Printf("    Owner Name:   %s\n", sub.Properties.OwnerName)
+			fmt.Printf("    Owner Email:  %s\n", sub.Properties.OwnerEmail)
+		}
+	}
+
+	if !findKeySuspend {
+		return nil
+	}
+
+	fmt.Println("\nSuspending matching subscription(s)...")
+	var failed int
+	for _, sub := range matches {
+		displayName := sub.Properties.DisplayName
+		if sub.Properties.State == "suspended" {
+			fmt.Printf("  [SKIP] %s (already suspended)\n", displayName)
+			continue
+		}
+		if _, err := client.SetState(ctx, sub.Name, "suspended", "Suspended by kura find-key: key leaked"); err != nil {
+			fmt.Printf("  [FAIL] %s: %v\n", displayName, err)
+			failed++
+			continue
+		}
+		fmt.Printf("  [OK]   %s (sid=%s) %s -> suspended\n", displayName, sub.Name, sub.Properties.State)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d subscription(s) failed to suspend", failed)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/spf13/cobra"
+)
+
+var gatewayRotateCmd = &cobra.Command{
+	Use:   "rotate <gateway-id>...",
+	Short: "Regenerate the key of self-hosted gateways and print new tokens",
+	Long: `Rotate regenerates the primary or the secondary key of self-hosted gateways
+and prints a new token signed with it for each gateway, valid for
+--token-expiry.
+
+Regenerating a key invalidates every token signed with it, so gateway
+deployments still configured with such a token stop working. To rotate without
+downtime, rotate the key the deployments do not use, deploy the new token, then
+rotate the other key the next time.
+
+Example:
+  kura gateway rotate edge-eu -g mygroup -a myapim --key secondary
+  kura gateway rotate edge-eu edge-us -g mygroup -a myapim --key primary --token-expiry 7d --yes`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runGatewayRotate,
+}
+
+// maxGatewayTokenExpiry is the longest validity APIM issues gateway tokens
+// for.
+const maxGatewayTokenExpiry = 30 * 24 * time.Hour
+
+var (
+	gatewayResourceGroup string
+	gatewayAPIMName      string
+	gatewaySubscription  string
+	gatewayKey           string
+	gatewayTokenExpiry   string
+	gatewayDryRun        bool
+	gatewayYes           bool
+)
+
+func init() {
+	gatewaysCmd := newConfigCommand(configKind[azure.GatewayInfo]{
+		use:    "gateways",
+		noun:   "gateway",
+		plural: "gateways",
+		short:  "Back up and restore the self-hosted gateways of an Azure API Management instance",
+		long: `Gateways backs up and restores the self-hosted gateways of an Azure API
+Management instance with their location, the APIs assigned to them and their
+keys, and rotates gateway keys.
+
+The keys are stored in plain text, so protect the backup file like a
+subscription key backup. APIM generates gateway keys and cannot be given one,
+so restore recreates the gateways and their API assignments with new keys:
+gateway deployments need a new token afterwards. The APIs must be restored
+first.`,
+		list: func(ctx context.Context, client *azure.Client) ([]azure.GatewayInfo, error) {
+			return client.ListGateways(ctx)
+		},
+		put: func(ctx context.Context, client *azure.Client, gateway azure.GatewayInfo) error {
+			return client.PutGateway(ctx, gateway)
+		},
+		name: func(gateway azure.GatewayInfo) string {
+			return gateway.ID
+		},
+	})
+	gatewaysCmd.Aliases = []string{"gateway"}
+	rootCmd.AddCommand(gatewaysCmd)
+	gatewaysCmd.AddCommand(gatewayRotateCmd)
+
+	gatewayRotateCmd.Flags().StringVarP(&gatewayResourceGroup, "resource-group", "g", "", "Azure resource group name (required)")
+	gatewayRotateCmd.Flags().StringVarP(&gatewayAPIMName, "apim-name", "a", "", "Azure API Management instance name (required)")
+	gatewayRotateCmd.Flags().StringVarP(&gatewaySubscription, "subscription", "s", "", "Azure subscription ID")
+	gatewayRotateCmd.Flags().StringVar(&gatewayKey, "key", "", "Key to regenerate: primary or secondary (required)")
+	gatewayRotateCmd.Flags().StringVar(&gatewayTokenExpiry, "token-expiry", "30d", "Validity of the new tokens, at most 30d (e.g. 7d or 12h)")
+	gatewayRotateCmd.Flags().BoolVar(&gatewayDryRun, "dry-run", false, "Preview the rotation without applying it")
+	registerYesFlags(gatewayRotateCmd, &gatewayYes)
+
+	gatewayRotateCmd.MarkFlagRequired("resource-group")
+	gatewayRotateCmd.MarkFlagRequired("apim-name")
+	gatewayRotateCmd.MarkFlagRequired("key")
+}
+
+func runGatewayRotate(cmd *cobra.Command, args []string) error {
+	primary, secondary, err := parseRotateKey(gatewayKey)
+	if err != nil || (primary && secondary) {
+		return fmt.Errorf("invalid --key %q: must be primary or secondary", gatewayKey)
+	}
+	validity, err := parseDays("token-expiry", gatewayTokenExpiry)
+	if err != nil {
+		return err
+	}
+	if validity > maxGatewayTokenExpiry {
+		return fmt.Errorf("invalid --token-expiry %q: gateway tokens are valid for at most 30d", gatewayTokenExpiry)
+	}
+
+	fmt.Printf("Rotating gateway keys in APIM instance: %s\n", gatewayAPIMName)
+	fmt.Printf("Resource Group: %s\n", gatewayResourceGroup)
+	fmt.Printf("Key: %s\n", gatewayKey)
+
+	if gatewayDryRun {
+		fmt.Println("\nRunning in DRY-RUN mode. No changes will be applied.")
+		for _, id := range args {
+			fmt.Printf("  [DRY-RUN] Would rotate %s key of: %s\n", gatewayKey, id)
+		}
+		return nil
+	}
+
+	if !gatewayYes {
+		ok, err := confirm(fmt.Sprintf("\nRegenerate the %s key of %d gateway(s)? Deployments using a token signed with it stop working.", gatewayKey, len(args)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println("Rotation cancelled.")
+			return nil
+		}
+	}
+
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	fmt.Println("\nAuthenticating with Azure CLI...")
+	client, err := azure.NewClient(ctx, gatewaySubscription, gatewayResourceGroup, gatewayAPIMName)
+	if err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	fmt.Println()
+
+	expiry := time.Now().UTC().Add(validity)
+	var rotated, failed int
+	for _, id := range args {
+		token, err := client.RegenerateGatewayKey(ctx, id, primary, expiry)
+		if err != nil {
+			fmt.Printf("  [FAIL] %s: %v\n", id, err)
+			failed++
+			continue
+		}
+		fmt.Printf("  [OK]   %s\n", id)
+		fmt.Printf("         Token (expires %s): %s\n", expiry.Format(time.RFC3339), token)
+		rotated++
+	}
+
+	fmt.Printf("\nRotate complete: %d rotated, %d failed\n", rotated, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d gateway(s) failed to rotate", failed)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/f-marschall/apim-kura/internal/hooks"
+	"github.com/spf13/cobra"
+)
+
+// Environment variables that set the defaults of the hook flags.
+const (
+	preHookEnv  = "KURA_PRE_HOOK"
+	postHookEnv = "KURA_POST_HOOK"
+)
+
+// hookFlags holds the values of the hook flags of a command.
+type hookFlags struct {
+	pre  string
+	post string
+}
+
+// registerHookFlags adds --pre-hook and --post-hook to cmd. Their defaults are
+// taken from KURA_PRE_HOOK and KURA_POST_HOOK, so one script can serve every
+// command; it tells them apart by KURA_HOOK_COMMAND.
+func registerHookFlags(cmd *cobra.Command, h *hookFlags) {
+	cmd.Flags().StringVar(&h.pre, "pre-hook", os.Getenv(preHookEnv),
+		"Shell command to run before the operation; the operation is aborted if it fails (default $"+preHookEnv+")")
+	cmd.Flags().StringVar(&h.post, "post-hook", os.Getenv(postHookEnv),
+		"Shell command to run after the operation, with its summary as JSON on stdin (default $"+postHookEnv+")")
+}
+
+// runPre runs the pre hook, if any, with the summary of the run that is about
+// to start. It returns an error if the hook failed, which aborts the run.
+func (h hookFlags) runPre(s *runSummary) error {
+	if h.pre == "" {
+		return nil
+	}
+	fmt.Println("Running pre-hook...")
+	s.Status = "running"
+	return runHook(h.pre, "pre", s)
+}
+
+// runPost runs the post hook, if any, with the summary of the finished run. A
+// failed hook is reported as a warning and does not change the result of the
+// run. It returns runErr, so it can be deferred:
+//
+//	defer func() { err = flags.runPost(summary, err) }()
+func (h hookFlags) runPost(s *runSummary, runErr error) error {
+	if h.post == "" {
+		return runErr
+	}
+	s.finish(runErr)
+	fmt.Println("Running post-hook...")
+	if err := runHook(h.post, "post", s); err != nil {
+		fmt.Printf("  [WARNING] %v\n", err)
+	}
+	return runErr
+}
+
+// runHook runs command for the given stage with the summary as JSON on stdin
+// and its main fields in KURA_HOOK_* environment variables.
+func runHook(command, stage string, s *runSummary) error {
+	input, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode hook input: %w", err)
+	}
+	env := map[string]string{
+		"KURA_HOOK_STAGE":           stage,
+		"KURA_HOOK_COMMAND":         s.Command,
+		"KURA_HOOK_RESOURCE_GROUP":  s.ResourceGroup,
+		"KURA_HOOK_APIM_NAME":       s.APIMName,
+		"KURA_HOOK_SUBSCRIPTION_ID": s.SubscriptionID,
+		"KURA_HOOK_DRY_RUN":         strconv.FormatBool(s.DryRun),
+	}
+	if s.Status != "" {
+		env["KURA_HOOK_STATUS"] = s.Status
+	}
+	return hooks.Run(context.Background(), command, env, input)
+}
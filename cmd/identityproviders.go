@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+)
+
+func init() {
+	rootCmd.AddCommand(newConfigCommand(configKind[azure.IdentityProviderInfo]{
+		use:    "identity-providers",
+		noun:   "identity provider",
+		plural: "identity providers",
+		short:  "Back up and restore the identity providers of an Azure API Management instance",
+		long: `Identity-providers backs up and restores the identity providers developers sign
+in to the developer portal with, such as Azure AD and Azure AD B2C: their
+client ID, allowed and signin tenants, authority and B2C policies.
+
+Client secrets are never written to the backup. Its clientSecret fields are
+empty; set clientSecret.secretIdentifier of each provider to the Key Vault
+secret holding its client secret before restoring. Restore reads the secrets
+with the credential of kura, which needs permission to get secrets from the
+vault.`,
+		list: func(ctx context.Context, client *azure.Client) ([]azure.IdentityProviderInfo, error) {
+			return client.ListIdentityProviders(ctx)
+		},
+		put: func(ctx context.Context, client *azure.Client, idp azure.IdentityProviderInfo) error {
+			return client.PutIdentityProvider(ctx, idp)
+		},
+		name: func(idp azure.IdentityProviderInfo) string {
+			return idp.Type
+		},
+	}))
+}
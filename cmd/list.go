@@ -3,8 +3,9 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"strings"
 
-	"github.com/f-marschall/apim-kura/internal/azure"
+	"github.com/f-marschall/apim-kura/pkg/azure"
 	"github.com/spf13/cobra"
 )
 
@@ -17,7 +18,10 @@ instance directly in the terminal.
 Example:
   kura list --resource-group mygroup --apim-name myapim
   kura list --resource-group mygroup --apim-name myapim --subscription mysubid
-  kura list --resource-group mygroup --apim-name myapim --product-id myproduct`,
+  kura list --resource-group mygroup --apim-name myapim --product-id myproduct
+  kura list --resource-group mygroup --apim-name myapim --api-id petstore
+  kura list -g mygroup -a myapim --resolve-owners
+  kura list -g mygroup -a myapim --owner jane@contoso.com`,
 	RunE: runList,
 }
 
@@ -26,6 +30,9 @@ var (
 	listAPIMName      string
 	listSubscription  string
 	listProductID     string
+	listAPIID         string
+	listOwner         string
+	listResolveOwners bool
 )
 
 func init() {
@@ -35,6 +42,11 @@ func init() {
 	listCmd.Flags().StringVarP(&listAPIMName, "apim-name", "a", "", "Azure API Management instance name (required)")
 	listCmd.Flags().StringVarP(&listSubscription, "subscription", "s", "", "Azure subscription ID")
 	listCmd.Flags().StringVarP(&listProductID, "product-id", "p", "", "Filter by product ID")
+	listCmd.Flags().StringVar(&listAPIID, "api-id", "", "Filter by API ID")
+	listCmd.Flags().StringVar(&listOwner, "owner", "", "Filter by owner, given by user ID or email address")
+	listCmd.Flags().BoolVar(&listResolveOwners, "resolve-owners", false, "Look up each owner and show their name and email")
+
+	listCmd.MarkFlagsMutuallyExclusive("product-id", "api-id")
 
 	listCmd.MarkFlagRequired("resource-group")
 	listCmd.MarkFlagRequired("apim-name")
@@ -50,8 +62,15 @@ func runList(cmd *cobra.Command, args []string) error {
 	if listProductID != "" {
 		fmt.Printf("Product ID: %s\n", listProductID)
 	}
+	if listAPIID != "" {
+		fmt.Printf("API ID: %s\n", listAPIID)
+	}
+	if listOwner != "" {
+		fmt.Printf("Owner: %s\n", listOwner)
+	}
 
-	ctx := context.Background()
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
 	fmt.Println("\nAuthenticating with Azure CLI...")
 
 	client, err := azure.NewClient(ctx, listSubscription, listResourceGroup, listAPIMName)
@@ -60,8 +79,16 @@ func runList(cmd *cobra.Command, args []string) error {
 	}
 	fmt.Println("Successfully authenticated with Azure CLI")
 
+	filter := azure.SubscriptionFilter{ProductID: listProductID, APIID: listAPIID}
+	if listOwner != "" {
+		filter.OwnerID, err = resolveOwnerID(ctx, client, listOwner)
+		if err != nil {
+			return err
+		}
+	}
+
 	fmt.Println("\nFetching subscriptions...")
-	subs, err := client.ListSubscriptions(ctx, listProductID)
+	subs, err := client.ListSubscriptionsMatching(ctx, filter)
 	if err != nil {
 		return fmt.Errorf("failed to list subscriptions: %w", err)
 	}
@@ -71,28 +98,60 @@ func runList(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	if listResolveOwners {
+		if err := client.ResolveOwners(ctx, subs); err != nil {
+			return fmt.Errorf("failed to resolve owners: %w", err)
+		}
+	}
+
 	fmt.Printf("\nFound %d subscription(s):\n", len(subs))
 	fmt.Println("────────────────────────────────────────────────────────────────")
 
 	for i, sub := range subs {
 		fmt.Printf("\n[%d] %s\n", i+1, sub.Properties.DisplayName)
-		fmt.Printf("    ID:               %s\n", sub.ID)
-		fmt.Printf("    Name:             %s\n", sub.Name)
-		fmt.Printf("    Type:             %s\n", sub.Type)
-		fmt.Printf("    Scope:            %s\n", sub.Properties.Scope)
-		fmt.Printf("    State:            %s\n", sub.Properties.State)
-		fmt.Printf("    Owner ID:         %s\n", sub.Properties.OwnerID)
-		fmt.Printf("    Created:          %s\n", sub.Properties.CreatedDate)
-		fmt.Printf("    Start Date:       %s\n", sub.Properties.StartDate)
-		fmt.Printf("    End Date:         %s\n", sub.Properties.EndDate)
-		fmt.Printf("    Expiration Date:  %s\n", sub.Properties.ExpirationDate)
-		fmt.Printf("    Notification Date:%s\n", sub.Properties.NotificationDate)
-		fmt.Printf("    State Comment:    %s\n", sub.Properties.StateComment)
-		fmt.Printf("    Allow Tracing:    %t\n", sub.Properties.AllowTracing)
-		fmt.Printf("    Primary Key:      %s\n", sub.Properties.PrimaryKey)
-		fmt.Printf("    Secondary Key:    %s\n", sub.Properties.SecondaryKey)
+		printSubscriptionDetails(&sub, listResolveOwners)
 	}
 
 	fmt.Println("\n────────────────────────────────────────────────────────────────")
 	return nil
 }
+
+// resolveOwnerID returns the user ID of owner, which is a user ID, a user
+// resource ID or the email address of a user.
+func resolveOwnerID(ctx context.Context, client *azure.Client, owner string) (string, error) {
+	if !strings.Contains(owner, "@") {
+		if id := azure.OwnerUserID(owner); id != "" {
+			return id, nil
+		}
+		return owner, nil
+	}
+	user, err := client.FindUserByEmail(ctx, owner)
+	if err != nil {
+		return "", err
+	}
+	return azure.OwnerUserID(user.ID), nil
+}
+
+// printSubscriptionDetails prints all attributes of sub, one per line. Owner
+// name and email are only printed with withOwners.
+func printSubscriptionDetails(sub *azure.SubscriptionInfo, withOwners bool) {
+	fmt.Printf("    ID:               %s\n", sub.ID)
+	fmt.Printf("    Name:             %s\n", sub.Name)
+	fmt.Printf("    Type:             %s\n", sub.Type)
+	fmt.Printf("    Scope:            %s\n", sub.Properties.Scope)
+	fmt.Printf("    State:            %s\n", sub.Properties.State)
+	fmt.Printf("    Owner ID:         %s\n", sub.Properties.OwnerID)
+	if withOwners {
+		fmt.Printf("    Owner Name:       %s\n", sub.Properties.OwnerName)
+		fmt.Printf("    Owner Email:      %s\n", sub.Properties.OwnerEmail)
+	}
+	fmt.Printf("    Created:          %s\n", sub.Properties.CreatedDate)
+	fmt.Printf("    Start Date:       %s\n", sub.Properties.StartDate)
+	fmt.Printf("    End Date:         %s\n", sub.Properties.EndDate)
+	fmt.Printf("    Expiration Date:  %s\n", sub.Properties.ExpirationDate)
+	fmt.Printf("    Notification Date:%s\n", sub.Properties.NotificationDate)
+	fmt.Printf("    State Comment:    %s\n", sub.Properties.StateComment)
+	fmt.Printf("    Allow Tracing:    %t\n", sub.Properties.AllowTracing)
+	fmt.Printf("    Primary Key:      %s\n", sub.Properties.PrimaryKey)
+	fmt.Printf("    Secondary Key:    %s\n", sub.Properties.SecondaryKey)
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/f-marschall/apim-kura/pkg/backup"
+	"github.com/spf13/cobra"
+)
+
+var unlockCmd = &cobra.Command{
+	Use:   "unlock",
+	Short: "Remove the lock of an APIM instance left behind by an interrupted run",
+	Long: `Commands that change many subscriptions at once (restore, rollback, sync,
+apply, copy, migrate and promote) lock the target instance while they run, so
+that two runs cannot change it at the same time and corrupt each other's
+changes. A second run fails with the details of the run holding the lock.
+
+Locks are files under backup/.locks, or under KURA_LOCK_DIR if set. To lock
+out operators on other machines, point KURA_LOCK_DIR to a directory all of
+them share. A lock left behind by a process on the same host that no longer
+runs is taken over automatically. Unlock removes any other lock that was left
+behind, for example after a machine crashed.
+
+Only remove a lock if you are sure the run holding it is no longer active.
+
+Example:
+  kura unlock --resource-group mygroup --apim-name myapim`,
+	Args: cobra.NoArgs,
+	RunE: runUnlock,
+}
+
+var (
+	unlockResourceGroup string
+	unlockAPIMName      string
+	unlockYes           bool
+)
+
+func init() {
+	rootCmd.AddCommand(unlockCmd)
+
+	unlockCmd.Flags().StringVarP(&unlockResourceGroup, "resource-group", "g", "", "Azure resource group name (required)")
+	unlockCmd.Flags().StringVarP(&unlockAPIMName, "apim-name", "a", "", "Azure API Management instance name (required)")
+	registerYesFlags(unlockCmd, &unlockYes)
+
+	unlockCmd.MarkFlagRequired("resource-group")
+	unlockCmd.MarkFlagRequired("apim-name")
+}
+
+func runUnlock(cmd *cobra.Command, args []string) error {
+	path := backup.LockPath(unlockResourceGroup, unlockAPIMName)
+	holder, err := backup.ReadLock(path)
+	if err != nil {
+		return err
+	}
+	if holder == nil {
+		fmt.Printf("APIM instance %s is not locked.\n", unlockAPIMName)
+		return nil
+	}
+
+	fmt.Printf("APIM instance %s is locked by %s\n", unlockAPIMName, holder)
+	if !unlockYes {
+		ok, err := confirm("Remove the lock?")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println("Aborted. The lock was kept.")
+			return nil
+		}
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove lock file: %w", err)
+	}
+	fmt.Printf("[OK]   Removed lock %s\n", path)
+	return nil
+}
+
+// lockInstance takes the lock of an instance for a run of command that
+// changes it. The returned function releases the lock.
+func lockInstance(resourceGroup, apimName, command string) (func(), error) {
+	lock, err := backup.AcquireLock(resourceGroup, apimName, command)
+	if err != nil {
+		var locked *backup.LockedError
+		if errors.As(err, &locked) {
+			return nil, fmt.Errorf("%w; if that run is no longer active, remove the lock with: kura unlock -g %s -a %s", err, resourceGroup, apimName)
+		}
+		return nil, err
+	}
+	return func() {
+		if err := lock.Release(); err != nil {
+			fmt.Printf("  [WARNING] %v\n", err)
+		}
+	}, nil
+}
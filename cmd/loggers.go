@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+)
+
+func init() {
+	rootCmd.AddCommand(newConfigCommand(configKind[azure.LoggerInfo]{
+		use:    "loggers",
+		noun:   "logger",
+		plural: "loggers",
+		short:  "Back up and restore the loggers of an Azure API Management instance",
+		long: `Loggers backs up and restores the loggers of an Azure API Management
+instance, the Application Insights, Event Hub and Azure Monitor destinations
+its diagnostics log to.
+
+The credentials of a logger, its instrumentation key or connection string, are
+usually stored as a reference to a named value, such as
+{{Logger-Credentials--0123}}. Restore the named values first, then the
+loggers, then the diagnostics that log to them.`,
+		list: func(ctx context.Context, client *azure.Client) ([]azure.LoggerInfo, error) {
+			return client.ListLoggers(ctx)
+		},
+		put: func(ctx context.Context, client *azure.Client, logger azure.LoggerInfo) error {
+			return client.PutLogger(ctx, logger)
+		},
+		name: func(logger azure.LoggerInfo) string {
+			return logger.ID
+		},
+	}))
+
+	rootCmd.AddCommand(newConfigCommand(configKind[azure.DiagnosticInfo]{
+		use:    "diagnostics",
+		noun:   "diagnostic",
+		plural: "diagnostics",
+		short:  "Back up and restore the diagnostic settings of an Azure API Management instance",
+		long: `Diagnostics backs up and restores the diagnostic settings of an Azure API
+Management instance, both the global ones and those of each API: the logger
+they log to, sampling, verbosity and what is logged of requests and responses.
+
+Diagnostics reference their logger by ID, so restore the loggers first. The
+logger is looked up in the instance being restored to, which lets the settings
+be restored to another instance.`,
+		list: func(ctx context.Context, client *azure.Client) ([]azure.DiagnosticInfo, error) {
+			return client.ListDiagnostics(ctx)
+		},
+		put: func(ctx context.Context, client *azure.Client, d azure.DiagnosticInfo) error {
+			return client.PutDiagnostic(ctx, d)
+		},
+		name: func(d azure.DiagnosticInfo) string {
+			if d.APIID == "" {
+				return d.ID
+			}
+			return d.APIID + "/" + d.ID
+		},
+	}))
+}
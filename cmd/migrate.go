@@ -0,0 +1,338 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/f-marschall/apim-kura/pkg/backup"
+	"github.com/spf13/cobra"
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Migrate subscriptions to an APIM instance in another tenant",
+	Long: `Migrate moves the subscriptions of a source Azure API Management instance to a
+target instance, typically in another Microsoft Entra tenant. Each side is
+authenticated separately: --source-tenant and --target-tenant select the tenant
+the Azure CLI requests tokens for, so both tenants must have been logged in to
+with "az login --tenant". With a tenant, the Azure subscription ID of that side
+is required.
+
+Scopes are rebuilt for the target instance and owners point to the user with
+the same ID in the target instance, unless --owner-map maps them to another
+user or drops them. Before anything is written, kura checks that the products,
+APIs and users the subscriptions refer to exist in the target instance; the
+subscriptions that fail this check are not migrated. The built-in master
+subscription is never migrated.
+
+If the migration would overwrite existing subscriptions of the target instance,
+kura asks for confirmation; use --yes (or --force) to skip the prompt.
+
+Every run, including --dry-run, writes a migration report without keys to
+backup/.migrations/<target-resource-group>/<target-apim>/ (see --report).
+
+Example:
+  kura migrate --source-tenant contoso.onmicrosoft.com --source-subscription <id> --source-resource-group rg --source-apim old-apim \
+    --target-tenant fabrikam.onmicrosoft.com --target-subscription <id> --target-resource-group rg --target-apim new-apim \
+    --owner-map owners.yaml --dry-run`,
+	Args: cobra.NoArgs,
+	RunE: runMigrate,
+}
+
+var (
+	migrateSourceTenant       string
+	migrateSourceSubscription string
+	migrateSourceRG           string
+	migrateSourceAPIM         string
+	migrateTargetTenant       string
+	migrateTargetSubscription string
+	migrateTargetRG           string
+	migrateTargetAPIM         string
+	migrateProductID          string
+	migrateOwnerMap           string
+	migrateSkipExisting       bool
+	migrateDryRun             bool
+	migrateReport             string
+	migrateYes                bool
+)
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+
+	migrateCmd.Flags().StringVar(&migrateSourceTenant, "source-tenant", "", "Microsoft Entra tenant of --source-apim (defaults to the Azure CLI's tenant)")
+	migrateCmd.Flags().StringVar(&migrateSourceSubscription, "source-subscription", "", "Azure subscription ID of --source-apim (required with --source-tenant)")
+	migrateCmd.Flags().StringVar(&migrateSourceRG, "source-resource-group", "", "Resource group of --source-apim (required)")
+	migrateCmd.Flags().StringVar(&migrateSourceAPIM, "source-apim", "", "APIM instance to migrate the subscriptions from (required)")
+	migrateCmd.Flags().StringVar(&migrateTargetTenant, "target-tenant", "", "Microsoft Entra tenant of --target-apim (defaults to the Azure CLI's tenant)")
+	migrateCmd.Flags().StringVar(&migrateTargetSubscription, "target-subscription", "", "Azure subscription ID of --target-apim (required with --target-tenant)")
+	migrateCmd.Flags().StringVar(&migrateTargetRG, "target-resource-group", "", "Resource group of --target-apim (defaults to --source-resource-group)")
+	migrateCmd.Flags().StringVar(&migrateTargetAPIM, "target-apim", "", "APIM instance to migrate the subscriptions to (required)")
+	migrateCmd.Flags().StringVarP(&migrateProductID, "product-id", "p", "", "Only migrate subscriptions scoped to this product")
+	migrateCmd.Flags().StringVar(&migrateOwnerMap, "owner-map", "", "YAML file mapping source user IDs to target user IDs (or \"drop\")")
+	migrateCmd.Flags().BoolVar(&migrateSkipExisting, "skip-existing", false, "Skip subscriptions whose sid already exists in the target instance")
+	migrateCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "Check and preview the migration without applying it")
+	migrateCmd.Flags().StringVar(&migrateReport, "report", "", "Path of the migration report (default: a new file under backup/.migrations)")
+	registerYesFlags(migrateCmd, &migrateYes)
+
+	migrateCmd.MarkFlagRequired("source-resource-group")
+	migrateCmd.MarkFlagRequired("source-apim")
+	migrateCmd.MarkFlagRequired("target-apim")
+}
+
+func runMigrate(cmd *cobra.Command, args []string) error {
+	if migrateTargetRG == "" {
+		migrateTargetRG = migrateSourceRG
+	}
+	if migrateTargetAPIM == migrateSourceAPIM && migrateTargetRG == migrateSourceRG &&
+		migrateTargetSubscription == migrateSourceSubscription && migrateTargetTenant == migrateSourceTenant {
+		return fmt.Errorf("source and target are the same APIM instance")
+	}
+
+	fmt.Printf("Migrating subscription keys to APIM instance: %s\n", migrateTargetAPIM)
+	fmt.Printf("Source: %s (resource group %s%s)\n", migrateSourceAPIM, migrateSourceRG, describeTenant(migrateSourceTenant))
+	fmt.Printf("Target: %s (resource group %s%s)\n", migrateTargetAPIM, migrateTargetRG, describeTenant(migrateTargetTenant))
+	if migrateProductID != "" {
+		fmt.Printf("Product ID: %s\n", migrateProductID)
+	}
+
+	var ownerMap backup.OwnerMap
+	if migrateOwnerMap != "" {
+		var err error
+		ownerMap, err = backup.LoadOwnerMap(migrateOwnerMap)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Owner map: %s (%d entries)\n", migrateOwnerMap, len(ownerMap))
+	}
+
+	if migrateDryRun {
+		fmt.Println("\nRunning in DRY-RUN mode. No changes will be applied.")
+	}
+
+	if !migrateDryRun {
+		unlock, err := lockInstance(migrateTargetRG, migrateTargetAPIM, "migrate")
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	fmt.Println("\nAuthenticating with Azure CLI...")
+
+	source, err := azure.NewClientForTenant(ctx, migrateSourceTenant, migrateSourceSubscription, migrateSourceRG, migrateSourceAPIM)
+	if err != nil {
+		return fmt.Errorf("authentication with the source tenant failed: %w", err)
+	}
+	target, err := azure.NewClientForTenant(ctx, migrateTargetTenant, migrateTargetSubscription, migrateTargetRG, migrateTargetAPIM)
+	if err != nil {
+		return fmt.Errorf("authentication with the target tenant failed: %w", err)
+	}
+	fmt.Println("Successfully authenticated with Azure CLI")
+
+	report := &backup.MigrationReport{
+		Source: backup.MigrationEndpoint{
+			TenantID:       migrateSourceTenant,
+			SubscriptionID: source.SubscriptionID(),
+			ResourceGroup:  migrateSourceRG,
+			APIMName:       migrateSourceAPIM,
+		},
+		Target: backup.MigrationEndpoint{
+			TenantID:       migrateTargetTenant,
+			SubscriptionID: target.SubscriptionID(),
+			ResourceGroup:  migrateTargetRG,
+			APIMName:       migrateTargetAPIM,
+		},
+		DryRun:    migrateDryRun,
+		StartedAt: time.Now().UTC(),
+	}
+
+	fmt.Println("\nFetching subscriptions of the source instance...")
+	all, err := source.ListSubscriptions(ctx, migrateProductID)
+	if err != nil {
+		return fmt.Errorf("failed to list source subscriptions: %w", err)
+	}
+	subs := filterOutMaster(all)
+	if len(subs) == 0 {
+		fmt.Println("No subscriptions found. Nothing to migrate.")
+		return nil
+	}
+	fmt.Printf("Found %d subscription(s)\n", len(subs))
+
+	fmt.Println("\nChecking the target instance...")
+	current, err := target.ListSubscriptionsWithoutKeys(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed to list target subscriptions: %w", err)
+	}
+	existing := make(map[string]bool, len(current))
+	for _, sub := range current {
+		existing[sub.Name] = true
+	}
+
+	// Owners and scopes are rewritten before the checks, so that they run
+	// against what will actually be written to the target instance.
+	desired := retarget(subs, ownerMap, target.SubscriptionID(), migrateTargetRG, migrateTargetAPIM)
+	problems, err := checkMigrationTargets(ctx, target, desired)
+	if err != nil {
+		return err
+	}
+
+	for i := range subs {
+		entry := backup.MigrationEntry{
+			SID:         subs[i].Name,
+			DisplayName: subs[i].Properties.DisplayName,
+			SourceScope: extractScopeSuffix(subs[i].Properties.Scope),
+			TargetScope: extractScopeSuffix(desired[i].Properties.Scope),
+			SourceOwner: azure.OwnerUserID(subs[i].Properties.OwnerID),
+			TargetOwner: azure.OwnerUserID(desired[i].Properties.OwnerID),
+			Overwrite:   existing[subs[i].Name],
+		}
+		switch {
+		case problems[entry.SID] != "":
+			entry.Outcome, entry.Reason = backup.MigrationFailed, problems[entry.SID]
+		case migrateSkipExisting && entry.Overwrite:
+			entry.Outcome, entry.Reason = backup.MigrationSkipped, "sid already exists in the target instance"
+		}
+		report.Entries = append(report.Entries, entry)
+	}
+	if len(problems) > 0 {
+		fmt.Printf("%d subscription(s) cannot be migrated, see below\n", len(problems))
+	}
+
+	if !migrateDryRun && !migrateYes {
+		var pending, overwrites int
+		for _, e := range report.Entries {
+			if e.Outcome == "" {
+				pending++
+				if e.Overwrite {
+					overwrites++
+				}
+			}
+		}
+		if overwrites > 0 {
+			fmt.Println()
+			ok, err := confirm(fmt.Sprintf("Migrate %d subscription(s) to APIM instance %s, overwriting %d existing subscription(s)?", pending, migrateTargetAPIM, overwrites))
+			if err != nil {
+				return err
+			}
+			if !ok {
+				fmt.Println("Aborted. No subscriptions were migrated.")
+				return nil
+			}
+		}
+	}
+
+	fmt.Println()
+	for i := range report.Entries {
+		migrateSubscription(ctx, target, &desired[i], &report.Entries[i])
+	}
+	report.FinishedAt = time.Now().UTC()
+
+	reportPath := migrateReport
+	if reportPath == "" {
+		reportPath = backup.NewMigrationReportPath(migrateTargetRG, migrateTargetAPIM, report.StartedAt)
+	}
+	if err := report.Save(reportPath); err != nil {
+		return err
+	}
+
+	migrated := report.Count(backup.MigrationMigrated) + report.Count(backup.MigrationPlanned)
+	skipped, failed := report.Count(backup.MigrationSkipped), report.Count(backup.MigrationFailed)
+	fmt.Printf("\nMigration complete: %d migrated, %d skipped, %d failed\n", migrated, skipped, failed)
+	fmt.Printf("Migration report saved to %s\n", reportPath)
+	if failed > 0 {
+		return fmt.Errorf("%d subscription(s) failed to migrate", failed)
+	}
+	return nil
+}
+
+// checkMigrationTargets returns, by sid, why a desired subscription cannot be
+// created in the target instance: its product or API, or its owner, does not
+// exist there.
+func checkMigrationTargets(ctx context.Context, target *azure.Client, desired []azure.SubscriptionInfo) (map[string]string, error) {
+	products, err := target.ListProducts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	apis, err := target.ListAPIs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	problems := make(map[string]string)
+	for _, o := range findOrphans(desired, products, apis) {
+		problems[o.sub.Name] = o.reason + " in the target instance"
+	}
+
+	users := make(map[string]bool)
+	for _, sub := range desired {
+		userID := azure.OwnerUserID(sub.Properties.OwnerID)
+		if userID == "" || problems[sub.Name] != "" {
+			continue
+		}
+		found, ok := users[userID]
+		if !ok {
+			_, err := target.GetUser(ctx, userID)
+			if err != nil && !azure.IsNotFound(err) {
+				return nil, err
+			}
+			found = err == nil
+			users[userID] = found
+		}
+		if !found {
+			problems[sub.Name] = "user " + userID + " does not exist in the target instance; map it with --owner-map"
+		}
+	}
+	return problems, nil
+}
+
+// migrateSubscription creates sub in the target instance, unless entry already
+// has an outcome from the checks, and records the result in entry.
+func migrateSubscription(ctx context.Context, target azure.SubscriptionStore, sub *azure.SubscriptionInfo, entry *backup.MigrationEntry) {
+	switch entry.Outcome {
+	case backup.MigrationSkipped:
+		fmt.Printf("  [SKIP] %s (sid=%s already exists)\n", entry.DisplayName, entry.SID)
+		return
+	case backup.MigrationFailed:
+		fmt.Printf("  [FAIL] %s (sid=%s): %s\n", entry.DisplayName, entry.SID, entry.Reason)
+		return
+	}
+
+	opts, err := newCreateOptions(sub)
+	if err != nil {
+		fmt.Printf("  [FAIL] %s: %v\n", entry.DisplayName, err)
+		entry.Outcome, entry.Reason = backup.MigrationFailed, err.Error()
+		return
+	}
+
+	if migrateDryRun {
+		action := "create"
+		if entry.Overwrite {
+			action = "overwrite"
+		}
+		fmt.Printf("  [DRY-RUN] Would %s: %s (sid=%s, scope=%s)\n", action, entry.DisplayName, entry.SID, entry.TargetScope)
+		entry.Outcome = backup.MigrationPlanned
+		return
+	}
+
+	fmt.Printf("  Migrating: %s (sid=%s, scope=%s)...\n", entry.DisplayName, entry.SID, entry.TargetScope)
+	if _, err := target.CreateSubscription(ctx, entry.SID, sub.Properties.Scope, sub.Properties.DisplayName, opts); warnExpiration(err) != nil {
+		fmt.Printf("  [FAIL] %s: %v\n", entry.DisplayName, err)
+		entry.Outcome, entry.Reason = backup.MigrationFailed, err.Error()
+		return
+	}
+	fmt.Printf("  [OK]   %s\n", entry.DisplayName)
+	entry.Outcome = backup.MigrationMigrated
+}
+
+// describeTenant formats tenantID for the header lines, or returns an empty
+// string for the Azure CLI's default tenant.
+func describeTenant(tenantID string) string {
+	if tenantID == "" {
+		return ""
+	}
+	return ", tenant " + tenantID
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+)
+
+func init() {
+	rootCmd.AddCommand(newConfigCommand(configKind[azure.NamedValueInfo]{
+		use:    "named-values",
+		noun:   "named value",
+		plural: "named values",
+		short:  "Back up and restore the named values of an Azure API Management instance",
+		long: `Named-values backs up and restores the named values of an Azure API
+Management instance, which policies reference as {{name}}. Restored policies
+and subscriptions are of little use without the named values they depend on.
+
+The backup holds the display name, tags and value of each named value. The
+values of secret named values are included in plain text, so protect the
+backup file like a subscription key backup. Named values that reference Key
+Vault are backed up as the reference; the instance needs access to the vault
+to restore them.`,
+		list: func(ctx context.Context, client *azure.Client) ([]azure.NamedValueInfo, error) {
+			return client.ListNamedValues(ctx)
+		},
+		put: func(ctx context.Context, client *azure.Client, nv azure.NamedValueInfo) error {
+			return client.PutNamedValue(ctx, nv)
+		},
+		name: func(nv azure.NamedValueInfo) string {
+			return nv.ID
+		},
+	}))
+}
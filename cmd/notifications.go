@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+)
+
+func init() {
+	rootCmd.AddCommand(newConfigCommand(configKind[azure.NotificationInfo]{
+		use:    "notification-recipients",
+		noun:   "notification",
+		plural: "notification recipients",
+		short:  "Back up and restore the notification recipients of an Azure API Management instance",
+		long: `Notification-recipients backs up and restores who receives the notifications
+an Azure API Management instance sends to its publishers, such as the requests
+to approve a subscription: the email addresses and users of each notification.
+
+Restore adds the recipients in the file to each notification and keeps the
+recipients the notification has already. Users are referenced by ID and must
+exist in the instance.`,
+		list: func(ctx context.Context, client *azure.Client) ([]azure.NotificationInfo, error) {
+			return client.ListNotifications(ctx)
+		},
+		put: func(ctx context.Context, client *azure.Client, n azure.NotificationInfo) error {
+			return client.PutNotification(ctx, n)
+		},
+		name: func(n azure.NotificationInfo) string {
+			return n.ID
+		},
+	}))
+}
@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/f-marschall/apim-kura/internal/notify"
+	"github.com/spf13/cobra"
+)
+
+// Environment variables that set the defaults of the notification flags.
+const (
+	notifyURLEnv            = "KURA_NOTIFY_URL"
+	notifyFormatEnv         = "KURA_NOTIFY_FORMAT"
+	notifyEmailEnv          = "KURA_NOTIFY_EMAIL"
+	notifyEmailOnFailureEnv = "KURA_NOTIFY_EMAIL_ON_FAILURE"
+)
+
+// notifyFlags holds the values of the notification flags of a command.
+type notifyFlags struct {
+	url            string
+	format         string
+	email          []string
+	emailOnFailure bool
+}
+
+// registerNotifyFlags adds --notify-url, --notify-format, --notify-email and
+// --notify-email-on-failure to cmd. Their defaults are taken from the
+// KURA_NOTIFY_* environment variables, so scheduled jobs can configure them
+// once.
+func registerNotifyFlags(cmd *cobra.Command, n *notifyFlags) {
+	format := os.Getenv(notifyFormatEnv)
+	if format == "" {
+		format = notifyFormatAuto
+	}
+	cmd.Flags().StringVar(&n.url, "notify-url", os.Getenv(notifyURLEnv),
+		"POST a summary of the run to this URL when it finishes (default $"+notifyURLEnv+")")
+	cmd.Flags().StringVar(&n.format, "notify-format", format,
+		"Notification format: auto, json, slack or teams (default $"+notifyFormatEnv+" or auto)")
+	var email []string
+	if v := os.Getenv(notifyEmailEnv); v != "" {
+		email = strings.Split(v, ",")
+	}
+	cmd.Flags().StringSliceVar(&n.email, "notify-email", email,
+		"Email a summary of the run to these comma-separated addresses through the KURA_SMTP_* server (default $"+notifyEmailEnv+")")
+	onFailure, _ := strconv.ParseBool(os.Getenv(notifyEmailOnFailureEnv))
+	cmd.Flags().BoolVar(&n.emailOnFailure, "notify-email-on-failure", onFailure,
+		"With --notify-email, only email failed runs (default $"+notifyEmailOnFailureEnv+")")
+}
+
+// runSummary is the JSON document POSTed to --notify-url when a run finishes.
+type runSummary struct {
+	Command        string         `json:"command"`
+	Status         string         `json:"status"`
+	ResourceGroup  string         `json:"resourceGroup"`
+	APIMName       string         `json:"apimName"`
+	SubscriptionID string         `json:"subscriptionId,omitempty"`
+	DryRun         bool           `json:"dryRun,omitempty"`
+	Counts         map[string]int `json:"counts"`
+	Failures       []runFailure   `json:"failures,omitempty"`
+	// Files maps the kind of each file the run wrote, such as "backup" or
+	// "rollback", to its path.
+	Files           map[string]string `json:"files,omitempty"`
+	Error           string            `json:"error,omitempty"`
+	StartedAt       time.Time         `json:"startedAt"`
+	FinishedAt      time.Time         `json:"finishedAt"`
+	DurationSeconds float64           `json:"durationSeconds"`
+}
+
+// runFailure is a subscription a run failed to process.
+type runFailure struct {
+	SID         string `json:"sid"`
+	DisplayName string `json:"displayName"`
+	Error       string `json:"error,omitempty"`
+}
+
+// newRunSummary starts the summary of a run of command against an instance.
+func newRunSummary(command, resourceGroup, apimName, subscriptionID string) *runSummary {
+	return &runSummary{
+		Command:        command,
+		ResourceGroup:  resourceGroup,
+		APIMName:       apimName,
+		SubscriptionID: subscriptionID,
+		Counts:         map[string]int{},
+		StartedAt:      time.Now().UTC(),
+	}
+}
+
+// addFailure records a subscription that failed. err may be nil if the
+// reason was only printed.
+func (s *runSummary) addFailure(sid, displayName string, err error) {
+	f := runFailure{SID: sid, DisplayName: displayName}
+	if err != nil {
+		f.Error = err.Error()
+	}
+	s.Failures = append(s.Failures, f)
+}
+
+// addFile records a file the run wrote.
+func (s *runSummary) addFile(kind, path string) {
+	if s.Files == nil {
+		s.Files = map[string]string{}
+	}
+	s.Files[kind] = path
+}
+
+// finish completes the summary with the result runErr of the run.
+func (s *runSummary) finish(runErr error) {
+	s.FinishedAt = time.Now().UTC()
+	s.DurationSeconds = s.FinishedAt.Sub(s.StartedAt).Seconds()
+	s.Status = "succeeded"
+	s.Error = ""
+	if runErr != nil {
+		s.Status = "failed"
+		s.Error = runErr.Error()
+	}
+}
+
+// send completes the summary with the result runErr of the run, POSTs it to
+// the notification URL in the configured format and emails it. A failed
+// notification is reported as a warning and does not change the result of the
+// run. It returns runErr, so it can be deferred:
+//
+//	defer func() { err = summary.send(flags, err) }()
+func (s *runSummary) send(n notifyFlags, runErr error) error {
+	insights, err := notify.AppInsightsFromEnv()
+	if err != nil {
+		fmt.Printf("  [WARNING] %v\n", err)
+	}
+	if n.url == "" && len(n.email) == 0 && insights == nil {
+		return runErr
+	}
+
+	s.finish(runErr)
+
+	if n.url != "" {
+		s.post(n)
+	}
+	if len(n.email) > 0 && (runErr != nil || !n.emailOnFailure) {
+		s.email(n.email)
+	}
+	if insights != nil {
+		s.track(insights)
+	}
+	return runErr
+}
+
+// post POSTs the summary to the notification URL.
+func (s *runSummary) post(n notifyFlags) {
+	payload, err := s.format(n.format, n.url)
+	if err == nil {
+		err = notify.Post(context.Background(), n.url, payload)
+	}
+	if err != nil {
+		fmt.Printf("  [WARNING] %v\n", err)
+		return
+	}
+	fmt.Printf("Notification sent to %s\n", n.url)
+}
+
+// track sends the summary to Application Insights as a "kura.<command>" event
+// with the duration, the number of failures and the counts as measurements.
+func (s *runSummary) track(insights *notify.AppInsights) {
+	t := notify.Telemetry{
+		Name: "kura." + s.Command,
+		Time: s.FinishedAt,
+		Properties: map[string]string{
+			"command":        s.Command,
+			"status":         s.Status,
+			"resourceGroup":  s.ResourceGroup,
+			"apimName":       s.APIMName,
+			"subscriptionId": s.SubscriptionID,
+			"dryRun":         strconv.FormatBool(s.DryRun),
+		},
+		Measurements: map[string]float64{
+			"durationSeconds": s.DurationSeconds,
+			"failures":        float64(len(s.Failures)),
+		},
+	}
+	if s.Error != "" {
+		t.Properties["error"] = s.Error
+	}
+	for k, v := range s.Counts {
+		t.Measurements[k] = float64(v)
+	}
+	if err := insights.Track(context.Background(), t); err != nil {
+		fmt.Printf("  [WARNING] %v\n", err)
+		return
+	}
+	fmt.Println("Telemetry sent to Application Insights")
+}
+
+// email sends the summary as a plain text email to the recipients.
+func (s *runSummary) email(to []string) {
+	smtpConfig, err := notify.SMTPConfigFromEnv()
+	if err == nil {
+		err = notify.SendMail(smtpConfig, to, s.title(), s.text())
+	}
+	if err != nil {
+		fmt.Printf("  [WARNING] %v\n", err)
+		return
+	}
+	fmt.Printf("Summary emailed to %s\n", strings.Join(to, ", "))
+}
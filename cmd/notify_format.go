@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Values of --notify-format.
+const (
+	notifyFormatAuto  = "auto"
+	notifyFormatJSON  = "json"
+	notifyFormatSlack = "slack"
+	notifyFormatTeams = "teams"
+)
+
+// maxNotifyFailures is the number of failed subscriptions listed in chat
+// messages and emails. The JSON format always lists all of them.
+const maxNotifyFailures = 10
+
+// format returns the payload to POST to webhookURL in the given format. The
+// auto format picks Slack or Teams by the host of webhookURL and falls back
+// to the plain JSON summary.
+func (s *runSummary) format(format, webhookURL string) (any, error) {
+	if format == notifyFormatAuto {
+		format = detectNotifyFormat(webhookURL)
+	}
+	switch format {
+	case notifyFormatJSON:
+		return s, nil
+	case notifyFormatSlack:
+		return s.slackMessage(), nil
+	case notifyFormatTeams:
+		return s.teamsMessage(), nil
+	default:
+		return nil, fmt.Errorf("invalid --notify-format %q: must be auto, json, slack or teams", format)
+	}
+}
+
+// detectNotifyFormat returns the format matching the host of an incoming
+// webhook URL.
+func detectNotifyFormat(webhookURL string) string {
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return notifyFormatJSON
+	}
+	host := strings.ToLower(u.Hostname())
+	switch {
+	case host == "hooks.slack.com":
+		return notifyFormatSlack
+	case strings.HasSuffix(host, ".webhook.office.com"), strings.HasSuffix(host, ".logic.azure.com"),
+		strings.HasSuffix(host, ".powerplatform.com"):
+		return notifyFormatTeams
+	}
+	return notifyFormatJSON
+}
+
+// title returns a one-line description of the run, such as
+// "kura restore failed on my-apim (resource group my-rg)".
+func (s *runSummary) title() string {
+	title := fmt.Sprintf("kura %s %s on %s (resource group %s)", s.Command, s.Status, s.APIMName, s.ResourceGroup)
+	if s.DryRun {
+		title += " [dry run]"
+	}
+	return title
+}
+
+// facts returns the details of the run as label and value pairs, in the order
+// they are shown in chat messages.
+func (s *runSummary) facts() [][2]string {
+	var facts [][2]string
+	if len(s.Counts) > 0 {
+		keys := make([]string, 0, len(s.Counts))
+		for k := range s.Counts {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		counts := make([]string, len(keys))
+		for i, k := range keys {
+			counts[i] = fmt.Sprintf("%s: %d", k, s.Counts[k])
+		}
+		facts = append(facts, [2]string{"Counts", strings.Join(counts, ", ")})
+	}
+	facts = append(facts, [2]string{"Duration", (time.Duration(s.DurationSeconds * float64(time.Second))).Round(time.Second).String()})
+	if s.Error != "" {
+		facts = append(facts, [2]string{"Error", s.Error})
+	}
+	if len(s.Failures) > 0 {
+		var lines []string
+		for i, f := range s.Failures {
+			if i == maxNotifyFailures {
+				lines = append(lines, fmt.Sprintf("… and %d more", len(s.Failures)-maxNotifyFailures))
+				break
+			}
+			line := fmt.Sprintf("%s (sid=%s)", f.DisplayName, f.SID)
+			if f.Error != "" {
+				line += ": " + f.Error
+			}
+			lines = append(lines, line)
+		}
+		facts = append(facts, [2]string{"Failures", strings.Join(lines, "\n")})
+	}
+	if len(s.Files) > 0 {
+		kinds := make([]string, 0, len(s.Files))
+		for k := range s.Files {
+			kinds = append(kinds, k)
+		}
+		sort.Strings(kinds)
+		var lines []string
+		for _, k := range kinds {
+			lines = append(lines, fmt.Sprintf("%s: %s", k, s.Files[k]))
+		}
+		facts = append(facts, [2]string{"Files", strings.Join(lines, "\n")})
+	}
+	return facts
+}
+
+// text renders the summary as plain text, such as for an email body.
+func (s *runSummary) text() string {
+	var b strings.Builder
+	b.WriteString(s.title() + "\n\n")
+	fmt.Fprintf(&b, "Started:  %s\n", s.StartedAt.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Finished: %s\n", s.FinishedAt.Format(time.RFC3339))
+	if s.SubscriptionID != "" {
+		fmt.Fprintf(&b, "Azure subscription: %s\n", s.SubscriptionID)
+	}
+	for _, f := range s.facts() {
+		if !strings.Contains(f[1], "\n") {
+			fmt.Fprintf(&b, "%s: %s\n", f[0], f[1])
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s:\n", f[0])
+		for _, line := range strings.Split(f[1], "\n") {
+			fmt.Fprintf(&b, "  %s\n", line)
+		}
+	}
+	return b.String()
+}
+
+// slackMessage renders the summary as a Slack incoming webhook message with a
+// green or red attachment.
+func (s *runSummary) slackMessage() map[string]any {
+	color := "good"
+	if s.Status != "succeeded" {
+		color = "danger"
+	}
+	var fields []map[string]any
+	for _, f := range s.facts() {
+		fields = append(fields, map[string]any{"title": f[0], "value": f[1], "short": f[0] == "Counts" || f[0] == "Duration"})
+	}
+	return map[string]any{
+		"text": s.title(),
+		"attachments": []map[string]any{{
+			"color":    color,
+			"fallback": s.title(),
+			"fields":   fields,
+			"ts":       s.FinishedAt.Unix(),
+		}},
+	}
+}
+
+// teamsMessage renders the summary as a Microsoft Teams message with an
+// Adaptive Card, as accepted by Teams workflow webhooks.
+func (s *runSummary) teamsMessage() map[string]any {
+	color := "Good"
+	if s.Status != "succeeded" {
+		color = "Attention"
+	}
+	var facts []map[string]any
+	for _, f := range s.facts() {
+		facts = append(facts, map[string]any{"title": f[0], "value": f[1]})
+	}
+	return map[string]any{
+		"type": "message",
+		"attachments": []map[string]any{{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content": map[string]any{
+				"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+				"type":    "AdaptiveCard",
+				"version": "1.4",
+				"body": []map[string]any{
+					{"type": "TextBlock", "text": s.title(), "weight": "Bolder", "size": "Medium", "color": color, "wrap": true},
+					{"type": "FactSet", "facts": facts},
+				},
+			},
+		}},
+	}
+}
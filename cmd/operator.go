@@ -0,0 +1,295 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/f-marschall/apim-kura/internal/kube"
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/f-marschall/apim-kura/pkg/backup"
+	"github.com/spf13/cobra"
+)
+
+var operatorCmd = &cobra.Command{
+	Use:   "operator",
+	Short: "Run backups declared as ApimSubscriptionBackup resources in Kubernetes",
+	Long: `Operator runs kura as a controller inside a Kubernetes cluster. It watches
+ApimSubscriptionBackup custom resources, backs up the subscriptions of the APIM
+instance each one names on its schedule, and reports the result in the status
+of the resource, so platform teams manage APIM backups like any other workload.
+
+Every --resync, the operator lists the resources and runs the backups that are
+due: resources that were never backed up, whose spec changed, or whose last
+backup is older than spec.schedule. Backups are written to
+spec.destination.path, usually a mounted persistent volume, and encrypted with
+age when spec.encryption.ageRecipients is set. An unencrypted backup is skipped
+if the newest file in the destination already holds the same subscriptions.
+
+The Ready condition of each resource reports the outcome of its last backup.
+The CustomResourceDefinition and example manifests are in deploy/kubernetes.
+
+Inside a pod, the operator authenticates with its service account. Outside a
+cluster, run kubectl proxy and pass its URL with --kube-api-server. Azure
+access uses the Azure CLI, which must be logged in inside the container.
+
+Example:
+  kura operator
+  kura operator --namespace apim-backups --resync 30s
+  kura operator --kube-api-server http://127.0.0.1:8001 --log-format text`,
+	Args: cobra.NoArgs,
+	RunE: runOperator,
+}
+
+var (
+	operatorNamespace     string
+	operatorResync        string
+	operatorKubeAPIServer string
+	operatorLogFormat     string
+)
+
+func init() {
+	rootCmd.AddCommand(operatorCmd)
+
+	operatorCmd.Flags().StringVarP(&operatorNamespace, "namespace", "n", "", "Only watch resources in this namespace (default all namespaces)")
+	operatorCmd.Flags().StringVar(&operatorResync, "resync", "1m", "Time between two checks for due backups")
+	operatorCmd.Flags().StringVar(&operatorKubeAPIServer, "kube-api-server", "", "URL of a kubectl proxy, when running outside a cluster")
+	operatorCmd.Flags().StringVar(&operatorLogFormat, "log-format", "json", "Log format: json or text")
+}
+
+// Reasons of the Ready condition of an ApimSubscriptionBackup.
+const (
+	reasonBackupSucceeded = "BackupSucceeded"
+	reasonBackupUnchanged = "BackupUnchanged"
+	reasonBackupFailed    = "BackupFailed"
+	reasonInvalidSpec     = "InvalidSpec"
+)
+
+// defaultOperatorNameTemplate names backup files when the resource sets no
+// spec.destination.nameTemplate.
+const defaultOperatorNameTemplate = "subscriptions-{{.Timestamp}}.json"
+
+// operator holds the clients of a running operator.
+type operator struct {
+	log  *slog.Logger
+	kube *kube.Client
+	// azure caches one APIM client per instance.
+	azure map[string]*azure.Client
+}
+
+func runOperator(cmd *cobra.Command, args []string) error {
+	resync, err := parseDays("resync", operatorResync)
+	if err != nil {
+		return err
+	}
+	logger, err := newLogger(operatorLogFormat)
+	if err != nil {
+		return err
+	}
+	kc, err := kube.NewClient(operatorKubeAPIServer)
+	if err != nil {
+		return err
+	}
+	o := &operator{log: logger, kube: kc, azure: map[string]*azure.Client{}}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	o.log.Info("operator started", "namespace", operatorNamespace, "resync", resync.String())
+
+	ticker := time.NewTicker(resync)
+	defer ticker.Stop()
+	for {
+		// As in the daemon, a running backup is not cancelled by a signal.
+		o.reconcileAll(context.WithoutCancel(ctx))
+
+		select {
+		case <-ctx.Done():
+			o.log.Info("operator stopped")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// reconcileAll runs the backups that are due. Errors are logged, not
+// returned, so the operator keeps running.
+func (o *operator) reconcileAll(ctx context.Context) {
+	items, err := o.kube.List(ctx, operatorNamespace)
+	if err != nil {
+		o.log.Error("list failed", "error", err.Error())
+		return
+	}
+	for i := range items {
+		o.reconcile(ctx, &items[i])
+	}
+}
+
+// reconcile backs up the instance of b if a backup is due and writes the
+// outcome to the status of b.
+func (o *operator) reconcile(ctx context.Context, b *kube.ApimSubscriptionBackup) {
+	log := o.log.With("namespace", b.Metadata.Namespace, "name", b.Metadata.Name)
+	now := time.Now().UTC()
+
+	schedule, err := validateBackupSpec(b.Spec)
+	if err != nil {
+		if o.setReady(b, "False", reasonInvalidSpec, err.Error(), now) {
+			log.Warn("invalid spec", "error", err.Error())
+			o.updateStatus(ctx, log, b)
+		}
+		return
+	}
+
+	st := b.Status
+	due := st.LastBackupTime == nil || st.ObservedGeneration != b.Metadata.Generation || !now.Before(st.LastBackupTime.Add(schedule))
+	if !due {
+		return
+	}
+
+	log = log.With("resourceGroup", b.Spec.ResourceGroup, "apimName", b.Spec.APIMName)
+	backupCtx, cancel := withTimeout(ctx)
+	path, count, err := o.backup(backupCtx, b.Spec, now)
+	cancel()
+	b.Status.ObservedGeneration = b.Metadata.Generation
+	b.Status.LastBackupTime = &now
+	switch {
+	case err != nil:
+		log.Error("backup failed", "error", err.Error())
+		o.setReady(b, "False", reasonBackupFailed, err.Error(), now)
+	case path == "":
+		log.Info("backup skipped", "reason", "no changes since last snapshot", "subscriptions", count)
+		b.Status.Subscriptions = count
+		o.setReady(b, "True", reasonBackupUnchanged, fmt.Sprintf("%d subscription(s) unchanged since %s", count, b.Status.LastBackupFile), now)
+	default:
+		log.Info("backup written", "path", path, "subscriptions", count)
+		b.Status.Subscriptions = count
+		b.Status.LastBackupFile = path
+		o.setReady(b, "True", reasonBackupSucceeded, fmt.Sprintf("Backed up %d subscription(s) to %s", count, path), now)
+	}
+	o.updateStatus(ctx, log, b)
+}
+
+// validateBackupSpec checks the required fields of spec and returns its
+// schedule.
+func validateBackupSpec(spec kube.BackupSpec) (time.Duration, error) {
+	switch {
+	case spec.ResourceGroup == "":
+		return 0, errors.New("spec.resourceGroup is required")
+	case spec.APIMName == "":
+		return 0, errors.New("spec.apimName is required")
+	case spec.Destination.Path == "":
+		return 0, errors.New("spec.destination.path is required")
+	case spec.Encryption != nil && len(spec.Encryption.AgeRecipients) == 0:
+		return 0, errors.New("spec.encryption.ageRecipients must not be empty")
+	}
+	schedule, err := parseDays("schedule", spec.Schedule)
+	if err != nil {
+		return 0, fmt.Errorf("spec.schedule: %w", err)
+	}
+	if _, err := backup.RenderFileName(operatorNameTemplate(spec), backup.NewNameData(spec.ResourceGroup, spec.APIMName, spec.ProductID, time.Now())); err != nil {
+		return 0, fmt.Errorf("spec.destination.nameTemplate: %w", err)
+	}
+	return schedule, nil
+}
+
+func operatorNameTemplate(spec kube.BackupSpec) string {
+	if spec.Destination.NameTemplate != "" {
+		return spec.Destination.NameTemplate
+	}
+	return defaultOperatorNameTemplate
+}
+
+// backup writes the subscriptions of the instance of spec to a new file in
+// the destination and returns its path and the number of subscriptions. The
+// path is empty if an unencrypted backup was skipped because nothing changed.
+func (o *operator) backup(ctx context.Context, spec kube.BackupSpec, now time.Time) (string, int, error) {
+	client, err := o.client(ctx, spec)
+	if err != nil {
+		return "", 0, err
+	}
+	subs, err := client.ListSubscriptions(ctx, spec.ProductID)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+
+	name, err := backup.RenderFileName(operatorNameTemplate(spec), backup.NewNameData(spec.ResourceGroup, spec.APIMName, spec.ProductID, now))
+	if err != nil {
+		return "", 0, err
+	}
+	path := filepath.Join(spec.Destination.Path, name)
+
+	if spec.Encryption != nil {
+		path += ".age"
+		if err := backup.WriteAgeEncrypted(path, subs, spec.Encryption.AgeRecipients); err != nil {
+			return "", 0, err
+		}
+		return path, len(subs), nil
+	}
+
+	previous, err := backup.LatestSnapshot(spec.Destination.Path)
+	if err != nil {
+		return "", 0, err
+	}
+	if backup.Unchanged(previous, subs) {
+		return "", len(subs), nil
+	}
+	file := &backup.File{
+		Metadata: backup.Metadata{
+			CreatedAt:      now.UTC(),
+			SubscriptionID: client.SubscriptionID(),
+			ResourceGroup:  spec.ResourceGroup,
+			APIMName:       spec.APIMName,
+			ProductID:      spec.ProductID,
+		},
+		Subscriptions: subs,
+	}
+	if err := backup.Save(path, file); err != nil {
+		return "", 0, err
+	}
+	return path, len(subs), nil
+}
+
+// client returns the cached APIM client of the instance of spec.
+func (o *operator) client(ctx context.Context, spec kube.BackupSpec) (*azure.Client, error) {
+	key := spec.SubscriptionID + "/" + spec.ResourceGroup + "/" + spec.APIMName
+	if c, ok := o.azure[key]; ok {
+		return c, nil
+	}
+	c, err := azure.NewClient(ctx, spec.SubscriptionID, spec.ResourceGroup, spec.APIMName)
+	if err != nil {
+		return nil, fmt.Errorf("authentication failed: %w", err)
+	}
+	o.azure[key] = c
+	return c, nil
+}
+
+// setReady sets the Ready condition of b and reports whether it changed,
+// ignoring the transition time.
+func (o *operator) setReady(b *kube.ApimSubscriptionBackup, status, reason, message string, now time.Time) bool {
+	for _, c := range b.Status.Conditions {
+		if c.Type == "Ready" && c.Status == status && c.Reason == reason && c.Message == message && c.ObservedGeneration == b.Metadata.Generation {
+			return false
+		}
+	}
+	b.Status.SetCondition(kube.Condition{
+		Type:               "Ready",
+		Status:             status,
+		ObservedGeneration: b.Metadata.Generation,
+		LastTransitionTime: now,
+		Reason:             reason,
+		Message:            message,
+	})
+	return true
+}
+
+func (o *operator) updateStatus(ctx context.Context, log *slog.Logger, b *kube.ApimSubscriptionBackup) {
+	if err := o.kube.UpdateStatus(ctx, b); err != nil {
+		log.Error("status update failed", "error", err.Error())
+	}
+}
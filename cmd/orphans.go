@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/spf13/cobra"
+)
+
+var orphansCmd = &cobra.Command{
+	Use:   "orphans",
+	Short: "Find subscriptions whose product or API no longer exists",
+	Long: `Orphans cross-references the scopes of all subscriptions with the products
+and APIs of an Azure API Management instance and reports subscriptions scoped
+to a product or API that no longer exists.
+
+Use --delete to delete the orphaned subscriptions. As with the delete command,
+kura asks for confirmation (skip with --yes) and saves the subscriptions to a
+backup under backup/.pre-delete first (skip with --no-backup).
+
+Example:
+  kura orphans --resource-group mygroup --apim-name myapim
+  kura orphans -g mygroup -a myapim --delete --dry-run
+  kura orphans -g mygroup -a myapim --delete --yes`,
+	Args: cobra.NoArgs,
+	RunE: runOrphans,
+}
+
+var (
+	orphansResourceGroup string
+	orphansAPIMName      string
+	orphansSubscription  string
+	orphansDelete        bool
+	orphansDryRun        bool
+	orphansNoBackup      bool
+	orphansYes           bool
+)
+
+func init() {
+	rootCmd.AddCommand(orphansCmd)
+
+	orphansCmd.Flags().StringVarP(&orphansResourceGroup, "resource-group", "g", "", "Azure resource group name (required)")
+	orphansCmd.Flags().StringVarP(&orphansAPIMName, "apim-name", "a", "", "Azure API Management instance name (required)")
+	orphansCmd.Flags().StringVarP(&orphansSubscription, "subscription", "s", "", "Azure subscription ID")
+	orphansCmd.Flags().BoolVar(&orphansDelete, "delete", false, "Delete the orphaned subscriptions")
+	orphansCmd.Flags().BoolVar(&orphansDryRun, "dry-run", false, "With --delete, preview deletions without applying them")
+	orphansCmd.Flags().BoolVar(&orphansNoBackup, "no-backup", false, "With --delete, do not back up the subscriptions before deleting them")
+	registerYesFlags(orphansCmd, &orphansYes)
+
+	orphansCmd.MarkFlagRequired("resource-group")
+	orphansCmd.MarkFlagRequired("apim-name")
+}
+
+// orphanedSubscription is a subscription whose scope target does not exist.
+type orphanedSubscription struct {
+	sub    azure.SubscriptionInfo
+	reason string
+}
+
+// findOrphans returns the subscriptions in subs that are scoped to a product
+// or API missing from products and apis. Names are compared case-insensitively,
+// as APIM does.
+func findOrphans(subs []azure.SubscriptionInfo, products []azure.ProductInfo, apis []azure.APIInfo) []orphanedSubscription {
+	productIDs := make(map[string]bool, len(products))
+	for _, p := range products {
+		productIDs[strings.ToLower(p.ID)] = true
+	}
+	apiIDs := make(map[string]bool, len(apis))
+	for _, a := range apis {
+		// Revisions are listed as "<api>;rev=<n>", scopes use the plain name.
+		id, _, _ := strings.Cut(a.ID, ";")
+		apiIDs[strings.ToLower(id)] = true
+	}
+
+	var orphans []orphanedSubscription
+	for _, sub := range subs {
+		if sub.Name == "master" {
+			continue
+		}
+		kind, id, ok := strings.Cut(extractScopeSuffix(sub.Properties.Scope), "/")
+		if !ok || id == "" {
+			continue
+		}
+		switch {
+		case kind == "products" && !productIDs[strings.ToLower(id)]:
+			orphans = append(orphans, orphanedSubscription{sub, "product " + id + " does not exist"})
+		case kind == "apis" && !apiIDs[strings.ToLower(id)]:
+			orphans = append(orphans, orphanedSubscription{sub, "API " + id + " does not exist"})
+		}
+	}
+	return orphans
+}
+
+func runOrphans(cmd *cobra.Command, args []string) error {
+	if !orphansDelete && (orphansDryRun || orphansNoBackup) {
+		return fmt.Errorf("--dry-run and --no-backup require --delete")
+	}
+
+	fmt.Printf("Checking subscription scopes in APIM instance: %s\n", orphansAPIMName)
+	fmt.Printf("Resource Group: %s\n", orphansResourceGroup)
+	if orphansSubscription != "" {
+		fmt.Printf("Subscription ID: %s\n", orphansSubscription)
+	}
+
+	if orphansDryRun {
+		fmt.Println("\nRunning in DRY-RUN mode. No changes will be applied.")
+	}
+
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	fmt.Println("\nAuthenticating with Azure CLI...")
+
+	client, err := azure.NewClient(ctx, orphansSubscription, orphansResourceGroup, orphansAPIMName)
+	if err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	fmt.Println("Successfully authenticated with Azure CLI")
+
+	fmt.Println("\nFetching subscriptions, products and APIs...")
+	subs, err := client.ListSubscriptionsWithoutKeys(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+	products, err := client.ListProducts(ctx)
+	if err != nil {
+		return err
+	}
+	apis, err := client.ListAPIs(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Found %d subscription(s), %d product(s) and %d API(s)\n", len(subs), len(products), len(apis))
+
+	orphans := findOrphans(subs, products, apis)
+	if len(orphans) == 0 {
+		fmt.Println("\nNo orphaned subscriptions found.")
+		return nil
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SID\tDISPLAY NAME\tSTATE\tREASON")
+	for _, o := range orphans {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", o.sub.Name, o.sub.Properties.DisplayName, o.sub.Properties.State, o.reason)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\n%d orphaned subscription(s)\n", len(orphans))
+
+	if !orphansDelete {
+		return nil
+	}
+	return deleteOrphans(ctx, client, orphans)
+}
+
+// deleteOrphans deletes the orphaned subscriptions, after confirmation and a
+// pre-delete backup unless disabled.
+func deleteOrphans(ctx context.Context, client azure.SubscriptionStore, orphans []orphanedSubscription) error {
+	if !orphansDryRun && !orphansYes {
+		fmt.Println()
+		ok, err := confirm(fmt.Sprintf("Delete %d orphaned subscription(s) from APIM instance %s?", len(orphans), orphansAPIMName))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println("Aborted. No subscriptions were deleted.")
+			return nil
+		}
+	}
+
+	if !orphansDryRun && !orphansNoBackup {
+		// The subscriptions were listed without keys; fetch them for the backup.
+		subs := make([]azure.SubscriptionInfo, 0, len(orphans))
+		for _, o := range orphans {
+			sub, err := client.GetSubscription(ctx, o.sub.Name)
+			if err != nil {
+				return fmt.Errorf("failed to back up subscription %s: %w", o.sub.Name, err)
+			}
+			subs = append(subs, *sub)
+		}
+		if _, err := backupBeforeDelete(orphansResourceGroup, orphansAPIMName, subs); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println()
+	var deleted, failed int
+	for _, o := range orphans {
+		sid := o.sub.Name
+		displayName := o.sub.Properties.DisplayName
+
+		if orphansDryRun {
+			fmt.Printf("  [DRY-RUN] Would delete: %s (id=%s)\n", displayName, sid)
+			deleted++
+			continue
+		}
+
+		fmt.Printf("  Deleting: %s (id=%s)...\n", displayName, sid)
+		if err := client.DeleteSubscription(ctx, sid); err != nil && !azure.IsNotFound(err) {
+			fmt.Printf("  [FAIL] %s: %v\n", displayName, err)
+			failed++
+			continue
+		}
+		fmt.Printf("  [OK]   %s\n", displayName)
+		deleted++
+	}
+
+	fmt.Printf("\nDelete complete: %d deleted, %d failed\n", deleted, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d subscription(s) failed to delete", failed)
+	}
+	return nil
+}
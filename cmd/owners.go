@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/spf13/cobra"
+)
+
+var ownersCmd = &cobra.Command{
+	Use:   "owners",
+	Short: "List the owners of subscriptions",
+	Long: `Owners lists the distinct users that own subscriptions in an Azure API
+Management instance, with their name and email from the Users API, the number
+of subscriptions they own and the states of those subscriptions. It shows who
+holds keys to an instance.
+
+Subscriptions without an owner are grouped under "(none)".
+
+Example:
+  kura owners --resource-group mygroup --apim-name myapim
+  kura owners -g mygroup -a myapim --product-id starter --output json`,
+	Args: cobra.NoArgs,
+	RunE: runOwners,
+}
+
+var (
+	ownersResourceGroup string
+	ownersAPIMName      string
+	ownersSubscription  string
+	ownersProductID     string
+	ownersOutput        string
+)
+
+func init() {
+	rootCmd.AddCommand(ownersCmd)
+
+	ownersCmd.Flags().StringVarP(&ownersResourceGroup, "resource-group", "g", "", "Azure resource group name (required)")
+	ownersCmd.Flags().StringVarP(&ownersAPIMName, "apim-name", "a", "", "Azure API Management instance name (required)")
+	ownersCmd.Flags().StringVarP(&ownersSubscription, "subscription", "s", "", "Azure subscription ID")
+	ownersCmd.Flags().StringVarP(&ownersProductID, "product-id", "p", "", "Only count subscriptions scoped to this product")
+	ownersCmd.Flags().StringVar(&ownersOutput, "output", "text", "Output format: text or json")
+
+	ownersCmd.MarkFlagRequired("resource-group")
+	ownersCmd.MarkFlagRequired("apim-name")
+}
+
+// subscriptionOwner is a user owning subscriptions, with their counts per state.
+type subscriptionOwner struct {
+	UserID        string         `json:"userId"`
+	Name          string         `json:"name,omitempty"`
+	Email         string         `json:"email,omitempty"`
+	Subscriptions int            `json:"subscriptions"`
+	ByState       map[string]int `json:"byState"`
+}
+
+// groupOwners groups subs by owner, ordered by descending subscription count.
+// Subscriptions without an owner are grouped under the user ID "(none)".
+func groupOwners(subs []azure.SubscriptionInfo) []*subscriptionOwner {
+	byUser := make(map[string]*subscriptionOwner)
+	var owners []*subscriptionOwner
+	for _, sub := range subs {
+		userID := azure.OwnerUserID(sub.Properties.OwnerID)
+		if userID == "" {
+			userID = "(none)"
+		}
+		owner, ok := byUser[userID]
+		if !ok {
+			owner = &subscriptionOwner{
+				UserID:  userID,
+				Name:    sub.Properties.OwnerName,
+				Email:   sub.Properties.OwnerEmail,
+				ByState: make(map[string]int),
+			}
+			byUser[userID] = owner
+			owners = append(owners, owner)
+		}
+		owner.Subscriptions++
+		owner.ByState[sub.Properties.State]++
+	}
+
+	sort.SliceStable(owners, func(i, j int) bool {
+		if owners[i].Subscriptions != owners[j].Subscriptions {
+			return owners[i].Subscriptions > owners[j].Subscriptions
+		}
+		return owners[i].UserID < owners[j].UserID
+	})
+	return owners
+}
+
+func runOwners(cmd *cobra.Command, args []string) error {
+	switch ownersOutput {
+	case "text", "json":
+	default:
+		return fmt.Errorf("invalid --output %q: must be text or json", ownersOutput)
+	}
+
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	client, err := azure.NewClient(ctx, ownersSubscription, ownersResourceGroup, ownersAPIMName)
+	if err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+
+	subs, err := client.ListSubscriptionsWithoutKeys(ctx, ownersProductID)
+	if err != nil {
+		return fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+	if err := client.ResolveOwners(ctx, subs); err != nil {
+		return fmt.Errorf("failed to resolve owners: %w", err)
+	}
+
+	owners := groupOwners(subs)
+	if ownersOutput == "json" {
+		return printJSON(owners)
+	}
+
+	if len(owners) == 0 {
+		fmt.Println("No subscriptions found.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "USER ID\tNAME\tEMAIL\tSUBSCRIPTIONS\tSTATES")
+	for _, owner := range owners {
+		name, email := owner.Name, owner.Email
+		if name == "" {
+			name = "-"
+		}
+		if email == "" {
+			email = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", owner.UserID, name, email, owner.Subscriptions, formatStateCounts(owner.ByState))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n%d owner(s) of %d subscription(s)\n", len(owners), len(subs))
+	return nil
+}
+
+// formatStateCounts formats counts as "active: 3, suspended: 1".
+func formatStateCounts(counts map[string]int) string {
+	parts := make([]string, 0, len(counts))
+	for _, state := range sortedCountKeys(counts) {
+		parts = append(parts, fmt.Sprintf("%s: %d", state, counts[state]))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/f-marschall/apim-kura/pkg/backup"
+	"github.com/spf13/cobra"
+)
+
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Save the plan that makes an APIM instance match a desired-state file",
+	Long: `Plan computes the creates, updates and deletes that make the subscriptions of
+an Azure API Management instance match a desired-state backup file, exactly as
+apply -f does, prints them and saves them to a plan file without changing
+anything. The plan can be reviewed and approved, then executed with:
+
+  kura apply plan.json
+
+apply refuses a plan if the subscriptions of the instance changed after the
+plan was created; run plan again in that case.
+
+The plan file contains the keys of the subscriptions it creates or updates and
+is written with permissions 0600. Treat it like a backup file.
+
+Example:
+  kura plan -g mygroup -a myapim -f desired.json --out plan.json
+  kura plan -g mygroup -a myapim -f desired.json --prune --out plan.json`,
+	Args: cobra.NoArgs,
+	RunE: runPlan,
+}
+
+var (
+	planResourceGroup string
+	planAPIMName      string
+	planSubscription  string
+	planFile          string
+	planProductID     string
+	planOwnerMap      string
+	planPrune         bool
+	planOut           string
+)
+
+func init() {
+	rootCmd.AddCommand(planCmd)
+
+	planCmd.Flags().StringVarP(&planResourceGroup, "resource-group", "g", "", "Azure resource group name (required)")
+	planCmd.Flags().StringVarP(&planAPIMName, "apim-name", "a", "", "Azure API Management instance name (required)")
+	planCmd.Flags().StringVarP(&planSubscription, "subscription", "s", "", "Azure subscription ID")
+	planCmd.Flags().StringVarP(&planFile, "file", "f", "", "Backup file holding the desired subscriptions (required)")
+	planCmd.Flags().StringVarP(&planProductID, "product-id", "p", "", "Only manage subscriptions scoped to this product")
+	planCmd.Flags().StringVar(&planOwnerMap, "owner-map", "", "YAML file mapping user IDs in the file to user IDs of the instance (or \"drop\")")
+	planCmd.Flags().BoolVar(&planPrune, "prune", false, "Delete subscriptions that are not in the file")
+	planCmd.Flags().StringVarP(&planOut, "out", "o", "plan.json", "Path of the plan file")
+
+	planCmd.MarkFlagRequired("resource-group")
+	planCmd.MarkFlagRequired("apim-name")
+	planCmd.MarkFlagRequired("file")
+}
+
+// planFileVersion is the version of the plan file format written by plan.
+const planFileVersion = 1
+
+// savedPlan is a reconcilePlan saved by the plan command for a later apply.
+type savedPlan struct {
+	Version        int       `json:"version"`
+	CreatedAt      time.Time `json:"createdAt"`
+	SubscriptionID string    `json:"subscriptionId"`
+	ResourceGroup  string    `json:"resourceGroup"`
+	APIMName       string    `json:"apimName"`
+	ProductID      string    `json:"productId,omitempty"`
+	DesiredState   string    `json:"desiredState"`
+	Prune          bool      `json:"prune"`
+	// LiveHash is the backup.ContentHash of the live subscriptions the plan
+	// was computed against. apply refuses the plan if it no longer matches.
+	LiveHash string        `json:"liveHash"`
+	Plan     reconcilePlan `json:"plan"`
+}
+
+func runPlan(cmd *cobra.Command, args []string) error {
+	fmt.Printf("Planning changes to APIM instance: %s\n", planAPIMName)
+	fmt.Printf("Resource Group: %s\n", planResourceGroup)
+	if planSubscription != "" {
+		fmt.Printf("Subscription ID: %s\n", planSubscription)
+	}
+
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	client, plan, live, err := planDesiredState(ctx, desiredStateOptions{
+		subscription:  planSubscription,
+		resourceGroup: planResourceGroup,
+		apimName:      planAPIMName,
+		file:          planFile,
+		productID:     planProductID,
+		ownerMap:      planOwnerMap,
+		prune:         planPrune,
+	})
+	if err != nil {
+		return err
+	}
+
+	liveHash, err := backup.ContentHash(live)
+	if err != nil {
+		return err
+	}
+	saved := &savedPlan{
+		Version:        planFileVersion,
+		CreatedAt:      time.Now().UTC(),
+		SubscriptionID: client.SubscriptionID(),
+		ResourceGroup:  planResourceGroup,
+		APIMName:       planAPIMName,
+		ProductID:      planProductID,
+		DesiredState:   planFile,
+		Prune:          planPrune,
+		LiveHash:       liveHash,
+		Plan:           *plan,
+	}
+
+	fmt.Println()
+	plan.print()
+	if len(plan.Actions) == 0 {
+		fmt.Println("Instance matches the desired state. No plan file written.")
+		return nil
+	}
+
+	if err := saved.save(planOut); err != nil {
+		return err
+	}
+	fmt.Printf("\nPlan saved to: %s\n", planOut)
+	fmt.Printf("To apply it, run: kura apply %s\n", planOut)
+	return nil
+}
+
+// save writes the plan to path with permissions 0600, as it contains keys.
+func (p *savedPlan) save(path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal plan: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write plan file: %w", err)
+	}
+	return nil
+}
+
+// loadSavedPlan reads a plan file written by the plan command.
+func loadSavedPlan(path string) (*savedPlan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan file: %w", err)
+	}
+	var p savedPlan
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse plan file %s: %w", path, err)
+	}
+	if p.Version != planFileVersion {
+		return nil, fmt.Errorf("plan file %s has version %d, expected %d", path, p.Version, planFileVersion)
+	}
+	for _, a := range p.Plan.Actions {
+		if a.Action != actionDelete && a.Desired == nil {
+			return nil, fmt.Errorf("plan file %s: %s action for sid %s has no desired subscription", path, a.Action, a.SID)
+		}
+	}
+	return &p, nil
+}
+
+// runApplySavedPlan executes a plan file written by the plan command, after
+// checking that the instance has not changed since the plan was created.
+func runApplySavedPlan(path string) error {
+	saved, err := loadSavedPlan(path)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Applying plan to APIM instance: %s\n", saved.APIMName)
+	fmt.Printf("Resource Group: %s\n", saved.ResourceGroup)
+	fmt.Printf("Subscription ID: %s\n", saved.SubscriptionID)
+	fmt.Printf("Plan: %s (created %s from %s)\n", path, saved.CreatedAt.Format(time.RFC3339), saved.DesiredState)
+	if saved.ProductID != "" {
+		fmt.Printf("Product ID: %s\n", saved.ProductID)
+	}
+
+	if applyDryRun {
+		fmt.Println("\nRunning in DRY-RUN mode. No changes will be applied.")
+	}
+
+	if !applyDryRun {
+		unlock, err := lockInstance(saved.ResourceGroup, saved.APIMName, "apply")
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	fmt.Println("\nAuthenticating with Azure CLI...")
+	client, err := azure.NewClient(ctx, saved.SubscriptionID, saved.ResourceGroup, saved.APIMName)
+	if err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	fmt.Println("Successfully authenticated with Azure CLI")
+
+	fmt.Println("\nChecking that the instance has not changed since the plan was created...")
+	live, err := client.ListSubscriptions(ctx, saved.ProductID)
+	if err != nil {
+		return fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+	liveHash, err := backup.ContentHash(live)
+	if err != nil {
+		return err
+	}
+	if liveHash != saved.LiveHash {
+		return fmt.Errorf("the subscriptions of %s changed since the plan was created; run kura plan again", saved.APIMName)
+	}
+
+	fmt.Println()
+	saved.Plan.print()
+	if applyDryRun {
+		return nil
+	}
+
+	return applyPlan(ctx, client, &saved.Plan, live, saved.ResourceGroup, saved.APIMName, applyYes, applyNoBackup)
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/spf13/cobra"
+)
+
+var productsCmd = &cobra.Command{
+	Use:   "products",
+	Short: "List the products of an Azure API Management instance",
+	Long: `Products lists the products of an Azure API Management instance with their
+IDs, display names, state, whether they require a subscription and the number
+of subscriptions scoped to them. The IDs are the values accepted by
+--product-id.
+
+Example:
+  kura products --resource-group mygroup --apim-name myapim
+  kura products -g mygroup -a myapim --output json`,
+	Args: cobra.NoArgs,
+	RunE: runProducts,
+}
+
+var (
+	productsResourceGroup string
+	productsAPIMName      string
+	productsSubscription  string
+	productsOutput        string
+)
+
+func init() {
+	rootCmd.AddCommand(productsCmd)
+
+	productsCmd.Flags().StringVarP(&productsResourceGroup, "resource-group", "g", "", "Azure resource group name (required)")
+	productsCmd.Flags().StringVarP(&productsAPIMName, "apim-name", "a", "", "Azure API Management instance name (required)")
+	productsCmd.Flags().StringVarP(&productsSubscription, "subscription", "s", "", "Azure subscription ID")
+	productsCmd.Flags().StringVar(&productsOutput, "output", "text", "Output format: text or json")
+
+	productsCmd.MarkFlagRequired("resource-group")
+	productsCmd.MarkFlagRequired("apim-name")
+}
+
+// productItem is a product with the number of subscriptions scoped to it.
+type productItem struct {
+	azure.ProductInfo
+	Subscriptions int `json:"subscriptions"`
+}
+
+func runProducts(cmd *cobra.Command, args []string) error {
+	switch productsOutput {
+	case "text", "json":
+	default:
+		return fmt.Errorf("invalid --output %q: must be text or json", productsOutput)
+	}
+
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	client, err := azure.NewClient(ctx, productsSubscription, productsResourceGroup, productsAPIMName)
+	if err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+
+	products, err := client.ListProducts(ctx)
+	if err != nil {
+		return err
+	}
+	subs, err := client.ListSubscriptionsWithoutKeys(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+
+	// Product names are case-insensitive in APIM.
+	counts := make(map[string]int)
+	for _, sub := range subs {
+		if kind, id, ok := strings.Cut(extractScopeSuffix(sub.Properties.Scope), "/"); ok && kind == "products" {
+			counts[strings.ToLower(id)]++
+		}
+	}
+
+	items := make([]productItem, len(products))
+	for i, p := range products {
+		items[i] = productItem{ProductInfo: p, Subscriptions: counts[strings.ToLower(p.ID)]}
+	}
+
+	if productsOutput == "json" {
+		return printJSON(items)
+	}
+
+	if len(items) == 0 {
+		fmt.Println("No products found.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tDISPLAY NAME\tSTATE\tSUBSCRIPTION REQUIRED\tSUBSCRIPTIONS")
+	for _, item := range items {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%d\n", item.ID, item.DisplayName, item.State, item.SubscriptionRequired, item.Subscriptions)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n%d product(s)\n", len(items))
+	return nil
+}
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+)
+
+// showListProgress prints the pages that client fetches while listing
+// subscriptions, so large instances do not look stuck. The returned function
+// stops printing; call it before printing anything else.
+func showListProgress(client *azure.Client) (stop func()) {
+	events, unsubscribe := client.Subscribe(64)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for e := range events {
+			if e.Kind == azure.EventPageFetched {
+				fmt.Printf("  Fetched %d subscription(s)...\n", e.Total)
+			}
+		}
+	}()
+	return func() {
+		unsubscribe()
+		<-done
+	}
+}
@@ -0,0 +1,327 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/f-marschall/apim-kura/internal/config"
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/f-marschall/apim-kura/pkg/backup"
+	"github.com/spf13/cobra"
+)
+
+var promoteCmd = &cobra.Command{
+	Use:   "promote",
+	Short: "Promote subscriptions from one configured environment to another",
+	Long: `Promote copies the subscriptions of the APIM instance of one profile to the
+instance of another, such as from dev to staging: subscriptions missing in the
+target are created and subscriptions whose attributes or keys differ are
+updated. Subscriptions that exist only in the target are left alone.
+
+Profiles are defined in the kura configuration file (see --config):
+
+  profiles:
+    dev:
+      subscription: <azure-subscription-id>
+      resourceGroup: dev-rg
+      apimName: dev-apim
+    staging:
+      tenant: contoso.onmicrosoft.com
+      subscription: <azure-subscription-id>
+      resourceGroup: staging-rg
+      apimName: staging-apim
+
+--product-map renames products between the environments, or leaves the
+subscriptions of a product out with "drop". --owner-map does the same for
+owners. Before anything is written, kura checks that the products, APIs and
+users the subscriptions refer to exist in the target instance; subscriptions
+that fail this check are not promoted. The built-in master subscription is
+never promoted.
+
+If the promotion updates existing subscriptions, kura asks for confirmation;
+use --yes (or --force) to skip the prompt.
+
+Every run, including --dry-run, writes a promotion report without keys to
+backup/.promotions/<target-resource-group>/<target-apim>/ (see --report).
+
+Example:
+  kura promote --from dev --to staging --dry-run
+  kura promote --from dev --to staging --product-map map.yaml --owner-map owners.yaml --yes`,
+	Args: cobra.NoArgs,
+	RunE: runPromote,
+}
+
+var (
+	promoteFrom       string
+	promoteTo         string
+	promoteConfig     string
+	promoteProductID  string
+	promoteProductMap string
+	promoteOwnerMap   string
+	promoteDryRun     bool
+	promoteReport     string
+	promoteYes        bool
+)
+
+func init() {
+	rootCmd.AddCommand(promoteCmd)
+
+	promoteCmd.Flags().StringVar(&promoteFrom, "from", "", "Profile to promote the subscriptions from (required)")
+	promoteCmd.Flags().StringVar(&promoteTo, "to", "", "Profile to promote the subscriptions to (required)")
+	promoteCmd.Flags().StringVar(&promoteConfig, "config", "", "Configuration file defining the profiles (default $"+config.PathEnv+" or ~/.config/kura/config.yaml)")
+	promoteCmd.Flags().StringVarP(&promoteProductID, "product-id", "p", "", "Only promote subscriptions scoped to this source product")
+	promoteCmd.Flags().StringVar(&promoteProductMap, "product-map", "", "YAML file mapping source product IDs to target product IDs (or \"drop\")")
+	promoteCmd.Flags().StringVar(&promoteOwnerMap, "owner-map", "", "YAML file mapping source user IDs to target user IDs (or \"drop\")")
+	promoteCmd.Flags().BoolVar(&promoteDryRun, "dry-run", false, "Check and preview the promotion without applying it")
+	promoteCmd.Flags().StringVar(&promoteReport, "report", "", "Path of the promotion report (default: a new file under backup/.promotions)")
+	registerYesFlags(promoteCmd, &promoteYes)
+
+	promoteCmd.MarkFlagRequired("from")
+	promoteCmd.MarkFlagRequired("to")
+}
+
+func runPromote(cmd *cobra.Command, args []string) error {
+	if promoteFrom == promoteTo {
+		return fmt.Errorf("--from and --to are the same profile")
+	}
+	if promoteConfig == "" {
+		promoteConfig = config.DefaultPath()
+	}
+	cfg, err := config.Load(promoteConfig)
+	if err != nil {
+		return err
+	}
+	from, err := cfg.Profile(promoteFrom)
+	if err != nil {
+		return err
+	}
+	to, err := cfg.Profile(promoteTo)
+	if err != nil {
+		return err
+	}
+	if from == to {
+		return fmt.Errorf("profiles %q and %q are the same APIM instance", promoteFrom, promoteTo)
+	}
+
+	fmt.Printf("Promoting subscription keys from %s to %s\n", promoteFrom, promoteTo)
+	fmt.Printf("Source: %s (resource group %s%s)\n", from.APIMName, from.ResourceGroup, describeTenant(from.Tenant))
+	fmt.Printf("Target: %s (resource group %s%s)\n", to.APIMName, to.ResourceGroup, describeTenant(to.Tenant))
+	if promoteProductID != "" {
+		fmt.Printf("Product ID: %s\n", promoteProductID)
+	}
+
+	var productMap backup.ProductMap
+	if promoteProductMap != "" {
+		productMap, err = backup.LoadProductMap(promoteProductMap)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Product map: %s (%d entries)\n", promoteProductMap, len(productMap))
+	}
+	var ownerMap backup.OwnerMap
+	if promoteOwnerMap != "" {
+		ownerMap, err = backup.LoadOwnerMap(promoteOwnerMap)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Owner map: %s (%d entries)\n", promoteOwnerMap, len(ownerMap))
+	}
+
+	if promoteDryRun {
+		fmt.Println("\nRunning in DRY-RUN mode. No changes will be applied.")
+	}
+
+	if !promoteDryRun {
+		unlock, err := lockInstance(to.ResourceGroup, to.APIMName, "promote")
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	fmt.Println("\nAuthenticating with Azure CLI...")
+
+	source, err := azure.NewClientForTenant(ctx, from.Tenant, from.SubscriptionID, from.ResourceGroup, from.APIMName)
+	if err != nil {
+		return fmt.Errorf("authentication for profile %s failed: %w", promoteFrom, err)
+	}
+	target, err := azure.NewClientForTenant(ctx, to.Tenant, to.SubscriptionID, to.ResourceGroup, to.APIMName)
+	if err != nil {
+		return fmt.Errorf("authentication for profile %s failed: %w", promoteTo, err)
+	}
+	fmt.Println("Successfully authenticated with Azure CLI")
+
+	report := &backup.PromotionReport{
+		From: promoteFrom,
+		To:   promoteTo,
+		Source: backup.MigrationEndpoint{
+			TenantID:       from.Tenant,
+			SubscriptionID: source.SubscriptionID(),
+			ResourceGroup:  from.ResourceGroup,
+			APIMName:       from.APIMName,
+		},
+		Target: backup.MigrationEndpoint{
+			TenantID:       to.Tenant,
+			SubscriptionID: target.SubscriptionID(),
+			ResourceGroup:  to.ResourceGroup,
+			APIMName:       to.APIMName,
+		},
+		ProductMap: promoteProductMap,
+		DryRun:     promoteDryRun,
+		StartedAt:  time.Now().UTC(),
+	}
+
+	fmt.Println("\nFetching subscriptions of the source instance...")
+	all, err := source.ListSubscriptions(ctx, promoteProductID)
+	if err != nil {
+		return fmt.Errorf("failed to list source subscriptions: %w", err)
+	}
+	subs := filterOutMaster(all)
+	if len(subs) == 0 {
+		fmt.Println("No subscriptions found. Nothing to promote.")
+		return nil
+	}
+	fmt.Printf("Found %d subscription(s)\n", len(subs))
+
+	// Products are renamed in the source scopes, so that retarget builds the
+	// scopes of the target instance from them.
+	entries := make(map[string]*backup.PromotionEntry, len(subs))
+	var kept []azure.SubscriptionInfo
+	for _, sub := range subs {
+		suffix := extractScopeSuffix(sub.Properties.Scope)
+		report.Entries = append(report.Entries, backup.PromotionEntry{
+			SID:         sub.Name,
+			DisplayName: sub.Properties.DisplayName,
+			SourceScope: suffix,
+		})
+		if productID, ok := strings.CutPrefix(suffix, "products/"); ok {
+			mapped, drop := productMap.Lookup(productID)
+			if drop {
+				e := &report.Entries[len(report.Entries)-1]
+				e.Outcome, e.Reason = backup.PromotionSkipped, "product "+productID+" is dropped by the product map"
+				continue
+			}
+			sub.Properties.Scope = buildScopeFromSuffix(source.SubscriptionID(), from.ResourceGroup, from.APIMName, "products/"+mapped)
+		}
+		kept = append(kept, sub)
+	}
+	for i := range report.Entries {
+		entries[report.Entries[i].SID] = &report.Entries[i]
+	}
+
+	fmt.Println("\nChecking the target instance...")
+	desired := retarget(kept, ownerMap, target.SubscriptionID(), to.ResourceGroup, to.APIMName)
+	problems, err := checkMigrationTargets(ctx, target, desired)
+	if err != nil {
+		return err
+	}
+	live, err := target.ListSubscriptions(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed to list target subscriptions: %w", err)
+	}
+
+	var promotable []azure.SubscriptionInfo
+	for _, sub := range desired {
+		e := entries[sub.Name]
+		e.TargetScope = extractScopeSuffix(sub.Properties.Scope)
+		if problems[sub.Name] != "" {
+			e.Outcome, e.Reason = backup.PromotionFailed, problems[sub.Name]
+			continue
+		}
+		promotable = append(promotable, sub)
+	}
+	plan := buildPlan(promotable, live, false)
+	for _, a := range plan.Actions {
+		e := entries[a.SID]
+		e.Action = a.Action
+		for _, c := range a.Changes {
+			e.Changes = append(e.Changes, c.Field)
+		}
+	}
+	for i := range report.Entries {
+		if e := &report.Entries[i]; e.Outcome == "" && e.Action == "" {
+			e.Outcome = backup.PromotionUnchanged
+		}
+	}
+
+	fmt.Println()
+	for _, e := range report.Entries {
+		switch e.Outcome {
+		case backup.PromotionSkipped:
+			fmt.Printf("  [SKIP] %s (sid=%s): %s\n", e.DisplayName, e.SID, e.Reason)
+		case backup.PromotionFailed:
+			fmt.Printf("  [FAIL] %s (sid=%s): %s\n", e.DisplayName, e.SID, e.Reason)
+		}
+	}
+	plan.print()
+
+	applied := true
+	switch {
+	case len(plan.Actions) == 0:
+		fmt.Println("Target is up to date. Nothing to promote.")
+	case promoteDryRun:
+		for _, a := range plan.Actions {
+			entries[a.SID].Outcome = backup.PromotionPlanned
+		}
+	default:
+		applied, err = promotePlan(ctx, target, plan, entries, to.APIMName)
+		if err != nil {
+			return err
+		}
+	}
+	if !applied {
+		return nil
+	}
+	report.FinishedAt = time.Now().UTC()
+
+	reportPath := promoteReport
+	if reportPath == "" {
+		reportPath = backup.NewPromotionReportPath(to.ResourceGroup, to.APIMName, report.StartedAt)
+	}
+	if err := report.Save(reportPath); err != nil {
+		return err
+	}
+
+	promoted := report.Count(backup.PromotionPromoted) + report.Count(backup.PromotionPlanned)
+	unchanged, skipped, failed := report.Count(backup.PromotionUnchanged), report.Count(backup.PromotionSkipped), report.Count(backup.PromotionFailed)
+	fmt.Printf("\nPromotion complete: %d promoted, %d unchanged, %d skipped, %d failed\n", promoted, unchanged, skipped, failed)
+	fmt.Printf("Promotion report saved to %s\n", reportPath)
+	if failed > 0 {
+		return fmt.Errorf("%d subscription(s) failed to promote", failed)
+	}
+	return nil
+}
+
+// promotePlan asks for confirmation if plan updates existing subscriptions,
+// then executes it and records the outcome of each action in entries. It
+// returns false if the user aborted.
+func promotePlan(ctx context.Context, target azure.SubscriptionStore, plan *reconcilePlan, entries map[string]*backup.PromotionEntry, apimName string) (bool, error) {
+	if updates := plan.count(actionUpdate); updates > 0 && !promoteYes {
+		fmt.Println()
+		ok, err := confirm(fmt.Sprintf("Promote %d subscription(s) to APIM instance %s, updating %d existing subscription(s)?", len(plan.Actions), apimName, updates))
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			fmt.Println("Aborted. No subscriptions were promoted.")
+			return false, nil
+		}
+	}
+
+	fmt.Println()
+	for _, a := range plan.Actions {
+		e := entries[a.SID]
+		if err := a.apply(ctx, target); err != nil {
+			fmt.Printf("  [FAIL] %s %s: %v\n", a.Action, a.DisplayName, err)
+			e.Outcome, e.Reason = backup.PromotionFailed, err.Error()
+			continue
+		}
+		fmt.Printf("  [OK]   %s %s (sid=%s)\n", actionDone[a.Action], a.DisplayName, a.SID)
+		e.Outcome = backup.PromotionPromoted
+	}
+	return true, nil
+}
@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/f-marschall/apim-kura/pkg/backup"
+)
+
+// Kinds of reconcileAction.
+const (
+	actionCreate = "create"
+	actionUpdate = "update"
+	actionDelete = "delete"
+)
+
+// actionDone describes a completed action in output.
+var actionDone = map[string]string{
+	actionCreate: "Created",
+	actionUpdate: "Updated",
+	actionDelete: "Deleted",
+}
+
+// reconcileAction is a single change that makes a live subscription match
+// the desired state.
+type reconcileAction struct {
+	Action      string      `json:"action"`
+	SID         string      `json:"sid"`
+	DisplayName string      `json:"displayName"`
+	Changes     []fieldDiff `json:"changes,omitempty"`
+	// Desired is the subscription to create or update, with its scope and
+	// owner already pointing to the target instance. It is nil for deletes.
+	Desired *azure.SubscriptionInfo `json:"desired,omitempty"`
+}
+
+// reconcilePlan lists the actions that make an instance match a desired state.
+type reconcilePlan struct {
+	Actions   []reconcileAction `json:"actions"`
+	Unchanged int               `json:"unchanged"`
+}
+
+// retarget prepares desired subscriptions for the given target instance: the
+// master subscription is dropped, scopes are rebuilt for the target and owners
+// point to the user with the same ID in the target instance, unless ownerMap
+// maps them to another user or drops them.
+func retarget(subs []azure.SubscriptionInfo, ownerMap backup.OwnerMap, azureSubID, resourceGroup, apimName string) []azure.SubscriptionInfo {
+	var result []azure.SubscriptionInfo
+	for _, sub := range filterOutMaster(subs) {
+		_, sub.Properties.Scope = targetScope(&sub, azureSubID, resourceGroup, apimName)
+		if _, _, mapped := ownerMap.Lookup(sub.Properties.OwnerID); mapped {
+			remapOwner(&sub, ownerMap, azureSubID, resourceGroup, apimName)
+		} else if userID := azure.OwnerUserID(sub.Properties.OwnerID); userID != "" {
+			sub.Properties.OwnerID = buildScopeFromSuffix(azureSubID, resourceGroup, apimName, "users/"+userID)
+		}
+		result = append(result, sub)
+	}
+	return result
+}
+
+// buildPlan compares the desired subscriptions with the live ones, by sid.
+// Desired subscriptions that do not exist are created and those that differ
+// are updated. With prune, live subscriptions that are not desired are
+// deleted. The master subscription is never touched.
+func buildPlan(desired, live []azure.SubscriptionInfo, prune bool) *reconcilePlan {
+	liveBySID := make(map[string]*azure.SubscriptionInfo, len(live))
+	for i := range live {
+		liveBySID[live[i].Name] = &live[i]
+	}
+
+	plan := &reconcilePlan{}
+	wanted := make(map[string]bool, len(desired))
+	for i := range desired {
+		want := &desired[i]
+		if want.Name == "master" {
+			continue
+		}
+		wanted[want.Name] = true
+
+		got, ok := liveBySID[want.Name]
+		if !ok {
+			plan.Actions = append(plan.Actions, reconcileAction{
+				Action:      actionCreate,
+				SID:         want.Name,
+				DisplayName: want.Properties.DisplayName,
+				Desired:     want,
+			})
+			continue
+		}
+		diffs := restoreConflicts(want, extractScopeSuffix(want.Properties.Scope), got)
+		if len(diffs) == 0 {
+			plan.Unchanged++
+			continue
+		}
+		plan.Actions = append(plan.Actions, reconcileAction{
+			Action:      actionUpdate,
+			SID:         want.Name,
+			DisplayName: want.Properties.DisplayName,
+			Changes:     diffs,
+			Desired:     want,
+		})
+	}
+
+	if prune {
+		for _, sub := range live {
+			if sub.Name == "master" || wanted[sub.Name] {
+				continue
+			}
+			plan.Actions = append(plan.Actions, reconcileAction{
+				Action:      actionDelete,
+				SID:         sub.Name,
+				DisplayName: sub.Properties.DisplayName,
+			})
+		}
+	}
+	return plan
+}
+
+// count returns the number of actions of the given kind.
+func (p *reconcilePlan) count(action string) int {
+	var n int
+	for _, a := range p.Actions {
+		if a.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// deleted returns the subscriptions of live that the plan deletes.
+func (p *reconcilePlan) deleted(live []azure.SubscriptionInfo) []azure.SubscriptionInfo {
+	sids := make(map[string]bool)
+	for _, a := range p.Actions {
+		if a.Action == actionDelete {
+			sids[a.SID] = true
+		}
+	}
+	var subs []azure.SubscriptionInfo
+	for _, sub := range live {
+		if sids[sub.Name] {
+			subs = append(subs, sub)
+		}
+	}
+	return subs
+}
+
+// print prints the actions of the plan and a summary line.
+func (p *reconcilePlan) print() {
+	for _, a := range p.Actions {
+		switch a.Action {
+		case actionCreate:
+			fmt.Printf("  [CREATE] %s (sid=%s, scope=%s)\n", a.DisplayName, a.SID, extractScopeSuffix(a.Desired.Properties.Scope))
+		case actionUpdate:
+			fmt.Printf("  [UPDATE] %s (sid=%s)\n", a.DisplayName, a.SID)
+			for _, d := range a.Changes {
+				fmt.Printf("      %s: %q -> %q\n", d.Field, d.Live, d.Backup)
+			}
+		case actionDelete:
+			fmt.Printf("  [DELETE] %s (sid=%s)\n", a.DisplayName, a.SID)
+		}
+	}
+	fmt.Printf("\nPlan: %d to create, %d to update, %d to delete, %d unchanged\n",
+		p.count(actionCreate), p.count(actionUpdate), p.count(actionDelete), p.Unchanged)
+}
+
+// execute applies the actions of the plan to the instance of client and
+// returns the number of applied and failed actions.
+func (p *reconcilePlan) execute(ctx context.Context, client azure.SubscriptionStore) (applied, failed int) {
+	for _, a := range p.Actions {
+		if err := a.apply(ctx, client); err != nil {
+			fmt.Printf("  [FAIL] %s %s: %v\n", a.Action, a.DisplayName, err)
+			failed++
+			continue
+		}
+		fmt.Printf("  [OK]   %s %s (sid=%s)\n", actionDone[a.Action], a.DisplayName, a.SID)
+		applied++
+	}
+	return applied, failed
+}
+
+// apply applies a single action to the instance of client.
+func (a *reconcileAction) apply(ctx context.Context, client azure.SubscriptionStore) error {
+	switch a.Action {
+	case actionCreate, actionUpdate:
+		opts, err := newCreateOptions(a.Desired)
+		if err != nil {
+			return err
+		}
+		_, err = client.CreateSubscription(ctx, a.SID, a.Desired.Properties.Scope, a.Desired.Properties.DisplayName, opts)
+		return warnExpiration(err)
+	case actionDelete:
+		return client.DeleteSubscription(ctx, a.SID)
+	default:
+		return fmt.Errorf("unknown action %q", a.Action)
+	}
+}
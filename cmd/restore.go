@@ -1,13 +1,20 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"text/template"
+	"time"
 
-	"github.com/f-marschall/apim-kura/internal/azure"
+	"github.com/f-marschall/apim-kura/internal/pool"
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/f-marschall/apim-kura/pkg/backup"
 	"github.com/spf13/cobra"
 )
 
@@ -18,9 +25,80 @@ var restoreCmd = &cobra.Command{
 	Long: `Restore reads a backup file and restores subscription keys
 to an Azure API Management instance.
 WARNING: The master subscription key is not restored as it is a built-in system subscription.
+
+Instead of --input, use --at to restore the newest snapshot taken at or before
+a point in time from the backup directory. By default the snapshot is looked up
+for the target instance; use --source-resource-group and --source-apim-name to
+restore another instance's snapshot.
+
+Use the --sid, --name, --sid-regex and --name-regex filters to restore only
+some entries (an entry is restored if it matches any of them), and
+--exclude-sid, --exclude-name and --exclude-regex to leave entries out.
+
+Use --skip-existing to leave subscriptions that already exist in the target
+untouched, which makes re-running a restore safe and fast.
+
+With --dry-run, each entry is compared with the live target and reported as
+would create, would update (with the per-field differences) or unchanged.
+
+Use --on-conflict to compare each entry with the live target before writing.
+Entries that are already up to date are left alone; for entries that exist
+with different attributes, skip leaves them, overwrite replaces them and fail
+aborts the restore.
+
+Use --owner-map when restoring into another instance or tenant, where the
+owner user IDs of the backup do not exist. The YAML file maps each source user
+ID (or full ownerId) to a target user ID, or to "drop" to restore without owner.
+
+Use --concurrency to restore several subscriptions in parallel and the global
+--max-rps to cap the Azure API request rate so large restores do not trip ARM
+throttling.
+
+Use --regenerate-keys to recreate the subscriptions with fresh keys generated
+by APIM instead of the backed-up ones, for example when cloning an environment
+where reusing production keys is forbidden. The old to new key mapping is
+written to --key-map.
+
+Entries without a name (sid) are given a GUID derived from their contents, so
+restoring the same file again reuses the same sids. Generated sids are listed
+in the summary.
+
+Use --interactive to review each subscription (name, scope, state and whether
+it overwrites an existing one) and answer y(es), N(o), a(ll remaining) or
+q(uit) before it is applied. Interactive restores run sequentially. Otherwise,
+if the restore would overwrite existing subscriptions, kura asks once for
+confirmation; use --yes (or --force) to skip the prompt in automation.
+
+Expiration dates are restored as well. Use --strip-expiration to restore the
+subscriptions without them. End dates are set by APIM and cannot be restored.
+
+Use --name-prefix, --name-suffix and --name-template to label restored
+subscriptions, e.g. in a test instance. The template is a Go text/template with
+the fields .DisplayName, .SID and .Scope; prefix and suffix are added around
+its result.
+
+Before applying any change, the current subscriptions of the target instance
+are saved to a rollback file under backup/.rollback, whose path is printed in
+the summary. Use --no-safety-backup to skip this step.
+
+Progress is saved to a checkpoint under backup/.checkpoints while restoring. If
+a restore is interrupted, re-run it with --resume to skip the subscriptions
+that were already applied.
 Example:
   kura restore --resource-group mygroup --apim-name myapim --input backup/mygroup/myapim/subscriptions.json
-  kura restore -g mygroup -a myapim -i backup/mygroup/myapim/myproduct/subscriptions.json --dry-run`,
+  kura restore -g mygroup -a myapim -i backup/mygroup/myapim/myproduct/subscriptions.json --dry-run
+  kura restore -g mygroup -a myapim --at 2024-05-01T00:00Z
+  kura restore -g mygroup -a myapim --at latest --skip-existing
+  kura restore -g mygroup -a myapim --at latest --on-conflict fail
+  kura restore -g target-rg -a target-apim -i subscriptions.json --owner-map owners.yaml
+  kura restore -g mygroup -a myapim --at latest --concurrency 8 --max-rps 20
+  kura restore -g mygroup -a myapim -i subscriptions.json --resume
+  kura restore -g test-rg -a test-apim -i subscriptions.json --regenerate-keys --key-map keys.json
+  kura restore -g test-rg -a test-apim -i subscriptions.json --name-prefix "copy-of-"
+  kura restore -g mygroup -a myapim --at latest --interactive
+  kura restore -g mygroup -a myapim -i subscriptions.json --sid 5f1c2a7e-0000-0000-0000-000000000001
+  kura restore -g mygroup -a myapim -i subscriptions.json --name-regex '^partner-' --exclude-name "partner-test"
+  kura restore -g target-rg -a target-apim --at latest --source-resource-group mygroup --source-apim-name myapim`,
 	RunE: runRestore,
 }
 
@@ -30,8 +108,35 @@ var (
 	restoreSubscription  string
 	restoreInput         string
 	restoreDryRun        bool
+	restoreAt            string
+	restoreSourceRG      string
+	restoreSourceAPIM    string
+	restoreProductID     string
+	restoreAPIID         string
+	restoreFilter        filterFlags
+	restoreSkipExisting  bool
+	restoreOnConflict    string
+	restoreOwnerMap      string
+	restoreConcurrency   int
+	restoreResume        bool
+	restoreNoSafety      bool
+	restoreRegenerate    bool
+	restoreKeyMap        string
+	restoreNamePrefix    string
+	restoreNameSuffix    string
+	restoreNameTemplate  string
+	restoreStripExpiry   bool
+	restoreInteractive   bool
+	restoreYes           bool
+	restoreNotify        notifyFlags
+	restoreHooks         hookFlags
+	restoreBreaker       breakerFlags
 )
 
+// errRestoreQuit is returned by restorer.restore when the user quits an
+// interactive restore. It stops the restore without being reported as a failure.
+var errRestoreQuit = errors.New("restore stopped by user")
+
 func init() {
 	rootCmd.AddCommand(restoreCmd)
 
@@ -39,13 +144,82 @@ func init() {
 	restoreCmd.Flags().StringVarP(&restoreResourceGroup, "resource-group", "g", "", "Azure resource group name (required)")
 	restoreCmd.Flags().StringVarP(&restoreAPIMName, "apim-name", "a", "", "Azure API Management instance name (required)")
 	restoreCmd.Flags().StringVarP(&restoreSubscription, "subscription", "s", "", "Azure subscription ID")
-	restoreCmd.Flags().StringVarP(&restoreInput, "input", "i", "", "Backup file path to restore from (required)")
+	restoreCmd.Flags().StringVarP(&restoreInput, "input", "i", "", "Backup file path or azblob:// URL to restore from")
 	restoreCmd.Flags().BoolVar(&restoreDryRun, "dry-run", false, "Preview changes without applying them")
+	restoreCmd.Flags().StringVar(&restoreAt, "at", "", "Restore the snapshot taken at or before this time (latest, a date or a timestamp)")
+	restoreCmd.Flags().StringVar(&restoreSourceRG, "source-resource-group", "", "Resource group whose snapshots --at searches (defaults to --resource-group)")
+	restoreCmd.Flags().StringVar(&restoreSourceAPIM, "source-apim-name", "", "APIM instance whose snapshots --at searches (defaults to --apim-name)")
+	restoreCmd.Flags().StringVarP(&restoreProductID, "product-id", "p", "", "Use product-scoped snapshots of this product with --at")
+	restoreCmd.Flags().StringVar(&restoreAPIID, "api-id", "", "Use snapshots of the subscriptions scoped to this API with --at")
+
+	restoreCmd.Flags().BoolVar(&restoreSkipExisting, "skip-existing", false, "Skip subscriptions whose sid already exists in the target instance")
+	restoreCmd.Flags().StringVar(&restoreOnConflict, "on-conflict", "overwrite", "What to do when a target sid exists with different attributes: skip, overwrite or fail")
+	restoreCmd.Flags().StringVar(&restoreOwnerMap, "owner-map", "", "YAML file mapping source user IDs to target user IDs (or \"drop\")")
+	restoreCmd.Flags().IntVar(&restoreConcurrency, "concurrency", 1, "Number of subscriptions to restore in parallel")
+	restoreCmd.Flags().BoolVar(&restoreResume, "resume", false, "Resume an interrupted restore from its checkpoint")
+	restoreCmd.Flags().BoolVar(&restoreNoSafety, "no-safety-backup", false, "Do not back up the target instance before restoring")
+	restoreCmd.Flags().BoolVar(&restoreRegenerate, "regenerate-keys", false, "Let APIM generate new keys instead of restoring the backed-up ones")
+	restoreCmd.Flags().StringVar(&restoreKeyMap, "key-map", "", "Where to write the old to new key mapping with --regenerate-keys (default key-map-<timestamp>.json)")
+	restoreCmd.Flags().StringVar(&restoreNamePrefix, "name-prefix", "", "Prefix added to the display name of every restored subscription")
+	restoreCmd.Flags().StringVar(&restoreNameSuffix, "name-suffix", "", "Suffix added to the display name of every restored subscription")
+	restoreCmd.Flags().StringVar(&restoreNameTemplate, "name-template", "", "Template for restored display names, e.g. \"copy-of-{{.DisplayName}}\"")
+	restoreCmd.Flags().BoolVar(&restoreStripExpiry, "strip-expiration", false, "Restore subscriptions without their expiration date")
+	restoreCmd.Flags().BoolVar(&restoreInteractive, "interactive", false, "Ask for confirmation before restoring each subscription")
+	registerYesFlags(restoreCmd, &restoreYes)
+	registerNotifyFlags(restoreCmd, &restoreNotify)
+	registerHookFlags(restoreCmd, &restoreHooks)
+	registerBreakerFlags(restoreCmd, &restoreBreaker)
+	restoreFilter.register(restoreCmd)
 
 	// Mark required flags
 	restoreCmd.MarkFlagRequired("resource-group")
 	restoreCmd.MarkFlagRequired("apim-name")
-	restoreCmd.MarkFlagRequired("input")
+	restoreCmd.MarkFlagsOneRequired("input", "at")
+	restoreCmd.MarkFlagsMutuallyExclusive("input", "at")
+	restoreCmd.MarkFlagsMutuallyExclusive("product-id", "api-id")
+}
+
+// resolveRestoreInput returns the backup file to restore, resolving --at
+// against the local snapshots if no --input file was given.
+func resolveRestoreInput() (string, error) {
+	if restoreAt == "" {
+		return restoreInput, nil
+	}
+
+	sourceRG := restoreSourceRG
+	if sourceRG == "" {
+		sourceRG = restoreResourceGroup
+	}
+	sourceAPIM := restoreSourceAPIM
+	if sourceAPIM == "" {
+		sourceAPIM = restoreAPIMName
+	}
+
+	all, err := backup.ListSnapshots(backup.RootDir)
+	if err != nil {
+		return "", err
+	}
+	var snapshots []backup.Snapshot
+	dir := backup.BackupDir(sourceRG, sourceAPIM, restoreProductID)
+	if restoreAPIID != "" {
+		snapshots, err = backup.FilterAPISnapshots(all, sourceRG, sourceAPIM, restoreAPIID)
+		dir = backup.APIBackupDir(sourceRG, sourceAPIM, restoreAPIID)
+	} else {
+		snapshots, err = backup.FilterSnapshots(all, sourceRG, sourceAPIM, restoreProductID)
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(snapshots) == 0 {
+		return "", fmt.Errorf("no snapshots found in %s", dir)
+	}
+
+	snap, err := backup.ResolveSnapshot(snapshots, restoreAt)
+	if err != nil {
+		return "", err
+	}
+	fmt.Printf("Resolved snapshot for %s: %s (%s)\n", restoreAt, snap.Path, snap.Time().UTC().Format("2006-01-02T15:04:05Z"))
+	return snap.Path, nil
 }
 
 // extractScopeSuffix extracts the scope suffix after the APIM service name.
@@ -87,10 +261,459 @@ func buildScopeFromSuffix(azureSubscriptionID, resourceGroup, apimName, suffix s
 	return base + "/" + suffix
 }
 
-func runRestore(cmd *cobra.Command, args []string) error {
+// targetScope returns the scope suffix of sub and the full scope to recreate
+// it with in the given target instance.
+func targetScope(sub *azure.SubscriptionInfo, azureSubID, resourceGroup, apimName string) (suffix, scope string) {
+	suffix = extractScopeSuffix(sub.Properties.Scope)
+	// Instance-level scopes (empty suffix) are not valid for CreateOrUpdate.
+	// Map them to "/apis" which covers all APIs — the closest equivalent.
+	if suffix == "" {
+		suffix = "apis"
+	}
+	return suffix, buildScopeFromSuffix(azureSubID, resourceGroup, apimName, suffix)
+}
+
+// remapOwner points the owner of sub to the user of the target instance that
+// ownerMap maps it to, or drops it. Owners not in ownerMap are left unchanged.
+func remapOwner(sub *azure.SubscriptionInfo, ownerMap backup.OwnerMap, azureSubID, resourceGroup, apimName string) {
+	if ownerMap == nil || sub.Properties.OwnerID == "" {
+		return
+	}
+	if userID, drop, ok := ownerMap.Lookup(sub.Properties.OwnerID); ok {
+		if drop {
+			sub.Properties.OwnerID = ""
+		} else {
+			sub.Properties.OwnerID = buildScopeFromSuffix(azureSubID, resourceGroup, apimName, "users/"+userID)
+		}
+	}
+}
+
+// newCreateOptions returns the options to recreate sub with its keys, state,
+// owner, tracing setting and expiration date.
+func newCreateOptions(sub *azure.SubscriptionInfo) (*azure.CreateSubscriptionOptions, error) {
+	opts := &azure.CreateSubscriptionOptions{
+		PrimaryKey:   sub.Properties.PrimaryKey,
+		SecondaryKey: sub.Properties.SecondaryKey,
+		State:        sub.Properties.State,
+		OwnerID:      sub.Properties.OwnerID,
+	}
+	allowTracing := sub.Properties.AllowTracing
+	opts.AllowTracing = &allowTracing
+
+	if sub.Properties.ExpirationDate != "" {
+		expiration, err := time.Parse(time.RFC3339, sub.Properties.ExpirationDate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expirationDate %q: %w", sub.Properties.ExpirationDate, err)
+		}
+		opts.ExpirationDate = &expiration
+	}
+	return opts, nil
+}
+
+// warnExpiration prints a warning for an error of CreateSubscription that
+// only failed to set the expiration date, and returns nil for it, as the
+// subscription was created. Other errors are returned as is.
+func warnExpiration(err error) error {
+	if errors.Is(err, azure.ErrExpirationNotSet) {
+		fmt.Printf("  [WARNING] %v\n", err)
+		return nil
+	}
+	return err
+}
+
+// displayNameData holds the values available to a --name-template on restore.
+type displayNameData struct {
+	DisplayName string
+	SID         string
+	Scope       string // scope suffix, e.g. "products/starter"
+}
+
+// newDisplayNameFunc returns a function that applies the display name
+// transformation flags, or nil if none are set.
+func newDisplayNameFunc(prefix, suffix, nameTemplate string) (func(sub *azure.SubscriptionInfo) (string, error), error) {
+	if prefix == "" && suffix == "" && nameTemplate == "" {
+		return nil, nil
+	}
+
+	var tmpl *template.Template
+	if nameTemplate != "" {
+		var err error
+		tmpl, err = template.New("display-name").Parse(nameTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --name-template: %w", err)
+		}
+	}
+
+	return func(sub *azure.SubscriptionInfo) (string, error) {
+		name := sub.Properties.DisplayName
+		if tmpl != nil {
+			var sb strings.Builder
+			data := displayNameData{
+				DisplayName: name,
+				SID:         sub.Name,
+				Scope:       extractScopeSuffix(sub.Properties.Scope),
+			}
+			if err := tmpl.Execute(&sb, data); err != nil {
+				return "", fmt.Errorf("failed to render --name-template: %w", err)
+			}
+			name = sb.String()
+		}
+		return prefix + name + suffix, nil
+	}, nil
+}
+
+// fieldDiff is a single attribute in which the live target subscription
+// differs from the backup entry that would be restored.
+type fieldDiff struct {
+	Field  string `json:"field"`
+	Live   string `json:"live"`
+	Backup string `json:"desired"`
+}
+
+// restoreConflicts returns the attributes in which the live target subscription
+// differs from the backup entry that would be restored. scopeSuffix is the
+// scope the entry will be restored to. Keys are masked.
+func restoreConflicts(sub *azure.SubscriptionInfo, scopeSuffix string, live *azure.SubscriptionInfo) []fieldDiff {
+	want := &sub.Properties
+	got := &live.Properties
+
+	var diffs []fieldDiff
+	add := func(field, live, backup string) {
+		if live != backup {
+			diffs = append(diffs, fieldDiff{Field: field, Live: live, Backup: backup})
+		}
+	}
+
+	add("displayName", got.DisplayName, want.DisplayName)
+	add("scope", extractScopeSuffix(got.Scope), scopeSuffix)
+	add("state", got.State, want.State)
+	if want.OwnerID != "" {
+		add("ownerId", got.OwnerID, want.OwnerID)
+	}
+	add("allowTracing", fmt.Sprint(got.AllowTracing), fmt.Sprint(want.AllowTracing))
+	if want.ExpirationDate != "" {
+		add("expirationDate", got.ExpirationDate, want.ExpirationDate)
+	}
+	// Empty keys are generated by APIM and cannot conflict.
+	if want.PrimaryKey != "" && got.PrimaryKey != want.PrimaryKey {
+		add("primaryKey", maskKey(got.PrimaryKey), maskKey(want.PrimaryKey))
+	}
+	if want.SecondaryKey != "" && got.SecondaryKey != want.SecondaryKey {
+		add("secondaryKey", maskKey(got.SecondaryKey), maskKey(want.SecondaryKey))
+	}
+	return diffs
+}
+
+// diffFields returns the comma-separated names of the differing fields.
+func diffFields(diffs []fieldDiff) string {
+	names := make([]string, len(diffs))
+	for i, d := range diffs {
+		names[i] = d.Field
+	}
+	return strings.Join(names, ", ")
+}
+
+// maskKey shortens a subscription key so it can be shown in logs without
+// revealing the secret. Different keys still look different.
+func maskKey(key string) string {
+	if len(key) <= 8 {
+		return strings.Repeat("*", len(key))
+	}
+	return key[:4] + "…" + key[len(key)-4:]
+}
+
+// restoreOutcome is the result of restoring a single subscription.
+type restoreOutcome int
+
+const (
+	restoreIgnored restoreOutcome = iota // built-in subscription, not counted
+	restoreOK
+	restoreSkipped
+	restoreDeclined // skipped interactively, not recorded in the checkpoint
+	restoreFailed
+)
+
+// restorer holds the state shared by all subscriptions of a restore run.
+type restorer struct {
+	client         azure.SubscriptionStore
+	azureSubID     string
+	ownerMap       backup.OwnerMap
+	checkConflicts bool
+	checkpoint     *backup.Checkpoint // nil if progress is not tracked
+	displayName    func(sub *azure.SubscriptionInfo) (string, error)
+
+	mu      sync.Mutex
+	keyMaps []backup.KeyMapping // filled with --regenerate-keys
+
+	prompt     *bufio.Reader // reads answers with --interactive
+	confirmAll bool          // the user answered "all"
+}
+
+// confirm asks the user whether to restore sub. It returns errRestoreQuit if
+// the user chose to stop. Only used with --interactive, which runs sequentially.
+func (r *restorer) confirm(sub *azure.SubscriptionInfo, scopeLabel string, live *azure.SubscriptionInfo, diffs []fieldDiff) (bool, error) {
+	if r.confirmAll {
+		return true, nil
+	}
+
+	fmt.Printf("\n  %s (sid=%s)\n", sub.Properties.DisplayName, sub.Name)
+	fmt.Printf("    Scope: %s\n", scopeLabel)
+	fmt.Printf("    State: %s\n", sub.Properties.State)
+	switch {
+	case live == nil:
+		fmt.Println("    Target: does not exist, will be created")
+	case len(diffs) == 0:
+		fmt.Println("    Target: exists and is up to date")
+	default:
+		fmt.Printf("    Target: exists, will be overwritten (differs in %s)\n", diffFields(diffs))
+	}
+
+	for {
+		fmt.Print("  Restore? [y/N/a/q] ")
+		answer, err := r.prompt.ReadString('\n')
+		if err != nil && answer == "" {
+			return false, errRestoreQuit
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return true, nil
+		case "", "n", "no":
+			return false, nil
+		case "a", "all":
+			r.confirmAll = true
+			return true, nil
+		case "q", "quit":
+			return false, errRestoreQuit
+		}
+	}
+}
+
+// restore restores a single backup entry to the target instance. A non-nil
+// error means the whole restore must be aborted.
+func (r *restorer) restore(ctx context.Context, sub azure.SubscriptionInfo) (restoreOutcome, error) {
+	sid := sub.Name // The subscription entity ID (GUID).
+	displayName := sub.Properties.DisplayName
+
+	// The "master" subscription always exists and cannot be recreated.
+	// Skip it completely.
+	if sid == "master" {
+		fmt.Printf("  [WARNING] Skipping built-in 'master' subscription\n")
+		return restoreIgnored, nil
+	}
+
+	if r.displayName != nil {
+		name, err := r.displayName(&sub)
+		if err != nil {
+			fmt.Printf("  [FAIL] %s: %v\n", displayName, err)
+			return restoreFailed, nil
+		}
+		sub.Properties.DisplayName = name
+		displayName = name
+	}
+
+	if r.checkpoint != nil && r.checkpoint.Done(sid) {
+		fmt.Printf("  [DONE] %s (sid=%s restored in a previous run)\n", displayName, sid)
+		return restoreSkipped, nil
+	}
+
+	if restoreSkipExisting {
+		exists, err := r.client.SubscriptionExists(ctx, sid)
+		if err != nil {
+			fmt.Printf("  [FAIL] %s: %v\n", displayName, err)
+			return restoreFailed, nil
+		}
+		if exists {
+			fmt.Printf("  [SKIP] %s (sid=%s already exists)\n", displayName, sid)
+			return restoreSkipped, nil
+		}
+	}
+
+	scopeSuffix, scope := targetScope(&sub, r.azureSubID, restoreResourceGroup, restoreAPIMName)
+	remapOwner(&sub, r.ownerMap, r.azureSubID, restoreResourceGroup, restoreAPIMName)
+
+	// With --regenerate-keys the backed-up keys are left out so APIM generates
+	// new ones. APIM keeps the keys of existing subscriptions, so those are
+	// regenerated after the update.
+	oldPrimaryKey, oldSecondaryKey := sub.Properties.PrimaryKey, sub.Properties.SecondaryKey
+	if restoreRegenerate {
+		sub.Properties.PrimaryKey = ""
+		sub.Properties.SecondaryKey = ""
+	}
+
+	if restoreStripExpiry {
+		sub.Properties.ExpirationDate = ""
+	}
+	opts, err := newCreateOptions(&sub)
+	if err != nil {
+		fmt.Printf("  [FAIL] %s: %v\n", displayName, err)
+		return restoreFailed, nil
+	}
+
+	scopeLabel := scopeSuffix
+	if scopeLabel == "" {
+		scopeLabel = "(instance)"
+	}
+
+	// Fetch the live target state for the conflict pre-check, the dry-run diff
+	// and to tell whether keys must be regenerated.
+	var live *azure.SubscriptionInfo
+	if r.checkConflicts || restoreDryRun || r.prompt != nil || restoreRegenerate {
+		l, err := r.client.GetSubscription(ctx, sid)
+		switch {
+		case azure.IsNotFound(err):
+			// The subscription does not exist in the target yet.
+		case err != nil:
+			fmt.Printf("  [FAIL] %s: %v\n", displayName, err)
+			return restoreFailed, nil
+		default:
+			live = l
+		}
+	}
+	var diffs []fieldDiff
+	if live != nil {
+		diffs = restoreConflicts(&sub, scopeSuffix, live)
+	}
+
+	if r.checkConflicts && live != nil {
+		if len(diffs) == 0 {
+			fmt.Printf("  [SAME] %s (sid=%s already up to date)\n", displayName, sid)
+			return restoreSkipped, nil
+		}
+		switch restoreOnConflict {
+		case "skip":
+			fmt.Printf("  [SKIP] %s (sid=%s exists with different %s)\n", displayName, sid, diffFields(diffs))
+			return restoreSkipped, nil
+		case "fail":
+			return restoreFailed, fmt.Errorf("subscription %s (sid=%s) exists with different %s", displayName, sid, diffFields(diffs))
+		}
+		if !restoreDryRun {
+			fmt.Printf("  [CONFLICT] %s (sid=%s differs in %s, overwriting)\n", displayName, sid, diffFields(diffs))
+		}
+	}
+
+	if r.prompt != nil {
+		ok, err := r.confirm(&sub, scopeLabel, live, diffs)
+		if err != nil {
+			return restoreIgnored, err
+		}
+		if !ok {
+			fmt.Printf("  [SKIP] %s (declined)\n", displayName)
+			return restoreDeclined, nil
+		}
+	}
+
+	if restoreDryRun {
+		// Build the whole message first so concurrent workers do not interleave lines.
+		var sb strings.Builder
+		switch {
+		case live == nil:
+			fmt.Fprintf(&sb, "  [DRY-RUN] Would create: %s (sid=%s, scope=%s)\n", displayName, sid, scopeLabel)
+		case len(diffs) == 0 && !restoreRegenerate:
+			fmt.Fprintf(&sb, "  [DRY-RUN] Unchanged: %s (sid=%s, scope=%s)\n", displayName, sid, scopeLabel)
+		default:
+			fmt.Fprintf(&sb, "  [DRY-RUN] Would update: %s (sid=%s, scope=%s)\n", displayName, sid, scopeLabel)
+			for _, d := range diffs {
+				fmt.Fprintf(&sb, "      %s: %q -> %q\n", d.Field, d.Live, d.Backup)
+			}
+		}
+		if restoreRegenerate && live != nil {
+			// APIM would keep the keys, so they are regenerated.
+			sb.WriteString("      keys: would be regenerated\n")
+		}
+		fmt.Print(sb.String())
+		return restoreOK, nil
+	}
+
+	fmt.Printf("  Restoring: %s (sid=%s, scope=%s)...\n", displayName, sid, scopeLabel)
+	createCtx := ctx
+	if live != nil {
+		// Do not overwrite changes made since the live state was compared.
+		createCtx = azure.WithIfMatch(ctx, live.ETag)
+	}
+	created, err := r.client.CreateSubscription(createCtx, sid, scope, displayName, opts)
+	err = warnExpiration(err)
+	switch {
+	case azure.IsConflict(err) && restoreOnConflict == "skip":
+		// Someone else changed the subscription since the pre-check.
+		fmt.Printf("  [SKIP] %s (sid=%s changed concurrently)\n", displayName, sid)
+		return restoreSkipped, nil
+	case azure.IsThrottled(err):
+		fmt.Printf("  [FAIL] %s: throttled by Azure after all retries, lower --max-rps or --concurrency or raise --max-retries: %v\n", displayName, err)
+		return restoreFailed, nil
+	case err != nil:
+		fmt.Printf("  [FAIL] %s: %v\n", displayName, err)
+		return restoreFailed, nil
+	}
+	if restoreRegenerate && live != nil {
+		primaryKey, secondaryKey, err := r.client.RegenerateKeys(ctx, sid, true, true)
+		if err != nil {
+			// The subscription was updated, so it is not reported as failed.
+			fmt.Printf("  [WARNING] %s: updated, but its keys were not regenerated: %v\n", displayName, err)
+			return restoreOK, nil
+		}
+		created.Properties.PrimaryKey = primaryKey
+		created.Properties.SecondaryKey = secondaryKey
+	}
+	if restoreRegenerate {
+		r.mu.Lock()
+		r.keyMaps = append(r.keyMaps, backup.KeyMapping{
+			SID:             sid,
+			DisplayName:     displayName,
+			OldPrimaryKey:   oldPrimaryKey,
+			NewPrimaryKey:   created.Properties.PrimaryKey,
+			OldSecondaryKey: oldSecondaryKey,
+			NewSecondaryKey: created.Properties.SecondaryKey,
+		})
+		r.mu.Unlock()
+	}
+	fmt.Printf("  [OK]   %s\n", displayName)
+	return restoreOK, nil
+}
+
+func runRestore(cmd *cobra.Command, args []string) (err error) {
+	summary := newRunSummary("restore", restoreResourceGroup, restoreAPIMName, restoreSubscription)
+	summary.DryRun = restoreDryRun
+	defer func() { err = summary.send(restoreNotify, err) }()
+	if err := restoreHooks.runPre(summary); err != nil {
+		return err
+	}
+	defer func() { err = restoreHooks.runPost(summary, err) }()
+
 	fmt.Printf("Restoring subscription keys to APIM instance: %s\n", restoreAPIMName)
 	fmt.Printf("Resource Group: %s\n", restoreResourceGroup)
-	fmt.Printf("Input file: %s\n", restoreInput)
+
+	input, err := resolveRestoreInput()
+	if err != nil {
+		return fmt.Errorf("failed to resolve snapshot: %w", err)
+	}
+	fmt.Printf("Input file: %s\n", input)
+
+	filter, err := restoreFilter.build()
+	if err != nil {
+		return err
+	}
+
+	switch restoreOnConflict {
+	case "skip", "overwrite", "fail":
+	default:
+		return fmt.Errorf("invalid --on-conflict %q: must be skip, overwrite or fail", restoreOnConflict)
+	}
+	// Only pay for the per-subscription pre-check if a strategy was chosen explicitly.
+	checkConflicts := cmd.Flags().Changed("on-conflict")
+
+	displayNameFunc, err := newDisplayNameFunc(restoreNamePrefix, restoreNameSuffix, restoreNameTemplate)
+	if err != nil {
+		return err
+	}
+
+	var ownerMap backup.OwnerMap
+	if restoreOwnerMap != "" {
+		ownerMap, err = backup.LoadOwnerMap(restoreOwnerMap)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Owner map: %s (%d entries)\n", restoreOwnerMap, len(ownerMap))
+	}
 
 	if restoreSubscription != "" {
 		fmt.Printf("Subscription ID: %s\n", restoreSubscription)
@@ -103,24 +726,67 @@ func runRestore(cmd *cobra.Command, args []string) error {
 	}
 
 	// 1. Read and parse the backup file.
-	data, err := os.ReadFile(restoreInput)
+	subs, err := loadRestoreFile(input)
 	if err != nil {
-		return fmt.Errorf("failed to read input file %s: %w", restoreInput, err)
-	}
-
-	var subs []azure.SubscriptionInfo
-	if err := json.Unmarshal(data, &subs); err != nil {
-		return fmt.Errorf("failed to parse input file: %w", err)
+		return fmt.Errorf("failed to load input file %s: %w", input, err)
 	}
 
 	if len(subs) == 0 {
 		fmt.Println("No subscriptions found in input file. Nothing to restore.")
 		return nil
 	}
+
+	// Hand-crafted or third-party files may omit the sid.
+	generatedSIDs := backup.GenerateMissingSIDs(subs)
+	if len(generatedSIDs) > 0 {
+		fmt.Printf("\nGenerated sids for %d entry(ies) without a name\n", len(generatedSIDs))
+	}
+
+	if !filter.IsEmpty() {
+		total := len(subs)
+		subs = filter.Apply(subs)
+		fmt.Printf("\nFilters selected %d of %d subscription(s)\n", len(subs), total)
+		if len(subs) == 0 {
+			fmt.Println("No subscriptions match the filters. Nothing to restore.")
+			return nil
+		}
+	}
 	fmt.Printf("\nFound %d subscription(s) to restore\n", len(subs))
 
+	// Load or start the checkpoint that tracks which subscriptions were applied.
+	var checkpoint *backup.Checkpoint
+	checkpointPath := backup.CheckpointPath(restoreResourceGroup, restoreAPIMName)
+	if restoreResume {
+		checkpoint, err = backup.LoadCheckpoint(checkpointPath)
+		if err != nil {
+			return err
+		}
+		if checkpoint == nil {
+			fmt.Println("\nNo checkpoint found, starting from the beginning")
+		} else if !checkpoint.Matches(input) {
+			return fmt.Errorf("checkpoint %s belongs to a restore of %s, not %s", checkpointPath, checkpoint.Input, input)
+		} else {
+			fmt.Printf("\nResuming from checkpoint %s (%d subscription(s) already restored)\n", checkpointPath, checkpoint.Len())
+		}
+	}
+	if checkpoint == nil && !restoreDryRun {
+		checkpoint = backup.NewCheckpoint(checkpointPath, input, restoreResourceGroup, restoreAPIMName)
+	}
+
+	if !restoreDryRun {
+		unlock, err := lockInstance(restoreResourceGroup, restoreAPIMName, "restore")
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
 	// 2. Authenticate to Azure.
-	ctx := context.Background()
+	// Ctrl-C cancels in-flight requests; progress so far stays in the checkpoint.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancelTimeout := withTimeout(ctx)
+	defer cancelTimeout()
 	fmt.Println("\nAuthenticating with Azure CLI...")
 
 	client, err := azure.NewClient(ctx, restoreSubscription, restoreResourceGroup, restoreAPIMName)
@@ -129,67 +795,167 @@ func runRestore(cmd *cobra.Command, args []string) error {
 	}
 	fmt.Println("Successfully authenticated with Azure CLI")
 
-	// Resolve the Azure subscription ID so we can rebuild scopes.
-	azureSubID := client.SubscriptionID()
+	// Overwriting live subscriptions needs confirmation, unless --yes is given
+	// or --interactive already asks for each subscription.
+	confirmOverwrites := !restoreDryRun && !restoreYes && !restoreInteractive &&
+		!restoreSkipExisting && restoreOnConflict == "overwrite"
 
-	// 3. Restore each subscription.
-	var restored, failed int
-	for _, sub := range subs {
-		sid := sub.Name // The subscription entity ID (GUID).
-		displayName := sub.Properties.DisplayName
+	var current []azure.SubscriptionInfo
+	if !restoreDryRun && (!restoreNoSafety || confirmOverwrites) {
+		fmt.Println("\nFetching subscriptions of the target instance...")
+		current, err = client.ListSubscriptions(ctx, "")
+		if err != nil {
+			return fmt.Errorf("failed to list target subscriptions: %w", err)
+		}
+	}
 
-		// The "master" subscription always exists and cannot be recreated.
-		// Skip it completely.
-		if sid == "master" {
-			fmt.Printf("  [WARNING] Skipping built-in 'master' subscription\n")
-			continue
+	if confirmOverwrites {
+		existing := make(map[string]bool, len(current))
+		for _, sub := range current {
+			existing[sub.Name] = true
 		}
+		var overwrites int
+		for _, sub := range subs {
+			if sub.Name != "master" && existing[sub.Name] {
+				overwrites++
+			}
+		}
+		if overwrites > 0 {
+			fmt.Println()
+			ok, err := confirm(fmt.Sprintf("Restore %d subscription(s) to APIM instance %s, overwriting %d existing subscription(s)?", len(subs), restoreAPIMName, overwrites))
+			if err != nil {
+				return err
+			}
+			if !ok {
+				fmt.Println("Aborted. No subscriptions were restored.")
+				return nil
+			}
+		}
+	}
 
-		// Determine the target scope.
-		// Extract the scope suffix from the backup and rebuild for the target environment.
-		scopeSuffix := extractScopeSuffix(sub.Properties.Scope)
-		// Instance-level scopes (empty suffix) are not valid for CreateOrUpdate.
-		// Map them to "/apis" which covers all APIs — the closest equivalent.
-		if scopeSuffix == "" {
-			scopeSuffix = "apis"
+	// Snapshot the target before changing it, so a bad restore can be rolled back.
+	var rollback *backup.Rollback
+	var rollbackPath string
+	if !restoreDryRun && !restoreNoSafety {
+		rollback = &backup.Rollback{
+			ResourceGroup: restoreResourceGroup,
+			APIMName:      restoreAPIMName,
+			Input:         input,
+			CreatedAt:     time.Now().UTC(),
+			Subscriptions: current,
 		}
-		scope := buildScopeFromSuffix(azureSubID, restoreResourceGroup, restoreAPIMName, scopeSuffix)
+		rollbackPath = backup.NewRollbackPath(restoreResourceGroup, restoreAPIMName, rollback.CreatedAt)
+		if err := rollback.Save(rollbackPath); err != nil {
+			return err
+		}
+		fmt.Printf("Safety backup of %d subscription(s) saved to %s\n", len(current), rollbackPath)
+		summary.addFile("rollback", rollbackPath)
+	}
 
-		opts := &azure.CreateSubscriptionOptions{
-			PrimaryKey:   sub.Properties.PrimaryKey,
-			SecondaryKey: sub.Properties.SecondaryKey,
-			State:        sub.Properties.State,
+	r := &restorer{
+		client:         client,
+		azureSubID:     client.SubscriptionID(), // Needed to rebuild scopes.
+		ownerMap:       ownerMap,
+		checkConflicts: checkConflicts,
+		checkpoint:     checkpoint,
+		displayName:    displayNameFunc,
+	}
+	if restoreInteractive {
+		r.prompt = bufio.NewReader(os.Stdin)
+	}
+
+	// 3. Restore the subscriptions with bounded concurrency.
+	concurrency := restoreConcurrency
+	if restoreInteractive {
+		concurrency = 1
+	}
+	var restored, skipped, failed int
+	breaker := restoreBreaker.breaker()
+	restoreOne := func(ctx context.Context, sub azure.SubscriptionInfo) (restoreOutcome, error) {
+		outcome, err := r.restore(ctx, sub)
+		if checkpoint != nil && !restoreDryRun && (outcome == restoreOK || outcome == restoreSkipped) {
+			if cpErr := checkpoint.MarkDone(sub.Name); cpErr != nil {
+				fmt.Printf("  [WARNING] %v\n", cpErr)
+			}
+		}
+		if err == nil && outcome != restoreIgnored {
+			err = breaker.Record(outcome == restoreFailed)
 		}
-		if sub.Properties.OwnerID != "" {
-			opts.OwnerID = sub.Properties.OwnerID
+		return outcome, pool.Stop(err)
+	}
+	abortErr := pool.Run(ctx, concurrency, subs, restoreOne, func(res pool.Result[azure.SubscriptionInfo, restoreOutcome]) {
+		switch res.Value {
+		case restoreOK:
+			restored++
+			if rollback != nil {
+				rollback.Applied = append(rollback.Applied, res.Item.Name)
+			}
+		case restoreSkipped, restoreDeclined:
+			skipped++
+		case restoreFailed:
+			failed++
+			summary.addFailure(res.Item.Name, res.Item.Properties.DisplayName, res.Err)
 		}
-		allowTracing := sub.Properties.AllowTracing
-		opts.AllowTracing = &allowTracing
+	})
+
+	// 4. Summary.
+	status := "complete"
+	if abortErr != nil {
+		status = "stopped"
+	}
+	quit := errors.Is(abortErr, errRestoreQuit)
+	if quit {
+		fmt.Println("\nRestore stopped by user.")
+		abortErr = nil
+	}
+	fmt.Printf("\nRestore %s: %d succeeded, %d skipped, %d failed (out of %d total)\n", status, restored, skipped, failed, len(subs))
+	summary.Counts["restored"] = restored
+	summary.Counts["skipped"] = skipped
+	summary.Counts["failed"] = failed
+	summary.Counts["total"] = len(subs)
 
-		scopeLabel := scopeSuffix
-		if scopeLabel == "" {
-			scopeLabel = "(instance)"
+	if len(generatedSIDs) > 0 {
+		fmt.Println("Generated sids:")
+		for _, g := range generatedSIDs {
+			fmt.Printf("  %s  %s\n", g.SID, g.DisplayName)
 		}
+	}
 
-		if restoreDryRun {
-			fmt.Printf("  [DRY-RUN] Would restore: %s (sid=%s, scope=%s)\n", displayName, sid, scopeLabel)
-			restored++
-			continue
+	if restoreRegenerate && !restoreDryRun && len(r.keyMaps) > 0 {
+		keyMapPath := restoreKeyMap
+		if keyMapPath == "" {
+			keyMapPath = fmt.Sprintf("key-map-%s.json", time.Now().UTC().Format("20060102T150405Z"))
 		}
+		if err := backup.WriteKeyMap(keyMapPath, r.keyMaps); err != nil {
+			fmt.Printf("  [WARNING] %v\n", err)
+		} else {
+			fmt.Printf("Key mapping for %d subscription(s) written to %s\n", len(r.keyMaps), keyMapPath)
+		}
+	}
 
-		fmt.Printf("  Restoring: %s (sid=%s, scope=%s)...\n", displayName, sid, scopeLabel)
-		_, err := client.CreateSubscription(ctx, sid, scope, displayName, opts)
-		if err != nil {
-			fmt.Printf("  [FAIL] %s: %v\n", displayName, err)
-			failed++
-			continue
+	if rollback != nil {
+		if err := rollback.Save(rollbackPath); err != nil {
+			fmt.Printf("  [WARNING] %v\n", err)
 		}
-		fmt.Printf("  [OK]   %s\n", displayName)
-		restored++
+		fmt.Printf("Rollback file: %s\n", rollbackPath)
 	}
 
-	// 4. Summary.
-	fmt.Printf("\nRestore complete: %d succeeded, %d failed (out of %d total)\n", restored, failed, len(subs))
+	if checkpoint != nil && !restoreDryRun {
+		if failed == 0 && status == "complete" {
+			if err := checkpoint.Remove(); err != nil {
+				fmt.Printf("  [WARNING] %v\n", err)
+			}
+		} else if checkpoint.Len() > 0 {
+			fmt.Printf("Progress saved to %s. Re-run with --resume to continue.\n", checkpoint.Path())
+		}
+	}
+
+	if abortErr != nil && errors.Is(abortErr, ctx.Err()) {
+		return fmt.Errorf("restore interrupted: %w", abortErr)
+	}
+	if abortErr != nil {
+		return abortErr
+	}
 	if failed > 0 {
 		return fmt.Errorf("%d subscription(s) failed to restore", failed)
 	}
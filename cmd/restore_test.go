@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+)
+
+func TestRestoreFilter(t *testing.T) {
+	subs := []azure.SubscriptionInfo{
+		testSub("partner-a", "Partner A", "k1", "k2"),
+		testSub("partner-b", "Partner B", "k3", "k4"),
+		testSub("internal", "Internal", "k5", "k6"),
+		testSub("test-1", "Test", "k7", "k8"),
+	}
+
+	for _, tc := range []struct {
+		name  string
+		flags filterFlags
+		want  string
+	}{
+		{"no flags", filterFlags{}, "partner-a,partner-b,internal,test-1"},
+		{"sid", filterFlags{sids: []string{"internal"}}, "internal"},
+		{"name", filterFlags{names: []string{"Partner B"}}, "partner-b"},
+		{"includes are combined", filterFlags{sids: []string{"internal"}, nameRegex: "^Test$"}, "internal,test-1"},
+		{"sid regex", filterFlags{sidRegex: "^partner-"}, "partner-a,partner-b"},
+		{"exclude sid", filterFlags{excludeSIDs: []string{"internal"}}, "partner-a,partner-b,test-1"},
+		{"exclude name", filterFlags{excludeNames: []string{"Test"}}, "partner-a,partner-b,internal"},
+		{"exclude regex matches sid or name", filterFlags{excludeRegex: "^(test-|Internal)"}, "partner-a,partner-b"},
+		{"excludes win over includes", filterFlags{sidRegex: "^partner-", excludeSIDs: []string{"partner-a"}}, "partner-b"},
+	} {
+		filter, err := tc.flags.build()
+		if err != nil {
+			t.Errorf("%s: build: %v", tc.name, err)
+			continue
+		}
+		var got []string
+		for _, sub := range filter.Apply(subs) {
+			got = append(got, sub.Name)
+		}
+		if strings.Join(got, ",") != tc.want {
+			t.Errorf("%s: selected %s, want %s", tc.name, strings.Join(got, ","), tc.want)
+		}
+	}
+
+	for _, flags := range []filterFlags{{sidRegex: "("}, {nameRegex: "["}, {excludeRegex: "*"}} {
+		if _, err := flags.build(); err == nil {
+			t.Errorf("build(%+v) accepted an invalid regular expression", flags)
+		}
+	}
+}
+
+func TestRestoreConflicts(t *testing.T) {
+	live := testSub("a", "A", "k1", "k2")
+	live.Properties.OwnerID = "/subscriptions/x/resourceGroups/rg/providers/Microsoft.ApiManagement/service/apim/users/u1"
+	live.Properties.ExpirationDate = "2027-01-01T00:00:00Z"
+	scopeSuffix := extractScopeSuffix(live.Properties.Scope)
+
+	for _, tc := range []struct {
+		name   string
+		change func(sub *azure.SubscriptionInfo)
+		suffix string
+		want   string
+	}{
+		{"same", func(sub *azure.SubscriptionInfo) {}, scopeSuffix, ""},
+		{"display name and state", func(sub *azure.SubscriptionInfo) {
+			sub.Properties.DisplayName = "B"
+			sub.Properties.State = "suspended"
+		}, scopeSuffix, "displayName, state"},
+		{"scope", func(sub *azure.SubscriptionInfo) {}, "apis", "scope"},
+		{"keys", func(sub *azure.SubscriptionInfo) { sub.Properties.PrimaryKey = "other" }, scopeSuffix, "primaryKey"},
+		{"empty keys are generated and do not conflict", func(sub *azure.SubscriptionInfo) {
+			sub.Properties.PrimaryKey = ""
+			sub.Properties.SecondaryKey = ""
+		}, scopeSuffix, ""},
+		{"empty owner and expiration are kept", func(sub *azure.SubscriptionInfo) {
+			sub.Properties.OwnerID = ""
+			sub.Properties.ExpirationDate = ""
+		}, scopeSuffix, ""},
+		{"expiration", func(sub *azure.SubscriptionInfo) { sub.Properties.ExpirationDate = "2028-01-01T00:00:00Z" }, scopeSuffix, "expirationDate"},
+		{"tracing", func(sub *azure.SubscriptionInfo) { sub.Properties.AllowTracing = true }, scopeSuffix, "allowTracing"},
+	} {
+		sub := live
+		tc.change(&sub)
+		diffs := restoreConflicts(&sub, tc.suffix, &live)
+		if got := diffFields(diffs); got != tc.want {
+			t.Errorf("%s: conflicting fields = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestTargetScope(t *testing.T) {
+	const instance = "/subscriptions/s/resourceGroups/rg/providers/Microsoft.ApiManagement/service/"
+	for _, tc := range []struct {
+		scope, suffix string
+	}{
+		{instance + "source/products/starter", "products/starter"},
+		{instance + "source/apis/petstore", "apis/petstore"},
+		{instance + "source/apis", "apis"},
+		// Instance-wide scopes cannot be created and map to all APIs.
+		{instance + "source", "apis"},
+		{instance + "source/", "apis"},
+	} {
+		sub := azure.SubscriptionInfo{Properties: azure.SubscriptionInfoProperties{Scope: tc.scope}}
+		suffix, scope := targetScope(&sub, "s2", "rg2", "target")
+		if suffix != tc.suffix {
+			t.Errorf("targetScope(%q) suffix = %q, want %q", tc.scope, suffix, tc.suffix)
+		}
+		if want := "/subscriptions/s2/resourceGroups/rg2/providers/Microsoft.ApiManagement/service/target/" + tc.suffix; scope != want {
+			t.Errorf("targetScope(%q) scope = %q, want %q", tc.scope, scope, want)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/f-marschall/apim-kura/pkg/backup"
+	"github.com/spf13/cobra"
+)
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Revert a restore using its safety backup",
+	Long: `Rollback re-applies the state an APIM instance had before a restore, using
+the safety backup that restore writes under backup/.rollback.
+
+Subscriptions the restore created are deleted, and subscriptions it overwrote
+are restored with their previous keys and attributes. Subscriptions the restore
+did not touch are left alone.
+
+Example:
+  kura rollback --resource-group mygroup --apim-name myapim --last
+  kura rollback -g mygroup -a myapim --last --dry-run
+  kura rollback --file backup/.rollback/mygroup/myapim/20240501T120000.000000000Z.json`,
+	RunE: runRollback,
+}
+
+var (
+	rollbackResourceGroup string
+	rollbackAPIMName      string
+	rollbackSubscription  string
+	rollbackLast          bool
+	rollbackFile          string
+	rollbackDryRun        bool
+)
+
+func init() {
+	rootCmd.AddCommand(rollbackCmd)
+
+	rollbackCmd.Flags().StringVarP(&rollbackResourceGroup, "resource-group", "g", "", "Azure resource group name (required with --last)")
+	rollbackCmd.Flags().StringVarP(&rollbackAPIMName, "apim-name", "a", "", "Azure API Management instance name (required with --last)")
+	rollbackCmd.Flags().StringVarP(&rollbackSubscription, "subscription", "s", "", "Azure subscription ID")
+	rollbackCmd.Flags().BoolVar(&rollbackLast, "last", false, "Roll back the most recent restore into the instance")
+	rollbackCmd.Flags().StringVarP(&rollbackFile, "file", "f", "", "Rollback file written by restore")
+	rollbackCmd.Flags().BoolVar(&rollbackDryRun, "dry-run", false, "Preview the rollback without applying it")
+
+	rollbackCmd.MarkFlagsOneRequired("last", "file")
+	rollbackCmd.MarkFlagsMutuallyExclusive("last", "file")
+}
+
+func runRollback(cmd *cobra.Command, args []string) error {
+	path := rollbackFile
+	if rollbackLast {
+		if rollbackResourceGroup == "" || rollbackAPIMName == "" {
+			return fmt.Errorf("--last requires --resource-group and --apim-name")
+		}
+		latest, err := backup.LatestRollback(rollbackResourceGroup, rollbackAPIMName)
+		if err != nil {
+			return err
+		}
+		if latest == "" {
+			return fmt.Errorf("no rollback file found in %s", backup.RollbackDir(rollbackResourceGroup, rollbackAPIMName))
+		}
+		path = latest
+	}
+
+	rb, err := backup.LoadRollback(path)
+	if err != nil {
+		return err
+	}
+	if rollbackResourceGroup != "" && rollbackResourceGroup != rb.ResourceGroup ||
+		rollbackAPIMName != "" && rollbackAPIMName != rb.APIMName {
+		return fmt.Errorf("rollback file %s belongs to %s/%s", path, rb.ResourceGroup, rb.APIMName)
+	}
+
+	fmt.Printf("Rolling back restore into APIM instance: %s\n", rb.APIMName)
+	fmt.Printf("Resource Group: %s\n", rb.ResourceGroup)
+	fmt.Printf("Rollback file: %s (taken %s)\n", path, rb.CreatedAt.Format("2006-01-02T15:04:05Z"))
+	if rb.Input != "" {
+		fmt.Printf("Restored from: %s\n", rb.Input)
+	}
+
+	if len(rb.Applied) == 0 {
+		fmt.Println("The restore did not change any subscriptions. Nothing to roll back.")
+		return nil
+	}
+
+	if rollbackDryRun {
+		fmt.Println("\nRunning in DRY-RUN mode. No changes will be applied.")
+	}
+
+	if !rollbackDryRun {
+		unlock, err := lockInstance(rb.ResourceGroup, rb.APIMName, "rollback")
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	fmt.Println("\nAuthenticating with Azure CLI...")
+
+	client, err := azure.NewClient(ctx, rollbackSubscription, rb.ResourceGroup, rb.APIMName)
+	if err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	fmt.Println("Successfully authenticated with Azure CLI")
+
+	previous := make(map[string]azure.SubscriptionInfo, len(rb.Subscriptions))
+	for _, sub := range rb.Subscriptions {
+		previous[sub.Name] = sub
+	}
+
+	fmt.Printf("\nRolling back %d subscription(s)\n", len(rb.Applied))
+
+	var reverted, deleted, failed int
+	for _, sid := range rb.Applied {
+		prev, existed := previous[sid]
+
+		if !existed {
+			// The restore created this subscription.
+			if rollbackDryRun {
+				fmt.Printf("  [DRY-RUN] Would delete: %s (created by restore)\n", sid)
+				deleted++
+				continue
+			}
+			if err := client.DeleteSubscription(ctx, sid); err != nil && !azure.IsNotFound(err) {
+				fmt.Printf("  [FAIL] %s: %v\n", sid, err)
+				failed++
+				continue
+			}
+			fmt.Printf("  [DELETED] %s (created by restore)\n", sid)
+			deleted++
+			continue
+		}
+
+		// The restore overwrote this subscription; put the previous state back.
+		displayName := prev.Properties.DisplayName
+		if rollbackDryRun {
+			fmt.Printf("  [DRY-RUN] Would revert: %s (sid=%s)\n", displayName, sid)
+			reverted++
+			continue
+		}
+
+		scopeSuffix := extractScopeSuffix(prev.Properties.Scope)
+		if scopeSuffix == "" {
+			scopeSuffix = "apis"
+		}
+		scope := buildScopeFromSuffix(client.SubscriptionID(), rb.ResourceGroup, rb.APIMName, scopeSuffix)
+
+		opts, err := newCreateOptions(&prev)
+		if err != nil {
+			fmt.Printf("  [FAIL] %s: %v\n", displayName, err)
+			failed++
+			continue
+		}
+		info, err := client.CreateSubscription(ctx, sid, scope, displayName, opts)
+		if err = warnExpiration(err); err != nil {
+			fmt.Printf("  [FAIL] %s: %v\n", displayName, err)
+			failed++
+			continue
+		}
+		// CreateOrUpdate keeps an expiration date the restore set.
+		if prev.Properties.ExpirationDate == "" && info.Properties.ExpirationDate != "" {
+			if _, err := client.ClearExpirationDate(ctx, sid); err != nil {
+				fmt.Printf("  [WARNING] failed to remove the expiration date of %s: %v\n", displayName, err)
+			}
+		}
+		fmt.Printf("  [REVERTED] %s (sid=%s)\n", displayName, sid)
+		reverted++
+	}
+
+	fmt.Printf("\nRollback complete: %d reverted, %d deleted, %d failed\n", reverted, deleted, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d subscription(s) failed to roll back", failed)
+	}
+	return nil
+}
@@ -1,13 +1,51 @@
 package cmd
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/f-marschall/apim-kura/internal/audit"
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/f-marschall/apim-kura/pkg/backup"
 	"github.com/spf13/cobra"
 )
 
 var (
 	Version = "dev"
+
+	// ageIdentity is the age identity file used to decrypt encrypted backup files.
+	ageIdentity string
+
+	// auditFile is the audit journal every change is recorded in, unless
+	// noAudit is set.
+	auditFile string
+	noAudit   bool
+
+	// retryOptions configures the retries of failed Azure requests.
+	retryOptions azure.RetryOptions
+	// maxRPS caps the Azure requests per second of the whole command.
+	maxRPS float64
+	// transportOptions configures the proxy and the trusted certificate
+	// authorities of all HTTP requests.
+	transportOptions azure.TransportOptions
+	// apiVersion overrides the API Management REST API version.
+	apiVersion string
+	// endpoint replaces Azure Resource Manager, such as with 'kura emulator'.
+	endpoint string
+	// recordFile and replayFile are the cassette files Azure requests are
+	// recorded to or replayed from. recorder is set while recording.
+	recordFile string
+	replayFile string
+	recorder   *azure.CassetteRecorder
+	// commandTimeout is the deadline of a command, or of a single cycle of
+	// the daemon and the operator. Zero means no deadline.
+	commandTimeout time.Duration
 )
 
 var rootCmd = &cobra.Command{
@@ -19,22 +57,145 @@ from Azure API Management instances.
 It provides simple commands to export subscription keys to a file
 and restore them from a backup file.`,
 	Version: Version,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if !noAudit {
+			journal := audit.NewJournal(auditFile, strings.TrimPrefix(cmd.CommandPath(), "kura "))
+			azure.SetRecorder(journal.Record)
+		}
+		retry := retryOptions
+		if retry.MaxRetries == 0 {
+			// Zero means the default to the Azure SDK.
+			retry.MaxRetries = -1
+		}
+		azure.SetDefaultRetryOptions(retry)
+		azure.SetDefaultMaxRPS(maxRPS)
+		if err := azure.SetDefaultAPIVersion(apiVersion); err != nil {
+			return err
+		}
+		if err := azure.SetDefaultEndpoint(endpoint); err != nil {
+			return err
+		}
+
+		var transport policy.Transporter
+		if transportOptions != (azure.TransportOptions{}) {
+			client, err := azure.NewHTTPClient(transportOptions)
+			if err != nil {
+				return err
+			}
+			transport = client
+			// Webhook notifications and the doctor's gateway probe go
+			// through the same proxy.
+			http.DefaultClient.Transport = client.Transport
+		}
+		switch {
+		case recordFile != "" && replayFile != "":
+			return fmt.Errorf("--record and --replay cannot be combined")
+		case recordFile != "":
+			recorder = azure.NewCassetteRecorder(recordFile, transport)
+			transport = recorder
+		case replayFile != "":
+			player, err := azure.LoadCassette(replayFile)
+			if err != nil {
+				return err
+			}
+			transport = player
+		}
+		if transport != nil {
+			azure.SetDefaultTransport(transport)
+		}
+		return nil
+	},
 }
 
 func Execute() {
-	err := rootCmd.Execute()
+	cmd, err := rootCmd.ExecuteC()
+	if recorder != nil {
+		// Also after a failure, whose requests are worth replaying too.
+		if err := recorder.Save(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+	}
 	if err != nil {
-		os.Exit(1)
+		if errors.Is(err, context.DeadlineExceeded) {
+			fmt.Fprintln(os.Stderr, "The command did not finish within --timeout, or Azure did not answer within --request-timeout")
+		}
+		os.Exit(exitCode(cmd, err))
 	}
 }
 
+// exitCode returns the process exit code for an error returned by cmd.
+// compare, drift, expiring and certificates expiring exit with 1 when they
+// found differences, drift or expiring subscriptions or certificates and with
+// 2 when they could not run, so CI can tell the two apart. All other failures
+// exit with 1.
+func exitCode(cmd *cobra.Command, err error) int {
+	switch cmd {
+	case compareCmd:
+		if !errors.Is(err, errCompareDifferences) {
+			return 2
+		}
+	case driftCmd:
+		if !errors.Is(err, errDriftFound) {
+			return 2
+		}
+	case expiringCmd:
+		if !errors.Is(err, errExpiringFound) {
+			return 2
+		}
+	case certificatesExpiringCmd:
+		if !errors.Is(err, errCertificatesExpiring) {
+			return 2
+		}
+	}
+	return 1
+}
+
 func init() {
+	backup.Generator = "kura " + Version
+
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 
 	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.kura.yaml)")
+	rootCmd.PersistentFlags().StringVar(&ageIdentity, "age-identity", "", "age identity file for decrypting age-encrypted backup files")
+	rootCmd.PersistentFlags().StringVar(&auditFile, "audit-file", defaultAuditFile(), "audit journal of the changes made to instances (default $"+audit.PathEnv+" or "+audit.DefaultPath+")")
+	rootCmd.PersistentFlags().BoolVar(&noAudit, "no-audit", false, "do not record changes in the audit journal")
+	rootCmd.PersistentFlags().Float64Var(&maxRPS, "max-rps", 0, "maximum Azure API requests per second across all requests of the command (0 means unlimited)")
+	rootCmd.PersistentFlags().StringVar(&transportOptions.ProxyURL, "proxy", "", "proxy for all HTTP requests (default $HTTPS_PROXY)")
+	rootCmd.PersistentFlags().StringVar(&transportOptions.CAFile, "ca-bundle", "", "PEM file of certificate authorities to trust in addition to the system's, such as the one of a TLS-intercepting proxy")
+	rootCmd.PersistentFlags().StringVar(&apiVersion, "api-version", os.Getenv(apiVersionEnv), "API Management REST API version to request instead of "+azure.DefaultAPIVersion+", for clouds that do not support it (default $"+apiVersionEnv+")")
+	rootCmd.PersistentFlags().StringVar(&endpoint, "endpoint", os.Getenv(endpointEnv), "base URL of a fake Azure Resource Manager, such as the one of 'kura emulator', to use instead of Azure (default $"+endpointEnv+")")
+	rootCmd.PersistentFlags().StringVar(&recordFile, "record", "", "record all Azure requests and responses to this cassette file, with keys and secret values redacted, for replaying them with --replay")
+	rootCmd.PersistentFlags().StringVar(&replayFile, "replay", "", "answer Azure requests from this cassette file recorded with --record instead of sending them; no Azure login is needed")
+	rootCmd.PersistentFlags().DurationVar(&commandTimeout, "timeout", 0, "overall deadline of the command, or of each cycle of daemon and operator (0 means none)")
+	rootCmd.PersistentFlags().DurationVar(&retryOptions.TryTimeout, "request-timeout", 2*time.Minute, "deadline of a single attempt of an Azure request; timed out attempts are retried (0 means none)")
+	rootCmd.PersistentFlags().IntVar(&retryOptions.MaxRetries, "max-retries", 3, "retries of Azure requests that were throttled (429) or failed with a server error (5xx); 0 disables retries")
+	rootCmd.PersistentFlags().DurationVar(&retryOptions.RetryDelay, "retry-delay", 800*time.Millisecond, "delay before the first retry of a failed Azure request, doubled for every further retry, unless Azure sends Retry-After")
+	rootCmd.PersistentFlags().DurationVar(&retryOptions.MaxRetryDelay, "max-retry-delay", time.Minute, "maximum delay between retries of a failed Azure request, including the delay requested by Retry-After")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
 	// rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
+
+// apiVersionEnv is the environment variable with the default of --api-version.
+const apiVersionEnv = "KURA_API_VERSION"
+
+// endpointEnv is the environment variable with the default of --endpoint.
+const endpointEnv = "KURA_ENDPOINT"
+
+// withTimeout returns parent limited by --timeout, if given.
+func withTimeout(parent context.Context) (context.Context, context.CancelFunc) {
+	if commandTimeout <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, commandTimeout)
+}
+
+// defaultAuditFile returns $KURA_AUDIT_FILE or audit.DefaultPath.
+func defaultAuditFile() string {
+	if path := os.Getenv(audit.PathEnv); path != "" {
+		return path
+	}
+	return audit.DefaultPath
+}
@@ -0,0 +1,340 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/f-marschall/apim-kura/internal/pool"
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/f-marschall/apim-kura/pkg/backup"
+	"github.com/spf13/cobra"
+)
+
+var rotateCmd = &cobra.Command{
+	Use:   "rotate [sid]",
+	Short: "Regenerate subscription keys in Azure API Management",
+	Long: `Rotate regenerates the primary key, the secondary key or both keys of a
+subscription and prints the new keys.
+
+Rotate a single subscription by passing its sid, or all subscriptions of the
+instance with --all. Combine --all with --product-id to rotate only the
+subscriptions of one product. The built-in master subscription is only rotated
+when its sid is given explicitly.
+
+With --graceful, the secondary keys are rotated first and printed, so consumers
+can switch to them while the primary keys keep working. The primary keys are
+rotated after confirmation, or after the --wait duration has passed.
+
+Use --export to write a CSV file with one row per regenerated key: the sid,
+display name, which key, the SHA-256 hash of the old key and the new key. It
+lets consumer teams find their new key by the hash of the key they used, e.g.
+when rotating all keys after a compromise.
+
+Example:
+  kura rotate 0f1e2d3c -g mygroup -a myapim --graceful
+  kura rotate -g mygroup -a myapim --all --graceful --wait 24h
+  kura rotate 0f1e2d3c --resource-group mygroup --apim-name myapim
+  kura rotate 0f1e2d3c -g mygroup -a myapim --key secondary
+  kura rotate -g mygroup -a myapim --all --product-id starter
+  kura rotate -g mygroup -a myapim --all --dry-run
+  kura rotate -g mygroup -a myapim --all --export mapping.csv`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runRotate,
+}
+
+var (
+	rotateResourceGroup string
+	rotateAPIMName      string
+	rotateSubscription  string
+	rotateProductID     string
+	rotateKey           string
+	rotateAll           bool
+	rotateDryRun        bool
+	rotateGraceful      bool
+	rotateWait          time.Duration
+	rotateExport        string
+	rotateConcurrency   int
+	rotateBreaker       breakerFlags
+
+	// rotations collects the regenerated keys for --export.
+	rotations []backup.KeyRotation
+)
+
+func init() {
+	rootCmd.AddCommand(rotateCmd)
+
+	rotateCmd.Flags().StringVarP(&rotateResourceGroup, "resource-group", "g", "", "Azure resource group name (required)")
+	rotateCmd.Flags().StringVarP(&rotateAPIMName, "apim-name", "a", "", "Azure API Management instance name (required)")
+	rotateCmd.Flags().StringVarP(&rotateSubscription, "subscription", "s", "", "Azure subscription ID")
+	rotateCmd.Flags().StringVarP(&rotateProductID, "product-id", "p", "", "With --all, only rotate subscriptions scoped to this product")
+	rotateCmd.Flags().StringVar(&rotateKey, "key", "both", "Key to regenerate: primary, secondary or both")
+	rotateCmd.Flags().BoolVar(&rotateAll, "all", false, "Rotate all subscriptions except the built-in master subscription")
+	rotateCmd.Flags().BoolVar(&rotateDryRun, "dry-run", false, "Preview the rotation without applying it")
+	rotateCmd.Flags().BoolVar(&rotateGraceful, "graceful", false, "Rotate the secondary keys first, then the primary keys after confirmation or --wait")
+	rotateCmd.Flags().DurationVar(&rotateWait, "wait", 0, "With --graceful, wait this long instead of asking before rotating the primary keys")
+	rotateCmd.Flags().StringVar(&rotateExport, "export", "", "Write the sid, old key hash and new key of every rotated key to this CSV file")
+	rotateCmd.Flags().IntVar(&rotateConcurrency, "concurrency", 1, "Number of subscriptions to rotate in parallel")
+
+	registerBreakerFlags(rotateCmd, &rotateBreaker)
+
+	rotateCmd.MarkFlagsMutuallyExclusive("graceful", "key")
+
+	rotateCmd.MarkFlagRequired("resource-group")
+	rotateCmd.MarkFlagRequired("apim-name")
+}
+
+// parseRotateKey reports which keys the --key value selects.
+func parseRotateKey(key string) (primary, secondary bool, err error) {
+	switch key {
+	case "primary":
+		return true, false, nil
+	case "secondary":
+		return false, true, nil
+	case "both":
+		return true, true, nil
+	default:
+		return false, false, fmt.Errorf("invalid --key %q: must be primary, secondary or both", key)
+	}
+}
+
+func runRotate(cmd *cobra.Command, args []string) error {
+	primary, secondary, err := parseRotateKey(rotateKey)
+	if err != nil {
+		return err
+	}
+	if len(args) == 1 == rotateAll {
+		return fmt.Errorf("provide either a sid or --all")
+	}
+	if rotateProductID != "" && !rotateAll {
+		return fmt.Errorf("--product-id requires --all")
+	}
+	if rotateWait != 0 && !rotateGraceful {
+		return fmt.Errorf("--wait requires --graceful")
+	}
+
+	fmt.Printf("Rotating subscription keys in APIM instance: %s\n", rotateAPIMName)
+	fmt.Printf("Resource Group: %s\n", rotateResourceGroup)
+	if rotateSubscription != "" {
+		fmt.Printf("Subscription ID: %s\n", rotateSubscription)
+	}
+	if rotateProductID != "" {
+		fmt.Printf("Product ID: %s\n", rotateProductID)
+	}
+	if rotateGraceful {
+		fmt.Println("Mode: Graceful (secondary keys first, then primary keys)")
+	} else {
+		fmt.Printf("Keys: %s\n", rotateKey)
+	}
+
+	if rotateDryRun {
+		fmt.Println("\nRunning in DRY-RUN mode. No changes will be applied.")
+	}
+
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	fmt.Println("\nAuthenticating with Azure CLI...")
+
+	client, err := azure.NewClient(ctx, rotateSubscription, rotateResourceGroup, rotateAPIMName)
+	if err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	fmt.Println("Successfully authenticated with Azure CLI")
+
+	var subs []azure.SubscriptionInfo
+	if rotateAll {
+		fmt.Println("\nFetching subscriptions...")
+		all, err := client.ListSubscriptions(ctx, rotateProductID)
+		if err != nil {
+			return fmt.Errorf("failed to list subscriptions: %w", err)
+		}
+		for _, sub := range all {
+			if sub.Name != "master" {
+				subs = append(subs, sub)
+			}
+		}
+	} else {
+		// The old keys are only needed to hash them for --export.
+		get := client.GetSubscriptionWithoutKeys
+		if rotateExport != "" {
+			get = client.GetSubscription
+		}
+		sub, err := get(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		subs = append(subs, *sub)
+	}
+
+	if len(subs) == 0 {
+		fmt.Println("No subscriptions found. Nothing to rotate.")
+		return nil
+	}
+	fmt.Printf("\nRotating %d subscription(s)\n", len(subs))
+
+	err = rotate(ctx, client, subs, primary, secondary)
+	if rotateExport != "" && len(rotations) > 0 {
+		if exportErr := backup.WriteKeyRotations(rotateExport, rotations); exportErr != nil {
+			fmt.Printf("  [WARNING] %v\n", exportErr)
+		} else {
+			fmt.Printf("Key mapping for %d key(s) written to %s\n", len(rotations), rotateExport)
+		}
+	}
+	return err
+}
+
+// rotate rotates the selected keys of subs, in two phases with --graceful.
+func rotate(ctx context.Context, client azure.SubscriptionStore, subs []azure.SubscriptionInfo, primary, secondary bool) error {
+	if !rotateGraceful {
+		rotated, failed, err := rotateKeys(ctx, client, subs, primary, secondary)
+		return rotateSummary(len(rotated), failed, err)
+	}
+
+	// Phase 1: rotate the secondary keys, so consumers can switch to them
+	// while the primary keys still work.
+	fmt.Println("\nPhase 1: rotating secondary keys")
+	rotated, failed, err := rotateKeys(ctx, client, subs, false, true)
+	if len(rotated) == 0 || err != nil {
+		return rotateSummary(len(rotated), failed, err)
+	}
+
+	if !rotateDryRun {
+		fmt.Println("\nSwitch consumers to the new secondary keys shown above before the primary keys are rotated.")
+		proceed, err := waitForPrimaryRotation(ctx)
+		if err != nil {
+			return err
+		}
+		if !proceed {
+			fmt.Println("Only the secondary keys were rotated. Run 'kura rotate --key primary' once consumers have switched.")
+			return rotateSummary(len(rotated), failed, nil)
+		}
+	}
+
+	// Phase 2: rotate the primary keys of the subscriptions whose secondary
+	// key was rotated.
+	fmt.Println("\nPhase 2: rotating primary keys")
+	rotated, failed2, err := rotateKeys(ctx, client, rotated, true, false)
+	return rotateSummary(len(rotated), failed+failed2, err)
+}
+
+// rotatedKeys are the keys of a subscription after rotateKeys regenerated
+// some of them.
+type rotatedKeys struct {
+	primary, secondary string
+}
+
+// rotateKeys regenerates the selected keys of subs and prints the new keys.
+// It returns the subscriptions that were rotated and the number of failures,
+// and an error if the --max-failures breaker stopped the rotation.
+func rotateKeys(ctx context.Context, client azure.SubscriptionStore, subs []azure.SubscriptionInfo, primary, secondary bool) ([]azure.SubscriptionInfo, int, error) {
+	if rotateDryRun {
+		for _, sub := range subs {
+			fmt.Printf("  [DRY-RUN] Would rotate %s of: %s (sid=%s)\n", describeKeys(primary, secondary), sub.Properties.DisplayName, sub.Name)
+		}
+		return subs, 0, nil
+	}
+
+	var rotated []azure.SubscriptionInfo
+	breaker := rotateBreaker.breaker()
+	rotateOne := func(ctx context.Context, sub azure.SubscriptionInfo) (rotatedKeys, error) {
+		primaryKey, secondaryKey, err := client.RegenerateKeys(ctx, sub.Name, primary, secondary)
+		if bErr := breaker.Record(err != nil); bErr != nil {
+			return rotatedKeys{}, pool.Stop(fmt.Errorf("%w; %w", err, bErr))
+		}
+		return rotatedKeys{primary: primaryKey, secondary: secondaryKey}, err
+	}
+	err := pool.Run(ctx, rotateConcurrency, subs, rotateOne, func(res pool.Result[azure.SubscriptionInfo, rotatedKeys]) {
+		sub := res.Item
+		if res.Err != nil {
+			fmt.Printf("  [FAIL] %s: %v\n", sub.Properties.DisplayName, res.Err)
+			return
+		}
+		fmt.Printf("  [OK]   %s (sid=%s)\n", sub.Properties.DisplayName, sub.Name)
+		if primary {
+			fmt.Printf("         Primary Key:   %s\n", res.Value.primary)
+			recordRotation(&sub, "primary", sub.Properties.PrimaryKey, res.Value.primary)
+		}
+		if secondary {
+			fmt.Printf("         Secondary Key: %s\n", res.Value.secondary)
+			recordRotation(&sub, "secondary", sub.Properties.SecondaryKey, res.Value.secondary)
+		}
+		rotated = append(rotated, sub)
+	})
+	if !errors.Is(err, pool.ErrTooManyFailures) {
+		err = nil
+	}
+	// Subscriptions that did not run because they were stopped count as failed.
+	return rotated, len(subs) - len(rotated), err
+}
+
+// recordRotation remembers a regenerated key for --export.
+func recordRotation(sub *azure.SubscriptionInfo, key, oldKey, newKey string) {
+	if rotateExport == "" {
+		return
+	}
+	rotations = append(rotations, backup.KeyRotation{
+		SID:         sub.Name,
+		DisplayName: sub.Properties.DisplayName,
+		Key:         key,
+		OldKeyHash:  backup.HashKey(oldKey),
+		NewKey:      newKey,
+	})
+}
+
+func describeKeys(primary, secondary bool) string {
+	switch {
+	case primary && secondary:
+		return "both keys"
+	case primary:
+		return "primary key"
+	default:
+		return "secondary key"
+	}
+}
+
+// waitForPrimaryRotation waits --wait before a graceful rotation proceeds to
+// the primary keys, or asks for confirmation if no wait was given. It reports
+// whether to proceed.
+func waitForPrimaryRotation(ctx context.Context) (bool, error) {
+	if rotateWait > 0 {
+		fmt.Printf("Waiting %s before rotating the primary keys (Ctrl+C to stop)...\n", rotateWait)
+		waitCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		select {
+		case <-time.After(rotateWait):
+			return true, nil
+		case <-waitCtx.Done():
+			fmt.Println()
+			return false, nil
+		}
+	}
+
+	fmt.Print("Rotate the primary keys now? [y/N] ")
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		return false, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+func rotateSummary(rotated, failed int, stopErr error) error {
+	if stopErr != nil {
+		fmt.Printf("\nRotate stopped: %d rotated, %d failed or not attempted\n", rotated, failed)
+		return stopErr
+	}
+	fmt.Printf("\nRotate complete: %d rotated, %d failed\n", rotated, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d subscription(s) failed to rotate", failed)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Run one backup cycle configured by environment variables",
+	Long: `Run takes a single backup of an Azure API Management instance, like one cycle
+of the daemon, and exits. It takes no flags and never prompts: it is
+configured entirely by environment variables, for Kubernetes CronJobs, Azure
+Container Instances and other schedulers that run a container without mounting
+flags or configuration files.
+
+  KURA_RESOURCE_GROUP              Azure resource group name (required)
+  KURA_APIM_NAME                   Azure API Management instance name (required)
+  KURA_SUBSCRIPTION                Azure subscription ID
+  KURA_PRODUCT_ID                  Scope the backup to a product
+  KURA_NAME_TEMPLATE               File name template for the backup file
+  KURA_DRIFT                       true to log how the live subscriptions differ from the previous backup
+  KURA_SYNC_TARGET_APIM            APIM instance to sync the subscriptions to
+  KURA_SYNC_TARGET_RESOURCE_GROUP  Resource group of the sync target (defaults to KURA_RESOURCE_GROUP)
+  KURA_SYNC_TARGET_SUBSCRIPTION    Azure subscription ID of the sync target
+  KURA_SYNC_PRUNE                  true to delete target subscriptions that do not exist in the source
+  KURA_SYNC_NO_BACKUP              true to not back up pruned subscriptions before deleting them
+  KURA_LOG_FORMAT                  json (default) or text
+
+The variables mean the same as the flags of the daemon command. As in the
+daemon, a backup is only written if the subscriptions changed since the newest
+backup, and results are logged as structured records to stdout. Run exits with
+an error if the backup, the drift check or the sync failed, so the scheduler
+marks the job as failed.
+
+Example:
+  KURA_RESOURCE_GROUP=mygroup KURA_APIM_NAME=myapim kura run`,
+	Args: cobra.NoArgs,
+	RunE: runRun,
+}
+
+// runEnv maps the environment variables of run to the daemon settings they
+// set.
+var runEnv = []struct {
+	name     string
+	value    *string
+	flag     *bool // set instead of value for boolean variables
+	required bool
+}{
+	{name: "KURA_RESOURCE_GROUP", value: &daemonResourceGroup, required: true},
+	{name: "KURA_APIM_NAME", value: &daemonAPIMName, required: true},
+	{name: "KURA_SUBSCRIPTION", value: &daemonSubscription},
+	{name: "KURA_PRODUCT_ID", value: &daemonProductID},
+	{name: "KURA_NAME_TEMPLATE", value: &daemonNameTemplate},
+	{name: "KURA_DRIFT", flag: &daemonDrift},
+	{name: "KURA_SYNC_TARGET_APIM", value: &daemonSyncTargetAPIM},
+	{name: "KURA_SYNC_TARGET_RESOURCE_GROUP", value: &daemonSyncTargetRG},
+	{name: "KURA_SYNC_TARGET_SUBSCRIPTION", value: &daemonSyncTargetSub},
+	{name: "KURA_SYNC_PRUNE", flag: &daemonSyncPrune},
+	{name: "KURA_SYNC_NO_BACKUP", flag: &daemonSyncNoBackup},
+	{name: "KURA_LOG_FORMAT", value: &daemonLogFormat},
+}
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+}
+
+func runRun(cmd *cobra.Command, args []string) error {
+	// Run takes no flags, so the usage is of no help with its errors.
+	cmd.SilenceUsage = true
+
+	var missing []string
+	for _, env := range runEnv {
+		v := strings.TrimSpace(os.Getenv(env.name))
+		switch {
+		case v == "" && env.required:
+			missing = append(missing, env.name)
+		case v == "":
+		case env.flag != nil:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: must be true or false", env.name, v)
+			}
+			*env.flag = b
+		default:
+			*env.value = v
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing environment variable(s): %s", strings.Join(missing, ", "))
+	}
+	if daemonSyncTargetAPIM == "" && (daemonSyncPrune || daemonSyncNoBackup) {
+		return fmt.Errorf("KURA_SYNC_PRUNE and KURA_SYNC_NO_BACKUP require KURA_SYNC_TARGET_APIM")
+	}
+	if err := checkDaemonSettings(); err != nil {
+		return err
+	}
+
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	d, err := newDaemon(ctx, "run")
+	if err != nil {
+		return err
+	}
+	return d.runCycle(ctx)
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/spf13/cobra"
+)
+
+var showCmd = &cobra.Command{
+	Use:   "show <sid>",
+	Short: "Show a single subscription from Azure API Management",
+	Long: `Show fetches exactly one subscription by its sid and prints it, without
+listing every subscription of the instance.
+
+The primary and secondary keys are only fetched and printed with --show-keys.
+
+Example:
+  kura show --resource-group mygroup --apim-name myapim 0f1e2d3c
+  kura show -g mygroup -a myapim 0f1e2d3c --show-keys
+  kura show -g mygroup -a myapim 0f1e2d3c --output json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runShow,
+}
+
+var (
+	showResourceGroup string
+	showAPIMName      string
+	showSubscription  string
+	showKeys          bool
+	showResolveOwners bool
+	showOutput        string
+)
+
+func init() {
+	rootCmd.AddCommand(showCmd)
+
+	showCmd.Flags().StringVarP(&showResourceGroup, "resource-group", "g", "", "Azure resource group name (required)")
+	showCmd.Flags().StringVarP(&showAPIMName, "apim-name", "a", "", "Azure API Management instance name (required)")
+	showCmd.Flags().StringVarP(&showSubscription, "subscription", "s", "", "Azure subscription ID")
+	showCmd.Flags().BoolVar(&showKeys, "show-keys", false, "Fetch and print the primary and secondary keys")
+	showCmd.Flags().BoolVar(&showResolveOwners, "resolve-owners", false, "Look up the owner and show their name and email")
+	showCmd.Flags().StringVar(&showOutput, "output", "text", "Output format: text or json")
+
+	showCmd.MarkFlagRequired("resource-group")
+	showCmd.MarkFlagRequired("apim-name")
+}
+
+func runShow(cmd *cobra.Command, args []string) error {
+	sid := args[0]
+
+	switch showOutput {
+	case "text", "json":
+	default:
+		return fmt.Errorf("invalid --output %q: must be text or json", showOutput)
+	}
+
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	client, err := azure.NewClient(ctx, showSubscription, showResourceGroup, showAPIMName)
+	if err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+
+	var sub *azure.SubscriptionInfo
+	if showKeys {
+		sub, err = client.GetSubscription(ctx, sid)
+	} else {
+		sub, err = client.GetSubscriptionWithoutKeys(ctx, sid)
+	}
+	if err != nil {
+		if azure.IsNotFound(err) {
+			return fmt.Errorf("subscription %s not found in APIM instance %s", sid, showAPIMName)
+		}
+		return err
+	}
+
+	if showResolveOwners {
+		subs := []azure.SubscriptionInfo{*sub}
+		if err := client.ResolveOwners(ctx, subs); err != nil {
+			return fmt.Errorf("failed to resolve owners: %w", err)
+		}
+		sub = &subs[0]
+	}
+
+	if showOutput == "json" {
+		data, err := json.MarshalIndent(sub, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal subscription: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if !showKeys {
+		sub.Properties.PrimaryKey = "(hidden, use --show-keys)"
+		sub.Properties.SecondaryKey = "(hidden, use --show-keys)"
+	}
+	fmt.Printf("%s\n", sub.Properties.DisplayName)
+	printSubscriptionDetails(sub, showResolveOwners)
+	return nil
+}
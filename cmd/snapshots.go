@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/f-marschall/apim-kura/pkg/backup"
+	"github.com/spf13/cobra"
+)
+
+var snapshotsCmd = &cobra.Command{
+	Use:   "snapshots",
+	Short: "Inspect local backup snapshots",
+	Long: `Snapshots provides commands for inspecting the backup files stored under
+the local backup directory.`,
+}
+
+var snapshotsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List available backup snapshots",
+	Long: `List scans the backup directory and shows every snapshot per APIM instance
+and product or API, with the time it was taken, number of subscriptions and
+file size. The time is the creation time in the metadata of the file, or its
+modification time for files without metadata.
+
+Example:
+  kura snapshots list
+  kura snapshots list --apim-name myapim
+  kura snapshots list -g mygroup -a myapim --product-id myproduct
+  kura snapshots list -g mygroup -a myapim --api-id petstore`,
+	Args: cobra.NoArgs,
+	RunE: runSnapshotsList,
+}
+
+var snapshotsDiffCmd = &cobra.Command{
+	Use:   "diff <apim-name>",
+	Short: "Compare two local snapshots of the same instance",
+	Long: `Diff resolves two snapshots of an APIM instance from the backup directory
+and compares them using the same rules as the compare command.
+
+--from and --to accept "latest", "earliest", a date (2006-01-02) or a
+timestamp (2006-01-02T15:04Z). A date or timestamp selects the newest
+snapshot taken at or before that point in time.
+
+Example:
+  kura snapshots diff myapim --from 2024-05-01 --to latest
+  kura snapshots diff myapim -g mygroup -p myproduct --from earliest
+  kura snapshots diff myapim -g mygroup --api-id petstore --from earliest`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSnapshotsDiff,
+}
+
+var (
+	snapshotsResourceGroup string
+	snapshotsAPIMName      string
+	snapshotsProductID     string
+	snapshotsAPIID         string
+	snapshotsFrom          string
+	snapshotsTo            string
+)
+
+func init() {
+	rootCmd.AddCommand(snapshotsCmd)
+	snapshotsCmd.AddCommand(snapshotsListCmd)
+	snapshotsCmd.AddCommand(snapshotsDiffCmd)
+
+	snapshotsListCmd.Flags().StringVarP(&snapshotsResourceGroup, "resource-group", "g", "", "Only show snapshots for this resource group")
+	snapshotsListCmd.Flags().StringVarP(&snapshotsAPIMName, "apim-name", "a", "", "Only show snapshots for this APIM instance")
+	snapshotsListCmd.Flags().StringVarP(&snapshotsProductID, "product-id", "p", "", "Only show snapshots for this product")
+	snapshotsListCmd.Flags().StringVar(&snapshotsAPIID, "api-id", "", "Only show snapshots of the subscriptions scoped to this API")
+	snapshotsListCmd.MarkFlagsMutuallyExclusive("product-id", "api-id")
+
+	snapshotsDiffCmd.Flags().StringVarP(&snapshotsResourceGroup, "resource-group", "g", "", "Resource group of the APIM instance (needed if the name is ambiguous)")
+	snapshotsDiffCmd.Flags().StringVarP(&snapshotsProductID, "product-id", "p", "", "Compare product-scoped snapshots of this product")
+	snapshotsDiffCmd.Flags().StringVar(&snapshotsAPIID, "api-id", "", "Compare snapshots of the subscriptions scoped to this API")
+	snapshotsDiffCmd.MarkFlagsMutuallyExclusive("product-id", "api-id")
+	snapshotsDiffCmd.Flags().StringVar(&snapshotsFrom, "from", "", "Older snapshot: latest, earliest, a date or a timestamp (required)")
+	snapshotsDiffCmd.Flags().StringVar(&snapshotsTo, "to", "latest", "Newer snapshot: latest, earliest, a date or a timestamp")
+
+	snapshotsDiffCmd.MarkFlagRequired("from")
+}
+
+func runSnapshotsList(cmd *cobra.Command, args []string) error {
+	snapshots, err := backup.ListSnapshots(backup.RootDir)
+	if err != nil {
+		return err
+	}
+
+	var filtered []backup.Snapshot
+	for _, snap := range snapshots {
+		if snapshotsResourceGroup != "" && snap.ResourceGroup != snapshotsResourceGroup {
+			continue
+		}
+		if snapshotsAPIMName != "" && snap.APIM != snapshotsAPIMName {
+			continue
+		}
+		if snapshotsProductID != "" && snap.Product != snapshotsProductID {
+			continue
+		}
+		if snapshotsAPIID != "" && snap.API != snapshotsAPIID {
+			continue
+		}
+		filtered = append(filtered, snap)
+	}
+
+	if len(filtered) == 0 {
+		fmt.Println("No snapshots found.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RESOURCE GROUP\tAPIM\tPRODUCT\tAPI\tTAKEN\tSUBSCRIPTIONS\tSIZE\tPATH")
+	for _, snap := range filtered {
+		product := snap.Product
+		if product == "" {
+			product = "-"
+		}
+		api := snap.API
+		if api == "" {
+			api = "-"
+		}
+		count := "?"
+		if snap.Count >= 0 {
+			count = fmt.Sprintf("%d", snap.Count)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			snap.ResourceGroup, snap.APIM, product, api,
+			snap.Time().UTC().Format("2006-01-02T15:04:05Z"),
+			count, formatSize(snap.Size), snap.Path)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n%d snapshot(s)\n", len(filtered))
+	return nil
+}
+
+func runSnapshotsDiff(cmd *cobra.Command, args []string) error {
+	apimName := args[0]
+
+	all, err := backup.ListSnapshots(backup.RootDir)
+	if err != nil {
+		return err
+	}
+	var snapshots []backup.Snapshot
+	if snapshotsAPIID != "" {
+		snapshots, err = backup.FilterAPISnapshots(all, snapshotsResourceGroup, apimName, snapshotsAPIID)
+	} else {
+		snapshots, err = backup.FilterSnapshots(all, snapshotsResourceGroup, apimName, snapshotsProductID)
+	}
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		return fmt.Errorf("no snapshots found for APIM instance %s", apimName)
+	}
+
+	from, err := backup.ResolveSnapshot(snapshots, snapshotsFrom)
+	if err != nil {
+		return fmt.Errorf("failed to resolve --from: %w", err)
+	}
+	to, err := backup.ResolveSnapshot(snapshots, snapshotsTo)
+	if err != nil {
+		return fmt.Errorf("failed to resolve --to: %w", err)
+	}
+
+	fmt.Printf("Comparing snapshots of APIM instance: %s\n", apimName)
+	fmt.Printf("  A: %s (%s)\n", from.Path, from.Time().UTC().Format("2006-01-02T15:04:05Z"))
+	fmt.Printf("  B: %s (%s)\n", to.Path, to.Time().UTC().Format("2006-01-02T15:04:05Z"))
+
+	subsA, err := loadBackupFile(from.Path)
+	if err != nil {
+		return fmt.Errorf("failed to load A: %w", err)
+	}
+	subsB, err := loadBackupFile(to.Path)
+	if err != nil {
+		return fmt.Errorf("failed to load B: %w", err)
+	}
+
+	result := compareSubscriptions(subsA, subsB, compareOptions{})
+	result.printText(false)
+	return result.Err()
+}
+
+// formatSize renders a byte count in a human-readable unit.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
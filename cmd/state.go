@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/spf13/cobra"
+)
+
+// stateCommand changes the state of one or more subscriptions. It backs the
+// suspend, activate and cancel commands, which differ only in the target state.
+type stateCommand struct {
+	state string // target state, e.g. "suspended"
+	verb  string // progressive verb for output, e.g. "Suspending"
+
+	resourceGroup string
+	apimName      string
+	subscription  string
+	comment       string
+	dryRun        bool
+	targets       targetFlags
+}
+
+var (
+	suspendState  = &stateCommand{state: "suspended", verb: "Suspending"}
+	activateState = &stateCommand{state: "active", verb: "Activating"}
+	cancelState   = &stateCommand{state: "cancelled", verb: "Cancelling"}
+)
+
+func init() {
+	rootCmd.AddCommand(suspendState.command("suspend", "Suspend subscriptions in Azure API Management",
+		`Suspend blocks subscriptions so their keys can no longer call any API, for
+example to cut off a leaked key during an incident. Suspended subscriptions
+keep their keys and can be re-enabled with 'kura activate'.`))
+	rootCmd.AddCommand(activateState.command("activate", "Activate subscriptions in Azure API Management",
+		`Activate re-enables suspended or cancelled subscriptions so their keys can
+call APIs again.`))
+	rootCmd.AddCommand(cancelState.command("cancel", "Cancel subscriptions in Azure API Management",
+		`Cancel marks subscriptions as cancelled. Like suspended subscriptions,
+cancelled subscriptions can no longer call any API.`))
+}
+
+// command builds the cobra command for s.
+func (s *stateCommand) command(use, short, long string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   use + " [sid]",
+		Short: short,
+		Long: long + `
+
+Pass a sid to change a single subscription. To change several subscriptions at
+once, select them with --all, --product-id or the selection flags (--sid,
+--name, --sid-regex, --name-regex and their --exclude variants). The built-in
+master subscription is never changed in bulk.
+
+Example:
+  kura ` + use + ` 0f1e2d3c --resource-group mygroup --apim-name myapim
+  kura ` + use + ` -g mygroup -a myapim --name-regex '^partner-' --dry-run
+  kura ` + use + ` -g mygroup -a myapim --all --product-id starter`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: s.run,
+	}
+
+	cmd.Flags().StringVarP(&s.resourceGroup, "resource-group", "g", "", "Azure resource group name (required)")
+	cmd.Flags().StringVarP(&s.apimName, "apim-name", "a", "", "Azure API Management instance name (required)")
+	cmd.Flags().StringVarP(&s.subscription, "subscription", "s", "", "Azure subscription ID")
+	cmd.Flags().StringVar(&s.comment, "comment", "", "State comment to record with the change")
+	cmd.Flags().BoolVar(&s.dryRun, "dry-run", false, "Preview the changes without applying them")
+	s.targets.register(cmd, "change")
+
+	cmd.MarkFlagRequired("resource-group")
+	cmd.MarkFlagRequired("apim-name")
+	return cmd
+}
+
+func (s *stateCommand) run(cmd *cobra.Command, args []string) error {
+	if err := s.targets.validate(args); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s subscriptions in APIM instance: %s\n", s.verb, s.apimName)
+	fmt.Printf("Resource Group: %s\n", s.resourceGroup)
+	if s.subscription != "" {
+		fmt.Printf("Subscription ID: %s\n", s.subscription)
+	}
+	if s.targets.productID != "" {
+		fmt.Printf("Product ID: %s\n", s.targets.productID)
+	}
+
+	if s.dryRun {
+		fmt.Println("\nRunning in DRY-RUN mode. No changes will be applied.")
+	}
+
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	fmt.Println("\nAuthenticating with Azure CLI...")
+
+	client, err := azure.NewClient(ctx, s.subscription, s.resourceGroup, s.apimName)
+	if err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	fmt.Println("Successfully authenticated with Azure CLI")
+
+	subs, err := s.targets.resolve(ctx, client, args)
+	if err != nil {
+		return err
+	}
+
+	if len(subs) == 0 {
+		fmt.Println("No subscriptions found. Nothing to change.")
+		return nil
+	}
+	fmt.Printf("\nFound %d subscription(s)\n", len(subs))
+
+	var changed, skipped, failed int
+	for _, sub := range subs {
+		sid := sub.Name
+		displayName := sub.Properties.DisplayName
+
+		if sub.Properties.State == s.state {
+			fmt.Printf("  [SKIP] %s (already %s)\n", displayName, s.state)
+			skipped++
+			continue
+		}
+
+		if s.dryRun {
+			fmt.Printf("  [DRY-RUN] Would change: %s (sid=%s) %s -> %s\n", displayName, sid, sub.Properties.State, s.state)
+			changed++
+			continue
+		}
+
+		// Fail instead of overwriting a change made since the subscription was read.
+		if _, err := client.SetState(azure.WithIfMatch(ctx, sub.ETag), sid, s.state, s.comment); err != nil {
+			fmt.Printf("  [FAIL] %s: %v\n", displayName, err)
+			failed++
+			continue
+		}
+		fmt.Printf("  [OK]   %s (sid=%s) %s -> %s\n", displayName, sid, sub.Properties.State, s.state)
+		changed++
+	}
+
+	fmt.Printf("\nComplete: %d changed, %d skipped, %d failed\n", changed, skipped, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d subscription(s) failed to change state", failed)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/spf13/cobra"
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show aggregate statistics about the subscriptions of an instance",
+	Long: `Stats summarizes the subscriptions of an Azure API Management instance: the
+total count, counts by state and by scope (product, API or the whole
+instance), how many allow tracing, and the oldest and newest creation dates.
+
+Use --output json to feed the numbers into a dashboard.
+
+Example:
+  kura stats --resource-group mygroup --apim-name myapim
+  kura stats -g mygroup -a myapim --output json`,
+	Args: cobra.NoArgs,
+	RunE: runStats,
+}
+
+var (
+	statsResourceGroup string
+	statsAPIMName      string
+	statsSubscription  string
+	statsOutput        string
+)
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+
+	statsCmd.Flags().StringVarP(&statsResourceGroup, "resource-group", "g", "", "Azure resource group name (required)")
+	statsCmd.Flags().StringVarP(&statsAPIMName, "apim-name", "a", "", "Azure API Management instance name (required)")
+	statsCmd.Flags().StringVarP(&statsSubscription, "subscription", "s", "", "Azure subscription ID")
+	statsCmd.Flags().StringVar(&statsOutput, "output", "text", "Output format: text or json")
+
+	statsCmd.MarkFlagRequired("resource-group")
+	statsCmd.MarkFlagRequired("apim-name")
+}
+
+// subscriptionStats aggregates the subscriptions of an instance.
+type subscriptionStats struct {
+	ResourceGroup  string         `json:"resourceGroup"`
+	APIMName       string         `json:"apimName"`
+	Total          int            `json:"total"`
+	ByState        map[string]int `json:"byState"`
+	ByScope        map[string]int `json:"byScope"`
+	TracingEnabled int            `json:"tracingEnabled"`
+	OldestCreated  string         `json:"oldestCreated,omitempty"`
+	NewestCreated  string         `json:"newestCreated,omitempty"`
+}
+
+// newSubscriptionStats aggregates subs. Creation dates are RFC 3339 UTC
+// timestamps, so they are ordered by comparing the strings.
+func newSubscriptionStats(resourceGroup, apimName string, subs []azure.SubscriptionInfo) *subscriptionStats {
+	stats := &subscriptionStats{
+		ResourceGroup: resourceGroup,
+		APIMName:      apimName,
+		Total:         len(subs),
+		ByState:       make(map[string]int),
+		ByScope:       make(map[string]int),
+	}
+	for _, sub := range subs {
+		stats.ByState[sub.Properties.State]++
+
+		scope := extractScopeSuffix(sub.Properties.Scope)
+		if scope == "" {
+			scope = "(instance)"
+		}
+		stats.ByScope[scope]++
+
+		if sub.Properties.AllowTracing {
+			stats.TracingEnabled++
+		}
+
+		if created := sub.Properties.CreatedDate; created != "" {
+			if stats.OldestCreated == "" || created < stats.OldestCreated {
+				stats.OldestCreated = created
+			}
+			if created > stats.NewestCreated {
+				stats.NewestCreated = created
+			}
+		}
+	}
+	return stats
+}
+
+func runStats(cmd *cobra.Command, args []string) error {
+	switch statsOutput {
+	case "text", "json":
+	default:
+		return fmt.Errorf("invalid --output %q: must be text or json", statsOutput)
+	}
+
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	client, err := azure.NewClient(ctx, statsSubscription, statsResourceGroup, statsAPIMName)
+	if err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+
+	subs, err := client.ListSubscriptionsWithoutKeys(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+
+	stats := newSubscriptionStats(statsResourceGroup, statsAPIMName, subs)
+	if statsOutput == "json" {
+		return printJSON(stats)
+	}
+	return stats.printText()
+}
+
+// printText prints the statistics as tables.
+func (s *subscriptionStats) printText() error {
+	fmt.Printf("APIM instance:   %s\n", s.APIMName)
+	fmt.Printf("Resource Group:  %s\n", s.ResourceGroup)
+	fmt.Printf("Subscriptions:   %d\n", s.Total)
+	fmt.Printf("Tracing enabled: %d\n", s.TracingEnabled)
+	if s.OldestCreated != "" {
+		fmt.Printf("Oldest created:  %s\n", s.OldestCreated)
+		fmt.Printf("Newest created:  %s\n", s.NewestCreated)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\nSTATE\tCOUNT")
+	for _, state := range sortedCountKeys(s.ByState) {
+		fmt.Fprintf(w, "%s\t%d\n", state, s.ByState[state])
+	}
+	fmt.Fprintln(w, "\nSCOPE\tCOUNT")
+	for _, scope := range sortedCountKeys(s.ByScope) {
+		fmt.Fprintf(w, "%s\t%d\n", scope, s.ByScope[scope])
+	}
+	return w.Flush()
+}
+
+// sortedCountKeys returns the keys of counts ordered by descending count,
+// then by name.
+func sortedCountKeys(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
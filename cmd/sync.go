@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/f-marschall/apim-kura/pkg/backup"
+	"github.com/spf13/cobra"
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Make the subscriptions of a target instance match a source instance",
+	Long: `Sync reconciles the subscriptions of a target Azure API Management instance
+with those of a source instance, one way: subscriptions missing in the target
+are created and subscriptions whose attributes or keys differ are updated.
+With --prune, subscriptions that exist only in the target are deleted.
+
+Subscriptions are paired by sid. Scopes are rebuilt for the target instance,
+and owners point to the user with the same ID in the target instance unless
+--owner-map maps them to another user. The built-in master subscription is
+never changed.
+
+Sync first prints the plan of creates, updates and deletes. With --dry-run it
+stops there. Otherwise, if the plan updates or deletes subscriptions, kura asks
+for confirmation (skip with --yes) and, before pruning, saves the subscriptions
+it deletes to a backup under backup/.pre-delete (skip with --no-backup).
+
+Example:
+  kura sync --source-resource-group prod-rg --source-apim prod-apim --target-resource-group dr-rg --target-apim dr-apim --dry-run
+  kura sync --source-resource-group rg --source-apim a --target-apim b --prune --yes`,
+	Args: cobra.NoArgs,
+	RunE: runSync,
+}
+
+var (
+	syncSourceRG           string
+	syncSourceAPIM         string
+	syncSourceSubscription string
+	syncTargetRG           string
+	syncTargetAPIM         string
+	syncTargetSubscription string
+	syncProductID          string
+	syncOwnerMap           string
+	syncPrune              bool
+	syncDryRun             bool
+	syncNoBackup           bool
+	syncYes                bool
+)
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+
+	syncCmd.Flags().StringVar(&syncSourceRG, "source-resource-group", "", "Resource group of --source-apim (required)")
+	syncCmd.Flags().StringVar(&syncSourceAPIM, "source-apim", "", "APIM instance whose subscriptions are the desired state (required)")
+	syncCmd.Flags().StringVar(&syncSourceSubscription, "source-subscription", "", "Azure subscription ID of --source-apim")
+	syncCmd.Flags().StringVar(&syncTargetRG, "target-resource-group", "", "Resource group of --target-apim (defaults to --source-resource-group)")
+	syncCmd.Flags().StringVar(&syncTargetAPIM, "target-apim", "", "APIM instance to change (required)")
+	syncCmd.Flags().StringVar(&syncTargetSubscription, "target-subscription", "", "Azure subscription ID of --target-apim")
+	syncCmd.Flags().StringVarP(&syncProductID, "product-id", "p", "", "Only sync subscriptions scoped to this product")
+	syncCmd.Flags().StringVar(&syncOwnerMap, "owner-map", "", "YAML file mapping source user IDs to target user IDs (or \"drop\")")
+	syncCmd.Flags().BoolVar(&syncPrune, "prune", false, "Delete target subscriptions that do not exist in the source")
+	syncCmd.Flags().BoolVar(&syncDryRun, "dry-run", false, "Only print the plan without applying it")
+	syncCmd.Flags().BoolVar(&syncNoBackup, "no-backup", false, "Do not back up pruned subscriptions before deleting them")
+	registerYesFlags(syncCmd, &syncYes)
+
+	syncCmd.MarkFlagRequired("source-resource-group")
+	syncCmd.MarkFlagRequired("source-apim")
+	syncCmd.MarkFlagRequired("target-apim")
+}
+
+func runSync(cmd *cobra.Command, args []string) error {
+	if syncTargetRG == "" {
+		syncTargetRG = syncSourceRG
+	}
+	if syncTargetAPIM == syncSourceAPIM && syncTargetRG == syncSourceRG && syncTargetSubscription == syncSourceSubscription {
+		return fmt.Errorf("source and target are the same APIM instance")
+	}
+
+	fmt.Printf("Syncing subscription keys to APIM instance: %s\n", syncTargetAPIM)
+	fmt.Printf("Source: %s (resource group %s)\n", syncSourceAPIM, syncSourceRG)
+	fmt.Printf("Target: %s (resource group %s)\n", syncTargetAPIM, syncTargetRG)
+	if syncProductID != "" {
+		fmt.Printf("Product ID: %s\n", syncProductID)
+	}
+	if syncPrune {
+		fmt.Println("Mode: Prune (delete target subscriptions missing in the source)")
+	}
+
+	var ownerMap backup.OwnerMap
+	if syncOwnerMap != "" {
+		var err error
+		ownerMap, err = backup.LoadOwnerMap(syncOwnerMap)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Owner map: %s (%d entries)\n", syncOwnerMap, len(ownerMap))
+	}
+
+	if syncDryRun {
+		fmt.Println("\nRunning in DRY-RUN mode. No changes will be applied.")
+	}
+
+	if !syncDryRun {
+		unlock, err := lockInstance(syncTargetRG, syncTargetAPIM, "sync")
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	fmt.Println("\nAuthenticating with Azure CLI...")
+
+	source, err := azure.NewClient(ctx, syncSourceSubscription, syncSourceRG, syncSourceAPIM)
+	if err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	target, err := azure.NewClient(ctx, syncTargetSubscription, syncTargetRG, syncTargetAPIM)
+	if err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	fmt.Println("Successfully authenticated with Azure CLI")
+
+	fmt.Println("\nFetching subscriptions of the source instance...")
+	desired, err := source.ListSubscriptions(ctx, syncProductID)
+	if err != nil {
+		return fmt.Errorf("failed to list source subscriptions: %w", err)
+	}
+	fmt.Println("Fetching subscriptions of the target instance...")
+	live, err := target.ListSubscriptions(ctx, syncProductID)
+	if err != nil {
+		return fmt.Errorf("failed to list target subscriptions: %w", err)
+	}
+
+	desired = retarget(desired, ownerMap, target.SubscriptionID(), syncTargetRG, syncTargetAPIM)
+	plan := buildPlan(desired, live, syncPrune)
+
+	fmt.Println()
+	plan.print()
+	if len(plan.Actions) == 0 {
+		fmt.Println("Target is in sync. Nothing to do.")
+		return nil
+	}
+	if syncDryRun {
+		return nil
+	}
+
+	return applyPlan(ctx, target, plan, live, syncTargetRG, syncTargetAPIM, syncYes, syncNoBackup)
+}
+
+// applyPlan asks for confirmation if plan changes or deletes existing
+// subscriptions, backs up the live subscriptions it deletes and executes it.
+func applyPlan(ctx context.Context, client azure.SubscriptionStore, plan *reconcilePlan, live []azure.SubscriptionInfo, resourceGroup, apimName string, yes, noBackup bool) error {
+	updates, deletes := plan.count(actionUpdate), plan.count(actionDelete)
+	if !yes && updates+deletes > 0 {
+		fmt.Println()
+		ok, err := confirm(fmt.Sprintf("Apply %d change(s) to APIM instance %s, updating %d and deleting %d existing subscription(s)?",
+			len(plan.Actions), apimName, updates, deletes))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println("Aborted. No changes were applied.")
+			return nil
+		}
+	}
+
+	if deletes > 0 && !noBackup {
+		if _, err := backupBeforeDelete(resourceGroup, apimName, plan.deleted(live)); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println()
+	applied, failed := plan.execute(ctx, client)
+	fmt.Printf("\nApply complete: %d applied, %d failed\n", applied, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d change(s) failed", failed)
+	}
+	return nil
+}
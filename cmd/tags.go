@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+)
+
+func init() {
+	rootCmd.AddCommand(newConfigCommand(configKind[azure.TagInfo]{
+		use:    "tags",
+		noun:   "tag",
+		plural: "tags",
+		short:  "Back up and restore the tags of an Azure API Management instance",
+		long: `Tags backs up and restores the tags of an Azure API Management instance and
+their assignments to APIs, products and operations, by which the developer
+portal groups and filters APIs and products.
+
+Restore creates each tag and assigns it again. The APIs, products and
+operations must already exist in the instance; assignments to missing ones are
+reported as failures of the tag, and its other assignments are still made.`,
+		list: func(ctx context.Context, client *azure.Client) ([]azure.TagInfo, error) {
+			return client.ListTags(ctx)
+		},
+		put: func(ctx context.Context, client *azure.Client, tag azure.TagInfo) error {
+			return client.PutTag(ctx, tag)
+		},
+		name: func(tag azure.TagInfo) string {
+			return tag.ID
+		},
+	}))
+}
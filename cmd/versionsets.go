@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+)
+
+func init() {
+	rootCmd.AddCommand(newConfigCommand(configKind[azure.APIVersionSetInfo]{
+		use:    "version-sets",
+		noun:   "API version set",
+		plural: "API version sets",
+		short:  "Back up and restore the API version sets of an Azure API Management instance",
+		long: `Version-sets backs up and restores the API version sets of an Azure API
+Management instance, with their versioning scheme and the APIs in each set
+with their version.
+
+Restore creates each version set, then adds its APIs to it again with their
+version, so the APIs must be restored first. Requests to a versioned API only
+reach it once it is in its version set again.`,
+		list: func(ctx context.Context, client *azure.Client) ([]azure.APIVersionSetInfo, error) {
+			return client.ListAPIVersionSets(ctx)
+		},
+		put: func(ctx context.Context, client *azure.Client, set azure.APIVersionSetInfo) error {
+			return client.PutAPIVersionSet(ctx, set)
+		},
+		name: func(set azure.APIVersionSetInfo) string {
+			return set.ID
+		},
+	}))
+}
@@ -0,0 +1,218 @@
+// Package audit keeps an append-only journal of the changes kura makes to
+// APIM subscriptions and the other entities of an instance.
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/f-marschall/apim-kura/pkg/backup"
+)
+
+// PathEnv is the environment variable that overrides DefaultPath.
+const PathEnv = "KURA_AUDIT_FILE"
+
+// DefaultPath is where the journal is kept unless PathEnv is set.
+var DefaultPath = filepath.Join(backup.RootDir, ".audit", "journal.jsonl")
+
+// Entry is a single change in the journal. It never contains keys: the state
+// of the subscription or other entity before and after the change is recorded
+// as a hash.
+type Entry struct {
+	// ID identifies the entry in the journal. It is not stored but derived
+	// from the entry when the journal is read.
+	ID             string    `json:"id,omitempty"`
+	Time           time.Time `json:"time"`
+	Actor          string    `json:"actor"`
+	Host           string    `json:"host,omitempty"`
+	Command        string    `json:"command,omitempty"`
+	Operation      string    `json:"operation"`
+	SubscriptionID string    `json:"subscriptionId"`
+	ResourceGroup  string    `json:"resourceGroup"`
+	APIMName       string    `json:"apimName"`
+	SID            string    `json:"sid,omitempty"`
+	// Kind and Target are the kind and ID of the entity changed, such as
+	// "named-values", for changes to other entities than subscriptions.
+	Kind        string `json:"kind,omitempty"`
+	Target      string `json:"target,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
+	// BeforeHash and AfterHash are empty if the entity did not exist.
+	BeforeHash string `json:"beforeHash,omitempty"`
+	AfterHash  string `json:"afterHash,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Journal appends entries to a JSON Lines file.
+type Journal struct {
+	path    string
+	command string
+
+	mu    sync.Mutex
+	actor string
+	host  string
+}
+
+// NewJournal returns a journal writing to path. command is recorded with
+// every entry, such as "restore".
+func NewJournal(path, command string) *Journal {
+	return &Journal{path: path, command: command}
+}
+
+// Record appends the mutation m to the journal. A journal that cannot be
+// written is reported on stderr, as the change itself already happened.
+func (j *Journal) Record(m azure.Mutation) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.actor == "" {
+		j.actor, j.host = resolveActor()
+	}
+	actor := m.Actor
+	if actor == "" {
+		actor = j.actor
+	}
+	e := Entry{
+		Time:           time.Now().UTC(),
+		Actor:          actor,
+		Host:           j.host,
+		Command:        j.command,
+		Operation:      m.Operation,
+		SubscriptionID: m.SubscriptionID,
+		ResourceGroup:  m.ResourceGroup,
+		APIMName:       m.APIMName,
+		SID:            m.SID,
+		Kind:           m.Kind,
+		Target:         m.Target,
+		BeforeHash:     Hash(m.Before),
+		AfterHash:      Hash(m.After),
+	}
+	if m.Kind != "" {
+		e.BeforeHash = HashEntity(m.BeforeEntity)
+		e.AfterHash = HashEntity(m.AfterEntity)
+	}
+	for _, s := range []*azure.SubscriptionInfo{m.After, m.Before} {
+		if s != nil {
+			e.DisplayName = s.Properties.DisplayName
+			break
+		}
+	}
+	if m.Err != nil {
+		e.Error = m.Err.Error()
+	}
+
+	if err := j.append(e); err != nil {
+		fmt.Fprintf(os.Stderr, "  [WARNING] failed to write audit journal: %v\n", err)
+	}
+}
+
+func (j *Journal) append(e Entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(j.path), backup.DirMode); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(j.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, backup.FileMode)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Hash returns a hash of the state of sub, including its keys, or an empty
+// string if sub is nil. Equal hashes mean equal subscriptions.
+func Hash(sub *azure.SubscriptionInfo) string {
+	if sub == nil {
+		return ""
+	}
+	return HashEntity(sub)
+}
+
+// HashEntity is like Hash for any entity of an instance, such as a named
+// value, or returns an empty string if entity is nil.
+func HashEntity(entity any) string {
+	if entity == nil {
+		return ""
+	}
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// resolveActor returns the account kura acts as if the credential does not
+// tell (see azure.Client.Identity), which is the Azure CLI account or else the
+// local user, and the host it runs on.
+func resolveActor() (actor, host string) {
+	out, err := exec.Command("az", "account", "show", "--query", "user.name", "-o", "tsv").Output()
+	if err == nil {
+		actor = strings.TrimSpace(string(out))
+	}
+	if actor == "" {
+		if u, err := user.Current(); err == nil {
+			actor = u.Username
+		}
+	}
+	if actor == "" {
+		actor = "unknown"
+	}
+	host, _ = os.Hostname()
+	return actor, host
+}
+
+// Read returns the entries of the journal at path, oldest first. A missing
+// journal has no entries.
+func Read(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open audit journal: %w", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("audit journal %s, line %d: %w", path, n, err)
+		}
+		e.ID = entryID(scanner.Bytes())
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit journal: %w", err)
+	}
+	return entries, nil
+}
+
+// entryID derives the ID of the entry stored as line. The journal is only
+// appended to, so IDs are stable.
+func entryID(line []byte) string {
+	sum := sha256.Sum256(line)
+	return hex.EncodeToString(sum[:6])
+}
@@ -0,0 +1,84 @@
+// Package config loads the kura configuration file, which names the APIM
+// instances kura works with as profiles.
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PathEnv is the environment variable that overrides the default path of the
+// configuration file.
+const PathEnv = "KURA_CONFIG"
+
+// Profile identifies an APIM instance, such as the one of an environment.
+type Profile struct {
+	Tenant         string `yaml:"tenant,omitempty"`
+	SubscriptionID string `yaml:"subscription,omitempty"`
+	ResourceGroup  string `yaml:"resourceGroup"`
+	APIMName       string `yaml:"apimName"`
+}
+
+// Config is the content of the configuration file:
+//
+//	profiles:
+//	  dev:
+//	    subscription: 00000000-0000-0000-0000-000000000000
+//	    resourceGroup: dev-rg
+//	    apimName: dev-apim
+type Config struct {
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// DefaultPath returns $KURA_CONFIG, or kura/config.yaml in the user's
+// configuration directory (~/.config on Linux).
+func DefaultPath() string {
+	if path := os.Getenv(PathEnv); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return filepath.Join(".kura", "config.yaml")
+	}
+	return filepath.Join(dir, "kura", "config.yaml")
+}
+
+// Load reads the configuration file at path.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+	var c Config
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	for name, p := range c.Profiles {
+		if p.ResourceGroup == "" || p.APIMName == "" {
+			return nil, fmt.Errorf("config %s: profile %q needs resourceGroup and apimName", path, name)
+		}
+		if p.Tenant != "" && p.SubscriptionID == "" {
+			return nil, fmt.Errorf("config %s: profile %q sets a tenant without a subscription", path, name)
+		}
+	}
+	return &c, nil
+}
+
+// Profile returns the profile with the given name.
+func (c *Config) Profile(name string) (Profile, error) {
+	p, ok := c.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(c.Profiles))
+		for n := range c.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return Profile{}, fmt.Errorf("unknown profile %q (configured: %s)", name, strings.Join(names, ", "))
+	}
+	return p, nil
+}
@@ -0,0 +1,374 @@
+// Package emulator serves a fake of the Azure Resource Manager endpoints for
+// API Management subscriptions that kura uses, backed by azure.MemoryStore.
+// It lets users try kura and run end-to-end tests without an Azure
+// subscription; see azure.SetDefaultEndpoint.
+package emulator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+)
+
+// servicePath matches the paths below an API Management instance.
+var servicePath = regexp.MustCompile(`(?i)^/subscriptions/([^/]+)/resourceGroups/([^/]+)/providers/Microsoft\.ApiManagement/service/([^/]+)(/.*)?$`)
+
+// Server is an http.Handler that emulates API Management instances. Every
+// instance a request refers to is created empty on first use, so any
+// resource group and instance name can be used.
+type Server struct {
+	mu        sync.Mutex
+	instances map[string]*azure.MemoryStore
+}
+
+// New returns a server without instances.
+func New() *Server {
+	return &Server{instances: make(map[string]*azure.MemoryStore)}
+}
+
+// instanceKey identifies an instance in Server.instances.
+func instanceKey(subscriptionID, resourceGroup, apimName string) string {
+	return strings.ToLower(subscriptionID + "/" + resourceGroup + "/" + apimName)
+}
+
+// Instance returns the store of an instance, creating it if needed, for
+// example to seed it with subscriptions before serving.
+func (s *Server) Instance(subscriptionID, resourceGroup, apimName string) *azure.MemoryStore {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := instanceKey(subscriptionID, resourceGroup, apimName)
+	store, ok := s.instances[key]
+	if !ok {
+		store = azure.NewMemoryStore(subscriptionID, resourceGroup, apimName, nil)
+		s.instances[key] = store
+	}
+	return store
+}
+
+// Seed replaces the store of an instance with one holding subs.
+func (s *Server) Seed(subscriptionID, resourceGroup, apimName string, subs []azure.SubscriptionInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.instances[instanceKey(subscriptionID, resourceGroup, apimName)] = azure.NewMemoryStore(subscriptionID, resourceGroup, apimName, subs)
+}
+
+// ServeHTTP serves the subscription, product, API and user endpoints of the
+// API Management REST API. The api-version parameter is ignored.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m := servicePath.FindStringSubmatch(r.URL.Path)
+	if m == nil {
+		writeError(w, http.StatusNotFound, "InvalidResourceType", "the emulator only serves API Management instances")
+		return
+	}
+	store := s.Instance(m[1], m[2], m[3])
+	parts := strings.Split(strings.Trim(m[4], "/"), "/")
+	h := &handler{store: store, w: w, r: r}
+
+	switch {
+	case len(parts) == 1 && parts[0] == "subscriptions" && r.Method == http.MethodGet:
+		h.list("")
+	case len(parts) == 3 && parts[0] == "products" && parts[2] == "subscriptions" && r.Method == http.MethodGet:
+		h.list(parts[1])
+	case len(parts) == 2 && parts[0] == "subscriptions":
+		h.subscription(parts[1])
+	case len(parts) == 3 && parts[0] == "subscriptions" && r.Method == http.MethodPost:
+		h.action(parts[1], parts[2])
+	case len(parts) == 1 && parts[0] == "products" && r.Method == http.MethodGet:
+		h.products()
+	case len(parts) == 1 && parts[0] == "apis" && r.Method == http.MethodGet:
+		h.apis()
+	case len(parts) == 2 && parts[0] == "users" && r.Method == http.MethodGet:
+		writeError(w, http.StatusNotFound, "ResourceNotFound", fmt.Sprintf("user %s not found", parts[1]))
+	default:
+		writeError(w, http.StatusNotFound, "ResourceNotFound", fmt.Sprintf("%s %s is not emulated", r.Method, r.URL.Path))
+	}
+}
+
+// handler serves a single request for an instance.
+type handler struct {
+	store *azure.MemoryStore
+	w     http.ResponseWriter
+	r     *http.Request
+}
+
+// list serves the subscriptions of the instance, or of one product.
+func (h *handler) list(productID string) {
+	subs, err := h.store.ListSubscriptionsWithoutKeys(h.r.Context(), productID)
+	if err != nil {
+		h.fail(err)
+		return
+	}
+	if subs == nil {
+		subs = []azure.SubscriptionInfo{}
+	}
+	writeJSON(h.w, http.StatusOK, "", map[string]any{"value": subs})
+}
+
+// subscription serves the requests for a single subscription.
+func (h *handler) subscription(sid string) {
+	ctx := h.r.Context()
+	if etag := h.r.Header.Get("If-Match"); etag != "" && etag != "*" {
+		ctx = azure.WithIfMatch(ctx, etag)
+	}
+
+	switch h.r.Method {
+	case http.MethodGet, http.MethodHead:
+		sub, err := h.store.GetSubscriptionWithoutKeys(ctx, sid)
+		if err != nil {
+			h.fail(err)
+			return
+		}
+		if h.r.Method == http.MethodHead {
+			h.w.Header().Set("ETag", sub.ETag)
+			h.w.WriteHeader(http.StatusOK)
+			return
+		}
+		writeJSON(h.w, http.StatusOK, sub.ETag, sub)
+
+	case http.MethodPut:
+		var body struct {
+			Properties struct {
+				Scope        string `json:"scope"`
+				DisplayName  string `json:"displayName"`
+				PrimaryKey   string `json:"primaryKey"`
+				SecondaryKey string `json:"secondaryKey"`
+				State        string `json:"state"`
+				OwnerID      string `json:"ownerId"`
+				AllowTracing *bool  `json:"allowTracing"`
+			} `json:"properties"`
+		}
+		if err := json.NewDecoder(h.r.Body).Decode(&body); err != nil {
+			writeError(h.w, http.StatusBadRequest, "InvalidRequestContent", err.Error())
+			return
+		}
+		p := body.Properties
+		if p.Scope == "" || p.DisplayName == "" {
+			writeError(h.w, http.StatusBadRequest, "ValidationError", "scope and displayName are required")
+			return
+		}
+		existed, err := h.store.SubscriptionExists(ctx, sid)
+		if err != nil {
+			h.fail(err)
+			return
+		}
+		sub, err := h.store.CreateSubscription(ctx, sid, p.Scope, p.DisplayName, &azure.CreateSubscriptionOptions{
+			PrimaryKey:   p.PrimaryKey,
+			SecondaryKey: p.SecondaryKey,
+			State:        p.State,
+			OwnerID:      p.OwnerID,
+			AllowTracing: p.AllowTracing,
+		})
+		if err != nil {
+			h.fail(err)
+			return
+		}
+		status := http.StatusCreated
+		if existed {
+			status = http.StatusOK
+		}
+		sub.Properties.PrimaryKey, sub.Properties.SecondaryKey = "", ""
+		writeJSON(h.w, status, sub.ETag, sub)
+
+	case http.MethodPatch:
+		h.update(ctx, sid)
+
+	case http.MethodDelete:
+		if err := h.store.DeleteSubscription(ctx, sid); err != nil {
+			h.fail(err)
+			return
+		}
+		h.w.WriteHeader(http.StatusOK)
+
+	default:
+		writeError(h.w, http.StatusMethodNotAllowed, "MethodNotAllowed", h.r.Method+" is not supported")
+	}
+}
+
+// update serves PATCH requests, which change the state, state comment or
+// expiration date of a subscription.
+func (h *handler) update(ctx context.Context, sid string) {
+	var body struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if err := json.NewDecoder(h.r.Body).Decode(&body); err != nil {
+		writeError(h.w, http.StatusBadRequest, "InvalidRequestContent", err.Error())
+		return
+	}
+
+	var sub *azure.SubscriptionInfo
+	var err error
+	if raw, ok := body.Properties["state"]; ok {
+		var state, comment string
+		if err := json.Unmarshal(raw, &state); err != nil {
+			writeError(h.w, http.StatusBadRequest, "InvalidRequestContent", "invalid state")
+			return
+		}
+		if raw, ok := body.Properties["stateComment"]; ok {
+			json.Unmarshal(raw, &comment)
+		}
+		if sub, err = h.store.SetState(ctx, sid, state, comment); err != nil {
+			h.fail(err)
+			return
+		}
+		// The entity tag changed with the state.
+		ctx = azure.WithIfMatch(ctx, sub.ETag)
+	}
+	if raw, ok := body.Properties["expirationDate"]; ok {
+		var expiration *time.Time
+		if err := json.Unmarshal(raw, &expiration); err != nil {
+			writeError(h.w, http.StatusBadRequest, "InvalidRequestContent", "invalid expirationDate")
+			return
+		}
+		if expiration == nil {
+			sub, err = h.store.ClearExpirationDate(ctx, sid)
+		} else {
+			sub, err = h.store.SetExpirationDate(ctx, sid, *expiration)
+		}
+		if err != nil {
+			h.fail(err)
+			return
+		}
+	}
+	if sub == nil {
+		if sub, err = h.store.GetSubscriptionWithoutKeys(ctx, sid); err != nil {
+			h.fail(err)
+			return
+		}
+	}
+	writeJSON(h.w, http.StatusOK, sub.ETag, sub)
+}
+
+// action serves the POST actions on a subscription.
+func (h *handler) action(sid, name string) {
+	ctx := h.r.Context()
+	switch name {
+	case "listSecrets":
+		sub, err := h.store.GetSubscription(ctx, sid)
+		if err != nil {
+			h.fail(err)
+			return
+		}
+		writeJSON(h.w, http.StatusOK, sub.ETag, map[string]string{
+			"primaryKey":   sub.Properties.PrimaryKey,
+			"secondaryKey": sub.Properties.SecondaryKey,
+		})
+	case "regeneratePrimaryKey", "regenerateSecondaryKey":
+		if _, _, err := h.store.RegenerateKeys(ctx, sid, name == "regeneratePrimaryKey", name == "regenerateSecondaryKey"); err != nil {
+			h.fail(err)
+			return
+		}
+		h.w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(h.w, http.StatusNotFound, "ResourceNotFound", fmt.Sprintf("action %s is not emulated", name))
+	}
+}
+
+// products serves the products the subscriptions of the instance are scoped
+// to, as the emulator has no other notion of products.
+func (h *handler) products() {
+	var products []map[string]any
+	for _, id := range h.scopes("/products/") {
+		products = append(products, map[string]any{
+			"id":   h.serviceID() + "/products/" + id,
+			"name": id,
+			"type": "Microsoft.ApiManagement/service/products",
+			"properties": map[string]any{
+				"displayName":          id,
+				"state":                "published",
+				"subscriptionRequired": true,
+			},
+		})
+	}
+	writeJSON(h.w, http.StatusOK, "", map[string]any{"value": emptyIfNil(products)})
+}
+
+// apis serves the APIs the subscriptions of the instance are scoped to.
+func (h *handler) apis() {
+	var apis []map[string]any
+	for _, id := range h.scopes("/apis/") {
+		apis = append(apis, map[string]any{
+			"id":         h.serviceID() + "/apis/" + id,
+			"name":       id,
+			"type":       "Microsoft.ApiManagement/service/apis",
+			"properties": map[string]any{"displayName": id, "path": id},
+		})
+	}
+	writeJSON(h.w, http.StatusOK, "", map[string]any{"value": emptyIfNil(apis)})
+}
+
+// scopes returns the sorted names of the products or APIs, selected by
+// marker, that the subscriptions of the instance are scoped to.
+func (h *handler) scopes(marker string) []string {
+	subs, err := h.store.ListSubscriptionsWithoutKeys(h.r.Context(), "")
+	if err != nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var names []string
+	for _, sub := range subs {
+		i := strings.LastIndex(sub.Properties.Scope, marker)
+		if i == -1 {
+			continue
+		}
+		name := strings.Trim(sub.Properties.Scope[i+len(marker):], "/")
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// serviceID returns the resource ID of the instance of the request.
+func (h *handler) serviceID() string {
+	m := servicePath.FindStringSubmatch(h.r.URL.Path)
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.ApiManagement/service/%s", m[1], m[2], m[3])
+}
+
+// fail writes the Azure error response for an error of the store.
+func (h *handler) fail(err error) {
+	switch {
+	case errors.Is(err, azure.ErrNotFound):
+		writeError(h.w, http.StatusNotFound, "ResourceNotFound", err.Error())
+	case errors.Is(err, azure.ErrConflict):
+		writeError(h.w, http.StatusPreconditionFailed, "PreconditionFailed", err.Error())
+	default:
+		writeError(h.w, http.StatusInternalServerError, "InternalError", err.Error())
+	}
+}
+
+func emptyIfNil(v []map[string]any) []map[string]any {
+	if v == nil {
+		return []map[string]any{}
+	}
+	return v
+}
+
+// writeJSON writes v as the JSON body of a response with the given status and
+// entity tag.
+func writeJSON(w http.ResponseWriter, status int, etag string, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error response in the format of Azure Resource
+// Manager.
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, "", map[string]any{
+		"error": map[string]string{"code": code, "message": message},
+	})
+}
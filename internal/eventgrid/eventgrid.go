@@ -0,0 +1,150 @@
+// Package eventgrid receives Azure Event Grid events delivered to a webhook.
+package eventgrid
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxBodySize limits the size of a delivery. Event Grid sends batches of at
+// most 1 MB.
+const maxBodySize = 1 << 20
+
+// validationEventType is the type of the event Event Grid sends to prove that
+// the endpoint of a new event subscription is willing to receive events.
+const validationEventType = "Microsoft.EventGrid.SubscriptionValidationEvent"
+
+// Event is an event delivered in the Event Grid or the CloudEvents 1.0 schema.
+// Only the fields used by kura are decoded.
+type Event struct {
+	ID      string          `json:"id"`
+	Type    string          `json:"-"`
+	Subject string          `json:"subject"`
+	Time    time.Time       `json:"-"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// UnmarshalJSON decodes both schemas: Event Grid uses eventType and
+// eventTime, CloudEvents uses type and time.
+func (e *Event) UnmarshalJSON(b []byte) error {
+	type event Event
+	var raw struct {
+		event
+		EventType string    `json:"eventType"`
+		EventTime time.Time `json:"eventTime"`
+		CEType    string    `json:"type"`
+		CETime    time.Time `json:"time"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	*e = Event(raw.event)
+	e.Type, e.Time = raw.EventType, raw.EventTime
+	if e.Type == "" {
+		e.Type, e.Time = raw.CEType, raw.CETime
+	}
+	return nil
+}
+
+// Handler is an http.Handler that answers the Event Grid and CloudEvents
+// validation handshakes and passes all other events to OnEvents.
+type Handler struct {
+	// Token, if set, must be passed as the token query parameter of the
+	// endpoint URL registered with Event Grid. Other requests are rejected.
+	Token string
+	// OnEvents is called with the events of each delivery. It must not block.
+	OnEvents func([]Event)
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.Token != "" && subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("token")), []byte(h.Token)) != 1 {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodOptions:
+		// CloudEvents abuse protection handshake.
+		if origin := r.Header.Get("WebHook-Request-Origin"); origin != "" {
+			w.Header().Set("WebHook-Allowed-Origin", origin)
+			w.Header().Set("WebHook-Allowed-Rate", "*")
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	case http.MethodPost:
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	events, err := decode(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for _, e := range events {
+		if e.Type != validationEventType {
+			continue
+		}
+		var data struct {
+			ValidationCode string `json:"validationCode"`
+		}
+		if err := json.Unmarshal(e.Data, &data); err != nil {
+			http.Error(w, "invalid validation event", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"validationResponse": data.ValidationCode})
+		return
+	}
+
+	if len(events) > 0 && h.OnEvents != nil {
+		h.OnEvents(events)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// decode reads a delivery, which is an array of events in the Event Grid
+// schema or in batched CloudEvents, or a single CloudEvent.
+func decode(r io.Reader) ([]Event, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read events: %w", err)
+	}
+	var events []Event
+	if strings.HasPrefix(strings.TrimSpace(string(body)), "{") {
+		var e Event
+		if err := json.Unmarshal(body, &e); err != nil {
+			return nil, fmt.Errorf("failed to parse event: %w", err)
+		}
+		events = []Event{e}
+	} else if err := json.Unmarshal(body, &events); err != nil {
+		return nil, fmt.Errorf("failed to parse events: %w", err)
+	}
+	return events, nil
+}
+
+// ConcernsSubscriptions reports whether e signals a change to a subscription
+// of the APIM instance apimName in resourceGroup. It recognizes the events
+// of an API Management system topic and the resource write and delete events
+// of an Azure subscription or resource group system topic.
+func ConcernsSubscriptions(e Event, resourceGroup, apimName string) bool {
+	switch {
+	case strings.HasPrefix(e.Type, "Microsoft.ApiManagement.Subscription"):
+	case e.Type == "Microsoft.Resources.ResourceWriteSuccess", e.Type == "Microsoft.Resources.ResourceDeleteSuccess":
+	default:
+		return false
+	}
+	// Subjects are resource IDs, such as
+	// /subscriptions/<id>/resourceGroups/<rg>/providers/Microsoft.ApiManagement/service/<apim>/subscriptions/<sid>.
+	subject := strings.ToLower(e.Subject)
+	want := strings.ToLower("/resourceGroups/" + resourceGroup + "/providers/Microsoft.ApiManagement/service/" + apimName + "/subscriptions/")
+	return strings.Contains(subject, want)
+}
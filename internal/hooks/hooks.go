@@ -0,0 +1,45 @@
+// Package hooks runs the commands users configure to run before and after a
+// kura operation, for example to pause traffic or trigger a downstream sync.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+)
+
+// Run runs command with the system shell (sh, or cmd on Windows). The
+// variables in env are added to the environment of kura, and input is passed
+// on stdin. The output of the command is passed through to the one of kura. Run
+// returns an error if the command cannot be started or exits with a non-zero
+// status.
+func Run(ctx context.Context, command string, env map[string]string, input []byte) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	cmd.Env = os.Environ()
+	for _, name := range names {
+		cmd.Env = append(cmd.Env, name+"="+env[name])
+	}
+
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("hook %q failed: %w", command, err)
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+// Package kube is a minimal client for the ApimSubscriptionBackup custom
+// resource, speaking the Kubernetes REST API directly.
+package kube
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Group, Version and Resource identify the ApimSubscriptionBackup API.
+const (
+	Group    = "kura.f-marschall.github.io"
+	Version  = "v1alpha1"
+	Resource = "apimsubscriptionbackups"
+)
+
+// serviceAccountDir holds the credentials Kubernetes mounts into every pod.
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// ApimSubscriptionBackup asks kura to back up the subscriptions of an APIM
+// instance on a schedule.
+type ApimSubscriptionBackup struct {
+	APIVersion string       `json:"apiVersion"`
+	Kind       string       `json:"kind"`
+	Metadata   ObjectMeta   `json:"metadata"`
+	Spec       BackupSpec   `json:"spec"`
+	Status     BackupStatus `json:"status,omitempty"`
+}
+
+// ObjectMeta holds the metadata fields used by kura.
+type ObjectMeta struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace"`
+	Generation      int64  `json:"generation,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+// BackupSpec is the desired backup of an ApimSubscriptionBackup.
+type BackupSpec struct {
+	ResourceGroup  string `json:"resourceGroup"`
+	APIMName       string `json:"apimName"`
+	SubscriptionID string `json:"subscriptionId,omitempty"`
+	ProductID      string `json:"productId,omitempty"`
+	// Schedule is the time between two backups, such as 6h or 1d.
+	Schedule    string            `json:"schedule"`
+	Destination BackupDestination `json:"destination"`
+	Encryption  *BackupEncryption `json:"encryption,omitempty"`
+}
+
+// BackupDestination is where backup files are written.
+type BackupDestination struct {
+	// Path is a directory, usually on a mounted persistent volume.
+	Path         string `json:"path"`
+	NameTemplate string `json:"nameTemplate,omitempty"`
+}
+
+// BackupEncryption selects how backup files are encrypted.
+type BackupEncryption struct {
+	AgeRecipients []string `json:"ageRecipients,omitempty"`
+}
+
+// BackupStatus is the observed state of an ApimSubscriptionBackup.
+type BackupStatus struct {
+	ObservedGeneration int64       `json:"observedGeneration,omitempty"`
+	LastBackupTime     *time.Time  `json:"lastBackupTime,omitempty"`
+	LastBackupFile     string      `json:"lastBackupFile,omitempty"`
+	Subscriptions      int         `json:"subscriptions,omitempty"`
+	Conditions         []Condition `json:"conditions,omitempty"`
+}
+
+// Condition is a standard Kubernetes status condition.
+type Condition struct {
+	Type               string    `json:"type"`
+	Status             string    `json:"status"`
+	ObservedGeneration int64     `json:"observedGeneration,omitempty"`
+	LastTransitionTime time.Time `json:"lastTransitionTime"`
+	Reason             string    `json:"reason"`
+	Message            string    `json:"message"`
+}
+
+// SetCondition adds c to the status or replaces the condition of the same
+// type. The transition time is kept if the status did not change.
+func (s *BackupStatus) SetCondition(c Condition) {
+	for i, existing := range s.Conditions {
+		if existing.Type != c.Type {
+			continue
+		}
+		if existing.Status == c.Status {
+			c.LastTransitionTime = existing.LastTransitionTime
+		}
+		s.Conditions[i] = c
+		return
+	}
+	s.Conditions = append(s.Conditions, c)
+}
+
+// Client talks to the Kubernetes API server.
+type Client struct {
+	server string
+	token  string
+	http   *http.Client
+}
+
+// NewClient returns a client for the API server. Inside a pod, it uses the
+// mounted service account. Outside, server must be the URL of a kubectl proxy,
+// which handles authentication.
+func NewClient(server string) (*Client, error) {
+	if server != "" {
+		return &Client{server: strings.TrimSuffix(server, "/"), http: &http.Client{Timeout: 30 * time.Second}}, nil
+	}
+
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in a Kubernetes pod; pass the URL of a kubectl proxy with --kube-api-server")
+	}
+	token, err := os.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %w", err)
+	}
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("invalid service account CA")
+	}
+	return &Client{
+		server: "https://" + net.JoinHostPort(host, port),
+		token:  strings.TrimSpace(string(token)),
+		http: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		},
+	}, nil
+}
+
+// resourcePath returns the API path of the backups in namespace, or of all
+// namespaces if namespace is empty.
+func resourcePath(namespace string) string {
+	if namespace == "" {
+		return "/apis/" + Group + "/" + Version + "/" + Resource
+	}
+	return "/apis/" + Group + "/" + Version + "/namespaces/" + namespace + "/" + Resource
+}
+
+// List returns the ApimSubscriptionBackups in namespace, or in all namespaces
+// if namespace is empty.
+func (c *Client) List(ctx context.Context, namespace string) ([]ApimSubscriptionBackup, error) {
+	var list struct {
+		Items []ApimSubscriptionBackup `json:"items"`
+	}
+	if err := c.do(ctx, http.MethodGet, resourcePath(namespace), "", nil, &list); err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", Resource, err)
+	}
+	return list.Items, nil
+}
+
+// UpdateStatus writes the status of b through the status subresource.
+func (c *Client) UpdateStatus(ctx context.Context, b *ApimSubscriptionBackup) error {
+	path := resourcePath(b.Metadata.Namespace) + "/" + b.Metadata.Name + "/status"
+	patch := map[string]any{"status": b.Status}
+	if err := c.do(ctx, http.MethodPatch, path, "application/merge-patch+json", patch, nil); err != nil {
+		return fmt.Errorf("failed to update status of %s/%s: %w", b.Metadata.Namespace, b.Metadata.Name, err)
+	}
+	return nil
+}
+
+func (c *Client) do(ctx context.Context, method, path, contentType string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.server+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var status struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &status) == nil && status.Message != "" {
+			return fmt.Errorf("%s: %s", resp.Status, status.Message)
+		}
+		return fmt.Errorf("%s", resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
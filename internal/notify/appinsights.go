@@ -0,0 +1,149 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Environment variables holding the Application Insights connection string.
+// AppInsightsEnv takes precedence over the variable the Azure SDKs use.
+const (
+	AppInsightsEnv         = "KURA_APPINSIGHTS_CONNECTION_STRING"
+	AppInsightsStandardEnv = "APPLICATIONINSIGHTS_CONNECTION_STRING"
+)
+
+// defaultIngestionEndpoint is used for connection strings without an
+// IngestionEndpoint.
+const defaultIngestionEndpoint = "https://dc.services.visualstudio.com"
+
+// AppInsights sends telemetry to an Application Insights resource.
+type AppInsights struct {
+	instrumentationKey string
+	endpoint           string
+}
+
+// Telemetry is a custom event. Each measurement is also sent as a custom
+// metric named "<Name>.<measurement>" with the same properties, so alerts can
+// be built on either.
+type Telemetry struct {
+	Name         string
+	Time         time.Time
+	Properties   map[string]string
+	Measurements map[string]float64
+}
+
+// AppInsightsFromEnv returns the Application Insights resource configured by
+// the environment, or nil if none is.
+func AppInsightsFromEnv() (*AppInsights, error) {
+	s := os.Getenv(AppInsightsEnv)
+	if s == "" {
+		s = os.Getenv(AppInsightsStandardEnv)
+	}
+	if s == "" {
+		return nil, nil
+	}
+	return ParseConnectionString(s)
+}
+
+// ParseConnectionString parses an Application Insights connection string,
+// such as "InstrumentationKey=...;IngestionEndpoint=https://...".
+func ParseConnectionString(s string) (*AppInsights, error) {
+	a := &AppInsights{endpoint: defaultIngestionEndpoint}
+	for _, part := range strings.Split(s, ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch strings.ToLower(key) {
+		case "instrumentationkey":
+			a.instrumentationKey = value
+		case "ingestionendpoint":
+			a.endpoint = strings.TrimSuffix(value, "/")
+		}
+	}
+	if a.instrumentationKey == "" {
+		return nil, fmt.Errorf("invalid Application Insights connection string: no InstrumentationKey")
+	}
+	return a, nil
+}
+
+// envelope is an item of the Application Insights ingestion API.
+type envelope struct {
+	Name string            `json:"name"`
+	Time string            `json:"time"`
+	IKey string            `json:"iKey"`
+	Tags map[string]string `json:"tags"`
+	Data envelopeData      `json:"data"`
+}
+
+type envelopeData struct {
+	BaseType string `json:"baseType"`
+	BaseData any    `json:"baseData"`
+}
+
+// Track sends t as a custom event and its measurements as custom metrics.
+func (a *AppInsights) Track(ctx context.Context, t Telemetry) error {
+	if t.Time.IsZero() {
+		t.Time = time.Now()
+	}
+	ts := t.Time.UTC().Format(time.RFC3339Nano)
+	tags := map[string]string{"ai.cloud.role": "kura"}
+	ikey := strings.ReplaceAll(a.instrumentationKey, "-", "")
+
+	items := []envelope{{
+		Name: "Microsoft.ApplicationInsights." + ikey + ".Event",
+		Time: ts,
+		IKey: a.instrumentationKey,
+		Tags: tags,
+		Data: envelopeData{BaseType: "EventData", BaseData: map[string]any{
+			"ver":          2,
+			"name":         t.Name,
+			"properties":   t.Properties,
+			"measurements": t.Measurements,
+		}},
+	}}
+	for name, value := range t.Measurements {
+		items = append(items, envelope{
+			Name: "Microsoft.ApplicationInsights." + ikey + ".Metric",
+			Time: ts,
+			IKey: a.instrumentationKey,
+			Tags: tags,
+			Data: envelopeData{BaseType: "MetricData", BaseData: map[string]any{
+				"ver":        2,
+				"metrics":    []map[string]any{{"name": t.Name + "." + name, "value": value, "count": 1}},
+				"properties": t.Properties,
+			}},
+		})
+	}
+
+	body, err := json.Marshal(items)
+	if err != nil {
+		return fmt.Errorf("failed to marshal telemetry: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.endpoint+"/v2/track", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid Application Insights ingestion endpoint %q: %w", a.endpoint, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "kura")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send telemetry: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to send telemetry: Application Insights returned %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
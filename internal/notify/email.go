@@ -0,0 +1,115 @@
+package notify
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// Environment variables that configure the SMTP server used by SendMail.
+const (
+	SMTPHostEnv     = "KURA_SMTP_HOST"
+	SMTPPortEnv     = "KURA_SMTP_PORT"
+	SMTPUsernameEnv = "KURA_SMTP_USERNAME"
+	SMTPPasswordEnv = "KURA_SMTP_PASSWORD"
+	SMTPFromEnv     = "KURA_SMTP_FROM"
+)
+
+// SMTPConfig is the SMTP server mail is sent through.
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// SMTPConfigFromEnv reads the SMTP configuration from the KURA_SMTP_*
+// environment variables. The port defaults to 587.
+func SMTPConfigFromEnv() (SMTPConfig, error) {
+	c := SMTPConfig{
+		Host:     os.Getenv(SMTPHostEnv),
+		Port:     os.Getenv(SMTPPortEnv),
+		Username: os.Getenv(SMTPUsernameEnv),
+		Password: os.Getenv(SMTPPasswordEnv),
+		From:     os.Getenv(SMTPFromEnv),
+	}
+	if c.Port == "" {
+		c.Port = "587"
+	}
+	if c.Host == "" || c.From == "" {
+		return c, fmt.Errorf("sending email requires %s and %s", SMTPHostEnv, SMTPFromEnv)
+	}
+	return c, nil
+}
+
+// SendMail sends a plain text message to the recipients. Port 465 uses
+// implicit TLS; other ports upgrade with STARTTLS when the server offers it.
+// The server must offer TLS if a username is set, so the password is never
+// sent in clear text.
+func SendMail(c SMTPConfig, to []string, subject, body string) error {
+	addr := net.JoinHostPort(c.Host, c.Port)
+	tlsConfig := &tls.Config{ServerName: c.Host}
+
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: timeout}
+	if c.Port == "465" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server %s: %w", addr, err)
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	client, err := smtp.NewClient(conn, c.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to connect to SMTP server %s: %w", addr, err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && c.Port != "465" {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("failed to start TLS with %s: %w", addr, err)
+		}
+	}
+	if c.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", c.Username, c.Password, c.Host)); err != nil {
+			return fmt.Errorf("SMTP authentication with %s failed: %w", addr, err)
+		}
+	}
+
+	if err := client.Mail(c.From); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	for _, rcpt := range to {
+		if err := client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("failed to send email to %s: %w", rcpt, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	msg := "From: " + c.From + "\r\n" +
+		"To: " + strings.Join(to, ", ") + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"Date: " + time.Now().Format(time.RFC1123Z) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" + strings.ReplaceAll(body, "\n", "\r\n")
+	if _, err := w.Write([]byte(msg)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return client.Quit()
+}
@@ -0,0 +1,58 @@
+package pool
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrTooManyFailures is matched, with errors.Is, by the error a Breaker
+// returns once its limit is reached.
+var ErrTooManyFailures = errors.New("too many failures")
+
+// Breaker stops a bulk operation that keeps failing, for example because the
+// caller lacks a role assignment, before it sends a doomed request for every
+// remaining item. The function of a Run records the outcome of each item and
+// returns the error of Record wrapped in Stop:
+//
+//	if err := breaker.Record(err != nil); err != nil {
+//		return value, pool.Stop(err)
+//	}
+//
+// A nil *Breaker never trips. Its methods are safe for concurrent use.
+type Breaker struct {
+	// MaxFailures trips the breaker once this many items failed in total.
+	// Zero means no limit.
+	MaxFailures int
+	// MaxConsecutive trips the breaker once this many items failed in a row,
+	// in the order they finished. Zero means no limit.
+	MaxConsecutive int
+
+	mu          sync.Mutex
+	failures    int
+	consecutive int
+}
+
+// Record records whether an item failed. It returns an error matching
+// ErrTooManyFailures if that makes the failures reach a limit, and nil
+// otherwise.
+func (b *Breaker) Record(failed bool) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !failed {
+		b.consecutive = 0
+		return nil
+	}
+	b.failures++
+	b.consecutive++
+	switch {
+	case b.MaxFailures > 0 && b.failures >= b.MaxFailures:
+		return fmt.Errorf("%w: stopped after %d failed items", ErrTooManyFailures, b.failures)
+	case b.MaxConsecutive > 0 && b.consecutive >= b.MaxConsecutive:
+		return fmt.Errorf("%w: stopped after %d items failed in a row", ErrTooManyFailures, b.consecutive)
+	}
+	return nil
+}
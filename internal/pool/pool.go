@@ -0,0 +1,147 @@
+// Package pool runs the items of bulk operations, such as restoring or
+// deleting many subscriptions, on a bounded number of goroutines.
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Result is the outcome of one item of a Run.
+type Result[T, R any] struct {
+	// Index is the position of Item in the items passed to Run.
+	Index int
+	Item  T
+	Value R
+	Err   error
+}
+
+// stopError marks the error of an item that stops the whole run.
+type stopError struct {
+	err error
+}
+
+func (e *stopError) Error() string { return e.err.Error() }
+func (e *stopError) Unwrap() error { return e.err }
+
+// Stop wraps err so that returning it from the function of a Run stops the
+// run: items that have not started are not run, and Run returns err. A nil
+// err is returned as is.
+func Stop(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &stopError{err: err}
+}
+
+// Errors is the error of a Run in which items failed. It holds the results
+// of the failed items in the order of the items.
+type Errors[T, R any] []Result[T, R]
+
+func (e Errors[T, R]) Error() string {
+	if len(e) == 1 {
+		return e[0].Err.Error()
+	}
+	return fmt.Sprintf("%d items failed, the first with: %v", len(e), e[0].Err)
+}
+
+// Unwrap returns the errors of the failed items.
+func (e Errors[T, R]) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, r := range e {
+		errs[i] = r.Err
+	}
+	return errs
+}
+
+// Run calls fn for every item on at most size goroutines; a size below one
+// means one. It calls each, if not nil, with the result of every item in
+// the order of items, as soon as the results of all items before it are
+// available, so progress can be printed in order while later items are
+// still running. each runs on the goroutine of the caller, one result at a
+// time.
+//
+// Run returns the error passed to Stop if an item stopped the run, the
+// error of ctx if it was cancelled, an Errors with the failed items if
+// there were any, and nil otherwise.
+func Run[T, R any](ctx context.Context, size int, items []T, fn func(ctx context.Context, item T) (R, error), each func(Result[T, R])) error {
+	if size < 1 {
+		size = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var stopped error
+	var stopOnce sync.Once
+
+	work := make(chan int)
+	done := make(chan Result[T, R])
+	var wg sync.WaitGroup
+	for w := 0; w < size && w < len(items); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				value, err := fn(ctx, items[i])
+				var stop *stopError
+				if errors.As(err, &stop) {
+					stopOnce.Do(func() {
+						stopped = stop.err
+						cancel()
+					})
+					err = stop.err
+				}
+				done <- Result[T, R]{Index: i, Item: items[i], Value: value, Err: err}
+			}
+		}()
+	}
+	go func() {
+		defer close(work)
+		for i := range items {
+			select {
+			case work <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	// Results that arrived before the ones of earlier items wait here.
+	pending := make(map[int]Result[T, R])
+	next := 0
+	var failed Errors[T, R]
+	for r := range done {
+		pending[r.Index] = r
+		for {
+			r, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			if r.Err != nil {
+				failed = append(failed, r)
+			}
+			if each != nil {
+				each(r)
+			}
+		}
+	}
+
+	switch {
+	case stopped != nil:
+		return stopped
+	case next < len(items):
+		// Only a cancelled ctx leaves items without a result.
+		return ctx.Err()
+	case len(failed) > 0:
+		return failed
+	}
+	return nil
+}
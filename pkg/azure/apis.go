@@ -0,0 +1,115 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/apimanagement/armapimanagement"
+)
+
+// Formats of ExportAPI.
+const (
+	APIFormatOpenAPI     = "openapi"      // OpenAPI 3 as YAML
+	APIFormatOpenAPIJSON = "openapi+json" // OpenAPI 3 as JSON
+	APIFormatSwagger     = "swagger"      // OpenAPI 2 as JSON
+	APIFormatWSDL        = "wsdl"         // only for SOAP APIs
+	APIFormatWADL        = "wadl"
+)
+
+// APIDetails holds the settings of an API of an APIM instance besides its
+// operations, which are part of its definition (see ExportAPI).
+type APIDetails struct {
+	// ID is the API name used in scopes, not the full resource ID.
+	ID                   string   `json:"id"`
+	DisplayName          string   `json:"displayName"`
+	Description          string   `json:"description,omitempty"`
+	Path                 string   `json:"path"`
+	ServiceURL           string   `json:"serviceUrl,omitempty"`
+	Protocols            []string `json:"protocols,omitempty"`
+	Type                 string   `json:"type,omitempty"`
+	APIVersion           string   `json:"apiVersion,omitempty"`
+	APIVersionSetID      string   `json:"apiVersionSetId,omitempty"`
+	APIRevision          string   `json:"apiRevision,omitempty"`
+	IsCurrent            bool     `json:"isCurrent"`
+	SubscriptionRequired bool     `json:"subscriptionRequired"`
+}
+
+// ListAPIDetails returns the current revision of every API of the APIM
+// instance with its settings.
+func (c *Client) ListAPIDetails(ctx context.Context) ([]APIDetails, error) {
+	pager := c.clientFactory.NewAPIClient().NewListByServicePager(c.resourceGroup, c.apimName, nil)
+
+	var results []APIDetails
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, wrapError(err, "failed to list APIs")
+		}
+		for _, api := range page.Value {
+			if api == nil {
+				continue
+			}
+			info := APIDetails{ID: deref(api.Name)}
+			if props := api.Properties; props != nil {
+				info.DisplayName = deref(props.DisplayName)
+				info.Description = deref(props.Description)
+				info.Path = deref(props.Path)
+				info.ServiceURL = deref(props.ServiceURL)
+				info.APIVersion = deref(props.APIVersion)
+				info.APIVersionSetID = deref(props.APIVersionSetID)
+				info.APIRevision = deref(props.APIRevision)
+				for _, p := range props.Protocols {
+					if p != nil {
+						info.Protocols = append(info.Protocols, string(*p))
+					}
+				}
+				if props.APIType != nil {
+					info.Type = string(*props.APIType)
+				}
+				if props.IsCurrent != nil {
+					info.IsCurrent = *props.IsCurrent
+				}
+				if props.SubscriptionRequired != nil {
+					info.SubscriptionRequired = *props.SubscriptionRequired
+				}
+			}
+			results = append(results, info)
+		}
+	}
+	return results, nil
+}
+
+// ExportAPI returns the definition of the API with the given ID in format,
+// one of the APIFormat constants. APIM writes the definition to a blob that
+// can be downloaded for five minutes, which ExportAPI does right away.
+func (c *Client) ExportAPI(ctx context.Context, apiID, format string) ([]byte, error) {
+	resp, err := c.clientFactory.NewAPIExportClient().Get(ctx, c.resourceGroup, c.apimName, apiID,
+		armapimanagement.ExportFormat(format+"-link"), armapimanagement.ExportAPITrue, nil)
+	if err != nil {
+		return nil, wrapError(err, "failed to export API %s", apiID)
+	}
+	if resp.Value == nil || resp.Value.Link == nil {
+		return nil, fmt.Errorf("failed to export API %s: no download link returned", apiID)
+	}
+
+	// The link carries a SAS token, so the download is not authenticated.
+	opts := c.clientOptions().ClientOptions
+	pipeline := runtime.NewPipeline("kura", "v1", runtime.PipelineOptions{}, &opts)
+	req, err := runtime.NewRequest(ctx, http.MethodGet, *resp.Value.Link)
+	if err != nil {
+		return nil, err
+	}
+	runtime.SkipBodyDownload(req)
+	download, err := pipeline.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download the definition of API %s: %w", apiID, err)
+	}
+	defer download.Body.Close()
+	if !runtime.HasStatusCode(download, http.StatusOK) {
+		return nil, fmt.Errorf("failed to download the definition of API %s: %w", apiID, newError(runtime.NewResponseError(download)))
+	}
+	return io.ReadAll(download.Body)
+}
@@ -0,0 +1,127 @@
+package azure
+
+import (
+	"context"
+	"sync"
+)
+
+// Operations of a Mutation.
+const (
+	OperationCreate          = "create"
+	OperationUpdate          = "update"
+	OperationSetState        = "set-state"
+	OperationSetExpiration   = "set-expiration"
+	OperationClearExpiration = "clear-expiration"
+	OperationDelete          = "delete"
+	OperationRegenerateKeys  = "regenerate-keys"
+)
+
+// Mutation describes a change made to a subscription, or to another entity of
+// an APIM instance, through a Client.
+type Mutation struct {
+	Operation      string
+	SubscriptionID string
+	ResourceGroup  string
+	APIMName       string
+	SID            string
+	// Before and After are the subscription, including its keys, before and
+	// after the change. They are nil if the subscription did not exist or
+	// could not be read.
+	Before *SubscriptionInfo
+	After  *SubscriptionInfo
+	// Err is the error of the change, if it failed.
+	Err error
+
+	// Kind is the kind of entity changed, such as "named-values", or empty
+	// for a subscription. Changes to other entities have a Target instead
+	// of a SID and BeforeEntity and AfterEntity instead of Before and After.
+	Kind   string
+	Target string
+	// BeforeEntity and AfterEntity are the entity before and after the
+	// change. They are nil if it did not exist or is not known.
+	BeforeEntity any
+	AfterEntity  any
+
+	// Actor is the identity the change was made with (see Identity), or
+	// empty if it is not known.
+	Actor string
+}
+
+var (
+	recorderMu sync.RWMutex
+	recorder   func(Mutation)
+)
+
+// SetRecorder installs fn to be called after every create, update or delete
+// made through any Client, such as to keep an audit journal. While a recorder
+// is installed, each change costs extra requests to read the subscription
+// before and after it. A nil fn removes the recorder.
+func SetRecorder(fn func(Mutation)) {
+	recorderMu.Lock()
+	defer recorderMu.Unlock()
+	recorder = fn
+}
+
+func currentRecorder() func(Mutation) {
+	recorderMu.RLock()
+	defer recorderMu.RUnlock()
+	return recorder
+}
+
+// record runs change, the operation op on subscription sid, emits its events
+// and passes it to the recorder, if any. For OperationCreate, the operation is
+// recorded as OperationUpdate if the subscription already existed.
+func (c *Client) record(ctx context.Context, op, sid string, change func() error) error {
+	c.emit(Event{Kind: EventItemStarted, Operation: op, SID: sid})
+	rec := currentRecorder()
+	if rec == nil {
+		err := change()
+		c.emitItem(op, sid, err)
+		return err
+	}
+
+	m := Mutation{
+		Operation:      op,
+		SubscriptionID: c.subscriptionID,
+		ResourceGroup:  c.resourceGroup,
+		APIMName:       c.apimName,
+		SID:            sid,
+		Actor:          c.Identity(ctx),
+	}
+	m.Before, _ = c.GetSubscription(ctx, sid)
+	if op == OperationCreate && m.Before != nil {
+		m.Operation = OperationUpdate
+	}
+	m.Err = change()
+	c.emitItem(op, sid, m.Err)
+	if op != OperationDelete || m.Err != nil {
+		m.After, _ = c.GetSubscription(ctx, sid)
+	}
+	rec(m)
+	return m.Err
+}
+
+// RecordChange runs change, the operation m.Operation on the entity m.Target
+// of kind m.Kind, and passes m to the recorder, if any, with the instance,
+// the actor, the error of change and the entity change returns as
+// AfterEntity filled in. m.BeforeEntity is left to the caller.
+func (c *Client) RecordChange(ctx context.Context, m Mutation, change func() (after any, err error)) error {
+	rec := currentRecorder()
+	if rec == nil {
+		_, err := change()
+		return err
+	}
+	m.SubscriptionID = c.subscriptionID
+	m.ResourceGroup = c.resourceGroup
+	m.APIMName = c.apimName
+	m.Actor = c.Identity(ctx)
+	m.AfterEntity, m.Err = change()
+	rec(m)
+	return m.Err
+}
+
+// Recording reports whether a recorder is installed, so callers of
+// RecordChange can skip reading the entities before a change otherwise.
+func Recording() bool {
+	return currentRecorder() != nil
+}
@@ -0,0 +1,330 @@
+package azure
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// cassette is the document stored in a cassette file.
+type cassette struct {
+	Interactions []interaction `json:"interactions"`
+}
+
+// interaction is a recorded request and the response to it.
+type interaction struct {
+	Request  recordedRequest  `json:"request"`
+	Response recordedResponse `json:"response"`
+}
+
+type recordedRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+type recordedResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// keyPattern matches the subscription keys, the gateway keys and the client
+// secrets of identity providers in JSON bodies. The values of secret named
+// values and Key Vault secrets are redacted by redactSecretValues.
+var keyPattern = regexp.MustCompile(`("(?:primaryKey|secondaryKey|primary|secondary|clientSecret)"\s*:\s*)"([^"]*)"`)
+
+// redactKeys replaces the keys matched by keyPattern in body by placeholders. Equal
+// keys get equal placeholders, so that replayed runs still see which keys
+// match.
+func redactKeys(body string) string {
+	return keyPattern.ReplaceAllStringFunc(body, func(m string) string {
+		parts := keyPattern.FindStringSubmatch(m)
+		if parts[2] == "" {
+			return m
+		}
+		return parts[1] + `"` + redacted(parts[2]) + `"`
+	})
+}
+
+// redacted returns the placeholder of a redacted secret.
+func redacted(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return "redacted-" + hex.EncodeToString(sum[:8])
+}
+
+// redactBody replaces the keys and secret values in the body of a request to
+// or a response from the URL with the given path by placeholders.
+func redactBody(path, body string) string {
+	return redactSecretValues(path, redactKeys(body))
+}
+
+// redactSecretValues replaces the "value" of the objects marked "secret":
+// true in the JSON body, such as secret named values listed or put, by
+// placeholders. The responses of listValue and of Key Vault secrets are not
+// marked, so their top-level "value" is replaced as well. Bodies that are not
+// JSON are returned as is.
+func redactSecretValues(path, body string) string {
+	if !strings.Contains(body, `"value"`) {
+		return body
+	}
+	var doc any
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return body
+	}
+	if !redactValues(doc, secretValuePath(path)) {
+		return body
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return body
+	}
+	return string(data)
+}
+
+// secretValuePath reports whether the body of the URL with the given path is
+// a secret value: the response of listValue or a Key Vault secret.
+func secretValuePath(path string) bool {
+	path = strings.ToLower(path)
+	return strings.HasSuffix(path, "/listvalue") || strings.HasPrefix(path, "/secrets/")
+}
+
+// redactValues replaces the "value" of v and of the objects in v marked
+// "secret": true, and of v itself if secret is set. It reports whether it
+// replaced any.
+func redactValues(v any, secret bool) bool {
+	changed := false
+	switch v := v.(type) {
+	case map[string]any:
+		if marked, ok := v["secret"].(bool); ok && marked {
+			secret = true
+		}
+		if value, ok := v["value"].(string); ok && secret && value != "" {
+			v["value"] = redacted(value)
+			changed = true
+		}
+		for _, child := range v {
+			if redactValues(child, false) {
+				changed = true
+			}
+		}
+	case []any:
+		for _, child := range v {
+			if redactValues(child, false) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// CassetteRecorder is a transport that sends requests with another transport
+// and records them. Pass it to SetDefaultTransport and call Save once the
+// requests are done.
+type CassetteRecorder struct {
+	path string
+	next policy.Transporter
+
+	mu           sync.Mutex
+	interactions []interaction
+}
+
+// NewCassetteRecorder returns a recorder that sends requests with next, or
+// with http.DefaultClient if next is nil, and saves them to the cassette file
+// at path.
+func NewCassetteRecorder(path string, next policy.Transporter) *CassetteRecorder {
+	if next == nil {
+		next = http.DefaultClient
+	}
+	return &CassetteRecorder{path: path, next: next}
+}
+
+// Do sends req and records it with its response.
+func (r *CassetteRecorder) Do(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		if reqBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	resp, err := r.next.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	header := resp.Header.Clone()
+	header.Del("Set-Cookie")
+	// Redacting changes the length of the body.
+	header.Del("Content-Length")
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interactions = append(r.interactions, interaction{
+		Request: recordedRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Body:   redactBody(req.URL.Path, string(reqBody)),
+		},
+		Response: recordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     header,
+			Body:       redactBody(req.URL.Path, string(respBody)),
+		},
+	})
+	return resp, nil
+}
+
+// Save writes the interactions recorded so far to the cassette file.
+func (r *CassetteRecorder) Save() error {
+	r.mu.Lock()
+	c := cassette{Interactions: r.interactions}
+	r.mu.Unlock()
+	if c.Interactions == nil {
+		c.Interactions = []interaction{}
+	}
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette: %w", err)
+	}
+	// Cassettes hold request and response bodies, so like backup files only
+	// their owner may read them. Writing a temporary file created with 0600
+	// and renaming it also restricts an existing cassette.
+	f, err := os.CreateTemp(filepath.Dir(r.path), "."+filepath.Base(r.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	_, err = f.Write(append(data, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), r.path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	return nil
+}
+
+// CassettePlayer is a transport that answers requests with the responses of a
+// cassette file instead of sending them. A request is answered by the first
+// unused interaction with the same method and URL, so requests may arrive in
+// a different order than recorded, for example with concurrency. A request
+// without one gets a 501 response.
+//
+// Clients created by NewClient while a CassettePlayer is the default
+// transport do not use the Azure CLI.
+type CassettePlayer struct {
+	path           string
+	subscriptionID string
+
+	mu           sync.Mutex
+	interactions []interaction
+	used         []bool
+}
+
+// LoadCassette reads the cassette file at path for replaying.
+func LoadCassette(path string) (*CassettePlayer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	var c cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+	p := &CassettePlayer{path: path, interactions: c.Interactions, used: make([]bool, len(c.Interactions))}
+	for _, i := range c.Interactions {
+		if id := urlSubscriptionID(i.Request.URL); id != "" {
+			p.subscriptionID = id
+			break
+		}
+	}
+	return p, nil
+}
+
+// urlSubscriptionID returns the Azure subscription ID in a Resource Manager
+// URL, or "".
+func urlSubscriptionID(url string) string {
+	_, rest, ok := strings.Cut(url, "/subscriptions/")
+	if !ok {
+		return ""
+	}
+	id, _, _ := strings.Cut(rest, "/")
+	return id
+}
+
+// SubscriptionID returns the Azure subscription ID of the recorded requests,
+// which NewClient uses if none is given.
+func (p *CassettePlayer) SubscriptionID() string {
+	return p.subscriptionID
+}
+
+// Do answers req with its recorded response.
+func (p *CassettePlayer) Do(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	url := req.URL.String()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for n, i := range p.interactions {
+		if p.used[n] || i.Request.Method != req.Method || i.Request.URL != url {
+			continue
+		}
+		p.used[n] = true
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", i.Response.StatusCode, http.StatusText(i.Response.StatusCode)),
+			StatusCode:    i.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        i.Response.Header.Clone(),
+			Body:          io.NopCloser(strings.NewReader(i.Response.Body)),
+			ContentLength: int64(len(i.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	// Not an error, which the SDK would retry, but a status it does not.
+	body, _ := json.Marshal(map[string]any{"error": map[string]string{
+		"code":    "NoRecordedInteraction",
+		"message": fmt.Sprintf("cassette %s has no unused interaction for %s %s", p.path, req.Method, url),
+	}})
+	return &http.Response{
+		Status:        "501 Not Implemented",
+		StatusCode:    http.StatusNotImplemented,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,135 @@
+package azure
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// transportFunc answers requests with a function.
+type transportFunc func(*http.Request) (*http.Response, error)
+
+func (f transportFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// jsonResponse returns a 200 response with the JSON body.
+func jsonResponse(req *http.Request, body string) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}
+}
+
+func TestCassetteRecorderRedactsSecretNamedValues(t *testing.T) {
+	const (
+		secret = "s3cr3t-backend-password"
+		plain  = "https://backend.example.com"
+		base   = "https://management.azure.com/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ApiManagement/service/apim/namedValues"
+	)
+	responses := map[string]string{
+		"GET " + base: `{"value":[` +
+			`{"name":"password","properties":{"displayName":"password","secret":true,"value":"` + secret + `"}},` +
+			`{"name":"url","properties":{"displayName":"url","secret":false,"value":"` + plain + `"}}]}`,
+		"POST " + base + "/password/listValue": `{"value":"` + secret + `"}`,
+		"PUT " + base + "/password":            `{"name":"password","properties":{"displayName":"password","secret":true}}`,
+	}
+	next := transportFunc(func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(req, responses[req.Method+" "+req.URL.String()]), nil
+	})
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	rec := NewCassetteRecorder(path, next)
+	requests := []struct{ method, url, body string }{
+		{http.MethodGet, base, ""},
+		{http.MethodPost, base + "/password/listValue", ""},
+		{http.MethodPut, base + "/password", `{"properties":{"displayName":"password","secret":true,"value":"` + secret + `"}}`},
+	}
+	for _, r := range requests {
+		req, err := http.NewRequest(r.method, r.url, strings.NewReader(r.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := rec.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", r.method, r.url, err)
+		}
+		// The caller still gets the real response.
+		body, _ := io.ReadAll(resp.Body)
+		if want := responses[r.method+" "+r.url]; string(body) != want {
+			t.Errorf("%s %s: got body %s, want %s", r.method, r.url, body, want)
+		}
+	}
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if info, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("cassette has mode %04o, want 0600", info.Mode().Perm())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), secret) {
+		t.Errorf("cassette contains the secret value:\n%s", data)
+	}
+	if n := strings.Count(string(data), redacted(secret)); n != 3 {
+		t.Errorf("cassette contains %d placeholders of the secret, want 3:\n%s", n, data)
+	}
+	if !strings.Contains(string(data), plain) {
+		t.Errorf("cassette lacks the value of the named value that is not secret:\n%s", data)
+	}
+}
+
+func TestCassetteRecorderRedactsClientSecrets(t *testing.T) {
+	const (
+		secret   = "oauth-client-secret"
+		vaultURL = "https://myvault.vault.azure.net/secrets/aad-client-secret?api-version=7.4"
+		idpURL   = "https://management.azure.com/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ApiManagement/service/apim/identityProviders/aad"
+	)
+	next := transportFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Host == "myvault.vault.azure.net" {
+			return jsonResponse(req, `{"value":"`+secret+`","id":"https://myvault.vault.azure.net/secrets/aad-client-secret/1"}`), nil
+		}
+		return jsonResponse(req, `{"name":"aad","properties":{"clientId":"app"}}`), nil
+	})
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	rec := NewCassetteRecorder(path, next)
+	requests := []struct{ method, url, body string }{
+		{http.MethodGet, vaultURL, ""},
+		{http.MethodPut, idpURL, `{"properties":{"clientId":"app","clientSecret":"` + secret + `"}}`},
+	}
+	for _, r := range requests {
+		req, err := http.NewRequest(r.method, r.url, strings.NewReader(r.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := rec.Do(req); err != nil {
+			t.Fatalf("%s %s: %v", r.method, r.url, err)
+		}
+	}
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), secret) {
+		t.Errorf("cassette contains the client secret:\n%s", data)
+	}
+	if n := strings.Count(string(data), redacted(secret)); n != 2 {
+		t.Errorf("cassette contains %d placeholders of the client secret, want 2:\n%s", n, data)
+	}
+}
@@ -0,0 +1,112 @@
+package azure
+
+import (
+	"context"
+	"time"
+)
+
+// Kinds of CertificateInfo.
+const (
+	// CertificateKindCertificate is a certificate entity, which policies use
+	// to authenticate to backends.
+	CertificateKindCertificate = "certificate"
+	// CertificateKindCA is a CA or root certificate installed on the
+	// instance to validate client and backend certificates.
+	CertificateKindCA = "ca"
+	// CertificateKindHostname is the TLS certificate of a host name of the
+	// instance, such as a custom domain of the gateway.
+	CertificateKindHostname = "hostname"
+)
+
+// CertificateInfo describes a certificate of an APIM instance without its
+// private key, so it can be inventoried and monitored for expiry.
+type CertificateInfo struct {
+	Kind string `json:"kind"`
+	// ID is the certificate ID for certificate entities, the store name
+	// (CertificateAuthority or Root) for CA certificates and the host name
+	// for host names.
+	ID string `json:"id"`
+	// HostnameType is the use of a host name, such as Proxy or Portal.
+	HostnameType   string     `json:"hostnameType,omitempty"`
+	Subject        string     `json:"subject,omitempty"`
+	Thumbprint     string     `json:"thumbprint,omitempty"`
+	ExpirationDate *time.Time `json:"expirationDate,omitempty"`
+	// KeyVault is the Key Vault secret the certificate is taken from, if
+	// any. Certificates without one were uploaded and must be uploaded
+	// again to rebuild the instance.
+	KeyVault *KeyVaultReference `json:"keyVault,omitempty"`
+}
+
+// ListCertificates returns the certificate entities, the CA certificates and
+// the host name certificates of the APIM instance.
+func (c *Client) ListCertificates(ctx context.Context) ([]CertificateInfo, error) {
+	pager := c.clientFactory.NewCertificateClient().NewListByServicePager(c.resourceGroup, c.apimName, nil)
+
+	var results []CertificateInfo
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, wrapError(err, "failed to list certificates")
+		}
+		for _, cert := range page.Value {
+			if cert == nil {
+				continue
+			}
+			info := CertificateInfo{Kind: CertificateKindCertificate, ID: deref(cert.Name)}
+			if props := cert.Properties; props != nil {
+				info.Subject = deref(props.Subject)
+				info.Thumbprint = deref(props.Thumbprint)
+				info.ExpirationDate = props.ExpirationDate
+				info.KeyVault = newKeyVaultReference(props.KeyVault)
+			}
+			results = append(results, info)
+		}
+	}
+
+	service, err := c.clientFactory.NewServiceClient().Get(ctx, c.resourceGroup, c.apimName, nil)
+	if err != nil {
+		return nil, wrapError(err, "failed to get APIM instance %s", c.apimName)
+	}
+	props := service.Properties
+	if props == nil {
+		return results, nil
+	}
+	for _, ca := range props.Certificates {
+		if ca == nil {
+			continue
+		}
+		info := CertificateInfo{Kind: CertificateKindCA}
+		if ca.StoreName != nil {
+			info.ID = string(*ca.StoreName)
+		}
+		if cert := ca.Certificate; cert != nil {
+			info.Subject = deref(cert.Subject)
+			info.Thumbprint = deref(cert.Thumbprint)
+			info.ExpirationDate = cert.Expiry
+		}
+		results = append(results, info)
+	}
+	for _, host := range props.HostnameConfigurations {
+		// Host names without a certificate use the one managed by Azure.
+		if host == nil || (host.Certificate == nil && host.KeyVaultID == nil) {
+			continue
+		}
+		info := CertificateInfo{Kind: CertificateKindHostname, ID: deref(host.HostName)}
+		if host.Type != nil {
+			info.HostnameType = string(*host.Type)
+		}
+		if cert := host.Certificate; cert != nil {
+			info.Subject = deref(cert.Subject)
+			info.Thumbprint = deref(cert.Thumbprint)
+			info.ExpirationDate = cert.Expiry
+		}
+		if host.KeyVaultID != nil {
+			info.KeyVault = &KeyVaultReference{
+				SecretIdentifier: *host.KeyVaultID,
+				IdentityClientID: deref(host.IdentityClientID),
+			}
+		}
+		results = append(results, info)
+	}
+	return results, nil
+}
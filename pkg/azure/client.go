@@ -0,0 +1,807 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/apimanagement/armapimanagement"
+	"github.com/f-marschall/apim-kura/internal/pool"
+)
+
+// Client provides methods for interacting with Azure API Management
+type Client struct {
+	subscriptionID string
+	resourceGroup  string
+	apimName       string
+	credential     azcore.TokenCredential
+	clientFactory  *armapimanagement.ClientFactory
+
+	retry      RetryOptions
+	rateLimit  *rateLimitPolicy
+	transport  policy.Transporter
+	apiVersion string
+
+	concurrency int
+	events      eventBus
+	endpoint    string
+
+	identityOnce sync.Once
+	identity     string
+}
+
+// SubscriptionInfo mirrors the Azure REST API SubscriptionContract schema.
+type SubscriptionInfo struct {
+	ID         string                     `json:"id"`
+	Name       string                     `json:"name"`
+	Type       string                     `json:"type"`
+	Properties SubscriptionInfoProperties `json:"properties"`
+
+	// ETag is the entity tag of the subscription as it was read, for
+	// conditional changes with WithIfMatch. Only the methods that read or
+	// change a single subscription set it, and it is not stored in backups.
+	ETag string `json:"-"`
+}
+
+// SubscriptionInfoProperties holds the properties of a SubscriptionContract.
+type SubscriptionInfoProperties struct {
+	OwnerID          string `json:"ownerId,omitempty"`
+	Scope            string `json:"scope"`
+	DisplayName      string `json:"displayName"`
+	State            string `json:"state"`
+	CreatedDate      string `json:"createdDate,omitempty"`
+	StartDate        string `json:"startDate,omitempty"`
+	EndDate          string `json:"endDate,omitempty"`
+	ExpirationDate   string `json:"expirationDate,omitempty"`
+	NotificationDate string `json:"notificationDate,omitempty"`
+	PrimaryKey       string `json:"primaryKey"`
+	SecondaryKey     string `json:"secondaryKey"`
+	StateComment     string `json:"stateComment,omitempty"`
+	AllowTracing     bool   `json:"allowTracing"`
+
+	// OwnerName and OwnerEmail are not part of the Azure schema. They are
+	// filled in by ResolveOwners to make backups readable by humans.
+	OwnerName  string `json:"ownerName,omitempty"`
+	OwnerEmail string `json:"ownerEmail,omitempty"`
+}
+
+// UserInfo holds the identity details of an APIM user.
+type UserInfo struct {
+	ID        string `json:"id"`
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+	Email     string `json:"email"`
+}
+
+// ProductInfo holds the details of an APIM product.
+type ProductInfo struct {
+	ID                   string `json:"id"`
+	DisplayName          string `json:"displayName"`
+	State                string `json:"state"`
+	SubscriptionRequired bool   `json:"subscriptionRequired"`
+}
+
+// APIInfo holds the details of an APIM API.
+type APIInfo struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+}
+
+// NewClient creates a new Azure API Management client using Azure CLI credentials
+func NewClient(ctx context.Context, subscriptionID, resourceGroup, apimName string) (*Client, error) {
+	return NewClientForTenant(ctx, "", subscriptionID, resourceGroup, apimName)
+}
+
+// NewClientForTenant is like NewClient, but requests tokens for the given
+// Microsoft Entra tenant instead of the Azure CLI's default tenant. The Azure
+// CLI must be logged in to that tenant. An empty tenantID behaves like NewClient.
+// subscriptionID is required with a tenant, as the CLI's current subscription
+// may belong to another one.
+func NewClientForTenant(ctx context.Context, tenantID, subscriptionID, resourceGroup, apimName string) (*Client, error) {
+	if currentEndpoint() != "" {
+		if subscriptionID == "" {
+			subscriptionID = EmulatorSubscriptionID
+		}
+		return NewClientWithCredential(placeholderCredential{}, subscriptionID, resourceGroup, apimName)
+	}
+	if player, ok := currentTransport().(*CassettePlayer); ok {
+		if subscriptionID == "" {
+			subscriptionID = player.SubscriptionID()
+		}
+		return NewClientWithCredential(placeholderCredential{}, subscriptionID, resourceGroup, apimName)
+	}
+	if tenantID != "" && subscriptionID == "" {
+		return nil, fmt.Errorf("a subscription ID is required with tenant %s", tenantID)
+	}
+
+	// If no subscription ID provided, resolve it from Azure CLI
+	if subscriptionID == "" {
+		id, err := resolveSubscriptionID()
+		if err != nil {
+			return nil, fmt.Errorf("no subscription ID provided and failed to resolve from Azure CLI: %w", err)
+		}
+		subscriptionID = id
+	}
+
+	// Use Azure CLI credentials
+	cred, err := azidentity.NewAzureCLICredential(&azidentity.AzureCLICredentialOptions{TenantID: tenantID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to authenticate with Azure CLI: %w", err)
+	}
+
+	return NewClientWithCredential(cred, subscriptionID, resourceGroup, apimName)
+}
+
+// NewClientWithCredential creates a client that authenticates with cred, such
+// as an azidentity.DefaultAzureCredential or a managed identity, instead of
+// the Azure CLI. subscriptionID is required.
+func NewClientWithCredential(cred azcore.TokenCredential, subscriptionID, resourceGroup, apimName string) (*Client, error) {
+	if subscriptionID == "" {
+		return nil, fmt.Errorf("a subscription ID is required")
+	}
+
+	c := &Client{
+		subscriptionID: subscriptionID,
+		resourceGroup:  resourceGroup,
+		apimName:       apimName,
+		credential:     cred,
+		retry:          currentRetryOptions(),
+		rateLimit:      currentRateLimit(),
+		transport:      currentTransport(),
+		apiVersion:     currentAPIVersion(),
+		endpoint:       currentEndpoint(),
+	}
+	if err := c.newClientFactory(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// SetMaxRPS limits the client to at most maxRPS Azure API requests per second,
+// shared across all goroutines using the client. It replaces the limit set
+// with SetDefaultMaxRPS. A value of zero or less removes the limit.
+func (c *Client) SetMaxRPS(maxRPS float64) error {
+	c.rateLimit = nil
+	if maxRPS > 0 {
+		c.rateLimit = newRateLimitPolicy(maxRPS)
+	}
+	return c.newClientFactory()
+}
+
+// SubscriptionID returns the Azure subscription ID used by this client.
+func (c *Client) SubscriptionID() string {
+	return c.subscriptionID
+}
+
+// serviceID returns the resource ID of the APIM instance of the client, the
+// prefix of the resource IDs of its entities.
+func (c *Client) serviceID() string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.ApiManagement/service/%s", c.subscriptionID, c.resourceGroup, c.apimName)
+}
+
+// ListSubscriptions returns APIM subscriptions including their secret keys.
+// If productID is non-empty, only subscriptions scoped to that product are returned.
+func (c *Client) ListSubscriptions(ctx context.Context, productID string) ([]SubscriptionInfo, error) {
+	return c.listSubscriptions(ctx, productID, true)
+}
+
+// ListSubscriptionsWithoutKeys is like ListSubscriptions, but does not fetch
+// the secret keys, which are left empty. It needs a single request per page
+// instead of one per subscription.
+func (c *Client) ListSubscriptionsWithoutKeys(ctx context.Context, productID string) ([]SubscriptionInfo, error) {
+	return c.listSubscriptions(ctx, productID, false)
+}
+
+// SubscriptionFilter selects subscriptions in ListSubscriptionsMatching.
+// Empty fields match every subscription.
+type SubscriptionFilter struct {
+	// ProductID selects the subscriptions scoped to a product.
+	ProductID string
+	// APIID selects the subscriptions scoped to a single API.
+	APIID string
+	// OwnerID selects the subscriptions owned by a user, given by the user
+	// ID (see OwnerUserID).
+	OwnerID string
+}
+
+// ListSubscriptionsMatching is like ListSubscriptions, but only returns the
+// subscriptions matching filter. The owner is filtered on the server, so
+// subscriptions of other developers are not downloaded, and checked again
+// on the client for endpoints that ignore the filter. The API is filtered on
+// the client by the API ID in the scope of each subscription, which is
+// case-insensitive like in APIM, rather than with a scope filter on the
+// server.
+func (c *Client) ListSubscriptionsMatching(ctx context.Context, filter SubscriptionFilter) ([]SubscriptionInfo, error) {
+	return c.listSubscriptionsMatching(ctx, filter, true)
+}
+
+// ListSubscriptionsMatchingWithoutKeys is like ListSubscriptionsMatching, but
+// does not fetch the secret keys.
+func (c *Client) ListSubscriptionsMatchingWithoutKeys(ctx context.Context, filter SubscriptionFilter) ([]SubscriptionInfo, error) {
+	return c.listSubscriptionsMatching(ctx, filter, false)
+}
+
+func (c *Client) listSubscriptions(ctx context.Context, productID string, withKeys bool) ([]SubscriptionInfo, error) {
+	return c.listSubscriptionsMatching(ctx, SubscriptionFilter{ProductID: productID}, withKeys)
+}
+
+func (c *Client) listSubscriptionsMatching(ctx context.Context, filter SubscriptionFilter, withKeys bool) ([]SubscriptionInfo, error) {
+	subClient := c.clientFactory.NewSubscriptionClient()
+	productID := filter.ProductID
+
+	// The OData filter of the list operations, see
+	// https://learn.microsoft.com/rest/api/apimanagement/subscription/list.
+	var odata *string
+	if filter.OwnerID != "" {
+		expr := fmt.Sprintf("userId eq '%s'", odataEscape(filter.OwnerID))
+		odata = &expr
+	}
+
+	// Build a page iterator depending on whether we filter by product.
+	type page struct {
+		Value []*armapimanagement.SubscriptionContract
+	}
+	var nextPage func() (page, bool, error)
+
+	if productID != "" {
+		prodPager := c.clientFactory.NewProductSubscriptionsClient().NewListPager(c.resourceGroup, c.apimName, productID,
+			&armapimanagement.ProductSubscriptionsClientListOptions{Filter: odata})
+		nextPage = func() (page, bool, error) {
+			if !prodPager.More() {
+				return page{}, false, nil
+			}
+			p, err := prodPager.NextPage(ctx)
+			return page{Value: p.Value}, true, err
+		}
+	} else {
+		allPager := subClient.NewListPager(c.resourceGroup, c.apimName, &armapimanagement.SubscriptionClientListOptions{Filter: odata})
+		nextPage = func() (page, bool, error) {
+			if !allPager.More() {
+				return page{}, false, nil
+			}
+			p, err := allPager.NextPage(ctx)
+			return page{Value: p.Value}, true, err
+		}
+	}
+
+	var results []SubscriptionInfo
+	for {
+		p, more, err := nextPage()
+		if err != nil {
+			return nil, wrapError(err, "failed to list subscriptions")
+		}
+		if !more {
+			break
+		}
+
+		for _, sub := range p.Value {
+			if sub == nil || sub.Properties == nil {
+				continue
+			}
+			info := newSubscriptionInfo(sub)
+			if filter.OwnerID != "" && !strings.EqualFold(OwnerUserID(info.Properties.OwnerID), filter.OwnerID) {
+				continue
+			}
+			if filter.APIID != "" && !strings.EqualFold(idSegment(info.Properties.Scope, "apis"), filter.APIID) {
+				continue
+			}
+
+			results = append(results, info)
+		}
+		c.emit(Event{Kind: EventPageFetched, Count: len(p.Value), Total: len(results)})
+	}
+
+	if withKeys {
+		// The keys need one request per subscription, which run in parallel.
+		getKeys := func(ctx context.Context, info SubscriptionInfo) (armapimanagement.SubscriptionClientListSecretsResponse, error) {
+			c.emit(Event{Kind: EventItemStarted, Operation: OperationListSecrets, SID: info.Name})
+			secrets, err := subClient.ListSecrets(ctx, c.resourceGroup, c.apimName, info.Name, nil)
+			c.emitItem(OperationListSecrets, info.Name, err)
+			if err != nil {
+				return secrets, pool.Stop(wrapError(err, "failed to get secrets for subscription %s", info.Name))
+			}
+			return secrets, nil
+		}
+		err := pool.Run(ctx, c.concurrency, results, getKeys, func(r pool.Result[SubscriptionInfo, armapimanagement.SubscriptionClientListSecretsResponse]) {
+			if r.Err == nil {
+				results[r.Index].Properties.PrimaryKey = deref(r.Value.PrimaryKey)
+				results[r.Index].Properties.SecondaryKey = deref(r.Value.SecondaryKey)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// GetSubscription returns a single APIM subscription including its secret keys.
+func (c *Client) GetSubscription(ctx context.Context, sid string) (*SubscriptionInfo, error) {
+	info, err := c.GetSubscriptionWithoutKeys(ctx, sid)
+	if err != nil {
+		return nil, err
+	}
+
+	subClient := c.clientFactory.NewSubscriptionClient()
+	secrets, err := subClient.ListSecrets(ctx, c.resourceGroup, c.apimName, sid, nil)
+	if err != nil {
+		return nil, wrapError(err, "failed to get secrets for subscription %s", sid)
+	}
+	info.Properties.PrimaryKey = deref(secrets.PrimaryKey)
+	info.Properties.SecondaryKey = deref(secrets.SecondaryKey)
+
+	return info, nil
+}
+
+// GetSubscriptionWithoutKeys returns a single APIM subscription without
+// fetching its secret keys, which are left empty.
+func (c *Client) GetSubscriptionWithoutKeys(ctx context.Context, sid string) (*SubscriptionInfo, error) {
+	subClient := c.clientFactory.NewSubscriptionClient()
+
+	resp, err := subClient.Get(ctx, c.resourceGroup, c.apimName, sid, nil)
+	if err != nil {
+		return nil, wrapError(err, "failed to get subscription %s", sid)
+	}
+	info := newSubscriptionInfo(&resp.SubscriptionContract)
+	info.ETag = deref(resp.ETag)
+	return &info, nil
+}
+
+// CreateSubscriptionOptions holds optional parameters for creating a subscription.
+type CreateSubscriptionOptions struct {
+	PrimaryKey   string
+	SecondaryKey string
+	State        string
+	OwnerID      string
+	AllowTracing *bool
+	// ExpirationDate cannot be set on creation, so it is applied with a
+	// follow-up update of the subscription. If that fails, CreateSubscription
+	// returns the subscription with an error matching ErrExpirationNotSet.
+	ExpirationDate *time.Time
+}
+
+// CreateSubscription creates (or updates) an APIM subscription key.
+// sid is the subscription entity identifier (e.g. a GUID).
+// scope is the full resource ID of the product or API the subscription is scoped to.
+// displayName is the human-readable name for the subscription.
+func (c *Client) CreateSubscription(ctx context.Context, sid, scope, displayName string, opts *CreateSubscriptionOptions) (*SubscriptionInfo, error) {
+	var info *SubscriptionInfo
+	err := c.record(ctx, OperationCreate, sid, func() (err error) {
+		info, err = c.createSubscription(ctx, sid, scope, displayName, opts)
+		return err
+	})
+	return info, err
+}
+
+func (c *Client) createSubscription(ctx context.Context, sid, scope, displayName string, opts *CreateSubscriptionOptions) (*SubscriptionInfo, error) {
+	if opts == nil {
+		opts = &CreateSubscriptionOptions{}
+	}
+
+	params := armapimanagement.SubscriptionCreateParameters{
+		Properties: &armapimanagement.SubscriptionCreateParameterProperties{
+			Scope:       &scope,
+			DisplayName: &displayName,
+		},
+	}
+
+	if opts.PrimaryKey != "" {
+		params.Properties.PrimaryKey = &opts.PrimaryKey
+	}
+	if opts.SecondaryKey != "" {
+		params.Properties.SecondaryKey = &opts.SecondaryKey
+	}
+	if opts.OwnerID != "" {
+		params.Properties.OwnerID = &opts.OwnerID
+	}
+	if opts.AllowTracing != nil {
+		params.Properties.AllowTracing = opts.AllowTracing
+	}
+	if opts.State != "" {
+		state := armapimanagement.SubscriptionState(opts.State)
+		params.Properties.State = &state
+	}
+
+	subClient := c.clientFactory.NewSubscriptionClient()
+
+	var createOpts *armapimanagement.SubscriptionClientCreateOrUpdateOptions
+	if etag := ifMatch(ctx); etag != "" {
+		createOpts = &armapimanagement.SubscriptionClientCreateOrUpdateOptions{IfMatch: &etag}
+	}
+	resp, err := subClient.CreateOrUpdate(ctx, c.resourceGroup, c.apimName, sid, params, createOpts)
+	if err != nil {
+		return nil, wrapError(err, "failed to create subscription %s", sid)
+	}
+
+	info := newSubscriptionInfo(&resp.SubscriptionContract)
+	info.ETag = deref(resp.ETag)
+
+	var expErr error
+	if opts.ExpirationDate != nil {
+		// The subscription has a new entity tag after CreateOrUpdate.
+		updated, err := c.updateExpirationDate(WithIfMatch(ctx, info.ETag), sid, opts.ExpirationDate)
+		if err != nil {
+			// The subscription exists now, so callers must not report it
+			// as failed.
+			expErr = fmt.Errorf("%w on subscription %s, which was created: %w", ErrExpirationNotSet, sid, err)
+		} else {
+			info = *updated
+		}
+	}
+
+	// Fetch the secrets since CreateOrUpdate does not return them.
+	secrets, err := subClient.ListSecrets(ctx, c.resourceGroup, c.apimName, sid, nil)
+	if err != nil {
+		return nil, wrapError(err, "failed to get secrets for subscription %s", sid)
+	}
+	info.Properties.PrimaryKey = deref(secrets.PrimaryKey)
+	info.Properties.SecondaryKey = deref(secrets.SecondaryKey)
+
+	return &info, expErr
+}
+
+// SetExpirationDate sets the date on which an APIM subscription expires.
+// The returned SubscriptionInfo does not include the secret keys.
+func (c *Client) SetExpirationDate(ctx context.Context, sid string, expiration time.Time) (*SubscriptionInfo, error) {
+	var info *SubscriptionInfo
+	err := c.record(ctx, OperationSetExpiration, sid, func() (err error) {
+		info, err = c.updateExpirationDate(ctx, sid, &expiration)
+		return err
+	})
+	return info, err
+}
+
+// ClearExpirationDate removes the expiration date of an APIM subscription.
+// The returned SubscriptionInfo does not include the secret keys.
+func (c *Client) ClearExpirationDate(ctx context.Context, sid string) (*SubscriptionInfo, error) {
+	var info *SubscriptionInfo
+	err := c.record(ctx, OperationClearExpiration, sid, func() (err error) {
+		// A nil date would be omitted from the request; an explicit null clears it.
+		info, err = c.updateExpirationDate(ctx, sid, azcore.NullValue[*time.Time]())
+		return err
+	})
+	return info, err
+}
+
+func (c *Client) updateExpirationDate(ctx context.Context, sid string, expiration *time.Time) (*SubscriptionInfo, error) {
+	params := armapimanagement.SubscriptionUpdateParameters{
+		Properties: &armapimanagement.SubscriptionUpdateParameterProperties{
+			ExpirationDate: expiration,
+		},
+	}
+
+	subClient := c.clientFactory.NewSubscriptionClient()
+	resp, err := subClient.Update(ctx, c.resourceGroup, c.apimName, sid, ifMatchOrAny(ctx), params, nil)
+	if err != nil {
+		return nil, wrapError(err, "failed to set expiration date of subscription %s", sid)
+	}
+
+	info := newSubscriptionInfo(&resp.SubscriptionContract)
+	info.ETag = deref(resp.ETag)
+	return &info, nil
+}
+
+// SetState changes the state of an APIM subscription, e.g. to "suspended".
+// comment is recorded as the state comment unless it is empty.
+// The returned SubscriptionInfo does not include the secret keys.
+func (c *Client) SetState(ctx context.Context, sid, state, comment string) (*SubscriptionInfo, error) {
+	var info *SubscriptionInfo
+	err := c.record(ctx, OperationSetState, sid, func() (err error) {
+		info, err = c.setState(ctx, sid, state, comment)
+		return err
+	})
+	return info, err
+}
+
+func (c *Client) setState(ctx context.Context, sid, state, comment string) (*SubscriptionInfo, error) {
+	subState := armapimanagement.SubscriptionState(state)
+	props := &armapimanagement.SubscriptionUpdateParameterProperties{
+		State: &subState,
+	}
+	if comment != "" {
+		props.StateComment = &comment
+	}
+
+	subClient := c.clientFactory.NewSubscriptionClient()
+	resp, err := subClient.Update(ctx, c.resourceGroup, c.apimName, sid, ifMatchOrAny(ctx), armapimanagement.SubscriptionUpdateParameters{Properties: props}, nil)
+	if err != nil {
+		return nil, wrapError(err, "failed to set state of subscription %s", sid)
+	}
+
+	info := newSubscriptionInfo(&resp.SubscriptionContract)
+	info.ETag = deref(resp.ETag)
+	return &info, nil
+}
+
+// DeleteSubscription deletes an APIM subscription by its ID.
+func (c *Client) DeleteSubscription(ctx context.Context, sid string) error {
+	return c.record(ctx, OperationDelete, sid, func() error {
+		subClient := c.clientFactory.NewSubscriptionClient()
+		if _, err := subClient.Delete(ctx, c.resourceGroup, c.apimName, sid, ifMatchOrAny(ctx), nil); err != nil {
+			return wrapError(err, "failed to delete subscription %s", sid)
+		}
+		return nil
+	})
+}
+
+// RegenerateKeys regenerates the primary and/or secondary key of an APIM
+// subscription and returns both keys as they are afterwards.
+func (c *Client) RegenerateKeys(ctx context.Context, sid string, primary, secondary bool) (primaryKey, secondaryKey string, err error) {
+	err = c.record(ctx, OperationRegenerateKeys, sid, func() (err error) {
+		primaryKey, secondaryKey, err = c.regenerateKeys(ctx, sid, primary, secondary)
+		return err
+	})
+	return primaryKey, secondaryKey, err
+}
+
+func (c *Client) regenerateKeys(ctx context.Context, sid string, primary, secondary bool) (primaryKey, secondaryKey string, err error) {
+	subClient := c.clientFactory.NewSubscriptionClient()
+
+	if primary {
+		if _, err := subClient.RegeneratePrimaryKey(ctx, c.resourceGroup, c.apimName, sid, nil); err != nil {
+			return "", "", wrapError(err, "failed to regenerate primary key of subscription %s", sid)
+		}
+	}
+	if secondary {
+		if _, err := subClient.RegenerateSecondaryKey(ctx, c.resourceGroup, c.apimName, sid, nil); err != nil {
+			return "", "", wrapError(err, "failed to regenerate secondary key of subscription %s", sid)
+		}
+	}
+
+	secrets, err := subClient.ListSecrets(ctx, c.resourceGroup, c.apimName, sid, nil)
+	if err != nil {
+		return "", "", wrapError(err, "failed to get secrets for subscription %s", sid)
+	}
+	return deref(secrets.PrimaryKey), deref(secrets.SecondaryKey), nil
+}
+
+// GetUser returns the APIM user with the given user ID.
+func (c *Client) GetUser(ctx context.Context, userID string) (*UserInfo, error) {
+	resp, err := c.clientFactory.NewUserClient().Get(ctx, c.resourceGroup, c.apimName, userID, nil)
+	if err != nil {
+		return nil, wrapError(err, "failed to get user %s", userID)
+	}
+
+	info := &UserInfo{ID: deref(resp.ID)}
+	if resp.Properties != nil {
+		info.FirstName = deref(resp.Properties.FirstName)
+		info.LastName = deref(resp.Properties.LastName)
+		info.Email = deref(resp.Properties.Email)
+	}
+	return info, nil
+}
+
+// FindUserByEmail returns the APIM user with the given email address, or an
+// error satisfying IsNotFound if there is none.
+func (c *Client) FindUserByEmail(ctx context.Context, email string) (*UserInfo, error) {
+	filter := fmt.Sprintf("email eq '%s'", odataEscape(email))
+	pager := c.clientFactory.NewUserClient().NewListByServicePager(c.resourceGroup, c.apimName,
+		&armapimanagement.UserClientListByServiceOptions{Filter: &filter})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, wrapError(err, "failed to find user %s", email)
+		}
+		for _, user := range page.Value {
+			if user == nil || user.Properties == nil || !strings.EqualFold(deref(user.Properties.Email), email) {
+				continue
+			}
+			return &UserInfo{
+				ID:        deref(user.ID),
+				FirstName: deref(user.Properties.FirstName),
+				LastName:  deref(user.Properties.LastName),
+				Email:     deref(user.Properties.Email),
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("no user with email %s: %w", email, ErrNotFound)
+}
+
+// odataEscape quotes s for a string literal in an OData filter.
+func odataEscape(s string) string {
+	return strings.ReplaceAll(s, "'", "''")
+}
+
+// ListProducts returns the products of the APIM instance. ID is the product
+// name used in scopes and --product-id, not the full resource ID.
+func (c *Client) ListProducts(ctx context.Context) ([]ProductInfo, error) {
+	pager := c.clientFactory.NewProductClient().NewListByServicePager(c.resourceGroup, c.apimName, nil)
+
+	var results []ProductInfo
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, wrapError(err, "failed to list products")
+		}
+		for _, product := range page.Value {
+			if product == nil {
+				continue
+			}
+			info := ProductInfo{ID: deref(product.Name)}
+			if props := product.Properties; props != nil {
+				info.DisplayName = deref(props.DisplayName)
+				if props.State != nil {
+					info.State = string(*props.State)
+				}
+				if props.SubscriptionRequired != nil {
+					info.SubscriptionRequired = *props.SubscriptionRequired
+				}
+			}
+			results = append(results, info)
+		}
+	}
+	return results, nil
+}
+
+// ListAPIs returns the APIs of the APIM instance. ID is the API name used in
+// scopes, not the full resource ID.
+func (c *Client) ListAPIs(ctx context.Context) ([]APIInfo, error) {
+	pager := c.clientFactory.NewAPIClient().NewListByServicePager(c.resourceGroup, c.apimName, nil)
+
+	var results []APIInfo
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, wrapError(err, "failed to list APIs")
+		}
+		for _, api := range page.Value {
+			if api == nil {
+				continue
+			}
+			info := APIInfo{ID: deref(api.Name)}
+			if api.Properties != nil {
+				info.DisplayName = deref(api.Properties.DisplayName)
+			}
+			results = append(results, info)
+		}
+	}
+	return results, nil
+}
+
+// ResolveOwners fills in OwnerName and OwnerEmail for every subscription that
+// has an owner. Each user is looked up only once. Owners that no longer exist
+// in the instance are left unresolved.
+func (c *Client) ResolveOwners(ctx context.Context, subs []SubscriptionInfo) error {
+	users := make(map[string]*UserInfo)
+	for i := range subs {
+		userID := OwnerUserID(subs[i].Properties.OwnerID)
+		if userID == "" {
+			continue
+		}
+
+		user, ok := users[userID]
+		if !ok {
+			var err error
+			user, err = c.GetUser(ctx, userID)
+			if err != nil {
+				if !IsNotFound(err) {
+					return err
+				}
+				user = nil
+			}
+			users[userID] = user
+		}
+		if user == nil {
+			continue
+		}
+
+		subs[i].Properties.OwnerName = strings.TrimSpace(user.FirstName + " " + user.LastName)
+		subs[i].Properties.OwnerEmail = user.Email
+	}
+	return nil
+}
+
+// OwnerUserID extracts the user ID from an ownerId resource path such as
+// "/subscriptions/.../service/<apim>/users/<id>" or "/users/<id>".
+// It returns an empty string if the path does not reference a user.
+func OwnerUserID(ownerID string) string {
+	const marker = "/users/"
+	idx := strings.LastIndex(ownerID, marker)
+	if idx == -1 {
+		return ""
+	}
+	return strings.Trim(ownerID[idx+len(marker):], "/")
+}
+
+// SubscriptionExists reports whether a subscription with the given sid exists.
+func (c *Client) SubscriptionExists(ctx context.Context, sid string) (bool, error) {
+	subClient := c.clientFactory.NewSubscriptionClient()
+	_, err := subClient.GetEntityTag(ctx, c.resourceGroup, c.apimName, sid, nil)
+	if err != nil {
+		if IsNotFound(newError(err)) {
+			return false, nil
+		}
+		return false, wrapError(err, "failed to check subscription %s", sid)
+	}
+	return true, nil
+}
+
+// newSubscriptionInfo converts an SDK subscription contract to a SubscriptionInfo.
+// Secret keys are not part of the contract and must be fetched separately.
+func newSubscriptionInfo(sub *armapimanagement.SubscriptionContract) SubscriptionInfo {
+	info := SubscriptionInfo{
+		ID:   deref(sub.ID),
+		Name: deref(sub.Name),
+		Type: deref(sub.Type),
+	}
+	props := sub.Properties
+	if props == nil {
+		return info
+	}
+
+	info.Properties = SubscriptionInfoProperties{
+		OwnerID:      deref(props.OwnerID),
+		Scope:        deref(props.Scope),
+		DisplayName:  deref(props.DisplayName),
+		StateComment: deref(props.StateComment),
+	}
+	if props.State != nil {
+		info.Properties.State = string(*props.State)
+	}
+	if props.AllowTracing != nil {
+		info.Properties.AllowTracing = *props.AllowTracing
+	}
+	if props.CreatedDate != nil {
+		info.Properties.CreatedDate = props.CreatedDate.Format("2006-01-02T15:04:05Z")
+	}
+	if props.StartDate != nil {
+		info.Properties.StartDate = props.StartDate.Format("2006-01-02T15:04:05Z")
+	}
+	if props.EndDate != nil {
+		info.Properties.EndDate = props.EndDate.Format("2006-01-02T15:04:05Z")
+	}
+	if props.ExpirationDate != nil {
+		info.Properties.ExpirationDate = props.ExpirationDate.Format("2006-01-02T15:04:05Z")
+	}
+	if props.NotificationDate != nil {
+		info.Properties.NotificationDate = props.NotificationDate.Format("2006-01-02T15:04:05Z")
+	}
+	return info
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// resolveSubscriptionID gets the current subscription ID from Azure CLI.
+func resolveSubscriptionID() (string, error) {
+	out, err := exec.Command("az", "account", "show", "--query", "id", "-o", "tsv").Output()
+	if err != nil {
+		// Fallback: try JSON output
+		out2, err2 := exec.Command("az", "account", "show", "-o", "json").Output()
+		if err2 != nil {
+			return "", fmt.Errorf("failed to run 'az account show': %w", err)
+		}
+		var acc struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(out2, &acc); err != nil {
+			return "", fmt.Errorf("failed to parse 'az account show' output: %w", err)
+		}
+		if acc.ID == "" {
+			return "", fmt.Errorf("no subscription ID found in 'az account show' output")
+		}
+		return acc.ID, nil
+	}
+
+	id := string(out)
+	// Trim whitespace/newlines
+	for len(id) > 0 && (id[len(id)-1] == '\n' || id[len(id)-1] == '\r' || id[len(id)-1] == ' ') {
+		id = id[:len(id)-1]
+	}
+	if id == "" {
+		return "", fmt.Errorf("empty subscription ID from 'az account show'")
+	}
+	return id, nil
+}
@@ -0,0 +1,176 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+)
+
+// CLIAccount is the account the Azure CLI is logged in with, as reported by
+// 'az account show'.
+type CLIAccount struct {
+	SubscriptionID   string `json:"id"`
+	SubscriptionName string `json:"name"`
+	TenantID         string `json:"tenantId"`
+	User             struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	} `json:"user"`
+}
+
+// CurrentAccount returns the account the Azure CLI is logged in with.
+func CurrentAccount() (*CLIAccount, error) {
+	out, err := exec.Command("az", "account", "show", "-o", "json").Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
+			return nil, fmt.Errorf("'az account show' failed: %s", strings.TrimSpace(string(ee.Stderr)))
+		}
+		return nil, fmt.Errorf("failed to run 'az account show': %w", err)
+	}
+	var acc CLIAccount
+	if err := json.Unmarshal(out, &acc); err != nil {
+		return nil, fmt.Errorf("failed to parse 'az account show' output: %w", err)
+	}
+	return &acc, nil
+}
+
+// ServiceInfo holds the details of an APIM instance.
+type ServiceInfo struct {
+	Name              string
+	Location          string
+	SKU               string
+	ProvisioningState string
+	GatewayURL        string
+}
+
+// AzureSubscription holds the details of the Azure subscription of a client.
+type AzureSubscription struct {
+	DisplayName string `json:"displayName"`
+	State       string `json:"state"`
+	// ServerTime is the time reported by Azure Resource Manager in the
+	// response, for comparing the local clock against.
+	ServerTime time.Time `json:"-"`
+}
+
+// Permission is a set of actions the caller may perform on a scope, as
+// granted by one or more Azure role assignments.
+type Permission struct {
+	Actions    []string `json:"actions"`
+	NotActions []string `json:"notActions"`
+}
+
+// TokenExpiry requests an Azure Resource Manager token with the credential of
+// the client and returns when it expires.
+func (c *Client) TokenExpiry(ctx context.Context) (time.Time, error) {
+	tok, err := c.credential.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{"https://management.azure.com/.default"}})
+	if err != nil {
+		return time.Time{}, err
+	}
+	return tok.ExpiresOn, nil
+}
+
+// GetAzureSubscription returns the Azure subscription of the client.
+func (c *Client) GetAzureSubscription(ctx context.Context) (*AzureSubscription, error) {
+	var sub AzureSubscription
+	header, err := c.armGet(ctx, "/subscriptions/"+c.subscriptionID, "2022-12-01", &sub)
+	if err != nil {
+		return nil, err
+	}
+	if date, err := http.ParseTime(header.Get("Date")); err == nil {
+		sub.ServerTime = date
+	}
+	return &sub, nil
+}
+
+// GetService returns the details of the APIM instance.
+func (c *Client) GetService(ctx context.Context) (*ServiceInfo, error) {
+	resp, err := c.clientFactory.NewServiceClient().Get(ctx, c.resourceGroup, c.apimName, nil)
+	if err != nil {
+		return nil, newError(err)
+	}
+	info := &ServiceInfo{
+		Name:     deref(resp.Name),
+		Location: deref(resp.Location),
+	}
+	if resp.SKU != nil && resp.SKU.Name != nil {
+		info.SKU = string(*resp.SKU.Name)
+	}
+	if p := resp.Properties; p != nil {
+		info.ProvisioningState = deref(p.ProvisioningState)
+		info.GatewayURL = deref(p.GatewayURL)
+	}
+	return info, nil
+}
+
+// Permissions returns the permissions the caller has on the APIM instance.
+func (c *Client) Permissions(ctx context.Context) ([]Permission, error) {
+	var result struct {
+		Value []Permission `json:"value"`
+	}
+	path := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.ApiManagement/service/%s/providers/Microsoft.Authorization/permissions",
+		c.subscriptionID, c.resourceGroup, c.apimName)
+	if _, err := c.armGet(ctx, path, "2022-04-01", &result); err != nil {
+		return nil, err
+	}
+	return result.Value, nil
+}
+
+// HasPermission reports whether perms allow action, such as
+// "Microsoft.ApiManagement/service/subscriptions/write". Actions may contain
+// wildcards, and NotActions only exclude actions of the same permission.
+func HasPermission(perms []Permission, action string) bool {
+	for _, p := range perms {
+		if matchesAny(p.Actions, action) && !matchesAny(p.NotActions, action) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAny(patterns []string, action string) bool {
+	for _, pattern := range patterns {
+		re := "(?i)^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$"
+		if ok, _ := regexp.MatchString(re, action); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// armGet GETs an Azure Resource Manager path that the API Management SDK has
+// no client for, decodes the JSON response into v and returns its headers.
+func (c *Client) armGet(ctx context.Context, path, apiVersion string, v any) (http.Header, error) {
+	client, err := arm.NewClient("kura", "v1", c.credential, c.clientOptions())
+	if err != nil {
+		return nil, err
+	}
+	req, err := runtime.NewRequest(ctx, http.MethodGet, runtime.JoinPaths(client.Endpoint(), path))
+	if err != nil {
+		return nil, err
+	}
+	q := req.Raw().URL.Query()
+	q.Set("api-version", apiVersion)
+	req.Raw().URL.RawQuery = q.Encode()
+	req.Raw().Header.Set("Accept", "application/json")
+
+	resp, err := client.Pipeline().Do(req)
+	if err != nil {
+		return nil, newError(err)
+	}
+	if !runtime.HasStatusCode(resp, http.StatusOK) {
+		return nil, newError(runtime.NewResponseError(resp))
+	}
+	if err := runtime.UnmarshalAsJSON(resp, v); err != nil {
+		return nil, err
+	}
+	return resp.Header, nil
+}
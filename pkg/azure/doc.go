@@ -0,0 +1,55 @@
+// Package azure manages the subscriptions of an Azure API Management
+// instance. It is the client kura itself uses, and it can be imported by other
+// Go programs.
+//
+// A Client is bound to a single instance. NewClient authenticates with the
+// Azure CLI like the kura commands do; NewClientWithCredential accepts any
+// azcore.TokenCredential, such as azidentity.NewDefaultAzureCredential for
+// services running with a managed identity:
+//
+//	cred, err := azidentity.NewDefaultAzureCredential(nil)
+//	if err != nil {
+//		return err
+//	}
+//	client, err := azure.NewClientWithCredential(cred, subscriptionID, "my-rg", "my-apim")
+//	if err != nil {
+//		return err
+//	}
+//	subs, err := client.ListSubscriptions(ctx, "") // all products, with keys
+//
+// SubscriptionInfo mirrors the SubscriptionContract of the Azure REST API,
+// including both keys, and is also the element type of kura backup files
+// (see package backup).
+//
+// The subscription operations are also described by the SubscriptionStore
+// interface, which the kura commands are written against. MemoryStore
+// implements it in memory, for testing code that manages subscriptions
+// without an APIM instance.
+//
+// Failed Azure requests are returned as *Error, which matches ErrNotFound,
+// ErrConflict, ErrThrottled or ErrForbidden with errors.Is depending on the
+// status code of the response, so callers can branch on the kind of failure.
+//
+// Changes overwrite the subscription as it is at the time. To change it
+// only if nobody else did since it was read, pass the ETag of the
+// SubscriptionInfo returned by GetSubscription to WithIfMatch.
+//
+// Every change a Client makes to a subscription is passed to the function
+// installed with SetRecorder, which kura uses for its audit journal.
+//
+// Client.Subscribe returns a channel of Events that report the progress of
+// the client, such as fetched pages and the start and outcome of the request
+// for each subscription, so a program can show progress without the client
+// printing anything.
+//
+// For integration tests without credentials, a CassetteRecorder installed
+// with SetDefaultTransport records the requests of a real run to a file once,
+// and a CassettePlayer loaded with LoadCassette replays them. Subscription
+// and gateway keys, the values of secret named values and Key Vault secrets
+// and the client secrets of identity providers are redacted in the recording,
+// so cassettes can be committed.
+//
+// The exported identifiers of this package follow the versioning of kura
+// releases: they are not removed or changed incompatibly within a major
+// version.
+package azure
@@ -0,0 +1,73 @@
+package azure
+
+import (
+	"context"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/apimanagement/armapimanagement"
+)
+
+// EmailTemplateInfo holds a notification email template of an APIM instance,
+// such as the one sent when a developer signs up.
+type EmailTemplateInfo struct {
+	// ID is the template name, such as "newDeveloperNotificationMessage".
+	ID          string `json:"id"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Subject     string `json:"subject"`
+	// Body is the HTML body with $-placeholders such as $DevFirstName.
+	Body string `json:"body"`
+}
+
+// ListEmailTemplates returns the email templates of the APIM instance that
+// were customized. Templates with their default content are left out, as a
+// new instance has them already.
+func (c *Client) ListEmailTemplates(ctx context.Context) ([]EmailTemplateInfo, error) {
+	pager := c.clientFactory.NewEmailTemplateClient().NewListByServicePager(c.resourceGroup, c.apimName, nil)
+
+	var results []EmailTemplateInfo
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, wrapError(err, "failed to list email templates")
+		}
+		for _, tmpl := range page.Value {
+			if tmpl == nil || tmpl.Properties == nil {
+				continue
+			}
+			props := tmpl.Properties
+			if props.IsDefault != nil && *props.IsDefault {
+				continue
+			}
+			results = append(results, EmailTemplateInfo{
+				ID:          deref(tmpl.Name),
+				Title:       deref(props.Title),
+				Description: deref(props.Description),
+				Subject:     deref(props.Subject),
+				Body:        deref(props.Body),
+			})
+		}
+	}
+	return results, nil
+}
+
+// PutEmailTemplate replaces the content of an email template. APIM has a
+// fixed set of templates, so tmpl.ID must be one of them.
+func (c *Client) PutEmailTemplate(ctx context.Context, tmpl EmailTemplateInfo) error {
+	props := &armapimanagement.EmailTemplateUpdateParameterProperties{
+		Subject: &tmpl.Subject,
+		Body:    &tmpl.Body,
+	}
+	if tmpl.Title != "" {
+		props.Title = &tmpl.Title
+	}
+	if tmpl.Description != "" {
+		props.Description = &tmpl.Description
+	}
+
+	_, err := c.clientFactory.NewEmailTemplateClient().CreateOrUpdate(ctx, c.resourceGroup, c.apimName,
+		armapimanagement.TemplateName(tmpl.ID), armapimanagement.EmailTemplateUpdateParameters{Properties: props}, nil)
+	if err != nil {
+		return wrapError(err, "failed to put email template %s", tmpl.ID)
+	}
+	return nil
+}
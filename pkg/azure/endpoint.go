@@ -0,0 +1,75 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// EmulatorSubscriptionID is the Azure subscription ID of clients created by
+// NewClient for an endpoint set with SetDefaultEndpoint, if none is given.
+const EmulatorSubscriptionID = "00000000-0000-0000-0000-000000000000"
+
+var (
+	endpointMu      sync.RWMutex
+	defaultEndpoint string
+)
+
+// SetDefaultEndpoint makes the clients created afterwards send their API
+// Management requests to endpoint, the base URL of a server that implements
+// the Azure Resource Manager API, such as "http://localhost:8780" for
+// 'kura emulator', instead of to Azure.
+//
+// Such servers are fakes for trying kura and for tests: with an endpoint,
+// NewClient and NewClientForTenant do not use the Azure CLI, but send a
+// placeholder token, and use EmulatorSubscriptionID if no subscription ID is
+// given. An empty endpoint restores Azure.
+func SetDefaultEndpoint(endpoint string) error {
+	if endpoint != "" {
+		u, err := url.Parse(endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid endpoint %q, expected an http:// or https:// URL", endpoint)
+		}
+		endpoint = strings.TrimSuffix(endpoint, "/")
+	}
+	endpointMu.Lock()
+	defer endpointMu.Unlock()
+	defaultEndpoint = endpoint
+	return nil
+}
+
+func currentEndpoint() string {
+	endpointMu.RLock()
+	defer endpointMu.RUnlock()
+	return defaultEndpoint
+}
+
+// endpointCloud returns the cloud configuration that sends Azure Resource
+// Manager requests to endpoint.
+func endpointCloud(endpoint string) cloud.Configuration {
+	return cloud.Configuration{
+		ActiveDirectoryAuthorityHost: cloud.AzurePublic.ActiveDirectoryAuthorityHost,
+		Services: map[cloud.ServiceName]cloud.ServiceConfiguration{
+			cloud.ResourceManager: {
+				Endpoint: endpoint,
+				Audience: cloud.AzurePublic.Services[cloud.ResourceManager].Audience,
+			},
+		},
+	}
+}
+
+// placeholderCredential returns the same fake token for every request. It is
+// used for endpoints set with SetDefaultEndpoint, which do not check tokens,
+// and for replaying cassettes, which send no requests.
+type placeholderCredential struct{}
+
+func (placeholderCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "kura-placeholder", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
@@ -0,0 +1,141 @@
+package azure
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+// Kinds of failed requests that callers commonly handle. Errors returned by
+// Client and MemoryStore match them with errors.Is:
+//
+//	if errors.Is(err, azure.ErrNotFound) {
+//		// the subscription does not exist (anymore)
+//	}
+var (
+	// ErrNotFound means the subscription or other resource does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict means the request conflicts with the current state of the
+	// resource, for example because another request changed it at the same
+	// time, or because it was changed since it was read (see WithIfMatch).
+	ErrConflict = errors.New("conflict")
+	// ErrThrottled means Azure rejected the request because too many were
+	// sent. Error.RetryAfter tells when to try again.
+	ErrThrottled = errors.New("throttled")
+	// ErrForbidden means the caller lacks a role assignment for the request.
+	ErrForbidden = errors.New("forbidden")
+	// ErrExpirationNotSet means CreateSubscription created or updated the
+	// subscription, but failed to set its expiration date, which takes a
+	// separate request. The returned SubscriptionInfo describes the
+	// subscription without it.
+	ErrExpirationNotSet = errors.New("expiration date not set")
+)
+
+// Error is a failed Azure request. It wraps the *azcore.ResponseError of the
+// SDK, which remains available with errors.As.
+type Error struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// ErrorCode is the error code reported by Azure, such as
+	// "ResourceNotFound", if there is one.
+	ErrorCode string
+	// RetryAfter is the delay requested by the Retry-After header of the
+	// response, or zero.
+	RetryAfter time.Duration
+
+	err error
+}
+
+func (e *Error) Error() string {
+	return e.err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// Is reports whether e is of the kind of one of the Err variables.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict || e.StatusCode == http.StatusPreconditionFailed
+	case ErrThrottled:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	}
+	return false
+}
+
+// newError wraps an *azcore.ResponseError in err into an *Error. Other errors,
+// such as network failures, are returned as they are.
+func newError(err error) error {
+	var respErr *azcore.ResponseError
+	if !errors.As(err, &respErr) {
+		return err
+	}
+	e := &Error{StatusCode: respErr.StatusCode, ErrorCode: respErr.ErrorCode, err: err}
+	if respErr.RawResponse != nil {
+		e.RetryAfter = parseRetryAfter(respErr.RawResponse.Header.Get("Retry-After"))
+	}
+	return e
+}
+
+// wrapError is like fmt.Errorf with a trailing ": %w" for err, converting an
+// Azure response error in err into an *Error.
+func wrapError(err error, format string, args ...any) error {
+	err = newError(err)
+	var e *Error
+	if errors.As(err, &e) && e.StatusCode == http.StatusPreconditionFailed {
+		format += ": it was changed since it was read"
+	}
+	return fmt.Errorf(format+": %w", append(args, err)...)
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP
+// date. It returns zero for a missing or invalid header.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// IsNotFound reports whether err means that the subscription or other
+// resource does not exist. It is short for errors.Is(err, ErrNotFound).
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+// IsConflict reports whether err is of kind ErrConflict.
+func IsConflict(err error) bool {
+	return errors.Is(err, ErrConflict)
+}
+
+// IsThrottled reports whether err is of kind ErrThrottled.
+func IsThrottled(err error) bool {
+	return errors.Is(err, ErrThrottled)
+}
+
+// IsForbidden reports whether err is of kind ErrForbidden, which means the
+// caller lacks a role assignment.
+func IsForbidden(err error) bool {
+	return errors.Is(err, ErrForbidden)
+}
@@ -0,0 +1,34 @@
+package azure
+
+import "context"
+
+type ifMatchKey struct{}
+
+// WithIfMatch returns a copy of ctx that makes the changes of a subscription
+// made with it conditional on etag, the SubscriptionInfo.ETag of the
+// subscription as it was read: CreateSubscription replacing an existing
+// subscription, SetState, SetExpirationDate, ClearExpirationDate and
+// DeleteSubscription fail with an error matching ErrConflict if the
+// subscription was changed or deleted since. An empty etag leaves the changes
+// unconditional.
+//
+// Without WithIfMatch, changes overwrite whatever the subscription looks like
+// at the time, like the Azure CLI does.
+func WithIfMatch(ctx context.Context, etag string) context.Context {
+	return context.WithValue(ctx, ifMatchKey{}, etag)
+}
+
+// ifMatch returns the entity tag set with WithIfMatch, or "" if there is none.
+func ifMatch(ctx context.Context) string {
+	etag, _ := ctx.Value(ifMatchKey{}).(string)
+	return etag
+}
+
+// ifMatchOrAny is like ifMatch, but returns "*", which matches any entity
+// tag, instead of "".
+func ifMatchOrAny(ctx context.Context) string {
+	if etag := ifMatch(ctx); etag != "" {
+		return etag
+	}
+	return "*"
+}
@@ -0,0 +1,99 @@
+package azure
+
+import (
+	"sync"
+	"time"
+)
+
+// Kinds of an Event.
+const (
+	// EventPageFetched reports a page of subscriptions listed by
+	// ListSubscriptions or ListSubscriptionsWithoutKeys.
+	EventPageFetched = "page-fetched"
+	// EventItemStarted reports that a request for one subscription started.
+	EventItemStarted = "item-started"
+	// EventItemSucceeded reports that a request for one subscription
+	// succeeded.
+	EventItemSucceeded = "item-succeeded"
+	// EventItemFailed reports that a request for one subscription failed.
+	EventItemFailed = "item-failed"
+)
+
+// OperationListSecrets is the Operation of the events of fetching the keys
+// of a listed subscription. The events of changes use the operations of a
+// Mutation, such as OperationCreate.
+const OperationListSecrets = "list-secrets"
+
+// Event reports the progress of a Client, so that a command line, a terminal
+// UI or a server can show it without the client printing anything.
+type Event struct {
+	Kind string
+	// Operation is the operation of an item event, such as OperationCreate
+	// or OperationListSecrets. It is empty for EventPageFetched.
+	Operation string
+	// SID is the subscription of an item event.
+	SID string
+	// Count is the number of subscriptions on a fetched page, and Total the
+	// number of subscriptions fetched so far, including them.
+	Count int
+	Total int
+	// Err is the error of EventItemFailed.
+	Err  error
+	Time time.Time
+}
+
+// eventBus delivers the events of a Client to its subscribers.
+type eventBus struct {
+	mu   sync.Mutex
+	subs map[chan Event]struct{}
+}
+
+// Subscribe returns a channel that receives the events of the client, and a
+// function that unsubscribes and closes the channel. The channel buffers
+// size events; events that do not fit because the receiver falls behind are
+// dropped rather than slowing down the client.
+func (c *Client) Subscribe(size int) (<-chan Event, func()) {
+	ch := make(chan Event, size)
+	c.events.mu.Lock()
+	if c.events.subs == nil {
+		c.events.subs = make(map[chan Event]struct{})
+	}
+	c.events.subs[ch] = struct{}{}
+	c.events.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			c.events.mu.Lock()
+			delete(c.events.subs, ch)
+			c.events.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// emit sends e to the subscribers of the client.
+func (c *Client) emit(e Event) {
+	c.events.mu.Lock()
+	defer c.events.mu.Unlock()
+	if len(c.events.subs) == 0 {
+		return
+	}
+	e.Time = time.Now()
+	for ch := range c.events.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// emitItem emits the event of a finished request for subscription sid: an
+// EventItemFailed if err is not nil, and an EventItemSucceeded otherwise.
+func (c *Client) emitItem(op, sid string, err error) {
+	kind := EventItemSucceeded
+	if err != nil {
+		kind = EventItemFailed
+	}
+	c.emit(Event{Kind: kind, Operation: op, SID: sid, Err: err})
+}
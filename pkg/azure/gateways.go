@@ -0,0 +1,181 @@
+package azure
+
+import (
+	"context"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/apimanagement/armapimanagement"
+)
+
+// GatewayInfo holds a self-hosted gateway of an APIM instance, the APIs
+// assigned to it and its keys.
+type GatewayInfo struct {
+	// ID is the gateway ID, not the full resource ID.
+	ID          string           `json:"id"`
+	Description string           `json:"description,omitempty"`
+	Location    *GatewayLocation `json:"location,omitempty"`
+	APIs        []string         `json:"apis,omitempty"`
+	// PrimaryKey and SecondaryKey sign the tokens self-hosted gateway
+	// deployments authenticate with. APIM generates them and cannot be given
+	// keys, so they are backed up for reference and not restored.
+	PrimaryKey   string `json:"primaryKey,omitempty"`
+	SecondaryKey string `json:"secondaryKey,omitempty"`
+}
+
+// GatewayLocation is where a self-hosted gateway is deployed.
+type GatewayLocation struct {
+	Name            string `json:"name"`
+	City            string `json:"city,omitempty"`
+	CountryOrRegion string `json:"countryOrRegion,omitempty"`
+	District        string `json:"district,omitempty"`
+}
+
+// ListGateways returns the self-hosted gateways of the APIM instance with
+// their API assignments and keys, with two extra requests per gateway.
+func (c *Client) ListGateways(ctx context.Context) ([]GatewayInfo, error) {
+	gateways := c.clientFactory.NewGatewayClient()
+	pager := gateways.NewListByServicePager(c.resourceGroup, c.apimName, nil)
+
+	var results []GatewayInfo
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, wrapError(err, "failed to list gateways")
+		}
+		for _, gw := range page.Value {
+			if gw == nil {
+				continue
+			}
+			info := GatewayInfo{ID: deref(gw.Name)}
+			if props := gw.Properties; props != nil {
+				info.Description = deref(props.Description)
+				if loc := props.LocationData; loc != nil {
+					info.Location = &GatewayLocation{
+						Name:            deref(loc.Name),
+						City:            deref(loc.City),
+						CountryOrRegion: deref(loc.CountryOrRegion),
+						District:        deref(loc.District),
+					}
+				}
+			}
+			results = append(results, info)
+		}
+	}
+
+	apis := c.clientFactory.NewGatewayAPIClient()
+	for i := range results {
+		gw := &results[i]
+		pager := apis.NewListByServicePager(c.resourceGroup, c.apimName, gw.ID, nil)
+		for pager.More() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				return nil, wrapError(err, "failed to list APIs of gateway %s", gw.ID)
+			}
+			for _, api := range page.Value {
+				if api != nil {
+					gw.APIs = append(gw.APIs, deref(api.Name))
+				}
+			}
+		}
+
+		keys, err := c.getGatewayKeys(ctx, gw.ID)
+		if err != nil {
+			return nil, err
+		}
+		gw.PrimaryKey = keys.Primary
+		gw.SecondaryKey = keys.Secondary
+	}
+	return results, nil
+}
+
+// PutGateway creates or replaces a self-hosted gateway and assigns its APIs,
+// which must exist. APIs assigned beyond those are kept. The keys of gateway
+// are ignored: a new gateway gets new keys.
+func (c *Client) PutGateway(ctx context.Context, gateway GatewayInfo) error {
+	props := &armapimanagement.GatewayContractProperties{}
+	if gateway.Description != "" {
+		props.Description = &gateway.Description
+	}
+	if loc := gateway.Location; loc != nil {
+		props.LocationData = &armapimanagement.ResourceLocationDataContract{Name: &loc.Name}
+		if loc.City != "" {
+			props.LocationData.City = &loc.City
+		}
+		if loc.CountryOrRegion != "" {
+			props.LocationData.CountryOrRegion = &loc.CountryOrRegion
+		}
+		if loc.District != "" {
+			props.LocationData.District = &loc.District
+		}
+	}
+	_, err := c.clientFactory.NewGatewayClient().CreateOrUpdate(ctx, c.resourceGroup, c.apimName, gateway.ID,
+		armapimanagement.GatewayContract{Properties: props}, nil)
+	if err != nil {
+		return wrapError(err, "failed to put gateway %s", gateway.ID)
+	}
+
+	apis := c.clientFactory.NewGatewayAPIClient()
+	for _, apiID := range gateway.APIs {
+		if _, err := apis.CreateOrUpdate(ctx, c.resourceGroup, c.apimName, gateway.ID, apiID, nil); err != nil {
+			return wrapError(err, "failed to assign API %s to gateway %s", apiID, gateway.ID)
+		}
+	}
+	return nil
+}
+
+// GatewayKeys are the keys of a self-hosted gateway.
+type GatewayKeys struct {
+	Primary   string `json:"primary"`
+	Secondary string `json:"secondary"`
+}
+
+// getGatewayKeys returns the keys of a self-hosted gateway.
+func (c *Client) getGatewayKeys(ctx context.Context, gatewayID string) (*GatewayKeys, error) {
+	keys, err := c.clientFactory.NewGatewayClient().ListKeys(ctx, c.resourceGroup, c.apimName, gatewayID, nil)
+	if err != nil {
+		return nil, wrapError(err, "failed to list keys of gateway %s", gatewayID)
+	}
+	return &GatewayKeys{Primary: deref(keys.Primary), Secondary: deref(keys.Secondary)}, nil
+}
+
+// RegenerateGatewayKey regenerates the primary or the secondary key of a
+// self-hosted gateway, which invalidates the tokens signed with it, and
+// returns a token signed with the new key that expires at expiry.
+func (c *Client) RegenerateGatewayKey(ctx context.Context, gatewayID string, primary bool, expiry time.Time) (string, error) {
+	keyType := armapimanagement.KeyTypeSecondary
+	if primary {
+		keyType = armapimanagement.KeyTypePrimary
+	}
+	gateways := c.clientFactory.NewGatewayClient()
+
+	m := Mutation{Kind: "gateways", Operation: OperationRegenerateKeys, Target: gatewayID}
+	if Recording() {
+		if keys, err := c.getGatewayKeys(ctx, gatewayID); err == nil {
+			m.BeforeEntity = keys
+		}
+	}
+	err := c.RecordChange(ctx, m, func() (any, error) {
+		_, err := gateways.RegenerateKey(ctx, c.resourceGroup, c.apimName, gatewayID,
+			armapimanagement.GatewayKeyRegenerationRequestContract{KeyType: &keyType}, nil)
+		if err != nil {
+			return nil, wrapError(err, "failed to regenerate %s key of gateway %s", keyType, gatewayID)
+		}
+		if !Recording() {
+			return nil, nil
+		}
+		if keys, err := c.getGatewayKeys(ctx, gatewayID); err == nil {
+			return keys, nil
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	token, err := gateways.GenerateToken(ctx, c.resourceGroup, c.apimName, gatewayID,
+		armapimanagement.GatewayTokenRequestContract{KeyType: &keyType, Expiry: &expiry}, nil)
+	if err != nil {
+		return "", wrapError(err, "failed to generate token for gateway %s", gatewayID)
+	}
+	return deref(token.Value), nil
+}
@@ -0,0 +1,60 @@
+package azure
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// Identity returns the identity the client acts as, read from the access
+// token of its credential: the user principal name of a user, or the
+// application ID of a service principal or managed identity. It returns an
+// empty string if the token does not tell, as with SetDefaultEndpoint.
+func (c *Client) Identity(ctx context.Context) string {
+	c.identityOnce.Do(func() {
+		cfg := c.clientOptions().Cloud
+		if cfg.Services == nil {
+			cfg = cloud.AzurePublic
+		}
+		scope := cfg.Services[cloud.ResourceManager].Audience + "/.default"
+		token, err := c.credential.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{scope}})
+		if err == nil {
+			c.identity = tokenIdentity(token.Token)
+		}
+	})
+	return c.identity
+}
+
+// tokenIdentity returns the identity in the claims of a JWT access token.
+// The signature is not verified; the token comes from the credential.
+func tokenIdentity(token string) string {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+	var claims struct {
+		UPN               string `json:"upn"`
+		PreferredUsername string `json:"preferred_username"`
+		UniqueName        string `json:"unique_name"`
+		AppID             string `json:"appid"`
+		AZP               string `json:"azp"`
+		OID               string `json:"oid"`
+	}
+	if json.Unmarshal(payload, &claims) != nil {
+		return ""
+	}
+	for _, id := range []string{claims.UPN, claims.PreferredUsername, claims.UniqueName, claims.AppID, claims.AZP, claims.OID} {
+		if id != "" {
+			return id
+		}
+	}
+	return ""
+}
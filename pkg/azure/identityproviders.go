@@ -0,0 +1,111 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/apimanagement/armapimanagement"
+)
+
+// IdentityProviderInfo holds an identity provider developers sign in to the
+// developer portal with, such as aad or aadB2C, without its client secret.
+type IdentityProviderInfo struct {
+	// Type is the provider type, which is also its ID.
+	Type           string   `json:"type"`
+	ClientID       string   `json:"clientId"`
+	AllowedTenants []string `json:"allowedTenants,omitempty"`
+	Authority      string   `json:"authority,omitempty"`
+	SigninTenant   string   `json:"signinTenant,omitempty"`
+	// The policies are the user flows of Azure AD B2C.
+	SigninPolicyName         string `json:"signinPolicyName,omitempty"`
+	SignupPolicyName         string `json:"signupPolicyName,omitempty"`
+	ProfileEditingPolicyName string `json:"profileEditingPolicyName,omitempty"`
+	PasswordResetPolicyName  string `json:"passwordResetPolicyName,omitempty"`
+	// ClientSecret is the Key Vault secret holding the client secret of the
+	// provider. Backups leave it empty; it must be filled in before a
+	// restore, which reads the secret with the credential of kura.
+	ClientSecret *KeyVaultReference `json:"clientSecret"`
+}
+
+// ListIdentityProviders returns the identity providers of the APIM instance.
+// Their client secrets are not read.
+func (c *Client) ListIdentityProviders(ctx context.Context) ([]IdentityProviderInfo, error) {
+	pager := c.clientFactory.NewIdentityProviderClient().NewListByServicePager(c.resourceGroup, c.apimName, nil)
+
+	var results []IdentityProviderInfo
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, wrapError(err, "failed to list identity providers")
+		}
+		for _, idp := range page.Value {
+			if idp == nil {
+				continue
+			}
+			info := IdentityProviderInfo{Type: deref(idp.Name)}
+			if props := idp.Properties; props != nil {
+				info.ClientID = deref(props.ClientID)
+				info.Authority = deref(props.Authority)
+				info.SigninTenant = deref(props.SigninTenant)
+				info.SigninPolicyName = deref(props.SigninPolicyName)
+				info.SignupPolicyName = deref(props.SignupPolicyName)
+				info.ProfileEditingPolicyName = deref(props.ProfileEditingPolicyName)
+				info.PasswordResetPolicyName = deref(props.PasswordResetPolicyName)
+				for _, tenant := range props.AllowedTenants {
+					if tenant != nil {
+						info.AllowedTenants = append(info.AllowedTenants, *tenant)
+					}
+				}
+			}
+			results = append(results, info)
+		}
+	}
+	return results, nil
+}
+
+// PutIdentityProvider creates or replaces an identity provider with the
+// client secret read from its Key Vault reference.
+func (c *Client) PutIdentityProvider(ctx context.Context, idp IdentityProviderInfo) error {
+	if idp.ClientSecret == nil || idp.ClientSecret.SecretIdentifier == "" {
+		return fmt.Errorf("identity provider %s has no clientSecret: set clientSecret.secretIdentifier to the Key Vault secret holding it", idp.Type)
+	}
+	secret, err := c.readKeyVaultSecret(ctx, idp.ClientSecret)
+	if err != nil {
+		return err
+	}
+
+	idpType := armapimanagement.IdentityProviderType(idp.Type)
+	props := &armapimanagement.IdentityProviderCreateContractProperties{
+		Type:         &idpType,
+		ClientID:     &idp.ClientID,
+		ClientSecret: &secret,
+	}
+	for i := range idp.AllowedTenants {
+		props.AllowedTenants = append(props.AllowedTenants, &idp.AllowedTenants[i])
+	}
+	if idp.Authority != "" {
+		props.Authority = &idp.Authority
+	}
+	if idp.SigninTenant != "" {
+		props.SigninTenant = &idp.SigninTenant
+	}
+	if idp.SigninPolicyName != "" {
+		props.SigninPolicyName = &idp.SigninPolicyName
+	}
+	if idp.SignupPolicyName != "" {
+		props.SignupPolicyName = &idp.SignupPolicyName
+	}
+	if idp.ProfileEditingPolicyName != "" {
+		props.ProfileEditingPolicyName = &idp.ProfileEditingPolicyName
+	}
+	if idp.PasswordResetPolicyName != "" {
+		props.PasswordResetPolicyName = &idp.PasswordResetPolicyName
+	}
+
+	_, err = c.clientFactory.NewIdentityProviderClient().CreateOrUpdate(ctx, c.resourceGroup, c.apimName, idpType,
+		armapimanagement.IdentityProviderCreateContract{Properties: props}, nil)
+	if err != nil {
+		return wrapError(err, "failed to put identity provider %s", idp.Type)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/apimanagement/armapimanagement"
+)
+
+// keyVaultAPIVersion is the version of the Key Vault data plane API used to
+// read secrets.
+const keyVaultAPIVersion = "7.4"
+
+// KeyVaultReference is the Key Vault secret an entity, such as a named value
+// or a certificate, is taken from. Backups keep the reference, not the
+// secret.
+type KeyVaultReference struct {
+	SecretIdentifier string `json:"secretIdentifier"`
+	// IdentityClientID is the client ID of the user-assigned identity the
+	// instance reads the secret with, or empty for its system identity.
+	IdentityClientID string `json:"identityClientId,omitempty"`
+}
+
+// newKeyVaultReference converts the Key Vault properties of an SDK contract.
+func newKeyVaultReference(kv *armapimanagement.KeyVaultContractProperties) *KeyVaultReference {
+	if kv == nil {
+		return nil
+	}
+	return &KeyVaultReference{
+		SecretIdentifier: deref(kv.SecretIdentifier),
+		IdentityClientID: deref(kv.IdentityClientID),
+	}
+}
+
+// createProperties converts r for creating an SDK contract.
+func (r *KeyVaultReference) createProperties() *armapimanagement.KeyVaultContractCreateProperties {
+	if r == nil {
+		return nil
+	}
+	props := &armapimanagement.KeyVaultContractCreateProperties{SecretIdentifier: &r.SecretIdentifier}
+	if r.IdentityClientID != "" {
+		props.IdentityClientID = &r.IdentityClientID
+	}
+	return props
+}
+
+// readKeyVaultSecret returns the value of the secret r references, read with
+// the credential of the client rather than the identity of the instance.
+func (c *Client) readKeyVaultSecret(ctx context.Context, r *KeyVaultReference) (string, error) {
+	u, err := url.Parse(r.SecretIdentifier)
+	if err != nil || u.Scheme != "https" || !strings.Contains(u.Host, ".") {
+		return "", fmt.Errorf("invalid Key Vault secret identifier %q", r.SecretIdentifier)
+	}
+	// The token audience is the vault domain of the cloud, such as
+	// https://vault.azure.net for myvault.vault.azure.net.
+	_, domain, _ := strings.Cut(u.Hostname(), ".")
+	q := u.Query()
+	q.Set("api-version", keyVaultAPIVersion)
+	u.RawQuery = q.Encode()
+
+	opts := c.clientOptions().ClientOptions
+	auth := runtime.NewBearerTokenPolicy(c.credential, []string{"https://" + domain + "/.default"}, nil)
+	pipeline := runtime.NewPipeline("kura", "v1", runtime.PipelineOptions{PerRetry: []policy.Policy{auth}}, &opts)
+	req, err := runtime.NewRequest(ctx, http.MethodGet, u.String())
+	if err != nil {
+		return "", err
+	}
+	resp, err := pipeline.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to read Key Vault secret %s: %w", r.SecretIdentifier, err)
+	}
+	if !runtime.HasStatusCode(resp, http.StatusOK) {
+		return "", fmt.Errorf("failed to read Key Vault secret %s: %w", r.SecretIdentifier, newError(runtime.NewResponseError(resp)))
+	}
+	var secret struct {
+		Value string `json:"value"`
+	}
+	if err := runtime.UnmarshalAsJSON(resp, &secret); err != nil {
+		return "", fmt.Errorf("failed to read Key Vault secret %s: %w", r.SecretIdentifier, err)
+	}
+	return secret.Value, nil
+}
@@ -0,0 +1,180 @@
+package azure
+
+import (
+	"context"
+	"path"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/apimanagement/armapimanagement"
+)
+
+// LoggerInfo holds a logger of an APIM instance, the Application Insights,
+// Event Hub or Azure Monitor destination diagnostics send their logs to.
+type LoggerInfo struct {
+	// ID is the logger ID, not the full resource ID.
+	ID          string `json:"id"`
+	LoggerType  string `json:"loggerType"`
+	Description string `json:"description,omitempty"`
+	// Credentials are the instrumentation key or the connection string of
+	// the destination. APIM returns them as references to the named values
+	// it stores them in, such as "{{Logger-Credentials--0123}}".
+	Credentials map[string]string `json:"credentials,omitempty"`
+	IsBuffered  bool              `json:"isBuffered"`
+	ResourceID  string            `json:"resourceId,omitempty"`
+}
+
+// ListLoggers returns the loggers of the APIM instance.
+func (c *Client) ListLoggers(ctx context.Context) ([]LoggerInfo, error) {
+	pager := c.clientFactory.NewLoggerClient().NewListByServicePager(c.resourceGroup, c.apimName, nil)
+
+	var results []LoggerInfo
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, wrapError(err, "failed to list loggers")
+		}
+		for _, logger := range page.Value {
+			if logger == nil {
+				continue
+			}
+			info := LoggerInfo{ID: deref(logger.Name)}
+			if props := logger.Properties; props != nil {
+				info.Description = deref(props.Description)
+				info.ResourceID = deref(props.ResourceID)
+				if props.LoggerType != nil {
+					info.LoggerType = string(*props.LoggerType)
+				}
+				if props.IsBuffered != nil {
+					info.IsBuffered = *props.IsBuffered
+				}
+				for key, value := range props.Credentials {
+					if value == nil {
+						continue
+					}
+					if info.Credentials == nil {
+						info.Credentials = make(map[string]string)
+					}
+					info.Credentials[key] = *value
+				}
+			}
+			results = append(results, info)
+		}
+	}
+	return results, nil
+}
+
+// PutLogger creates or replaces a logger.
+func (c *Client) PutLogger(ctx context.Context, logger LoggerInfo) error {
+	loggerType := armapimanagement.LoggerType(logger.LoggerType)
+	props := &armapimanagement.LoggerContractProperties{
+		LoggerType: &loggerType,
+		IsBuffered: &logger.IsBuffered,
+	}
+	if logger.Description != "" {
+		props.Description = &logger.Description
+	}
+	if logger.ResourceID != "" {
+		props.ResourceID = &logger.ResourceID
+	}
+	if len(logger.Credentials) > 0 {
+		props.Credentials = make(map[string]*string, len(logger.Credentials))
+		for key, value := range logger.Credentials {
+			props.Credentials[key] = &value
+		}
+	}
+
+	_, err := c.clientFactory.NewLoggerClient().CreateOrUpdate(ctx, c.resourceGroup, c.apimName, logger.ID,
+		armapimanagement.LoggerContract{Properties: props}, nil)
+	if err != nil {
+		return wrapError(err, "failed to put logger %s", logger.ID)
+	}
+	return nil
+}
+
+// DiagnosticInfo holds a diagnostic of an APIM instance, which configures
+// what requests log to a logger, either for all APIs or for a single one.
+type DiagnosticInfo struct {
+	// APIID is the API the diagnostic applies to, or empty for the global
+	// diagnostic of the instance.
+	APIID string `json:"apiId,omitempty"`
+	// ID is the diagnostic ID, such as "applicationinsights".
+	ID string `json:"id"`
+	// LoggerID is the ID of the logger, not the full resource ID, so the
+	// diagnostic can be restored to another instance.
+	LoggerID string `json:"loggerId"`
+	// Settings are the other properties of the diagnostic, such as sampling
+	// and verbosity, in the schema of the REST API.
+	Settings *armapimanagement.DiagnosticContractProperties `json:"settings,omitempty"`
+}
+
+// ListDiagnostics returns the global diagnostics of the APIM instance and
+// those of each of its APIs, with one extra request per API.
+func (c *Client) ListDiagnostics(ctx context.Context) ([]DiagnosticInfo, error) {
+	var results []DiagnosticInfo
+	pager := c.clientFactory.NewDiagnosticClient().NewListByServicePager(c.resourceGroup, c.apimName, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, wrapError(err, "failed to list diagnostics")
+		}
+		for _, d := range page.Value {
+			if d != nil {
+				results = append(results, newDiagnosticInfo("", d))
+			}
+		}
+	}
+
+	apis, err := c.ListAPIs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	apiClient := c.clientFactory.NewAPIDiagnosticClient()
+	for _, api := range apis {
+		pager := apiClient.NewListByServicePager(c.resourceGroup, c.apimName, api.ID, nil)
+		for pager.More() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				return nil, wrapError(err, "failed to list diagnostics of API %s", api.ID)
+			}
+			for _, d := range page.Value {
+				if d != nil {
+					results = append(results, newDiagnosticInfo(api.ID, d))
+				}
+			}
+		}
+	}
+	return results, nil
+}
+
+// newDiagnosticInfo converts an SDK diagnostic contract.
+func newDiagnosticInfo(apiID string, d *armapimanagement.DiagnosticContract) DiagnosticInfo {
+	info := DiagnosticInfo{APIID: apiID, ID: deref(d.Name)}
+	if d.Properties != nil {
+		settings := *d.Properties
+		info.LoggerID = path.Base(deref(settings.LoggerID))
+		settings.LoggerID = nil
+		info.Settings = &settings
+	}
+	return info
+}
+
+// PutDiagnostic creates or replaces a diagnostic. Its logger must exist.
+func (c *Client) PutDiagnostic(ctx context.Context, d DiagnosticInfo) error {
+	var props armapimanagement.DiagnosticContractProperties
+	if d.Settings != nil {
+		props = *d.Settings
+	}
+	loggerID := c.serviceID() + "/loggers/" + d.LoggerID
+	props.LoggerID = &loggerID
+	params := armapimanagement.DiagnosticContract{Properties: &props}
+
+	var err error
+	if d.APIID == "" {
+		_, err = c.clientFactory.NewDiagnosticClient().CreateOrUpdate(ctx, c.resourceGroup, c.apimName, d.ID, params, nil)
+	} else {
+		_, err = c.clientFactory.NewAPIDiagnosticClient().CreateOrUpdate(ctx, c.resourceGroup, c.apimName, d.APIID, d.ID, params, nil)
+	}
+	if err != nil {
+		return wrapError(err, "failed to put diagnostic %s", d.ID)
+	}
+	return nil
+}
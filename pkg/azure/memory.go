@@ -0,0 +1,295 @@
+package azure
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a SubscriptionStore that keeps the subscriptions of a
+// simulated APIM instance in memory. It is safe for concurrent use.
+//
+// It follows the behavior of Azure where kura depends on it: new
+// subscriptions are "submitted" unless a state is given, keys that are not
+// given are generated, operations on a missing sid fail with an error
+// that matches ErrNotFound, and every change gives the subscription a new
+// ETag, which WithIfMatch checks. Changes are not passed to the function
+// installed with SetRecorder.
+type MemoryStore struct {
+	subscriptionID string
+	resourceGroup  string
+	apimName       string
+
+	mu      sync.Mutex
+	subs    map[string]SubscriptionInfo
+	version int // of the last change, for entity tags
+}
+
+// NewMemoryStore returns a store for a simulated instance that holds subs.
+// The subscriptions are copied.
+func NewMemoryStore(subscriptionID, resourceGroup, apimName string, subs []SubscriptionInfo) *MemoryStore {
+	m := &MemoryStore{
+		subscriptionID: subscriptionID,
+		resourceGroup:  resourceGroup,
+		apimName:       apimName,
+		subs:           make(map[string]SubscriptionInfo, len(subs)),
+	}
+	for _, sub := range subs {
+		m.put(sub)
+	}
+	return m
+}
+
+// put stores sub with a new entity tag. m.mu must be held, except during
+// construction.
+func (m *MemoryStore) put(sub SubscriptionInfo) SubscriptionInfo {
+	m.version++
+	sub.ETag = strconv.Quote(strconv.Itoa(m.version))
+	m.subs[sub.Name] = sub
+	return sub
+}
+
+// checkIfMatch returns an error if ctx has an entity tag from WithIfMatch
+// that sub, which exists if ok, does not have.
+func checkIfMatch(ctx context.Context, sid string, sub SubscriptionInfo, ok bool) error {
+	switch etag := ifMatch(ctx); {
+	case etag == "":
+		return nil
+	case ok && (etag == "*" || etag == sub.ETag):
+		return nil
+	}
+	return &conflictError{sid: sid}
+}
+
+// notFoundError is returned by MemoryStore for a sid that does not exist.
+type notFoundError struct {
+	sid string
+}
+
+func (e *notFoundError) Error() string {
+	return fmt.Sprintf("subscription %s not found", e.sid)
+}
+
+// Is makes the error match ErrNotFound.
+func (e *notFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// conflictError is returned by MemoryStore for a change whose entity tag
+// (see WithIfMatch) does not match.
+type conflictError struct {
+	sid string
+}
+
+func (e *conflictError) Error() string {
+	return fmt.Sprintf("subscription %s was changed since it was read", e.sid)
+}
+
+// Is makes the error match ErrConflict.
+func (e *conflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// SubscriptionID returns the Azure subscription ID of the simulated instance.
+func (m *MemoryStore) SubscriptionID() string {
+	return m.subscriptionID
+}
+
+// ListSubscriptions returns the subscriptions sorted by sid, including their
+// keys.
+func (m *MemoryStore) ListSubscriptions(ctx context.Context, productID string) ([]SubscriptionInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	suffix := "/products/" + strings.ToLower(productID)
+	var result []SubscriptionInfo
+	for _, sub := range m.subs {
+		if productID == "" || strings.HasSuffix(strings.ToLower(sub.Properties.Scope), suffix) {
+			result = append(result, sub)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// ListSubscriptionsWithoutKeys is like ListSubscriptions without the keys.
+func (m *MemoryStore) ListSubscriptionsWithoutKeys(ctx context.Context, productID string) ([]SubscriptionInfo, error) {
+	subs, err := m.ListSubscriptions(ctx, productID)
+	for i := range subs {
+		subs[i].Properties.PrimaryKey, subs[i].Properties.SecondaryKey = "", ""
+	}
+	return subs, err
+}
+
+// GetSubscription returns a single subscription including its keys.
+func (m *MemoryStore) GetSubscription(ctx context.Context, sid string) (*SubscriptionInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sub, ok := m.subs[sid]
+	if !ok {
+		return nil, &notFoundError{sid: sid}
+	}
+	return &sub, nil
+}
+
+// GetSubscriptionWithoutKeys is like GetSubscription without the keys.
+func (m *MemoryStore) GetSubscriptionWithoutKeys(ctx context.Context, sid string) (*SubscriptionInfo, error) {
+	sub, err := m.GetSubscription(ctx, sid)
+	if err != nil {
+		return nil, err
+	}
+	sub.Properties.PrimaryKey, sub.Properties.SecondaryKey = "", ""
+	return sub, nil
+}
+
+// SubscriptionExists reports whether a subscription with sid exists.
+func (m *MemoryStore) SubscriptionExists(ctx context.Context, sid string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.subs[sid]
+	return ok, nil
+}
+
+// CreateSubscription creates or replaces a subscription. Like Azure, a
+// replaced subscription keeps its keys, state, owner and tracing setting
+// unless opts set them.
+func (m *MemoryStore) CreateSubscription(ctx context.Context, sid, scope, displayName string, opts *CreateSubscriptionOptions) (*SubscriptionInfo, error) {
+	if opts == nil {
+		opts = &CreateSubscriptionOptions{}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sub, exists := m.subs[sid]
+	if err := checkIfMatch(ctx, sid, sub, exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		sub = SubscriptionInfo{
+			ID:   fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.ApiManagement/service/%s/subscriptions/%s", m.subscriptionID, m.resourceGroup, m.apimName, sid),
+			Name: sid,
+			Type: "Microsoft.ApiManagement/service/subscriptions",
+			Properties: SubscriptionInfoProperties{
+				State:        "submitted",
+				CreatedDate:  time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+				PrimaryKey:   newMemoryKey(),
+				SecondaryKey: newMemoryKey(),
+			},
+		}
+	}
+	sub.Properties.Scope = scope
+	sub.Properties.DisplayName = displayName
+	if opts.PrimaryKey != "" {
+		sub.Properties.PrimaryKey = opts.PrimaryKey
+	}
+	if opts.SecondaryKey != "" {
+		sub.Properties.SecondaryKey = opts.SecondaryKey
+	}
+	if opts.State != "" {
+		sub.Properties.State = opts.State
+	}
+	if opts.OwnerID != "" {
+		sub.Properties.OwnerID = opts.OwnerID
+	}
+	if opts.AllowTracing != nil {
+		sub.Properties.AllowTracing = *opts.AllowTracing
+	}
+	if opts.ExpirationDate != nil {
+		sub.Properties.ExpirationDate = opts.ExpirationDate.UTC().Format("2006-01-02T15:04:05Z")
+	}
+	sub = m.put(sub)
+	return &sub, nil
+}
+
+// update applies fn to an existing subscription and returns it without keys.
+func (m *MemoryStore) update(ctx context.Context, sid string, fn func(p *SubscriptionInfoProperties)) (*SubscriptionInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sub, ok := m.subs[sid]
+	if !ok {
+		return nil, &notFoundError{sid: sid}
+	}
+	if err := checkIfMatch(ctx, sid, sub, ok); err != nil {
+		return nil, err
+	}
+	fn(&sub.Properties)
+	sub = m.put(sub)
+	sub.Properties.PrimaryKey, sub.Properties.SecondaryKey = "", ""
+	return &sub, nil
+}
+
+// SetState changes the state of a subscription.
+func (m *MemoryStore) SetState(ctx context.Context, sid, state, comment string) (*SubscriptionInfo, error) {
+	return m.update(ctx, sid, func(p *SubscriptionInfoProperties) {
+		p.State = state
+		if comment != "" {
+			p.StateComment = comment
+		}
+	})
+}
+
+// SetExpirationDate sets the date on which a subscription expires.
+func (m *MemoryStore) SetExpirationDate(ctx context.Context, sid string, expiration time.Time) (*SubscriptionInfo, error) {
+	return m.update(ctx, sid, func(p *SubscriptionInfoProperties) {
+		p.ExpirationDate = expiration.UTC().Format("2006-01-02T15:04:05Z")
+	})
+}
+
+// ClearExpirationDate removes the expiration date of a subscription.
+func (m *MemoryStore) ClearExpirationDate(ctx context.Context, sid string) (*SubscriptionInfo, error) {
+	return m.update(ctx, sid, func(p *SubscriptionInfoProperties) {
+		p.ExpirationDate = ""
+	})
+}
+
+// DeleteSubscription deletes a subscription.
+func (m *MemoryStore) DeleteSubscription(ctx context.Context, sid string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sub, ok := m.subs[sid]
+	if !ok {
+		return &notFoundError{sid: sid}
+	}
+	if err := checkIfMatch(ctx, sid, sub, ok); err != nil {
+		return err
+	}
+	delete(m.subs, sid)
+	return nil
+}
+
+// RegenerateKeys replaces the selected keys of a subscription with new
+// random keys.
+func (m *MemoryStore) RegenerateKeys(ctx context.Context, sid string, primary, secondary bool) (primaryKey, secondaryKey string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sub, ok := m.subs[sid]
+	if !ok {
+		return "", "", &notFoundError{sid: sid}
+	}
+	if primary {
+		sub.Properties.PrimaryKey = newMemoryKey()
+	}
+	if secondary {
+		sub.Properties.SecondaryKey = newMemoryKey()
+	}
+	sub = m.put(sub)
+	return sub.Properties.PrimaryKey, sub.Properties.SecondaryKey, nil
+}
+
+// newMemoryKey returns a random key in the format of APIM keys.
+func newMemoryKey() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
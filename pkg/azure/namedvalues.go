@@ -0,0 +1,91 @@
+package azure
+
+import (
+	"context"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/apimanagement/armapimanagement"
+)
+
+// NamedValueInfo holds a named value of an APIM instance, the properties that
+// policies reference as {{displayName}}.
+type NamedValueInfo struct {
+	// ID is the named value ID, not the full resource ID.
+	ID          string   `json:"id"`
+	DisplayName string   `json:"displayName"`
+	Secret      bool     `json:"secret"`
+	Tags        []string `json:"tags,omitempty"`
+	// Value is the value of the named value, including that of secret ones.
+	// It is empty for named values that reference Key Vault.
+	Value    string             `json:"value,omitempty"`
+	KeyVault *KeyVaultReference `json:"keyVault,omitempty"`
+}
+
+// ListNamedValues returns the named values of the APIM instance. The values
+// of secrets are fetched with one extra request each.
+func (c *Client) ListNamedValues(ctx context.Context) ([]NamedValueInfo, error) {
+	nvClient := c.clientFactory.NewNamedValueClient()
+	pager := nvClient.NewListByServicePager(c.resourceGroup, c.apimName, nil)
+
+	var results []NamedValueInfo
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, wrapError(err, "failed to list named values")
+		}
+		for _, nv := range page.Value {
+			if nv == nil {
+				continue
+			}
+			info := NamedValueInfo{ID: deref(nv.Name)}
+			if props := nv.Properties; props != nil {
+				info.DisplayName = deref(props.DisplayName)
+				info.Value = deref(props.Value)
+				if props.Secret != nil {
+					info.Secret = *props.Secret
+				}
+				for _, tag := range props.Tags {
+					if tag != nil {
+						info.Tags = append(info.Tags, *tag)
+					}
+				}
+				info.KeyVault = newKeyVaultReference(props.KeyVault)
+			}
+			if info.Secret && info.KeyVault == nil {
+				secret, err := nvClient.ListValue(ctx, c.resourceGroup, c.apimName, info.ID, nil)
+				if err != nil {
+					return nil, wrapError(err, "failed to get the value of named value %s", info.ID)
+				}
+				info.Value = deref(secret.Value)
+			}
+			results = append(results, info)
+		}
+	}
+	return results, nil
+}
+
+// PutNamedValue creates or replaces a named value and waits until the
+// instance applied it, which can take a while for Key Vault references.
+func (c *Client) PutNamedValue(ctx context.Context, nv NamedValueInfo) error {
+	props := &armapimanagement.NamedValueCreateContractProperties{
+		DisplayName: &nv.DisplayName,
+		Secret:      &nv.Secret,
+	}
+	if nv.KeyVault != nil {
+		props.KeyVault = nv.KeyVault.createProperties()
+	} else {
+		props.Value = &nv.Value
+	}
+	for i := range nv.Tags {
+		props.Tags = append(props.Tags, &nv.Tags[i])
+	}
+
+	poller, err := c.clientFactory.NewNamedValueClient().BeginCreateOrUpdate(ctx, c.resourceGroup, c.apimName, nv.ID,
+		armapimanagement.NamedValueCreateContract{Properties: props}, nil)
+	if err != nil {
+		return wrapError(err, "failed to put named value %s", nv.ID)
+	}
+	if _, err := poller.PollUntilDone(ctx, nil); err != nil {
+		return wrapError(err, "failed to put named value %s", nv.ID)
+	}
+	return nil
+}
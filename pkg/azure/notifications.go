@@ -0,0 +1,72 @@
+package azure
+
+import (
+	"context"
+	"path"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/apimanagement/armapimanagement"
+)
+
+// NotificationInfo holds the recipients of a notification APIM sends to its
+// publishers, such as the one asking to approve a subscription request.
+type NotificationInfo struct {
+	// ID is the notification name, such as "RequestPublisherNotificationMessage".
+	ID     string   `json:"id"`
+	Title  string   `json:"title,omitempty"`
+	Emails []string `json:"emails,omitempty"`
+	// Users are the IDs of the users that receive the notification.
+	Users []string `json:"users,omitempty"`
+}
+
+// ListNotifications returns the notifications of the APIM instance that have
+// recipients.
+func (c *Client) ListNotifications(ctx context.Context) ([]NotificationInfo, error) {
+	pager := c.clientFactory.NewNotificationClient().NewListByServicePager(c.resourceGroup, c.apimName, nil)
+
+	var results []NotificationInfo
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, wrapError(err, "failed to list notifications")
+		}
+		for _, n := range page.Value {
+			if n == nil || n.Properties == nil || n.Properties.Recipients == nil {
+				continue
+			}
+			info := NotificationInfo{ID: deref(n.Name), Title: deref(n.Properties.Title)}
+			for _, email := range n.Properties.Recipients.Emails {
+				if email != nil {
+					info.Emails = append(info.Emails, *email)
+				}
+			}
+			for _, user := range n.Properties.Recipients.Users {
+				if user != nil {
+					info.Users = append(info.Users, path.Base(*user))
+				}
+			}
+			if len(info.Emails) > 0 || len(info.Users) > 0 {
+				results = append(results, info)
+			}
+		}
+	}
+	return results, nil
+}
+
+// PutNotification adds the recipients of n to the notification. The users
+// must exist. Recipients the notification has beyond those are kept.
+func (c *Client) PutNotification(ctx context.Context, n NotificationInfo) error {
+	name := armapimanagement.NotificationName(n.ID)
+	emails := c.clientFactory.NewNotificationRecipientEmailClient()
+	for _, email := range n.Emails {
+		if _, err := emails.CreateOrUpdate(ctx, c.resourceGroup, c.apimName, name, email, nil); err != nil {
+			return wrapError(err, "failed to add %s to notification %s", email, n.ID)
+		}
+	}
+	users := c.clientFactory.NewNotificationRecipientUserClient()
+	for _, userID := range n.Users {
+		if _, err := users.CreateOrUpdate(ctx, c.resourceGroup, c.apimName, name, userID, nil); err != nil {
+			return wrapError(err, "failed to add user %s to notification %s", userID, n.ID)
+		}
+	}
+	return nil
+}
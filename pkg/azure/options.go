@@ -0,0 +1,162 @@
+package azure
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/apimanagement/armapimanagement"
+)
+
+// RetryOptions configures how a Client retries requests that failed with a
+// network error or with status 408, 429, 500, 502, 503 or 504. Before each
+// retry it waits for the delay requested by the Retry-After header of the
+// response or, without one, for an exponentially growing delay with random
+// jitter.
+type RetryOptions struct {
+	// MaxRetries is the number of retries after the first attempt. Zero
+	// means 3, a negative value disables retries.
+	MaxRetries int
+	// RetryDelay is the delay before the first retry without Retry-After;
+	// it doubles with every further retry. Zero means 800ms.
+	RetryDelay time.Duration
+	// MaxRetryDelay caps the delay before a retry, including the delay
+	// requested by Retry-After. Zero means 60s.
+	MaxRetryDelay time.Duration
+	// TryTimeout limits each attempt of a request, so that a request that
+	// hangs is retried instead of blocking forever. Zero means no limit;
+	// the context of the call limits all attempts together.
+	TryTimeout time.Duration
+}
+
+// DefaultAPIVersion is the version of the API Management REST API that the
+// Azure SDK module kura is built with requests, unless it is overridden with
+// SetAPIVersion or SetDefaultAPIVersion.
+const DefaultAPIVersion = "2021-08-01"
+
+// apiVersionPattern matches API Management REST API versions such as
+// "2021-08-01" and "2023-03-01-preview".
+var apiVersionPattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}(-preview)?$`)
+
+var (
+	optionsMu         sync.RWMutex
+	defaultRetry      RetryOptions
+	defaultAPIVersion string
+)
+
+// SetDefaultRetryOptions sets the retry options of the clients created
+// afterwards. Existing clients keep theirs; use Client.SetRetryOptions to
+// change them.
+func SetDefaultRetryOptions(opts RetryOptions) {
+	optionsMu.Lock()
+	defer optionsMu.Unlock()
+	defaultRetry = opts
+}
+
+func currentRetryOptions() RetryOptions {
+	optionsMu.RLock()
+	defer optionsMu.RUnlock()
+	return defaultRetry
+}
+
+// SetDefaultAPIVersion sets the API Management REST API version of the
+// clients created afterwards, like Client.SetAPIVersion.
+func SetDefaultAPIVersion(version string) error {
+	if err := checkAPIVersion(version); err != nil {
+		return err
+	}
+	optionsMu.Lock()
+	defer optionsMu.Unlock()
+	defaultAPIVersion = version
+	return nil
+}
+
+func currentAPIVersion() string {
+	optionsMu.RLock()
+	defer optionsMu.RUnlock()
+	return defaultAPIVersion
+}
+
+// checkAPIVersion returns an error if version is neither empty nor an API
+// version.
+func checkAPIVersion(version string) error {
+	if version != "" && !apiVersionPattern.MatchString(version) {
+		return fmt.Errorf("invalid API version %q: must be a date such as %s, optionally with -preview", version, DefaultAPIVersion)
+	}
+	return nil
+}
+
+// SetAPIVersion makes the client request the given version of the API
+// Management REST API instead of DefaultAPIVersion, such as an older one for
+// instances in sovereign clouds that do not support it yet. The requests and
+// responses keep the shape of DefaultAPIVersion, so versions with a
+// different shape may lose or reject fields. An empty version restores the
+// default.
+func (c *Client) SetAPIVersion(version string) error {
+	if err := checkAPIVersion(version); err != nil {
+		return err
+	}
+	c.apiVersion = version
+	return c.newClientFactory()
+}
+
+// APIVersion returns the API Management REST API version the client requests.
+func (c *Client) APIVersion() string {
+	if c.apiVersion == "" {
+		return DefaultAPIVersion
+	}
+	return c.apiVersion
+}
+
+// SetRetryOptions changes how the client retries failed requests.
+func (c *Client) SetRetryOptions(opts RetryOptions) error {
+	c.retry = opts
+	return c.newClientFactory()
+}
+
+// SetConcurrency makes ListSubscriptions fetch the keys of up to n
+// subscriptions at a time, one request each. Values below one mean one, the
+// default. Combine it with SetMaxRPS to stay below the throttling limits.
+func (c *Client) SetConcurrency(n int) {
+	c.concurrency = n
+}
+
+// clientOptions returns the options of the SDK clients of c.
+func (c *Client) clientOptions() *arm.ClientOptions {
+	opts := &arm.ClientOptions{
+		ClientOptions: policy.ClientOptions{
+			Retry: policy.RetryOptions{
+				MaxRetries:    int32(c.retry.MaxRetries),
+				RetryDelay:    c.retry.RetryDelay,
+				MaxRetryDelay: c.retry.MaxRetryDelay,
+				TryTimeout:    c.retry.TryTimeout,
+			},
+			Transport: c.transport,
+		},
+	}
+	if c.endpoint != "" {
+		opts.Cloud = endpointCloud(c.endpoint)
+		opts.InsecureAllowCredentialWithHTTP = true
+	}
+	if c.rateLimit != nil {
+		// Per retry, so that retries count against the limit as well.
+		opts.PerRetryPolicies = []policy.Policy{c.rateLimit}
+	}
+	return opts
+}
+
+// newClientFactory (re)creates the SDK client factory from the settings of c.
+func (c *Client) newClientFactory() error {
+	opts := c.clientOptions()
+	// Only for API Management; armGet requests other services.
+	opts.APIVersion = c.apiVersion
+	clientFactory, err := armapimanagement.NewClientFactory(c.subscriptionID, c.credential, opts)
+	if err != nil {
+		return fmt.Errorf("failed to create Azure API Management client factory: %w", err)
+	}
+	c.clientFactory = clientFactory
+	return nil
+}
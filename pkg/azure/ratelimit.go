@@ -0,0 +1,91 @@
+package azure
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// rateLimitPolicy is a pipeline policy that limits outgoing requests with a
+// token bucket, across all goroutines and clients sharing the policy. The
+// bucket holds up to one second worth of requests, so short bursts are sent
+// at once while the average rate stays below the limit.
+type rateLimitPolicy struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimitPolicy(maxRPS float64) *rateLimitPolicy {
+	burst := math.Max(1, math.Floor(maxRPS))
+	return &rateLimitPolicy{rate: maxRPS, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// Do implements policy.Policy.
+func (p *rateLimitPolicy) Do(req *policy.Request) (*http.Response, error) {
+	if err := p.wait(req.Raw().Context()); err != nil {
+		return nil, err
+	}
+	return req.Next()
+}
+
+// wait takes a token from the bucket, blocking until one is available or ctx
+// is done.
+func (p *rateLimitPolicy) wait(ctx context.Context) error {
+	p.mu.Lock()
+	now := time.Now()
+	p.tokens = math.Min(p.burst, p.tokens+now.Sub(p.last).Seconds()*p.rate)
+	p.last = now
+	// Taking the token before it is available reserves it, so waiting
+	// callers are served in order.
+	p.tokens--
+	delay := time.Duration(-p.tokens / p.rate * float64(time.Second))
+	p.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// Return the reserved token for the callers after us.
+		p.mu.Lock()
+		p.tokens++
+		p.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+var (
+	rateLimitMu      sync.RWMutex
+	defaultRateLimit *rateLimitPolicy
+)
+
+// SetDefaultMaxRPS limits the clients created afterwards to at most maxRPS
+// Azure API requests per second in total, so that several clients, such as
+// the source and target of a copy, share one limit. A value of zero or less
+// removes the limit. Existing clients are not affected; use Client.SetMaxRPS
+// to give a client a limit of its own.
+func SetDefaultMaxRPS(maxRPS float64) {
+	rateLimitMu.Lock()
+	defer rateLimitMu.Unlock()
+	defaultRateLimit = nil
+	if maxRPS > 0 {
+		defaultRateLimit = newRateLimitPolicy(maxRPS)
+	}
+}
+
+func currentRateLimit() *rateLimitPolicy {
+	rateLimitMu.RLock()
+	defer rateLimitMu.RUnlock()
+	return defaultRateLimit
+}
@@ -0,0 +1,53 @@
+package azure
+
+import (
+	"context"
+	"time"
+)
+
+// SubscriptionStore is the set of operations on the subscriptions of an APIM
+// instance that kura's commands are built on. Client implements it against
+// Azure; MemoryStore implements it in memory, for tests and dry runs. Other
+// backends, such as other API gateways, can be plugged in by implementing it.
+//
+// Methods that return secret keys say so; all others leave them empty unless
+// noted otherwise.
+type SubscriptionStore interface {
+	// SubscriptionID returns the Azure subscription ID of the instance,
+	// used to build the scopes of its subscriptions.
+	SubscriptionID() string
+
+	// ListSubscriptions returns the subscriptions including their secret
+	// keys. If productID is non-empty, only subscriptions scoped to that
+	// product are returned.
+	ListSubscriptions(ctx context.Context, productID string) ([]SubscriptionInfo, error)
+	// ListSubscriptionsWithoutKeys is like ListSubscriptions without the keys.
+	ListSubscriptionsWithoutKeys(ctx context.Context, productID string) ([]SubscriptionInfo, error)
+	// GetSubscription returns a single subscription including its secret keys.
+	GetSubscription(ctx context.Context, sid string) (*SubscriptionInfo, error)
+	// GetSubscriptionWithoutKeys is like GetSubscription without the keys.
+	GetSubscriptionWithoutKeys(ctx context.Context, sid string) (*SubscriptionInfo, error)
+	// SubscriptionExists reports whether a subscription with sid exists.
+	SubscriptionExists(ctx context.Context, sid string) (bool, error)
+
+	// CreateSubscription creates or replaces a subscription and returns it
+	// including its secret keys.
+	CreateSubscription(ctx context.Context, sid, scope, displayName string, opts *CreateSubscriptionOptions) (*SubscriptionInfo, error)
+	// SetState changes the state of a subscription, recording comment as
+	// the state comment unless it is empty.
+	SetState(ctx context.Context, sid, state, comment string) (*SubscriptionInfo, error)
+	// SetExpirationDate sets the date on which a subscription expires.
+	SetExpirationDate(ctx context.Context, sid string, expiration time.Time) (*SubscriptionInfo, error)
+	// ClearExpirationDate removes the expiration date of a subscription.
+	ClearExpirationDate(ctx context.Context, sid string) (*SubscriptionInfo, error)
+	// DeleteSubscription deletes a subscription.
+	DeleteSubscription(ctx context.Context, sid string) error
+	// RegenerateKeys regenerates the selected secret keys of a subscription
+	// and returns both keys as they are afterwards.
+	RegenerateKeys(ctx context.Context, sid string, primary, secondary bool) (primaryKey, secondaryKey string, err error)
+}
+
+var (
+	_ SubscriptionStore = (*Client)(nil)
+	_ SubscriptionStore = (*MemoryStore)(nil)
+)
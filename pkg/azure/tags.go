@@ -0,0 +1,122 @@
+package azure
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/apimanagement/armapimanagement"
+)
+
+// TagInfo holds a tag of an APIM instance and the APIs, products and
+// operations it is assigned to. The developer portal groups and filters APIs
+// and products by their tags.
+type TagInfo struct {
+	// ID is the tag ID, not the full resource ID.
+	ID          string   `json:"id"`
+	DisplayName string   `json:"displayName"`
+	APIs        []string `json:"apis,omitempty"`
+	Products    []string `json:"products,omitempty"`
+	// Operations are the operations the tag is assigned to, as
+	// "<api id>/<operation id>".
+	Operations []string `json:"operations,omitempty"`
+}
+
+// ListTags returns the tags of the APIM instance with their assignments.
+func (c *Client) ListTags(ctx context.Context) ([]TagInfo, error) {
+	pager := c.clientFactory.NewTagClient().NewListByServicePager(c.resourceGroup, c.apimName, nil)
+
+	var results []TagInfo
+	byID := make(map[string]int)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, wrapError(err, "failed to list tags")
+		}
+		for _, tag := range page.Value {
+			if tag == nil {
+				continue
+			}
+			info := TagInfo{ID: deref(tag.Name)}
+			if tag.Properties != nil {
+				info.DisplayName = deref(tag.Properties.DisplayName)
+			}
+			byID[strings.ToLower(info.ID)] = len(results)
+			results = append(results, info)
+		}
+	}
+
+	// The tag resources list every assignment of every tag in one go, with
+	// IDs relative to the instance, such as /apis/echo-api/operations/get.
+	resources := c.clientFactory.NewTagResourceClient().NewListByServicePager(c.resourceGroup, c.apimName, nil)
+	for resources.More() {
+		page, err := resources.NextPage(ctx)
+		if err != nil {
+			return nil, wrapError(err, "failed to list tag assignments")
+		}
+		for _, res := range page.Value {
+			if res == nil || res.Tag == nil {
+				continue
+			}
+			i, ok := byID[strings.ToLower(idSegment(deref(res.Tag.ID), "tags"))]
+			if !ok {
+				continue
+			}
+			tag := &results[i]
+			switch {
+			case res.Operation != nil:
+				id := deref(res.Operation.ID)
+				tag.Operations = append(tag.Operations, idSegment(id, "apis")+"/"+idSegment(id, "operations"))
+			case res.API != nil:
+				tag.APIs = append(tag.APIs, idSegment(deref(res.API.ID), "apis"))
+			case res.Product != nil:
+				tag.Products = append(tag.Products, idSegment(deref(res.Product.ID), "products"))
+			}
+		}
+	}
+	return results, nil
+}
+
+// idSegment returns the segment following collection in a resource ID, such
+// as "echo-api" for "apis" in "/apis/echo-api/operations/get".
+func idSegment(id, collection string) string {
+	segments := strings.Split(id, "/")
+	for i := 0; i < len(segments)-1; i++ {
+		if strings.EqualFold(segments[i], collection) {
+			return segments[i+1]
+		}
+	}
+	return ""
+}
+
+// PutTag creates or replaces a tag and assigns it to its APIs, products and
+// operations, which must exist. Assignments the tag has beyond those are kept.
+// It attempts every assignment and returns the errors of those that failed.
+func (c *Client) PutTag(ctx context.Context, tag TagInfo) error {
+	tags := c.clientFactory.NewTagClient()
+	_, err := tags.CreateOrUpdate(ctx, c.resourceGroup, c.apimName, tag.ID, armapimanagement.TagCreateUpdateParameters{
+		Properties: &armapimanagement.TagContractProperties{DisplayName: &tag.DisplayName},
+	}, nil)
+	if err != nil {
+		return wrapError(err, "failed to put tag %s", tag.ID)
+	}
+
+	var errs []error
+	for _, apiID := range tag.APIs {
+		if _, err := tags.AssignToAPI(ctx, c.resourceGroup, c.apimName, apiID, tag.ID, nil); err != nil {
+			errs = append(errs, wrapError(err, "failed to assign tag %s to API %s", tag.ID, apiID))
+		}
+	}
+	for _, productID := range tag.Products {
+		if _, err := tags.AssignToProduct(ctx, c.resourceGroup, c.apimName, productID, tag.ID, nil); err != nil {
+			errs = append(errs, wrapError(err, "failed to assign tag %s to product %s", tag.ID, productID))
+		}
+	}
+	for _, operation := range tag.Operations {
+		apiID, operationID, _ := strings.Cut(operation, "/")
+		if _, err := tags.AssignToOperation(ctx, c.resourceGroup, c.apimName, apiID, operationID, tag.ID, nil); err != nil {
+			errs = append(errs, wrapError(err, "failed to assign tag %s to operation %s", tag.ID, operation))
+		}
+	}
+	return errors.Join(errs...)
+}
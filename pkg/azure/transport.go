@@ -0,0 +1,78 @@
+package azure
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// TransportOptions configures how requests reach Azure in networks that only
+// allow them through a proxy, such as a TLS-intercepting one.
+type TransportOptions struct {
+	// ProxyURL is the proxy all requests are sent through, such as
+	// "http://proxy.example.com:3128". If empty, the proxy is taken from
+	// the HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables.
+	ProxyURL string
+	// CAFile is a PEM file of certificate authorities that are trusted in
+	// addition to the system's, such as the one of an intercepting proxy.
+	CAFile string
+}
+
+// NewHTTPClient returns an HTTP client that sends requests as configured by
+// opts. It can be passed to SetDefaultTransport, and serves other requests,
+// such as webhook notifications, the same way.
+func NewHTTPClient(opts TransportOptions) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if opts.ProxyURL != "" {
+		proxy, err := url.Parse(opts.ProxyURL)
+		if err != nil || proxy.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", opts.ProxyURL)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates found in CA bundle %s", opts.CAFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+var (
+	transportMu      sync.RWMutex
+	defaultTransport policy.Transporter
+)
+
+// SetDefaultTransport makes the clients created afterwards send their requests
+// with t, such as an *http.Client returned by NewHTTPClient. A nil t restores
+// the transport of the Azure SDK, which honors the proxy environment
+// variables but trusts only the system's certificate authorities.
+func SetDefaultTransport(t policy.Transporter) {
+	transportMu.Lock()
+	defer transportMu.Unlock()
+	defaultTransport = t
+}
+
+func currentTransport() policy.Transporter {
+	transportMu.RLock()
+	defer transportMu.RUnlock()
+	return defaultTransport
+}
@@ -0,0 +1,115 @@
+package azure
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/apimanagement/armapimanagement"
+)
+
+// APIVersionSetInfo holds an API version set of an APIM instance, which
+// groups the versions of an API, and the APIs in it.
+type APIVersionSetInfo struct {
+	// ID is the version set ID, not the full resource ID.
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+	Description string `json:"description,omitempty"`
+	// VersioningScheme is where requests carry the version: Segment,
+	// Header or Query.
+	VersioningScheme  string         `json:"versioningScheme"`
+	VersionHeaderName string         `json:"versionHeaderName,omitempty"`
+	VersionQueryName  string         `json:"versionQueryName,omitempty"`
+	APIs              []VersionedAPI `json:"apis,omitempty"`
+}
+
+// VersionedAPI is an API in a version set.
+type VersionedAPI struct {
+	ID         string `json:"id"`
+	APIVersion string `json:"apiVersion"`
+}
+
+// ListAPIVersionSets returns the API version sets of the APIM instance with
+// the APIs in each of them.
+func (c *Client) ListAPIVersionSets(ctx context.Context) ([]APIVersionSetInfo, error) {
+	pager := c.clientFactory.NewAPIVersionSetClient().NewListByServicePager(c.resourceGroup, c.apimName, nil)
+
+	var results []APIVersionSetInfo
+	byID := make(map[string]int)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, wrapError(err, "failed to list API version sets")
+		}
+		for _, set := range page.Value {
+			if set == nil {
+				continue
+			}
+			info := APIVersionSetInfo{ID: deref(set.Name)}
+			if props := set.Properties; props != nil {
+				info.DisplayName = deref(props.DisplayName)
+				info.Description = deref(props.Description)
+				info.VersionHeaderName = deref(props.VersionHeaderName)
+				info.VersionQueryName = deref(props.VersionQueryName)
+				if props.VersioningScheme != nil {
+					info.VersioningScheme = string(*props.VersioningScheme)
+				}
+			}
+			byID[strings.ToLower(info.ID)] = len(results)
+			results = append(results, info)
+		}
+	}
+
+	apis, err := c.ListAPIDetails(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, api := range apis {
+		if api.APIVersionSetID == "" {
+			continue
+		}
+		if i, ok := byID[strings.ToLower(path.Base(api.APIVersionSetID))]; ok {
+			results[i].APIs = append(results[i].APIs, VersionedAPI{ID: api.ID, APIVersion: api.APIVersion})
+		}
+	}
+	return results, nil
+}
+
+// PutAPIVersionSet creates or replaces an API version set and adds its APIs,
+// which must exist, to it with their versions.
+func (c *Client) PutAPIVersionSet(ctx context.Context, set APIVersionSetInfo) error {
+	scheme := armapimanagement.VersioningScheme(set.VersioningScheme)
+	props := &armapimanagement.APIVersionSetContractProperties{
+		DisplayName:      &set.DisplayName,
+		VersioningScheme: &scheme,
+	}
+	if set.Description != "" {
+		props.Description = &set.Description
+	}
+	if set.VersionHeaderName != "" {
+		props.VersionHeaderName = &set.VersionHeaderName
+	}
+	if set.VersionQueryName != "" {
+		props.VersionQueryName = &set.VersionQueryName
+	}
+	_, err := c.clientFactory.NewAPIVersionSetClient().CreateOrUpdate(ctx, c.resourceGroup, c.apimName, set.ID,
+		armapimanagement.APIVersionSetContract{Properties: props}, nil)
+	if err != nil {
+		return wrapError(err, "failed to put API version set %s", set.ID)
+	}
+
+	setID := c.serviceID() + "/apiVersionSets/" + set.ID
+	apis := c.clientFactory.NewAPIClient()
+	for _, api := range set.APIs {
+		_, err := apis.Update(ctx, c.resourceGroup, c.apimName, api.ID, "*", armapimanagement.APIUpdateContract{
+			Properties: &armapimanagement.APIContractUpdateProperties{
+				APIVersionSetID: &setID,
+				APIVersion:      &api.APIVersion,
+			},
+		}, nil)
+		if err != nil {
+			return wrapError(err, "failed to add API %s to version set %s", api.ID, set.ID)
+		}
+	}
+	return nil
+}
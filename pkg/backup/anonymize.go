@@ -0,0 +1,102 @@
+package backup
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+)
+
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// NewSalt returns a random hex-encoded salt for use with Anonymize.
+func NewSalt() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Anonymize returns a copy of subs that is safe to share outside the organization.
+// Owner IDs and keys are replaced with salted hashes, email addresses are removed
+// from display names, and state comments and resolved owner details are dropped.
+// Sids and the Azure subscription, resource group and instance in resource IDs
+// and scopes are hashed as well; the master sid is kept. The same input value
+// always maps to the same hash for a given salt, so records can still be
+// correlated.
+func Anonymize(subs []azure.SubscriptionInfo, salt string) []azure.SubscriptionInfo {
+	out := make([]azure.SubscriptionInfo, len(subs))
+	for i, sub := range subs {
+		sub.ID = anonymizeResourceID(salt, sub.ID)
+		sub.Name = anonymizeName(salt, sub.Name)
+		props := sub.Properties
+		props.Scope = anonymizeResourceID(salt, props.Scope)
+		if props.OwnerID != "" {
+			props.OwnerID = "/users/" + saltedHash(salt, props.OwnerID)
+		}
+		if props.PrimaryKey != "" {
+			props.PrimaryKey = saltedHash(salt, props.PrimaryKey)
+		}
+		if props.SecondaryKey != "" {
+			props.SecondaryKey = saltedHash(salt, props.SecondaryKey)
+		}
+		props.OwnerName = ""
+		props.OwnerEmail = ""
+		props.DisplayName = emailPattern.ReplaceAllString(props.DisplayName, "<redacted>")
+		props.StateComment = ""
+		sub.Properties = props
+		out[i] = sub
+	}
+	return out
+}
+
+// AnonymizeMetadata returns the metadata of a file of subscriptions anonymized
+// with salt: the Azure subscription, resource group and instance are hashed
+// like in resource IDs, and the file is marked as Anonymized.
+func AnonymizeMetadata(m Metadata, salt string) Metadata {
+	m.SubscriptionID = anonymizeName(salt, m.SubscriptionID)
+	m.ResourceGroup = anonymizeName(salt, m.ResourceGroup)
+	m.APIMName = anonymizeName(salt, m.APIMName)
+	m.Anonymized = true
+	return m
+}
+
+// anonymizedSegments are the lowercased resource ID segments whose values
+// identify the Azure subscription, the resource group, the instance or the
+// APIM subscription. Products and APIs are kept.
+var anonymizedSegments = map[string]bool{
+	"subscriptions":  true,
+	"resourcegroups": true,
+	"service":        true,
+}
+
+// anonymizeResourceID hashes the values of the anonymizedSegments of id.
+func anonymizeResourceID(salt, id string) string {
+	parts := strings.Split(id, "/")
+	for i := 1; i < len(parts); i++ {
+		if anonymizedSegments[strings.ToLower(parts[i-1])] {
+			parts[i] = anonymizeName(salt, parts[i])
+			i++ // the hashed value is no segment name
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// anonymizeName hashes name, except for empty names and the master sid, which
+// every instance has.
+func anonymizeName(salt, name string) string {
+	if name == "" || name == "master" {
+		return name
+	}
+	return saltedHash(salt, name)
+}
+
+func saltedHash(salt, value string) string {
+	sum := sha256.Sum256([]byte(salt + value))
+	return hex.EncodeToString(sum[:])
+}
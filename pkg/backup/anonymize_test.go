@@ -0,0 +1,53 @@
+package backup
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+)
+
+func TestAnonymizeHidesInstance(t *testing.T) {
+	const instance = "/subscriptions/0000-1111/resourceGroups/rg-prod/providers/Microsoft.ApiManagement/service/apim-contoso"
+	subs := []azure.SubscriptionInfo{
+		{
+			ID:   instance + "/subscriptions/partner-acme",
+			Name: "partner-acme",
+			Properties: azure.SubscriptionInfoProperties{
+				Scope:       instance + "/products/starter",
+				DisplayName: "Acme",
+				PrimaryKey:  "k3y-acme",
+			},
+		},
+		{
+			ID:         instance + "/subscriptions/master",
+			Name:       "master",
+			Properties: azure.SubscriptionInfoProperties{Scope: instance + "/"},
+		},
+	}
+	meta := AnonymizeMetadata(Metadata{SubscriptionID: "0000-1111", ResourceGroup: "rg-prod", APIMName: "apim-contoso", ProductID: "starter"}, "salt")
+	data, err := json.Marshal(File{Metadata: meta, Subscriptions: Anonymize(subs, "salt")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"0000-1111", "rg-prod", "apim-contoso", "partner-acme", "k3y-acme"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("anonymized file contains %q: %s", secret, data)
+		}
+	}
+	if !meta.Anonymized {
+		t.Error("AnonymizeMetadata did not mark the file as anonymized")
+	}
+
+	anon := Anonymize(subs, "salt")
+	if got := anon[0].ID; !strings.HasSuffix(got, "/subscriptions/"+anon[0].Name) {
+		t.Errorf("anonymized ID %s does not end with the anonymized sid %s", got, anon[0].Name)
+	}
+	if got := anon[0].Properties.Scope; !strings.HasSuffix(got, "/providers/Microsoft.ApiManagement/service/"+saltedHash("salt", "apim-contoso")+"/products/starter") {
+		t.Errorf("anonymized scope = %s, want the product kept", got)
+	}
+	if anon[1].Name != "master" || !strings.HasSuffix(anon[1].ID, "/subscriptions/master") {
+		t.Errorf("master subscription = %s (%s), want the master sid kept", anon[1].Name, anon[1].ID)
+	}
+}
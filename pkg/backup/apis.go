@@ -0,0 +1,57 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+)
+
+// APIExportDir returns the directory holding the exported API definitions of
+// the given instance.
+func APIExportDir(resourceGroup, serviceName string) string {
+	return filepath.Join(ConfigDir(resourceGroup, serviceName), "apis")
+}
+
+// APIDefinitionFile returns the file name of an API definition exported in
+// format, one of the azure.APIFormat constants.
+func APIDefinitionFile(format string) string {
+	switch format {
+	case azure.APIFormatOpenAPI:
+		return "openapi.yaml"
+	case azure.APIFormatOpenAPIJSON:
+		return "openapi.json"
+	case azure.APIFormatSwagger:
+		return "swagger.json"
+	}
+	return format + ".xml"
+}
+
+// joinLocation joins a directory, which may also be the URL of a registered
+// Storage, and slash-separated elements.
+func joinLocation(dir string, elem ...string) string {
+	if IsRemote(dir) {
+		return strings.TrimSuffix(dir, "/") + "/" + strings.Join(elem, "/")
+	}
+	return filepath.Join(append([]string{dir}, elem...)...)
+}
+
+// SaveAPIExport writes the settings of api as api.json and its definition in
+// format to the directory <dir>/<api id>, and returns the path of the
+// definition. dir may also be the URL of a registered Storage.
+func SaveAPIExport(dir string, api azure.APIDetails, format string, definition []byte) (string, error) {
+	meta, err := json.MarshalIndent(api, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal API %s to JSON: %w", api.ID, err)
+	}
+	if err := writeLocation(joinLocation(dir, api.ID, "api.json"), meta); err != nil {
+		return "", err
+	}
+	path := joinLocation(dir, api.ID, APIDefinitionFile(format))
+	if err := writeLocation(path, definition); err != nil {
+		return "", err
+	}
+	return path, nil
+}
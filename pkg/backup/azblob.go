@@ -0,0 +1,133 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+)
+
+// azureBlobAPIVersion is the version of the Blob Storage REST API requested by
+// AzureBlobStorage.
+const azureBlobAPIVersion = "2021-08-06"
+
+func init() {
+	RegisterStorage("azblob", &AzureBlobStorage{})
+}
+
+// AzureBlobStorage stores backup files as block blobs in Azure Blob Storage.
+// Its locations are URLs of the form
+//
+//	azblob://<storage account>/<container>/<blob path>
+//
+// The caller needs the Storage Blob Data Contributor role on the container,
+// or Storage Blob Data Reader for reading only.
+type AzureBlobStorage struct {
+	// Credential authenticates the requests. If nil, an
+	// azidentity.DefaultAzureCredential is used, which includes the Azure
+	// CLI and managed identities.
+	Credential azcore.TokenCredential
+
+	once     sync.Once
+	pipeline runtime.Pipeline
+	err      error
+}
+
+// init creates the HTTP pipeline of s on first use.
+func (s *AzureBlobStorage) init() error {
+	s.once.Do(func() {
+		cred := s.Credential
+		if cred == nil {
+			cred, s.err = azidentity.NewDefaultAzureCredential(nil)
+			if s.err != nil {
+				s.err = fmt.Errorf("failed to authenticate for Azure Blob Storage: %w", s.err)
+				return
+			}
+		}
+		auth := runtime.NewBearerTokenPolicy(cred, []string{"https://storage.azure.com/.default"}, nil)
+		// http.DefaultClient honors the --proxy and --ca-bundle flags of kura.
+		s.pipeline = runtime.NewPipeline("kura", "", runtime.PipelineOptions{PerRetry: []policy.Policy{auth}},
+			&policy.ClientOptions{Transport: http.DefaultClient})
+	})
+	return s.err
+}
+
+// blobURL converts an azblob:// location into the HTTPS URL of the blob.
+func blobURL(location string) (string, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return "", fmt.Errorf("invalid blob location %q: %w", location, err)
+	}
+	container, blob, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	if u.Host == "" || container == "" || blob == "" {
+		return "", fmt.Errorf("invalid blob location %q, expected azblob://<account>/<container>/<blob>", location)
+	}
+	return (&url.URL{Scheme: "https", Host: u.Host + ".blob.core.windows.net", Path: "/" + container + "/" + blob}).String(), nil
+}
+
+// do sends a request for the blob at location and returns the response if
+// its status is one of the expected ones.
+func (s *AzureBlobStorage) do(ctx context.Context, method, location string, body []byte, expected ...int) (*http.Response, error) {
+	endpoint, err := blobURL(location)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.init(); err != nil {
+		return nil, err
+	}
+	req, err := runtime.NewRequest(ctx, method, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	req.Raw().Header.Set("x-ms-version", azureBlobAPIVersion)
+	if body != nil {
+		req.Raw().Header.Set("x-ms-blob-type", "BlockBlob")
+		if err := req.SetBody(streaming.NopCloser(bytes.NewReader(body)), "application/json"); err != nil {
+			return nil, err
+		}
+	} else {
+		runtime.SkipBodyDownload(req)
+	}
+	resp, err := s.pipeline.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if !runtime.HasStatusCode(resp, expected...) {
+		if resp.StatusCode == http.StatusNotFound {
+			resp.Body.Close()
+			return nil, fmt.Errorf("%s: %w", location, os.ErrNotExist)
+		}
+		return nil, runtime.NewResponseError(resp)
+	}
+	return resp, nil
+}
+
+// Open downloads the blob at location.
+func (s *AzureBlobStorage) Open(ctx context.Context, location string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, location, nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// WriteFile uploads data as the blob at location, replacing an existing one.
+func (s *AzureBlobStorage) WriteFile(ctx context.Context, location string, data []byte) error {
+	resp, err := s.do(ctx, http.MethodPut, location, data, http.StatusCreated)
+	if err != nil {
+		return fmt.Errorf("failed to upload backup file: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,145 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"github.com/google/uuid"
+)
+
+// RootDir is the directory, relative to the working directory, under which backups are stored.
+const RootDir = "backup"
+
+// DefaultFileName is the name of the backup file written when no name template is given.
+const DefaultFileName = "subscriptions.json"
+
+// NameData holds the values available to a backup file name template.
+type NameData struct {
+	ResourceGroup string
+	APIM          string
+	Product       string
+	API           string
+	Date          string // 2006-01-02
+	Time          string // 150405
+	Timestamp     string // 20060102T150405Z
+}
+
+// NewNameData returns the template values for a backup taken at t.
+func NewNameData(resourceGroup, serviceName, productID string, t time.Time) NameData {
+	t = t.UTC()
+	return NameData{
+		ResourceGroup: resourceGroup,
+		APIM:          serviceName,
+		Product:       productID,
+		Date:          t.Format("2006-01-02"),
+		Time:          t.Format("150405"),
+		Timestamp:     t.Format("20060102T150405Z"),
+	}
+}
+
+// RenderFileName executes a Go text/template such as "{{.APIM}}-{{.Date}}.json"
+// and returns the resulting file name. The result must be a plain file name
+// without directory components.
+func RenderFileName(nameTemplate string, data NameData) (string, error) {
+	tmpl, err := template.New("name").Parse(nameTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid name template: %w", err)
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to render name template: %w", err)
+	}
+
+	name := strings.TrimSpace(sb.String())
+	if name == "" || name == "." || name == ".." {
+		return "", fmt.Errorf("name template %q rendered an empty file name", nameTemplate)
+	}
+	if strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("name template %q rendered %q, which contains a path separator", nameTemplate, name)
+	}
+	return name, nil
+}
+
+// BackupDir builds the backup directory path: backup/<resourceGroup>/<serviceName>[/<productID>]
+func BackupDir(resourceGroup, serviceName, productID string) string {
+	dir := filepath.Join(RootDir, resourceGroup, serviceName)
+	if productID != "" {
+		dir = filepath.Join(dir, productID)
+	}
+	return dir
+}
+
+// apisDir is the directory of an instance that holds the backup directories
+// of API-scoped subscriptions. It cannot be used as a product ID.
+const apisDir = "apis"
+
+// APIBackupDir returns the backup directory of the subscriptions scoped to
+// an API: backup/<resourceGroup>/<serviceName>/apis/<apiID>
+func APIBackupDir(resourceGroup, serviceName, apiID string) string {
+	return filepath.Join(RootDir, resourceGroup, serviceName, apisDir, apiID)
+}
+
+// EnsureBackupDir creates the backup directory structure and returns the path.
+// It fails for the product ID "apis", whose directory holds the backups of
+// API-scoped subscriptions.
+func EnsureBackupDir(resourceGroup, serviceName, productID string) (string, error) {
+	if strings.EqualFold(productID, apisDir) {
+		return "", fmt.Errorf("the backup directory of product %q holds API-scoped backups, write the backup elsewhere with --output", productID)
+	}
+	return ensureDir(BackupDir(resourceGroup, serviceName, productID))
+}
+
+// EnsureAPIBackupDir is like EnsureBackupDir for the backup directory of the
+// subscriptions scoped to an API.
+func EnsureAPIBackupDir(resourceGroup, serviceName, apiID string) (string, error) {
+	return ensureDir(APIBackupDir(resourceGroup, serviceName, apiID))
+}
+
+func ensureDir(dir string) (string, error) {
+	if err := os.MkdirAll(dir, DirMode); err != nil {
+		return "", fmt.Errorf("failed to create backup directory %s: %w", dir, err)
+	}
+	return dir, nil
+}
+
+// sidNamespace is the UUID namespace used to derive sids for backup entries without a name.
+var sidNamespace = uuid.MustParse("3c1f7a52-9b7e-4d0e-8f0b-6a2f3e9d1c45")
+
+// GeneratedSID records a sid that was generated for a backup entry without a name.
+type GeneratedSID struct {
+	SID         string
+	DisplayName string
+}
+
+// GenerateMissingSIDs assigns a sid to every entry of subs whose name is empty.
+// The sid is a UUID derived from the entry's display name, scope and keys, so
+// restoring the same file twice yields the same sids instead of duplicates,
+// even if its entries were reordered. Only entries identical in these fields
+// are told apart by their order.
+func GenerateMissingSIDs(subs []azure.SubscriptionInfo) []GeneratedSID {
+	var generated []GeneratedSID
+	seen := make(map[string]int)
+	for i := range subs {
+		if subs[i].Name != "" {
+			continue
+		}
+		props := &subs[i].Properties
+		seed := strings.Join([]string{props.DisplayName, props.Scope, props.PrimaryKey, props.SecondaryKey}, "\x00")
+		n := seen[seed]
+		seen[seed]++
+		if n > 0 {
+			seed += "\x00" + strconv.Itoa(n)
+		}
+		sid := uuid.NewSHA1(sidNamespace, []byte(seed)).String()
+		subs[i].Name = sid
+		generated = append(generated, GeneratedSID{SID: sid, DisplayName: props.DisplayName})
+	}
+	return generated
+}
@@ -0,0 +1,46 @@
+package backup
+
+import (
+	"testing"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+)
+
+func unnamed(displayName, primaryKey string) azure.SubscriptionInfo {
+	return azure.SubscriptionInfo{Properties: azure.SubscriptionInfoProperties{
+		DisplayName: displayName,
+		Scope:       "/apis",
+		PrimaryKey:  primaryKey,
+	}}
+}
+
+func TestGenerateMissingSIDsIgnoresOrder(t *testing.T) {
+	a := []azure.SubscriptionInfo{unnamed("alpha", "k1"), unnamed("beta", "k2")}
+	b := []azure.SubscriptionInfo{unnamed("beta", "k2"), unnamed("alpha", "k1")}
+	GenerateMissingSIDs(a)
+	GenerateMissingSIDs(b)
+
+	if a[0].Name != b[1].Name || a[1].Name != b[0].Name {
+		t.Errorf("sids depend on the order of the entries: %s, %s and %s, %s", a[0].Name, a[1].Name, b[1].Name, b[0].Name)
+	}
+	if a[0].Name == a[1].Name {
+		t.Errorf("different entries got the same sid %s", a[0].Name)
+	}
+}
+
+func TestGenerateMissingSIDsTellsDuplicatesApart(t *testing.T) {
+	subs := []azure.SubscriptionInfo{unnamed("alpha", "k1"), unnamed("alpha", "k1")}
+	generated := GenerateMissingSIDs(subs)
+
+	if len(generated) != 2 {
+		t.Fatalf("generated %d sids, want 2", len(generated))
+	}
+	if subs[0].Name == subs[1].Name {
+		t.Errorf("identical entries got the same sid %s", subs[0].Name)
+	}
+	single := []azure.SubscriptionInfo{unnamed("alpha", "k1")}
+	GenerateMissingSIDs(single)
+	if single[0].Name != subs[0].Name {
+		t.Errorf("the first of identical entries got sid %s, want %s as on its own", subs[0].Name, single[0].Name)
+	}
+}
@@ -0,0 +1,131 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// checkpointDir is the directory under RootDir where restore checkpoints are kept.
+const checkpointDir = ".checkpoints"
+
+// Checkpoint records which subscriptions of a restore have already been applied
+// to the target instance, so an interrupted restore can be resumed.
+// It is safe for concurrent use.
+type Checkpoint struct {
+	path string
+	mu   sync.Mutex
+	done map[string]bool
+
+	Input         string    `json:"input"`
+	ResourceGroup string    `json:"resourceGroup"`
+	APIMName      string    `json:"apimName"`
+	Completed     []string  `json:"completed"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// CheckpointPath returns the checkpoint file for restores into the given instance.
+func CheckpointPath(resourceGroup, serviceName string) string {
+	return filepath.Join(RootDir, checkpointDir, resourceGroup, serviceName+".json")
+}
+
+// NewCheckpoint returns an empty checkpoint for restoring input into the given instance.
+func NewCheckpoint(path, input, resourceGroup, serviceName string) *Checkpoint {
+	return &Checkpoint{
+		path:          path,
+		done:          make(map[string]bool),
+		Input:         absPath(input),
+		ResourceGroup: resourceGroup,
+		APIMName:      serviceName,
+	}
+}
+
+// LoadCheckpoint reads the checkpoint at path. It returns nil if none exists.
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read checkpoint %s: %w", path, err)
+	}
+
+	cp := &Checkpoint{path: path, done: make(map[string]bool)}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %s: %w", path, err)
+	}
+	for _, sid := range cp.Completed {
+		cp.done[sid] = true
+	}
+	return cp, nil
+}
+
+// Path returns the file the checkpoint is saved to.
+func (c *Checkpoint) Path() string {
+	return c.path
+}
+
+// Matches reports whether the checkpoint was created for restoring input.
+func (c *Checkpoint) Matches(input string) bool {
+	return c.Input == absPath(input)
+}
+
+// Done reports whether sid was already restored.
+func (c *Checkpoint) Done(sid string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.done[sid]
+}
+
+// Len returns the number of restored subscriptions recorded in the checkpoint.
+func (c *Checkpoint) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.done)
+}
+
+// MarkDone records sid as restored and saves the checkpoint to disk.
+func (c *Checkpoint) MarkDone(sid string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.done[sid] = true
+	c.Completed = c.Completed[:0]
+	for s := range c.done {
+		c.Completed = append(c.Completed, s)
+	}
+	sort.Strings(c.Completed)
+	c.UpdatedAt = time.Now().UTC()
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+	// writeFile never leaves a truncated checkpoint behind.
+	if err := writeFile(c.path, data); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Remove deletes the checkpoint file once a restore has completed.
+func (c *Checkpoint) Remove() error {
+	if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove checkpoint %s: %w", c.path, err)
+	}
+	return nil
+}
+
+func absPath(path string) string {
+	if IsRemote(path) {
+		return path
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
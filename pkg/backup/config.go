@@ -0,0 +1,79 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// configDir is the directory under RootDir where backups of the configuration
+// of instances other than subscriptions, such as named values, are kept.
+const configDir = ".config"
+
+// ConfigDir returns the directory holding the configuration backups of the
+// given instance.
+func ConfigDir(resourceGroup, serviceName string) string {
+	return filepath.Join(RootDir, configDir, resourceGroup, serviceName)
+}
+
+// ConfigPath returns the default path of the configuration backup of the
+// given kind, such as "named-values", of an instance.
+func ConfigPath(resourceGroup, serviceName, kind string) string {
+	return filepath.Join(ConfigDir(resourceGroup, serviceName), kind+".json")
+}
+
+// ConfigFile is the document stored in a backup file of the configuration of
+// an instance other than its subscriptions. Kind tells the files of different
+// entities apart, so that a file of one kind is never restored as another.
+type ConfigFile[T any] struct {
+	SchemaVersion int      `json:"schemaVersion"`
+	Kind          string   `json:"kind"`
+	Metadata      Metadata `json:"metadata"`
+	Items         []T      `json:"items"`
+}
+
+// SaveConfig writes items as a configuration backup of the given kind to path,
+// which may also be the URL of a registered Storage. CreatedAt defaults to
+// now and Generator to the package variable.
+func SaveConfig[T any](path, kind string, meta Metadata, items []T) error {
+	if meta.CreatedAt.IsZero() {
+		meta.CreatedAt = time.Now().UTC()
+	}
+	if meta.Generator == "" {
+		meta.Generator = Generator
+	}
+	if items == nil {
+		items = []T{}
+	}
+	data, err := json.MarshalIndent(ConfigFile[T]{
+		SchemaVersion: SchemaVersion,
+		Kind:          kind,
+		Metadata:      meta,
+		Items:         items,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s to JSON: %w", kind, err)
+	}
+	return writeLocation(path, data)
+}
+
+// LoadConfig reads a configuration backup of the given kind from path, which
+// may also be the URL of a registered Storage.
+func LoadConfig[T any](path, kind string) (*ConfigFile[T], error) {
+	data, err := readLocation(path)
+	if err != nil {
+		return nil, err
+	}
+	var f ConfigFile[T]
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if err := checkSchemaVersion(f.SchemaVersion); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if f.Kind != kind {
+		return nil, fmt.Errorf("%s is a backup of %q, not of %s", path, f.Kind, kind)
+	}
+	return &f, nil
+}
@@ -0,0 +1,115 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+)
+
+// Encryption formats recognized by DetectEncryption.
+const (
+	EncryptionNone = ""
+	EncryptionAge  = "age"
+	EncryptionSOPS = "sops"
+)
+
+// DecryptOptions configures how encrypted backup files are decrypted.
+type DecryptOptions struct {
+	// AgeIdentity is the path to an age identity file used for age-encrypted backups.
+	AgeIdentity string
+}
+
+// DetectEncryption reports the encryption format of a backup file's contents.
+// SOPS files may use any SOPS key source, including Azure Key Vault.
+func DetectEncryption(data []byte) string {
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("age-encryption.org/")) ||
+		bytes.HasPrefix(trimmed, []byte("-----BEGIN AGE ENCRYPTED FILE-----")) {
+		return EncryptionAge
+	}
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		var doc map[string]json.RawMessage
+		if err := json.Unmarshal(trimmed, &doc); err == nil {
+			if _, ok := doc["sops"]; ok {
+				return EncryptionSOPS
+			}
+		}
+	}
+	return EncryptionNone
+}
+
+// Open loads the subscriptions stored in a backup file, locally or in a
+// registered Storage, transparently decrypting age and SOPS encrypted files.
+// Decryption uses the age and sops command-line tools.
+func Open(filePath string, opts *DecryptOptions) ([]azure.SubscriptionInfo, error) {
+	f, err := OpenFile(filePath, opts)
+	if err != nil {
+		return nil, err
+	}
+	return f.Subscriptions, nil
+}
+
+// OpenFile is like Open, but returns the whole document, including its metadata.
+func OpenFile(filePath string, opts *DecryptOptions) (*File, error) {
+	if opts == nil {
+		opts = &DecryptOptions{}
+	}
+
+	data, err := readLocation(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if IsRemote(filePath) && DetectEncryption(data) != EncryptionNone {
+		// The decryption tools read local files only.
+		tmp, err := os.CreateTemp("", "kura-*"+path.Ext(filePath))
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(tmp.Name())
+		_, err = tmp.Write(data)
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, err
+		}
+		filePath = tmp.Name()
+	}
+
+	switch DetectEncryption(data) {
+	case EncryptionAge:
+		if opts.AgeIdentity == "" {
+			return nil, fmt.Errorf("%s is age-encrypted, provide an identity file with --age-identity", filePath)
+		}
+		data, err = runDecrypt("age", "--decrypt", "--identity", opts.AgeIdentity, filePath)
+	case EncryptionSOPS:
+		data, err = runDecrypt("sops", "--decrypt", filePath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return Decode(data)
+}
+
+func runDecrypt(name string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return nil, fmt.Errorf("failed to decrypt with %s: %w", name, err)
+		}
+		return nil, fmt.Errorf("failed to decrypt with %s: %w: %s", name, err, msg)
+	}
+	return out, nil
+}
@@ -0,0 +1,32 @@
+// Package backup reads and writes kura backup files and the state kura keeps
+// next to them.
+//
+// A backup file is a JSON document with a schemaVersion, Metadata about where
+// the backup was taken and the azure.SubscriptionInfo of every subscription,
+// including its keys (see File). Save writes one and Load reads one; both
+// also read the legacy format, a bare JSON array of subscriptions, and
+// migrate it in memory. WriteSubscriptions and ReadFile are shorthands for
+// the subscriptions alone.
+// Open also reads files encrypted with age or SOPS, and Stream reads one
+// subscription at a time, for files too large to load at once. Filter selects
+// subscriptions by sid or display name.
+//
+// Load, ReadFile, Open, Stream and Save accept, besides local paths, URLs of
+// the Storage registered for their scheme, such as "azblob://" for
+// AzureBlobStorage. RegisterStorage adds further destinations.
+//
+// Backups are kept under RootDir, by resource group, instance and optionally
+// product (see BackupDir). ListSnapshots, LatestSnapshot and ResolveSnapshot
+// find the backup files in that tree.
+//
+// The remaining identifiers manage the state of kura commands in the
+// directories with a leading dot under RootDir: restore checkpoints and
+// rollback files, pre-delete backups, migration and promotion reports and
+// instance locks. Programs that share a backup directory with kura can use
+// them to interoperate with it, such as AcquireLock to keep kura from
+// changing an instance at the same time.
+//
+// The exported identifiers of this package follow the versioning of kura
+// releases: they are not removed or changed incompatibly within a major
+// version.
+package backup
@@ -0,0 +1,44 @@
+package backup
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+)
+
+// WriteAgeEncrypted writes subs to path encrypted to the given age
+// recipients, using the age command-line tool. The plaintext never touches
+// the disk. The file can be read by Open with a matching identity.
+func WriteAgeEncrypted(path string, subs []azure.SubscriptionInfo, recipients []string) error {
+	if len(recipients) == 0 {
+		return fmt.Errorf("no age recipients given")
+	}
+	data, err := Encode(&File{Subscriptions: subs})
+	if err != nil {
+		return err
+	}
+
+	var args []string
+	for _, r := range recipients {
+		args = append(args, "--recipient", r)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("age", args...)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("failed to encrypt with age: %w: %s", err, msg)
+		}
+		return fmt.Errorf("failed to encrypt with age: %w", err)
+	}
+
+	if err := writeFile(path, stdout.Bytes()); err != nil {
+		return fmt.Errorf("failed to write backup file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package backup
+
+import (
+	"regexp"
+	"slices"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+)
+
+// Filter selects subscriptions by sid (the subscription name) and display name.
+// A subscription is selected if it matches at least one include criterion (or
+// no include criteria are set) and matches none of the exclude criteria.
+type Filter struct {
+	SIDs        []string
+	Names       []string
+	SIDPattern  *regexp.Regexp
+	NamePattern *regexp.Regexp
+
+	ExcludeSIDs    []string
+	ExcludeNames   []string
+	ExcludePattern *regexp.Regexp // matched against both sid and display name
+}
+
+// IsEmpty reports whether the filter selects every subscription.
+func (f *Filter) IsEmpty() bool {
+	return !f.hasIncludes() && len(f.ExcludeSIDs) == 0 && len(f.ExcludeNames) == 0 && f.ExcludePattern == nil
+}
+
+// Match reports whether sub is selected by the filter.
+func (f *Filter) Match(sub azure.SubscriptionInfo) bool {
+	sid := sub.Name
+	name := sub.Properties.DisplayName
+
+	if slices.Contains(f.ExcludeSIDs, sid) || slices.Contains(f.ExcludeNames, name) {
+		return false
+	}
+	if f.ExcludePattern != nil && (f.ExcludePattern.MatchString(sid) || f.ExcludePattern.MatchString(name)) {
+		return false
+	}
+
+	if !f.hasIncludes() {
+		return true
+	}
+	return slices.Contains(f.SIDs, sid) ||
+		slices.Contains(f.Names, name) ||
+		(f.SIDPattern != nil && f.SIDPattern.MatchString(sid)) ||
+		(f.NamePattern != nil && f.NamePattern.MatchString(name))
+}
+
+// Apply returns the subscriptions selected by the filter.
+func (f *Filter) Apply(subs []azure.SubscriptionInfo) []azure.SubscriptionInfo {
+	if f.IsEmpty() {
+		return subs
+	}
+	var selected []azure.SubscriptionInfo
+	for _, sub := range subs {
+		if f.Match(sub) {
+			selected = append(selected, sub)
+		}
+	}
+	return selected
+}
+
+func (f *Filter) hasIncludes() bool {
+	return len(f.SIDs) > 0 || len(f.Names) > 0 || f.SIDPattern != nil || f.NamePattern != nil
+}
@@ -0,0 +1,85 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// KeyMapping records the keys a subscription had in a backup and the keys it
+// was given when it was restored with freshly generated keys.
+type KeyMapping struct {
+	SID             string `json:"sid"`
+	DisplayName     string `json:"displayName"`
+	OldPrimaryKey   string `json:"oldPrimaryKey"`
+	NewPrimaryKey   string `json:"newPrimaryKey"`
+	OldSecondaryKey string `json:"oldSecondaryKey"`
+	NewSecondaryKey string `json:"newSecondaryKey"`
+}
+
+// WriteKeyMap writes key mappings, ordered by sid, to a JSON file.
+func WriteKeyMap(path string, mappings []KeyMapping) error {
+	sorted := make([]KeyMapping, len(mappings))
+	copy(sorted, mappings)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].SID < sorted[j].SID })
+
+	data, err := json.MarshalIndent(sorted, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal key map: %w", err)
+	}
+	if err := writeFile(path, data); err != nil {
+		return fmt.Errorf("failed to write key map: %w", err)
+	}
+	return nil
+}
+
+// KeyRotation records a key regenerated by rotate. The old key is only kept as
+// a hash, so consumers can recognize it without the file leaking it.
+type KeyRotation struct {
+	SID         string
+	DisplayName string
+	Key         string // "primary" or "secondary"
+	OldKeyHash  string
+	NewKey      string
+}
+
+// HashKey returns the hex-encoded SHA-256 hash of key, or an empty string for
+// an empty key.
+func HashKey(key string) string {
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// WriteKeyRotations writes key rotations, ordered by sid and key, to a CSV file.
+func WriteKeyRotations(path string, rotations []KeyRotation) error {
+	sorted := make([]KeyRotation, len(rotations))
+	copy(sorted, rotations)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].SID != sorted[j].SID {
+			return sorted[i].SID < sorted[j].SID
+		}
+		return sorted[i].Key < sorted[j].Key
+	})
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"sid", "displayName", "key", "oldKeySha256", "newKey"})
+	for _, r := range sorted {
+		w.Write([]string{r.SID, r.DisplayName, r.Key, r.OldKeyHash, r.NewKey})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write export file: %w", err)
+	}
+	if err := writeFile(path, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write export file: %w", err)
+	}
+	return nil
+}
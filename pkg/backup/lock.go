@@ -0,0 +1,187 @@
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// lockDir is the directory under RootDir where instance locks are kept.
+const lockDir = ".locks"
+
+// LockDirEnv is the environment variable that overrides the directory of
+// instance locks, such as to put them on a volume shared by all operators.
+const LockDirEnv = "KURA_LOCK_DIR"
+
+// LockHolder describes the process holding an instance lock.
+type LockHolder struct {
+	Command string    `json:"command"`
+	User    string    `json:"user"`
+	Host    string    `json:"host"`
+	PID     int       `json:"pid"`
+	Since   time.Time `json:"since"`
+}
+
+// String describes the holder for error messages.
+func (h LockHolder) String() string {
+	if h == (LockHolder{}) {
+		return "an unknown kura run"
+	}
+	return fmt.Sprintf("kura %s run by %s on %s (pid %d) since %s", h.Command, h.User, h.Host, h.PID, h.Since.Format(time.RFC3339))
+}
+
+// LockedError is returned by AcquireLock when another run holds the lock.
+type LockedError struct {
+	Path   string
+	Holder LockHolder
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("instance is locked by %s (lock file %s)", e.Holder, e.Path)
+}
+
+// lockReadAttempts and lockReadDelay bound how long AcquireLock waits for a
+// lock file that another run has created but not yet written.
+const (
+	lockReadAttempts = 5
+	lockReadDelay    = 20 * time.Millisecond
+)
+
+// Lock is an instance lock held by this process.
+type Lock struct {
+	path string
+}
+
+// LockDir returns the directory of the instance locks: the one given by
+// LockDirEnv, or .locks under RootDir.
+func LockDir() string {
+	if dir := os.Getenv(LockDirEnv); dir != "" {
+		return dir
+	}
+	return filepath.Join(RootDir, lockDir)
+}
+
+// LockPath returns the lock file of the given instance. Names are lowercased,
+// as Azure resource names are case-insensitive.
+func LockPath(resourceGroup, serviceName string) string {
+	return filepath.Join(LockDir(), strings.ToLower(resourceGroup), strings.ToLower(serviceName)+".lock")
+}
+
+// AcquireLock takes the lock of the given instance for command, so that no
+// other kura run changes the instance at the same time. It returns a
+// *LockedError if another run holds the lock. A lock left behind by a process
+// on this host that no longer runs is taken over; an empty or unparsable lock
+// is considered held, as its owner may not have written it yet.
+func AcquireLock(resourceGroup, serviceName, command string) (*Lock, error) {
+	path := LockPath(resourceGroup, serviceName)
+	if err := os.MkdirAll(filepath.Dir(path), DirMode); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+
+	holder := LockHolder{Command: command, PID: os.Getpid(), Since: time.Now().UTC()}
+	holder.Host, _ = os.Hostname()
+	if u, err := user.Current(); err == nil {
+		holder.User = u.Username
+	}
+	data, err := json.MarshalIndent(holder, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	// Two attempts: the second one after removing a stale lock.
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, FileMode)
+		if err == nil {
+			_, werr := f.Write(data)
+			if cerr := f.Close(); werr == nil {
+				werr = cerr
+			}
+			if werr != nil {
+				os.Remove(path)
+				return nil, fmt.Errorf("failed to write lock file: %w", werr)
+			}
+			return &Lock{path: path}, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to create lock file: %w", err)
+		}
+
+		current, err := heldLock(path)
+		if err != nil {
+			return nil, err
+		}
+		if current == nil {
+			continue // released in the meantime
+		}
+		if attempt > 0 || !current.stale(holder.Host) {
+			return nil, &LockedError{Path: path, Holder: *current}
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove stale lock file: %w", err)
+		}
+	}
+	return nil, fmt.Errorf("failed to acquire lock %s", path)
+}
+
+// ReadLock returns the holder of the lock file at path, or nil if the
+// instance is not locked. An empty or unparsable lock file, which another run
+// may not have written yet, holds the lock for the zero LockHolder, an unknown
+// holder that is never stale.
+func ReadLock(path string) (*LockHolder, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read lock file: %w", err)
+	}
+	var h LockHolder
+	if err := json.Unmarshal(data, &h); err != nil {
+		return &LockHolder{}, nil
+	}
+	return &h, nil
+}
+
+// heldLock returns the holder of the existing lock file at path, or nil if it
+// was released. A lock of an unknown holder is read again for a short while,
+// as another run writes the file only after creating it.
+func heldLock(path string) (*LockHolder, error) {
+	for attempt := 1; ; attempt++ {
+		h, err := ReadLock(path)
+		if err != nil || h == nil || *h != (LockHolder{}) || attempt == lockReadAttempts {
+			return h, err
+		}
+		time.Sleep(lockReadDelay)
+	}
+}
+
+// stale reports whether the holder was a process on host that no longer
+// runs. Locks of other hosts are never considered stale, as kura cannot tell
+// whether their process still runs. On Windows, processes cannot be probed
+// this way, so no lock is considered stale.
+func (h LockHolder) stale(host string) bool {
+	if h.Host != host || runtime.GOOS == "windows" {
+		return false
+	}
+	p, err := os.FindProcess(h.PID)
+	if err != nil {
+		return true
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err != nil && !errors.Is(err, syscall.EPERM)
+}
+
+// Release removes the lock.
+func (l *Lock) Release() error {
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to release lock %s: %w", l.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func writeTestLock(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), DirMode); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, FileMode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAcquireLockHeld(t *testing.T) {
+	t.Setenv(LockDirEnv, t.TempDir())
+	lock, err := AcquireLock("RG", "APIM", "restore")
+	if err != nil {
+		t.Fatalf("AcquireLock: %v", err)
+	}
+	_, err = AcquireLock("rg", "apim", "delete")
+	var locked *LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("second AcquireLock = %v, want *LockedError", err)
+	}
+	if locked.Holder.Command != "restore" || locked.Holder.PID != os.Getpid() {
+		t.Errorf("holder = %+v, want the restore of this process", locked.Holder)
+	}
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	lock, err = AcquireLock("rg", "apim", "delete")
+	if err != nil {
+		t.Fatalf("AcquireLock after Release: %v", err)
+	}
+	lock.Release()
+}
+
+func TestAcquireLockUnreadableIsHeld(t *testing.T) {
+	t.Setenv(LockDirEnv, t.TempDir())
+	for _, data := range []string{"", `{"command":"rest`} {
+		path := LockPath("rg", "apim")
+		writeTestLock(t, path, []byte(data))
+		_, err := AcquireLock("rg", "apim", "restore")
+		var locked *LockedError
+		if !errors.As(err, &locked) {
+			t.Errorf("AcquireLock with lock file %q = %v, want *LockedError", data, err)
+		} else if locked.Holder != (LockHolder{}) {
+			t.Errorf("AcquireLock with lock file %q: holder = %+v, want unknown", data, locked.Holder)
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("lock file %q was removed: %v", data, err)
+		}
+	}
+}
+
+func TestAcquireLockStaleTakeover(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("locks are never stale on Windows")
+	}
+	t.Setenv(LockDirEnv, t.TempDir())
+	exited := exec.Command("true")
+	if err := exited.Run(); err != nil {
+		t.Skipf("cannot start a process: %v", err)
+	}
+	host, _ := os.Hostname()
+
+	for _, tc := range []struct {
+		name     string
+		holder   LockHolder
+		takeover bool
+	}{
+		{"exited process on this host", LockHolder{Command: "restore", Host: host, PID: exited.Process.Pid}, true},
+		{"running process on this host", LockHolder{Command: "restore", Host: host, PID: os.Getpid()}, false},
+		{"process on another host", LockHolder{Command: "restore", Host: host + "-other", PID: exited.Process.Pid}, false},
+	} {
+		tc.holder.Since = time.Now().UTC()
+		data, err := json.Marshal(tc.holder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeTestLock(t, LockPath("rg", "apim"), data)
+
+		lock, err := AcquireLock("rg", "apim", "delete")
+		if tc.takeover {
+			if err != nil {
+				t.Errorf("%s: AcquireLock = %v, want the lock taken over", tc.name, err)
+				continue
+			}
+			holder, err := ReadLock(LockPath("rg", "apim"))
+			if err != nil || holder == nil || holder.Command != "delete" {
+				t.Errorf("%s: lock holder after takeover = %+v, %v, want delete", tc.name, holder, err)
+			}
+			lock.Release()
+			continue
+		}
+		var locked *LockedError
+		if !errors.As(err, &locked) {
+			t.Errorf("%s: AcquireLock = %v, want *LockedError", tc.name, err)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// migrationDir is the directory under RootDir where migration reports are kept.
+const migrationDir = ".migrations"
+
+// Outcomes of a MigrationEntry.
+const (
+	MigrationMigrated = "migrated"
+	MigrationPlanned  = "planned"
+	MigrationSkipped  = "skipped"
+	MigrationFailed   = "failed"
+)
+
+// MigrationEndpoint identifies one side of a migration.
+type MigrationEndpoint struct {
+	TenantID       string `json:"tenantId,omitempty"`
+	SubscriptionID string `json:"subscriptionId"`
+	ResourceGroup  string `json:"resourceGroup"`
+	APIMName       string `json:"apimName"`
+}
+
+// MigrationEntry records what happened to a single subscription. It never
+// contains keys.
+type MigrationEntry struct {
+	SID         string `json:"sid"`
+	DisplayName string `json:"displayName"`
+	SourceScope string `json:"sourceScope"`
+	TargetScope string `json:"targetScope"`
+	SourceOwner string `json:"sourceOwner,omitempty"`
+	TargetOwner string `json:"targetOwner,omitempty"`
+	Overwrite   bool   `json:"overwrite,omitempty"`
+	Outcome     string `json:"outcome"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// MigrationReport is the result of a migration between two APIM instances.
+type MigrationReport struct {
+	Source     MigrationEndpoint `json:"source"`
+	Target     MigrationEndpoint `json:"target"`
+	DryRun     bool              `json:"dryRun"`
+	StartedAt  time.Time         `json:"startedAt"`
+	FinishedAt time.Time         `json:"finishedAt"`
+	Entries    []MigrationEntry  `json:"subscriptions"`
+}
+
+// NewMigrationReportPath returns a new, timestamped migration report path for
+// the given target instance.
+func NewMigrationReportPath(resourceGroup, serviceName string, t time.Time) string {
+	name := t.UTC().Format("20060102T150405.000000000Z") + ".json"
+	return filepath.Join(RootDir, migrationDir, resourceGroup, serviceName, name)
+}
+
+// Count returns the number of entries with the given outcome.
+func (r *MigrationReport) Count(outcome string) int {
+	var n int
+	for _, e := range r.Entries {
+		if e.Outcome == outcome {
+			n++
+		}
+	}
+	return n
+}
+
+// Save writes the report to path, creating parent directories as needed.
+func (r *MigrationReport) Save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal migration report: %w", err)
+	}
+	if err := writeFile(path, data); err != nil {
+		return fmt.Errorf("failed to write migration report: %w", err)
+	}
+	return nil
+}
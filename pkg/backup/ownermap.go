@@ -0,0 +1,56 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+	"gopkg.in/yaml.v3"
+)
+
+// DropOwner is the owner map value that removes the owner from a subscription.
+const DropOwner = "drop"
+
+// OwnerMap maps source user IDs to target user IDs for cross-environment restores.
+// Keys may be plain user IDs or full ownerId resource paths. A value of DropOwner
+// restores the subscription without an owner.
+type OwnerMap map[string]string
+
+// LoadOwnerMap reads an owner map from a YAML (or JSON) file of the form:
+//
+//	old-user-id: new-user-id
+//	another-user-id: drop
+func LoadOwnerMap(filePath string) (OwnerMap, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read owner map %s: %w", filePath, err)
+	}
+
+	var m OwnerMap
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse owner map %s: %w", filePath, err)
+	}
+	for from, to := range m {
+		if to == "" {
+			return nil, fmt.Errorf("owner map %s: empty target for %q (use %q to remove the owner)", filePath, from, DropOwner)
+		}
+	}
+	return m, nil
+}
+
+// Lookup returns the target user ID for ownerID. The full ownerId path is tried
+// first, then the plain user ID. ok is false if the owner is not in the map;
+// drop is true if the owner should be removed.
+func (m OwnerMap) Lookup(ownerID string) (userID string, drop bool, ok bool) {
+	to, found := m[ownerID]
+	if !found {
+		to, found = m[azure.OwnerUserID(ownerID)]
+	}
+	if !found {
+		return "", false, false
+	}
+	if to == DropOwner {
+		return "", true, true
+	}
+	return to, false, true
+}
@@ -0,0 +1,25 @@
+package backup
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+)
+
+// preDeleteDir is the directory under RootDir where backups taken right before
+// a delete are kept.
+const preDeleteDir = ".pre-delete"
+
+// NewPreDeletePath returns a new, timestamped pre-delete backup path for the given instance.
+func NewPreDeletePath(resourceGroup, serviceName string, t time.Time) string {
+	name := t.UTC().Format("20060102T150405.000000000Z") + ".json"
+	return filepath.Join(RootDir, preDeleteDir, resourceGroup, serviceName, name)
+}
+
+// WriteSubscriptions writes subs to path in the regular backup format, so the
+// file can be passed to restore --input. Parent directories are created as
+// needed. Use Save to record where the subscriptions came from as well.
+func WriteSubscriptions(path string, subs []azure.SubscriptionInfo) error {
+	return Save(path, &File{Subscriptions: subs})
+}
@@ -0,0 +1,52 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DropProduct is the product map value that leaves the subscriptions of a
+// product out.
+const DropProduct = "drop"
+
+// ProductMap maps source product IDs to target product IDs, for environments
+// whose products are named differently. A value of DropProduct leaves the
+// subscriptions of the product out.
+type ProductMap map[string]string
+
+// LoadProductMap reads a product map from a YAML (or JSON) file of the form:
+//
+//	starter: starter-staging
+//	internal-tools: drop
+func LoadProductMap(filePath string) (ProductMap, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read product map %s: %w", filePath, err)
+	}
+
+	var m ProductMap
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse product map %s: %w", filePath, err)
+	}
+	for from, to := range m {
+		if to == "" {
+			return nil, fmt.Errorf("product map %s: empty target for %q (use %q to leave the product out)", filePath, from, DropProduct)
+		}
+	}
+	return m, nil
+}
+
+// Lookup returns the target product ID for productID, which is productID
+// itself if it is not in the map. drop is true if the product is left out.
+func (m ProductMap) Lookup(productID string) (target string, drop bool) {
+	to, ok := m[productID]
+	if !ok {
+		return productID, false
+	}
+	if to == DropProduct {
+		return "", true
+	}
+	return to, false
+}
@@ -0,0 +1,77 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// promotionDir is the directory under RootDir where promotion reports are kept.
+const promotionDir = ".promotions"
+
+// Outcomes of a PromotionEntry.
+const (
+	PromotionPromoted  = "promoted"
+	PromotionPlanned   = "planned"
+	PromotionUnchanged = "unchanged"
+	PromotionSkipped   = "skipped"
+	PromotionFailed    = "failed"
+)
+
+// PromotionEntry records what happened to a single subscription. It never
+// contains keys.
+type PromotionEntry struct {
+	SID         string `json:"sid"`
+	DisplayName string `json:"displayName"`
+	SourceScope string `json:"sourceScope"`
+	TargetScope string `json:"targetScope,omitempty"`
+	// Action is create or update, or empty if nothing had to change.
+	Action  string   `json:"action,omitempty"`
+	Changes []string `json:"changes,omitempty"`
+	Outcome string   `json:"outcome"`
+	Reason  string   `json:"reason,omitempty"`
+}
+
+// PromotionReport is the result of a promotion between two profiles.
+type PromotionReport struct {
+	From       string            `json:"from"`
+	To         string            `json:"to"`
+	Source     MigrationEndpoint `json:"source"`
+	Target     MigrationEndpoint `json:"target"`
+	ProductMap string            `json:"productMap,omitempty"`
+	DryRun     bool              `json:"dryRun"`
+	StartedAt  time.Time         `json:"startedAt"`
+	FinishedAt time.Time         `json:"finishedAt"`
+	Entries    []PromotionEntry  `json:"subscriptions"`
+}
+
+// NewPromotionReportPath returns a new, timestamped promotion report path for
+// the given target instance.
+func NewPromotionReportPath(resourceGroup, serviceName string, t time.Time) string {
+	name := t.UTC().Format("20060102T150405.000000000Z") + ".json"
+	return filepath.Join(RootDir, promotionDir, resourceGroup, serviceName, name)
+}
+
+// Count returns the number of entries with the given outcome.
+func (r *PromotionReport) Count(outcome string) int {
+	var n int
+	for _, e := range r.Entries {
+		if e.Outcome == outcome {
+			n++
+		}
+	}
+	return n
+}
+
+// Save writes the report to path, creating parent directories as needed.
+func (r *PromotionReport) Save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal promotion report: %w", err)
+	}
+	if err := writeFile(path, data); err != nil {
+		return fmt.Errorf("failed to write promotion report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+)
+
+// rollbackDir is the directory under RootDir where pre-restore safety backups are kept.
+const rollbackDir = ".rollback"
+
+// Rollback is a safety backup of a target instance taken right before a restore.
+// It holds the subscriptions as they were before the restore and, once the
+// restore has finished, the sids the restore wrote.
+type Rollback struct {
+	ResourceGroup string                   `json:"resourceGroup"`
+	APIMName      string                   `json:"apimName"`
+	Input         string                   `json:"input"`
+	CreatedAt     time.Time                `json:"createdAt"`
+	Applied       []string                 `json:"applied"`
+	Subscriptions []azure.SubscriptionInfo `json:"subscriptions"`
+}
+
+// RollbackDir returns the directory holding safety backups of the given instance.
+func RollbackDir(resourceGroup, serviceName string) string {
+	return filepath.Join(RootDir, rollbackDir, resourceGroup, serviceName)
+}
+
+// NewRollbackPath returns a new, timestamped safety backup path for the given instance.
+func NewRollbackPath(resourceGroup, serviceName string, t time.Time) string {
+	name := t.UTC().Format("20060102T150405.000000000Z") + ".json"
+	return filepath.Join(RollbackDir(resourceGroup, serviceName), name)
+}
+
+// Save writes the rollback file to path, creating parent directories as needed.
+func (r *Rollback) Save(path string) error {
+	sort.Strings(r.Applied)
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal rollback file: %w", err)
+	}
+	if err := writeFile(path, data); err != nil {
+		return fmt.Errorf("failed to write rollback file: %w", err)
+	}
+	return nil
+}
+
+// LoadRollback reads a rollback file.
+func LoadRollback(path string) (*Rollback, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rollback file %s: %w", path, err)
+	}
+	var r Rollback
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse rollback file %s: %w", path, err)
+	}
+	return &r, nil
+}
+
+// LatestRollback returns the newest safety backup of the given instance, or an
+// empty string if there is none.
+func LatestRollback(resourceGroup, serviceName string) (string, error) {
+	entries, err := os.ReadDir(RollbackDir(resourceGroup, serviceName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read rollback directory: %w", err)
+	}
+
+	// File names are UTC timestamps, so the lexically greatest is the newest.
+	var latest string
+	for _, e := range entries {
+		if !e.IsDir() && filepath.Ext(e.Name()) == ".json" && e.Name() > latest {
+			latest = e.Name()
+		}
+	}
+	if latest == "" {
+		return "", nil
+	}
+	return filepath.Join(RollbackDir(resourceGroup, serviceName), latest), nil
+}
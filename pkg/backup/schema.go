@@ -0,0 +1,123 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+)
+
+// SchemaVersion is the version of the backup file format written by Save.
+// Version 0 is the legacy format, a bare JSON array of subscriptions.
+const SchemaVersion = 1
+
+// Generator is recorded in the metadata of every file written by Save, such
+// as "kura v1.4.0". It is set by the kura CLI.
+var Generator string
+
+// Metadata describes where and when a backup was taken. All fields but
+// CreatedAt are optional.
+type Metadata struct {
+	CreatedAt      time.Time `json:"createdAt"`
+	Generator      string    `json:"generator,omitempty"`
+	SubscriptionID string    `json:"subscriptionId,omitempty"`
+	ResourceGroup  string    `json:"resourceGroup,omitempty"`
+	APIMName       string    `json:"apimName,omitempty"`
+	ProductID      string    `json:"productId,omitempty"`
+	APIID          string    `json:"apiId,omitempty"`
+	// Anonymized marks files written by backup --anonymize, whose sids and
+	// keys are hashes and cannot be restored.
+	Anonymized bool `json:"anonymized,omitempty"`
+}
+
+// File is the document stored in a backup file.
+type File struct {
+	// SchemaVersion is the version of the file as read; Decode migrates
+	// older versions in memory, so the other fields always follow the
+	// current version.
+	SchemaVersion int                      `json:"schemaVersion"`
+	Metadata      Metadata                 `json:"metadata"`
+	Subscriptions []azure.SubscriptionInfo `json:"subscriptions"`
+}
+
+// Decode parses the contents of a plain (not encrypted) backup file of any
+// supported schema version. Legacy files without metadata are returned with
+// SchemaVersion 0 and empty Metadata.
+func Decode(data []byte) (*File, error) {
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		var subs []azure.SubscriptionInfo
+		if err := json.Unmarshal(trimmed, &subs); err != nil {
+			return nil, err
+		}
+		return &File{Subscriptions: subs}, nil
+	}
+
+	var f File
+	if err := json.Unmarshal(trimmed, &f); err != nil {
+		return nil, err
+	}
+	if err := checkSchemaVersion(f.SchemaVersion); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// checkSchemaVersion returns an error for a document that is not a backup
+// file of a supported version.
+func checkSchemaVersion(version int) error {
+	switch {
+	case version <= 0:
+		return fmt.Errorf("not a backup file: expected a JSON array or a document with a schemaVersion")
+	case version > SchemaVersion:
+		return fmt.Errorf("backup file has schema version %d, but this version of kura only reads up to %d; upgrade kura", version, SchemaVersion)
+	}
+	return nil
+}
+
+// Encode renders f in the current schema version. CreatedAt defaults to now
+// and Generator to the package variable.
+func Encode(f *File) ([]byte, error) {
+	out := *f
+	out.SchemaVersion = SchemaVersion
+	if out.Metadata.CreatedAt.IsZero() {
+		out.Metadata.CreatedAt = time.Now().UTC()
+	}
+	if out.Metadata.Generator == "" {
+		out.Metadata.Generator = Generator
+	}
+	if out.Subscriptions == nil {
+		out.Subscriptions = []azure.SubscriptionInfo{}
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal subscriptions to JSON: %w", err)
+	}
+	return data, nil
+}
+
+// Load reads a plain backup file of any supported schema version. path may
+// also be the URL of a registered Storage.
+func Load(path string) (*File, error) {
+	data, err := readLocation(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// Save writes f to path in the current schema version. path may also be the
+// URL of a registered Storage. Local parent directories are created as needed.
+func Save(path string, f *File) error {
+	data, err := Encode(f)
+	if err != nil {
+		return err
+	}
+	return writeLocation(path, data)
+}
@@ -0,0 +1,310 @@
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+)
+
+// ReadFile loads the subscriptions stored in a plain backup file of any
+// schema version, locally or in a registered Storage. Use Load for its
+// metadata as well.
+func ReadFile(filePath string) ([]azure.SubscriptionInfo, error) {
+	data, err := readLocation(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return f.Subscriptions, nil
+}
+
+// ContentHash returns a SHA-256 hash of subs that does not depend on the order
+// in which the subscriptions were returned by Azure.
+func ContentHash(subs []azure.SubscriptionInfo) (string, error) {
+	sorted := make([]azure.SubscriptionInfo, len(subs))
+	copy(sorted, subs)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Name != sorted[j].Name {
+			return sorted[i].Name < sorted[j].Name
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	data, err := json.Marshal(sorted)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal subscriptions: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// LatestSnapshot returns the most recently taken backup file in dir, ordered
+// like ListSnapshots by Snapshot.Time. It returns an empty string if dir does
+// not exist or contains no backup files.
+func LatestSnapshot(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read backup directory %s: %w", dir, err)
+	}
+
+	var latest Snapshot
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return "", fmt.Errorf("failed to stat %s: %w", e.Name(), err)
+		}
+		snap := Snapshot{Path: filepath.Join(dir, e.Name()), ModTime: info.ModTime()}
+		if f, err := Load(snap.Path); err == nil {
+			snap.CreatedAt = f.Metadata.CreatedAt
+		}
+		if latest.Path == "" || snap.Time().After(latest.Time()) {
+			latest = snap
+		}
+	}
+	return latest.Path, nil
+}
+
+// Unchanged reports whether the backup file at previous holds the same
+// subscriptions as subs. A missing or unreadable previous file counts as changed.
+// Anonymized subscriptions never match, as every run hashes with a new salt.
+func Unchanged(previous string, subs []azure.SubscriptionInfo) bool {
+	if previous == "" {
+		return false
+	}
+	prevSubs, err := ReadFile(previous)
+	if err != nil {
+		return false
+	}
+	prevHash, err := ContentHash(prevSubs)
+	if err != nil {
+		return false
+	}
+	hash, err := ContentHash(subs)
+	if err != nil {
+		return false
+	}
+	return prevHash == hash
+}
+
+// Snapshot describes a backup file found under the backup root.
+type Snapshot struct {
+	Path          string
+	ResourceGroup string
+	APIM          string
+	Product       string
+	// API is the API of the subscriptions of API-scoped backups.
+	API string
+	// CreatedAt is the creation time in the metadata of the file, zero for
+	// files without metadata, such as legacy or encrypted ones.
+	CreatedAt time.Time
+	ModTime   time.Time
+	Size      int64
+	// Count is the number of subscriptions in the file, or -1 if it could not be read.
+	Count int
+}
+
+// Time returns when the snapshot was taken: CreatedAt, or ModTime for files
+// without metadata. Unlike the modification time, the creation time survives
+// copying or syncing the backup directory.
+func (s Snapshot) Time() time.Time {
+	if !s.CreatedAt.IsZero() {
+		return s.CreatedAt
+	}
+	return s.ModTime
+}
+
+// ListSnapshots scans root for backup files laid out as
+// <resource-group>/<apim-name>[/<product-id>|/apis/<api-id>]/<file>.json and
+// returns them ordered by instance, product, API and the time they were taken
+// (see Snapshot.Time).
+func ListSnapshots(root string) ([]Snapshot, error) {
+	var snapshots []Snapshot
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Directories with a leading dot hold the state of kura commands,
+		// such as restore checkpoints, rollback files, locks and the audit
+		// journal, not snapshots. Every such directory under RootDir starts
+		// with a dot so that it is skipped here and kept by kura clean
+		// unless --all is given.
+		if d.IsDir() && path != root && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".json") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		isAPI := len(parts) == 5 && parts[2] == apisDir
+		if len(parts) != 3 && len(parts) != 4 && !isAPI {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		snap := Snapshot{
+			Path:          path,
+			ResourceGroup: parts[0],
+			APIM:          parts[1],
+			ModTime:       info.ModTime(),
+			Size:          info.Size(),
+			Count:         -1,
+		}
+		switch {
+		case isAPI:
+			snap.API = parts[3]
+		case len(parts) == 4:
+			snap.Product = parts[2]
+		}
+		if f, err := Load(path); err == nil {
+			snap.Count = len(f.Subscriptions)
+			snap.CreatedAt = f.Metadata.CreatedAt
+		}
+
+		snapshots = append(snapshots, snap)
+		return nil
+	})
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to scan backup directory %s: %w", root, err)
+	}
+
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		a, b := snapshots[i], snapshots[j]
+		if a.ResourceGroup != b.ResourceGroup {
+			return a.ResourceGroup < b.ResourceGroup
+		}
+		if a.APIM != b.APIM {
+			return a.APIM < b.APIM
+		}
+		if a.Product != b.Product {
+			return a.Product < b.Product
+		}
+		if a.API != b.API {
+			return a.API < b.API
+		}
+		return a.Time().Before(b.Time())
+	})
+	return snapshots, nil
+}
+
+// snapshotTimeLayouts are the formats accepted by ParseSnapshotTime, most specific first.
+var snapshotTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// ParseSnapshotTime parses a point in time given as an RFC 3339 timestamp, a
+// timestamp without seconds or zone (UTC is assumed), or a plain date. A plain
+// date refers to the end of that day, so it selects the last snapshot taken on it.
+func ParseSnapshotTime(value string) (time.Time, error) {
+	for _, layout := range snapshotTimeLayouts {
+		t, err := time.Parse(layout, value)
+		if err != nil {
+			continue
+		}
+		if layout == "2006-01-02" {
+			t = t.Add(24*time.Hour - time.Nanosecond)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: expected a date (2006-01-02) or timestamp (2006-01-02T15:04Z)", value)
+}
+
+// ResolveSnapshot selects a snapshot by reference. ref is either "latest",
+// "earliest", or a point in time accepted by ParseSnapshotTime, in which case
+// the newest snapshot taken at or before that time is returned. snapshots must
+// be ordered by the time they were taken, as returned by ListSnapshots.
+func ResolveSnapshot(snapshots []Snapshot, ref string) (Snapshot, error) {
+	if len(snapshots) == 0 {
+		return Snapshot{}, fmt.Errorf("no snapshots found")
+	}
+
+	switch ref {
+	case "", "latest":
+		return snapshots[len(snapshots)-1], nil
+	case "earliest":
+		return snapshots[0], nil
+	}
+
+	at, err := ParseSnapshotTime(ref)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if !snapshots[i].Time().After(at) {
+			return snapshots[i], nil
+		}
+	}
+	return Snapshot{}, fmt.Errorf("no snapshot found at or before %s (earliest is %s)",
+		at.UTC().Format(time.RFC3339), snapshots[0].Time().UTC().Format(time.RFC3339))
+}
+
+// FilterSnapshots returns the snapshots of a single APIM instance, or of one of
+// its products when productID is non-empty. An empty resourceGroup matches any
+// resource group, but an error is returned if that makes the instance ambiguous.
+// API-scoped snapshots are left out, see FilterAPISnapshots.
+func FilterSnapshots(snapshots []Snapshot, resourceGroup, serviceName, productID string) ([]Snapshot, error) {
+	return filterSnapshots(snapshots, resourceGroup, serviceName, func(snap Snapshot) bool {
+		return snap.Product == productID && snap.API == ""
+	})
+}
+
+// FilterAPISnapshots is like FilterSnapshots for the snapshots of the
+// subscriptions of an instance scoped to the API with the given ID.
+func FilterAPISnapshots(snapshots []Snapshot, resourceGroup, serviceName, apiID string) ([]Snapshot, error) {
+	return filterSnapshots(snapshots, resourceGroup, serviceName, func(snap Snapshot) bool {
+		return snap.API == apiID
+	})
+}
+
+func filterSnapshots(snapshots []Snapshot, resourceGroup, serviceName string, scope func(Snapshot) bool) ([]Snapshot, error) {
+	var filtered []Snapshot
+	groups := make(map[string]bool)
+	for _, snap := range snapshots {
+		if snap.APIM != serviceName || !scope(snap) {
+			continue
+		}
+		if resourceGroup != "" && snap.ResourceGroup != resourceGroup {
+			continue
+		}
+		groups[snap.ResourceGroup] = true
+		filtered = append(filtered, snap)
+	}
+	if len(groups) > 1 {
+		return nil, fmt.Errorf("APIM instance %s has snapshots in several resource groups, specify one with --resource-group", serviceName)
+	}
+	return filtered, nil
+}
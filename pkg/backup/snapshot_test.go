@@ -0,0 +1,132 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeSnapshot writes an empty backup file at the slash-separated path rel
+// under root.
+func writeSnapshot(t *testing.T, root, rel string) {
+	t.Helper()
+	path := filepath.Join(root, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), DirMode); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(`{"schemaVersion":1,"subscriptions":[]}`), FileMode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListSnapshotsAPIScoped(t *testing.T) {
+	root := t.TempDir()
+	writeSnapshot(t, root, "rg/apim/subscriptions.json")
+	writeSnapshot(t, root, "rg/apim/starter/subscriptions.json")
+	writeSnapshot(t, root, "rg/apim/apis/petstore/subscriptions.json")
+
+	snapshots, err := ListSnapshots(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 3 {
+		t.Fatalf("ListSnapshots found %d snapshots, want 3: %+v", len(snapshots), snapshots)
+	}
+
+	api, err := FilterAPISnapshots(snapshots, "rg", "apim", "petstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(api) != 1 {
+		t.Fatalf("FilterAPISnapshots found %d snapshots, want 1: %+v", len(api), api)
+	}
+	if got := api[0]; got.API != "petstore" || got.Product != "" || got.Count != 0 {
+		t.Errorf("API-scoped snapshot = %+v, want API petstore without product and with 0 subscriptions", got)
+	}
+
+	// The API-scoped snapshot belongs to neither the instance nor a product.
+	for _, product := range []string{"", "apis", "starter"} {
+		snaps, err := FilterSnapshots(snapshots, "rg", "apim", product)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, snap := range snaps {
+			if snap.API != "" {
+				t.Errorf("FilterSnapshots(%q) returned the API-scoped snapshot %s", product, snap.Path)
+			}
+		}
+	}
+}
+
+func TestEnsureBackupDirRejectsAPIsProduct(t *testing.T) {
+	if _, err := EnsureBackupDir("rg", "apim", "APIs"); err == nil {
+		t.Error("EnsureBackupDir accepted the product ID APIs, which collides with the API-scoped backups")
+	}
+}
+
+func TestListSnapshotsOrdersByCreatedAt(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "rg", "apim")
+	if err := os.MkdirAll(dir, DirMode); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"old.json":    `{"schemaVersion":1,"metadata":{"createdAt":"2026-01-01T00:00:00Z"},"subscriptions":[]}`,
+		"new.json":    `{"schemaVersion":1,"metadata":{"createdAt":"2026-03-01T00:00:00Z"},"subscriptions":[]}`,
+		"legacy.json": `[]`,
+	}
+	// The modification times contradict the creation times, as after copying
+	// the directory.
+	mtimes := map[string]time.Time{
+		"old.json":    time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC),
+		"new.json":    time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC),
+		"legacy.json": time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), FileMode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtimes[name], mtimes[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snapshots, err := ListSnapshots(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	for _, snap := range snapshots {
+		order = append(order, filepath.Base(snap.Path))
+	}
+	if got, want := strings.Join(order, ","), "old.json,legacy.json,new.json"; got != want {
+		t.Errorf("ListSnapshots ordered %s, want %s", got, want)
+	}
+
+	for ref, want := range map[string]string{
+		"latest":     "new.json",
+		"earliest":   "old.json",
+		"2026-02-15": "legacy.json",
+		"2026-01-31": "old.json",
+	} {
+		snap, err := ResolveSnapshot(snapshots, ref)
+		if err != nil {
+			t.Errorf("ResolveSnapshot(%q): %v", ref, err)
+			continue
+		}
+		if got := filepath.Base(snap.Path); got != want {
+			t.Errorf("ResolveSnapshot(%q) = %s, want %s", ref, got, want)
+		}
+	}
+
+	latest, err := LatestSnapshot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := filepath.Base(latest); got != "new.json" {
+		t.Errorf("LatestSnapshot = %s, want new.json", got)
+	}
+}
@@ -0,0 +1,197 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Storage reads and writes backup files at the locations of one URL scheme,
+// such as objects in Azure Blob Storage for "azblob://". Load, ReadFile,
+// Open, Stream and Save select the Storage by the scheme of their path, so a
+// new destination only has to implement Storage and be registered with
+// RegisterStorage to be usable by every command.
+type Storage interface {
+	// Open opens the backup file at location for reading. It returns an
+	// error matching os.ErrNotExist if there is none.
+	Open(ctx context.Context, location string) (io.ReadCloser, error)
+	// WriteFile creates or replaces the backup file at location.
+	WriteFile(ctx context.Context, location string, data []byte) error
+}
+
+// Permissions of local backup files and of the directories kura creates for
+// them. Backup files hold subscription keys and secrets, so only their owner
+// may read them.
+const (
+	FileMode os.FileMode = 0600
+	DirMode  os.FileMode = 0700
+)
+
+var (
+	storagesMu sync.RWMutex
+	storages   = map[string]Storage{}
+)
+
+// RegisterStorage makes locations with the URL scheme scheme, such as
+// "s3" for "s3://bucket/key", use s. It replaces a Storage registered
+// before for the scheme.
+func RegisterStorage(scheme string, s Storage) {
+	storagesMu.Lock()
+	defer storagesMu.Unlock()
+	storages[strings.ToLower(scheme)] = s
+}
+
+// StorageFor returns the Storage of location: the one registered for its URL
+// scheme, or LocalStorage for plain paths and file:// URLs.
+func StorageFor(location string) (Storage, error) {
+	scheme := locationScheme(location)
+	if scheme == "" || scheme == "file" {
+		return LocalStorage{}, nil
+	}
+	storagesMu.RLock()
+	defer storagesMu.RUnlock()
+	s, ok := storages[scheme]
+	if !ok {
+		schemes := make([]string, 0, len(storages))
+		for name := range storages {
+			schemes = append(schemes, name+"://")
+		}
+		sort.Strings(schemes)
+		return nil, fmt.Errorf("unsupported storage %s://, supported are local paths and %s", scheme, strings.Join(schemes, ", "))
+	}
+	return s, nil
+}
+
+// IsRemote reports whether location is a URL of a registered or unknown
+// storage rather than a local path.
+func IsRemote(location string) bool {
+	scheme := locationScheme(location)
+	return scheme != "" && scheme != "file"
+}
+
+// locationScheme returns the lower-case URL scheme of location, or "" for a
+// plain path. Windows drive letters such as "C:" are not schemes.
+func locationScheme(location string) string {
+	i := strings.Index(location, "://")
+	if i < 2 {
+		return ""
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Scheme)
+}
+
+// readLocation reads the backup file at location from its Storage.
+func readLocation(location string) ([]byte, error) {
+	s, err := StorageFor(location)
+	if err != nil {
+		return nil, err
+	}
+	r, err := s.Open(context.Background(), location)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// writeLocation writes the backup file at location to its Storage.
+func writeLocation(location string, data []byte) error {
+	s, err := StorageFor(location)
+	if err != nil {
+		return err
+	}
+	return s.WriteFile(context.Background(), location, data)
+}
+
+// LocalStorage stores backup files in the local file system. Its locations
+// are paths or file:// URLs.
+type LocalStorage struct{}
+
+// Open opens the file at location.
+func (LocalStorage) Open(_ context.Context, location string) (io.ReadCloser, error) {
+	return os.Open(localPath(location))
+}
+
+// WriteFile writes the file at location with FileMode, creating its parent
+// directories with DirMode as needed. An existing file is replaced, so it
+// gets FileMode as well.
+func (LocalStorage) WriteFile(_ context.Context, location string, data []byte) error {
+	if err := writeFile(localPath(location), data); err != nil {
+		return fmt.Errorf("failed to write backup file: %w", err)
+	}
+	return nil
+}
+
+// writeFile writes data to the local file at path with FileMode, creating its
+// parent directories with DirMode as needed. The data is written to a
+// temporary file that is renamed into place, so a replaced file never keeps
+// its old permissions and an interrupted write never leaves it truncated.
+func writeFile(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, DirMode); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, FileMode)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// CheckPermissions returns an error if the local backup file at location can
+// be read by its group or by other users, which exposes the keys it holds.
+// Remote locations and Windows, where the permission bits do not apply, are
+// not checked.
+func CheckPermissions(location string) error {
+	if IsRemote(location) || runtime.GOOS == "windows" {
+		return nil
+	}
+	path := localPath(location)
+	info, err := os.Stat(path)
+	if err != nil {
+		// Reading the file reports the error.
+		return nil
+	}
+	if mode := info.Mode().Perm(); mode&0077 != 0 {
+		return fmt.Errorf("%s holds keys but is readable by its group or other users (mode %04o); restrict it with: chmod 600 %s", path, mode, path)
+	}
+	return nil
+}
+
+// localPath returns the path of a local location, which is either a path or
+// a file:// URL.
+func localPath(location string) string {
+	if locationScheme(location) != "file" {
+		return location
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		return location
+	}
+	return filepath.FromSlash(u.Path)
+}
@@ -0,0 +1,41 @@
+package backup
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+func TestWindowsPathsAreLocal(t *testing.T) {
+	for _, location := range []string{`C:\x\y.json`, `C:/x/y.json`, "backup/rg/apim/subscriptions.json"} {
+		if IsRemote(location) {
+			t.Errorf("IsRemote(%q) = true, want false", location)
+		}
+		s, err := StorageFor(location)
+		if err != nil {
+			t.Fatalf("StorageFor(%q): %v", location, err)
+		}
+		if _, ok := s.(LocalStorage); !ok {
+			t.Errorf("StorageFor(%q) = %T, want LocalStorage", location, s)
+		}
+	}
+}
+
+func TestLocalStorageRoundTrip(t *testing.T) {
+	data := []byte(`{"schemaVersion":1}`)
+	for _, location := range []string{
+		filepath.Join(t.TempDir(), "x", "y.json"),
+		"file://" + filepath.ToSlash(filepath.Join(t.TempDir(), "x", "y.json")),
+	} {
+		if err := writeLocation(location, data); err != nil {
+			t.Fatalf("writeLocation(%q): %v", location, err)
+		}
+		got, err := readLocation(location)
+		if err != nil {
+			t.Fatalf("readLocation(%q): %v", location, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("readLocation(%q) = %s, want %s", location, got, data)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package backup
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/f-marschall/apim-kura/pkg/azure"
+)
+
+// streamPeekSize is how much of a file Stream inspects to detect encryption.
+const streamPeekSize = 512
+
+// Stream decodes the subscriptions of a plain JSON backup file of any schema
+// version one at a time and calls fn for each of them, so that the file never
+// has to be held in memory as a whole. Encrypted files cannot be streamed; use
+// Open for them.
+func Stream(filePath string, fn func(sub *azure.SubscriptionInfo) error) error {
+	storage, err := StorageFor(filePath)
+	if err != nil {
+		return err
+	}
+	f, err := storage.Open(context.Background(), filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	head, _ := r.Peek(streamPeekSize)
+	if enc := DetectEncryption(head); enc != EncryptionNone {
+		return fmt.Errorf("%s is %s-encrypted and cannot be streamed", filePath, enc)
+	}
+
+	// Errors of fn are returned as they are, not as parse errors.
+	var fnErr error
+	each := func(sub *azure.SubscriptionInfo) error {
+		fnErr = fn(sub)
+		return fnErr
+	}
+
+	dec := json.NewDecoder(r)
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", filePath, err)
+	}
+	switch tok {
+	case json.Delim('['):
+		// Legacy format: a bare array of subscriptions.
+		err = streamArray(dec, each)
+	case json.Delim('{'):
+		err = streamDocument(dec, each)
+	default:
+		err = fmt.Errorf("expected a JSON array or object")
+	}
+	if fnErr != nil {
+		return fnErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// streamDocument streams the subscriptions of a versioned backup document
+// whose opening brace was already read.
+func streamDocument(dec *json.Decoder, fn func(sub *azure.SubscriptionInfo) error) error {
+	version := 0
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case "schemaVersion":
+			if err := dec.Decode(&version); err != nil {
+				return err
+			}
+			if err := checkSchemaVersion(version); err != nil {
+				return err
+			}
+		case "subscriptions":
+			if version == 0 {
+				return fmt.Errorf("schemaVersion must precede the subscriptions")
+			}
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			if tok != json.Delim('[') {
+				return fmt.Errorf("subscriptions must be a JSON array")
+			}
+			if err := streamArray(dec, fn); err != nil {
+				return err
+			}
+		case "sops":
+			// SOPS files are JSON objects, which DetectEncryption cannot
+			// always recognize from the first bytes alone.
+			return fmt.Errorf("the file is sops-encrypted and cannot be streamed")
+		default:
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return err
+			}
+		}
+	}
+	if version == 0 {
+		return checkSchemaVersion(version)
+	}
+	_, err := dec.Token()
+	return err
+}
+
+// streamArray calls fn for each element of an array whose opening bracket
+// was already read, and reads the closing bracket.
+func streamArray(dec *json.Decoder, fn func(sub *azure.SubscriptionInfo) error) error {
+	for dec.More() {
+		var sub azure.SubscriptionInfo
+		if err := dec.Decode(&sub); err != nil {
+			return err
+		}
+		if err := fn(&sub); err != nil {
+			return err
+		}
+	}
+	_, err := dec.Token()
+	return err
+}